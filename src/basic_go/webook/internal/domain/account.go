@@ -0,0 +1,95 @@
+package domain
+
+import "time"
+
+type AccountType uint8
+
+const (
+	AccountTypeUnknown AccountType = iota
+	// AccountTypeUser 用户的账户，比如作者收到的打赏。余额不能是负数
+	AccountTypeUser
+	// AccountTypePlatform 平台的账户，Uid 固定是 0。
+	// 用户通过第三方付进来的钱记在平台账户的借方，所以它的余额可以是负数
+	AccountTypePlatform
+)
+
+func (t AccountType) String() string {
+	switch t {
+	case AccountTypeUser:
+		return "user"
+	case AccountTypePlatform:
+		return "platform"
+	default:
+		return "unknown"
+	}
+}
+
+// PlatformAccount 平台只有一个账户
+var PlatformAccount = AccountKey{Type: AccountTypePlatform}
+
+// AccountKey 用 Uid + Type 确定一个账户
+type AccountKey struct {
+	Uid  int64
+	Type AccountType
+}
+
+func UserAccount(uid int64) AccountKey {
+	return AccountKey{Uid: uid, Type: AccountTypeUser}
+}
+
+// Posting 一笔记账。钱从 Debit 账户转到 Credit 账户，两边一起记，要么都成功要么都失败。
+// 同一个 Biz + BizId 只会记一次账
+type Posting struct {
+	Biz    string
+	BizId  int64
+	Debit  AccountKey
+	Credit AccountKey
+	// 单位是分，必须大于 0
+	Amount int64
+	Memo   string
+}
+
+type EntryDirection uint8
+
+const (
+	EntryDirectionUnknown EntryDirection = iota
+	// EntryDirectionDebit 借方，账户余额减少
+	EntryDirectionDebit
+	// EntryDirectionCredit 贷方，账户余额增加
+	EntryDirectionCredit
+)
+
+func (d EntryDirection) String() string {
+	switch d {
+	case EntryDirectionDebit:
+		return "debit"
+	case EntryDirectionCredit:
+		return "credit"
+	default:
+		return "unknown"
+	}
+}
+
+// AccountEntry 账户的一条流水，记了就不能改
+type AccountEntry struct {
+	Id        int64
+	Account   AccountKey
+	Biz       string
+	BizId     int64
+	Direction EntryDirection
+	// 单位是分，总是正数，增减看 Direction
+	Amount int64
+	// 记完这一笔之后的余额
+	Balance int64
+	Memo    string
+	Ctime   time.Time
+}
+
+// StatementQuery 查账户流水。Start 和 End 是零值的时候不限制，
+// 分页用 MaxId，拿上一页最后一条的 ID，第一页传 0
+type StatementQuery struct {
+	Start time.Time
+	End   time.Time
+	MaxId int64
+	Limit int
+}
@@ -0,0 +1,40 @@
+package domain
+
+import "time"
+
+// ArticlePolicy 注销之后文章怎么处理，申请的时候用户自己选
+type ArticlePolicy uint8
+
+const (
+	ArticlePolicyUnknown ArticlePolicy = iota
+	// ArticlePolicyUnpublish 全部撤回，变成仅自己可见，注销之后就没人看得到了
+	ArticlePolicyUnpublish
+	// ArticlePolicyReattribute 文章保留，作者换成配置好的匿名账号
+	ArticlePolicyReattribute
+)
+
+type AccountDeletionStatus uint8
+
+const (
+	AccountDeletionStatusUnknown AccountDeletionStatus = iota
+	// AccountDeletionStatusPending 冷静期，这期间可以取消
+	AccountDeletionStatusPending
+	// AccountDeletionStatusCancelled 用户自己取消了
+	AccountDeletionStatusCancelled
+	// AccountDeletionStatusProcessing 冷静期过了，正在处理，不能取消了
+	AccountDeletionStatusProcessing
+	// AccountDeletionStatusDone 已经注销了，个人信息都抹掉了
+	AccountDeletionStatusDone
+)
+
+// AccountDeletion 用户自己申请的注销，一个用户只有一条，取消之后再申请会覆盖
+type AccountDeletion struct {
+	Id            int64
+	Uid           int64
+	ArticlePolicy ArticlePolicy
+	Status        AccountDeletionStatus
+	// 冷静期结束的时间，过了这个时间才会真的注销
+	ScheduledAt time.Time
+	Ctime       time.Time
+	Utime       time.Time
+}
@@ -0,0 +1,44 @@
+package domain
+
+import "time"
+
+type Article struct {
+	Id      int64
+	Title   string
+	Content string
+	Author  Author
+	Status  ArticleStatus
+	Ctime   time.Time
+	Utime   time.Time
+}
+
+// Abstract 列表页展示的摘要
+func (a Article) Abstract() string {
+	cs := []rune(a.Content)
+	if len(cs) < 100 {
+		return a.Content
+	}
+	return string(cs[:100])
+}
+
+type Author struct {
+	Id   int64
+	Name string
+}
+
+type ArticleStatus uint8
+
+// 和前端约定好的值，不要随便改
+const (
+	ArticleStatusUnknown ArticleStatus = iota
+	// ArticleStatusUnpublished 未发表
+	ArticleStatusUnpublished
+	// ArticleStatusPublished 已发表
+	ArticleStatusPublished
+	// ArticleStatusPrivate 仅自己可见，也就是撤回了
+	ArticleStatusPrivate
+)
+
+func (s ArticleStatus) ToUint8() uint8 {
+	return uint8(s)
+}
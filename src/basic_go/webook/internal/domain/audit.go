@@ -0,0 +1,37 @@
+package domain
+
+import "time"
+
+// 审计日志的操作对象和操作类型
+const (
+	AuditTargetUser = "user"
+
+	AuditActionUserDisable       = "user_disable"
+	AuditActionUserBan           = "user_ban"
+	AuditActionUserEnable        = "user_enable"
+	AuditActionUserResetPassword = "user_reset_password"
+	AuditActionUserUnlock        = "user_unlock"
+)
+
+// AuditLog 管理员的一次操作，记下来之后不能改
+type AuditLog struct {
+	Id         int64
+	OperatorId int64
+	TargetType string
+	TargetId   int64
+	Action     string
+	Reason     string
+	// 操作的细节，比如改之前和改之后的状态
+	Detail map[string]any
+	Ip     string
+	Ctime  time.Time
+}
+
+// AuditLogQuery OperatorId 和 TargetId 是 0 的时候不限制
+type AuditLogQuery struct {
+	OperatorId int64
+	TargetType string
+	TargetId   int64
+	MaxId      int64
+	Limit      int
+}
@@ -0,0 +1,24 @@
+package domain
+
+import "time"
+
+// Comment 评论或者回复，用 Biz + BizId 标识评论的是哪个资源
+type Comment struct {
+	Id    int64
+	Uid   int64
+	Biz   string
+	BizId int64
+	// 根评论是 0
+	RootId int64
+	// 回复的是哪一条，根评论是 0
+	ParentId int64
+	Content  string
+
+	// 只有列表里面的根评论才有，预先加载的前几条回复
+	Replies []Comment
+	// 只有列表里面的根评论才有，一共有多少条回复
+	ReplyCnt int64
+
+	Ctime time.Time
+	Utime time.Time
+}
@@ -0,0 +1,31 @@
+package domain
+
+import "time"
+
+type DataExportStatus uint8
+
+const (
+	DataExportStatusUnknown DataExportStatus = iota
+	// DataExportStatusPending 排队等着打包
+	DataExportStatusPending
+	// DataExportStatusDone 打包好了，可以下载
+	DataExportStatusDone
+	// DataExportStatusFailed 打包失败了，要重新申请
+	DataExportStatusFailed
+	// DataExportStatusExpired 过了保留期，文件已经删掉了
+	DataExportStatusExpired
+)
+
+// DataExport 用户导出自己的数据，异步打包成 ZIP
+type DataExport struct {
+	Id     int64
+	Uid    int64
+	Status DataExportStatus
+	// 对象存储里面的 key，打包好了才有
+	Key  string
+	Size int64
+	// 下载地址，带签名的，会过期
+	URL   string
+	Ctime time.Time
+	Utime time.Time
+}
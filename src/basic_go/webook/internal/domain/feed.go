@@ -0,0 +1,35 @@
+package domain
+
+import "time"
+
+// 动态的类型
+const (
+	FeedTypeArticle = "article"
+	FeedTypeLike    = "like"
+	FeedTypeFollow  = "follow"
+)
+
+// FeedEvent 一条动态。不同类型的动态要的字段不一样，都放在 Ext 里面
+type FeedEvent struct {
+	Id int64
+	// 收件箱里面是谁收到的，发件箱里面是谁发的
+	Uid  int64
+	Type string
+	// Biz 和 BizId 是这条动态的来源，比如 article 和文章 ID。
+	// 同一个人的收件箱（或者发件箱）里面同一个来源只会有一条，消费者重试也不会重复
+	Biz   string
+	BizId int64
+	Ext   map[string]string
+	Ctime time.Time
+	// Pulled 是不是从发件箱拉过来的，收件箱里面的是 false
+	Pulled bool
+}
+
+// FeedCursor 翻页的位置，传上一页最后一条动态的 Ctime、Pulled 和 Id，第一页用零值。
+// 动态按 (Ctime, 收件箱在前, Id) 倒序：收件箱和发件箱的 ID 是各自分配的，
+// 同一个 Ctime 的两边直接比 ID 会漏掉或者重复
+type FeedCursor struct {
+	Time   time.Time
+	Pulled bool
+	Id     int64
+}
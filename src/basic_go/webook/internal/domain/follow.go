@@ -0,0 +1,18 @@
+package domain
+
+import "time"
+
+// FollowRelation Follower 关注了 Followee
+type FollowRelation struct {
+	Id       int64
+	Follower int64
+	Followee int64
+	Ctime    time.Time
+}
+
+// FollowStats 一个用户的粉丝数和关注数
+type FollowStats struct {
+	Uid       int64
+	Followers int64
+	Followees int64
+}
@@ -0,0 +1,22 @@
+package domain
+
+import "time"
+
+// Interactive 某个资源的互动数据，用 Biz + BizId 标识资源
+type Interactive struct {
+	Biz        string
+	BizId      int64
+	ReadCnt    int64
+	LikeCnt    int64
+	CollectCnt int64
+	CommentCnt int64
+}
+
+// UserLike 用户给某个资源点的赞
+type UserLike struct {
+	Id    int64
+	Uid   int64
+	Biz   string
+	BizId int64
+	Ctime time.Time
+}
@@ -0,0 +1,49 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+type JobStatus uint8
+
+const (
+	JobStatusUnknown JobStatus = iota
+	// JobStatusWaiting 等着到时间被抢占
+	JobStatusWaiting
+	// JobStatusRunning 有实例抢到了，正在跑
+	JobStatusRunning
+	// JobStatusPaused 暂停了，不会被调度
+	JobStatusPaused
+)
+
+// 支持秒，也支持 @every 1m、@hourly 这种写法
+var jobCronParser = cron.NewParser(cron.SecondOptional | cron.Minute | cron.Hour |
+	cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+
+// Job 定时任务，同一时刻只会在一个实例上面跑
+type Job struct {
+	Id   int64
+	Name string
+	// Executor 用哪个执行器跑，按名字找
+	Executor string
+	// Cfg 给执行器的配置，执行器自己解析
+	Cfg string
+	// Expression cron 表达式
+	Expression string
+	Status     JobStatus
+	Version    int64
+	NextTime   time.Time
+	// Owner 抢到这个任务的实例
+	Owner string
+}
+
+// Next 从 t 开始算下一次执行的时间
+func (j Job) Next(t time.Time) (time.Time, error) {
+	s, err := jobCronParser.Parse(j.Expression)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return s.Next(t), nil
+}
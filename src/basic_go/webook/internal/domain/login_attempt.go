@@ -0,0 +1,15 @@
+package domain
+
+import "time"
+
+// LoginAttempt 登录失败的记录，可以是某个账号的，也可以是某个 IP 的
+type LoginAttempt struct {
+	// 计数窗口内的失败次数
+	Failures int64
+	// 在这个时间之前都不允许登录，零值代表没有被限制
+	BlockedUntil time.Time
+}
+
+func (a LoginAttempt) Blocked(now time.Time) bool {
+	return a.BlockedUntil.After(now)
+}
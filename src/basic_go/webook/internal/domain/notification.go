@@ -0,0 +1,70 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+)
+
+// 通知的类型
+const (
+	NotificationTypeLike      = "like"
+	NotificationTypeComment   = "comment"
+	NotificationTypeReply     = "reply"
+	NotificationTypeFollow    = "follow"
+	NotificationTypePublished = "article_published"
+)
+
+// Notification 站内通知。同一个目标上面同一种还没读的通知会合并成一条
+type Notification struct {
+	Id int64
+	// EventId 产生这条通知的事件，消费者重试的时候靠它去重
+	EventId string
+	// 收通知的人
+	Uid  int64
+	Type string
+	// 通知是关于哪个资源的
+	Biz   string
+	BizId int64
+	// 资源的标题，比如文章标题
+	Title string
+	// 最近的几个人，新的在前面
+	Actors []int64
+	// 一共多少人
+	ActorCnt int64
+	// 最近一条评论的内容
+	Content string
+	Read    bool
+	Ctime   time.Time
+	Utime   time.Time
+}
+
+// Aggregatable 这种通知要不要合并
+func (n Notification) Aggregatable() bool {
+	return n.Type != NotificationTypePublished
+}
+
+// Summary 展示给用户看的一句话，比如 "用户1等13人赞了你的文章《X》"。
+// 现在用户还没有昵称，先用 ID 代替
+func (n Notification) Summary() string {
+	who := ""
+	if len(n.Actors) > 0 {
+		who = fmt.Sprintf("用户%d", n.Actors[0])
+		if n.ActorCnt > 1 {
+			who += fmt.Sprintf("等%d人", n.ActorCnt)
+		}
+	}
+	switch n.Type {
+	case NotificationTypeLike:
+		return fmt.Sprintf("%s赞了你的文章《%s》", who, n.Title)
+	case NotificationTypeComment:
+		return fmt.Sprintf("%s评论了你的文章《%s》", who, n.Title)
+	case NotificationTypeReply:
+		return fmt.Sprintf("%s回复了你在《%s》下面的评论", who, n.Title)
+	case NotificationTypeFollow:
+		return fmt.Sprintf("%s关注了你", who)
+	case NotificationTypePublished:
+		return fmt.Sprintf("你的文章《%s》发表成功", n.Title)
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,41 @@
+package domain
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNotification_Summary(t *testing.T) {
+	testCases := []struct {
+		name string
+		n    Notification
+		want string
+	}{
+		{
+			name: "一个人点赞",
+			n:    Notification{Type: NotificationTypeLike, Title: "Go", Actors: []int64{1}, ActorCnt: 1},
+			want: "用户1赞了你的文章《Go》",
+		},
+		{
+			name: "合并之后的点赞",
+			n:    Notification{Type: NotificationTypeLike, Title: "Go", Actors: []int64{3, 2, 1}, ActorCnt: 13},
+			want: "用户3等13人赞了你的文章《Go》",
+		},
+		{
+			name: "关注",
+			n:    Notification{Type: NotificationTypeFollow, Actors: []int64{2, 1}, ActorCnt: 2},
+			want: "用户2等2人关注了你",
+		},
+		{
+			name: "发表成功",
+			n:    Notification{Type: NotificationTypePublished, Title: "Go"},
+			want: "你的文章《Go》发表成功",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, tc.n.Summary())
+		})
+	}
+}
@@ -0,0 +1,177 @@
+package domain
+
+import (
+	"bufio"
+	_ "embed"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// 常见的弱密码，一行一个，统一用小写
+//
+//go:embed common_passwords.txt
+var commonPasswords string
+
+type CharClass uint8
+
+const (
+	CharClassLetter CharClass = iota + 1
+	CharClassLower
+	CharClassUpper
+	CharClassDigit
+	// CharClassSymbol 除了字母和数字以外的字符
+	CharClassSymbol
+)
+
+func (c CharClass) match(r rune) bool {
+	switch c {
+	case CharClassLetter:
+		return unicode.IsLetter(r)
+	case CharClassLower:
+		return unicode.IsLower(r)
+	case CharClassUpper:
+		return unicode.IsUpper(r)
+	case CharClassDigit:
+		return unicode.IsDigit(r)
+	case CharClassSymbol:
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && !unicode.IsSpace(r)
+	default:
+		return false
+	}
+}
+
+func (c CharClass) String() string {
+	switch c {
+	case CharClassLetter:
+		return "字母"
+	case CharClassLower:
+		return "小写字母"
+	case CharClassUpper:
+		return "大写字母"
+	case CharClassDigit:
+		return "数字"
+	case CharClassSymbol:
+		return "特殊字符"
+	default:
+		return "unknown"
+	}
+}
+
+// PasswordViolation 密码不符合要求的原因
+type PasswordViolation struct {
+	// Code 给程序看的，前端可以根据它做国际化
+	Code string
+	Msg  string
+}
+
+const (
+	PasswordViolationTooShort     = "too_short"
+	PasswordViolationMissingClass = "missing_class"
+	PasswordViolationCommon       = "common"
+	PasswordViolationContainEmail = "contain_email"
+)
+
+// PasswordPolicyError 密码不符合 PasswordPolicy
+type PasswordPolicyError struct {
+	Violations []PasswordViolation
+}
+
+func (e PasswordPolicyError) Error() string {
+	msgs := make([]string, 0, len(e.Violations))
+	for _, v := range e.Violations {
+		msgs = append(msgs, v.Msg)
+	}
+	return strings.Join(msgs, "；")
+}
+
+type PasswordPolicy struct {
+	// 最短长度，按照字符算，不是按照字节
+	MinLength int
+	// 必须包含的字符类型
+	RequiredClasses []CharClass
+	// 禁止使用的弱密码，key 是小写的密码
+	Denylist map[string]struct{}
+	// 禁止密码里面包含邮箱
+	ForbidEmail bool
+}
+
+// DefaultPasswordPolicy 默认策略：至少 8 位，包含字母、数字和特殊字符，
+// 不能是常见弱密码，也不能包含邮箱
+func DefaultPasswordPolicy() PasswordPolicy {
+	return PasswordPolicy{
+		MinLength:       8,
+		RequiredClasses: []CharClass{CharClassLetter, CharClassDigit, CharClassSymbol},
+		Denylist:        CommonPasswords(),
+		ForbidEmail:     true,
+	}
+}
+
+// CommonPasswords 内置的弱密码列表
+func CommonPasswords() map[string]struct{} {
+	res := make(map[string]struct{}, 128)
+	scanner := bufio.NewScanner(strings.NewReader(commonPasswords))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		res[strings.ToLower(line)] = struct{}{}
+	}
+	return res
+}
+
+// Validate 返回所有不符合要求的地方，没有问题就返回 nil
+func (p PasswordPolicy) Validate(password string, email string) []PasswordViolation {
+	var res []PasswordViolation
+	if utf8.RuneCountInString(password) < p.MinLength {
+		res = append(res, PasswordViolation{
+			Code: PasswordViolationTooShort,
+			Msg:  "密码长度不能少于 " + strconv.Itoa(p.MinLength) + " 位",
+		})
+	}
+	for _, c := range p.RequiredClasses {
+		if !strings.ContainsFunc(password, c.match) {
+			res = append(res, PasswordViolation{
+				Code: PasswordViolationMissingClass,
+				Msg:  "密码必须包含" + c.String(),
+			})
+		}
+	}
+	lower := strings.ToLower(password)
+	if _, ok := p.Denylist[lower]; ok {
+		res = append(res, PasswordViolation{
+			Code: PasswordViolationCommon,
+			Msg:  "密码太常见了，请换一个",
+		})
+	}
+	if p.ForbidEmail && containsEmail(lower, strings.ToLower(email)) {
+		res = append(res, PasswordViolation{
+			Code: PasswordViolationContainEmail,
+			Msg:  "密码不能包含邮箱",
+		})
+	}
+	return res
+}
+
+// Check 和 Validate 一样，只不过返回的是 error
+func (p PasswordPolicy) Check(password string, email string) error {
+	violations := p.Validate(password, email)
+	if len(violations) == 0 {
+		return nil
+	}
+	return PasswordPolicyError{Violations: violations}
+}
+
+func containsEmail(password string, email string) bool {
+	if email == "" {
+		return false
+	}
+	if strings.Contains(password, email) {
+		return true
+	}
+	// 邮箱 @ 前面的部分太短的话，误伤会比较多
+	name, _, _ := strings.Cut(email, "@")
+	return len(name) >= 3 && strings.Contains(password, name)
+}
@@ -0,0 +1,61 @@
+package domain
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPasswordPolicy_Validate(t *testing.T) {
+	p := DefaultPasswordPolicy()
+	testCases := []struct {
+		name     string
+		password string
+		email    string
+		want     []string
+	}{
+		{
+			name:     "合法密码",
+			password: "hello#world123",
+			email:    "tom@qq.com",
+		},
+		{
+			name:     "太短",
+			password: "h#1",
+			email:    "tom@qq.com",
+			want:     []string{PasswordViolationTooShort},
+		},
+		{
+			name:     "缺特殊字符和数字",
+			password: "helloworld",
+			email:    "tom@qq.com",
+			want:     []string{PasswordViolationMissingClass, PasswordViolationMissingClass},
+		},
+		{
+			name:     "常见密码",
+			password: "P@ssw0rd",
+			email:    "tom@qq.com",
+			want:     []string{PasswordViolationCommon},
+		},
+		{
+			name:     "包含邮箱",
+			password: "Alice#2023",
+			email:    "alice@qq.com",
+			want:     []string{PasswordViolationContainEmail},
+		},
+		{
+			name:     "中文也算字母",
+			password: "你好世界#2023",
+			email:    "tom@qq.com",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var codes []string
+			for _, v := range p.Validate(tc.password, tc.email) {
+				codes = append(codes, v.Code)
+			}
+			assert.Equal(t, tc.want, codes)
+		})
+	}
+}
@@ -0,0 +1,10 @@
+package domain
+
+// HotArticle 热榜上的一篇文章
+type HotArticle struct {
+	// Article 的 Content 只保留了摘要，热榜用不到全文
+	Article Article
+	ReadCnt int64
+	LikeCnt int64
+	Score   float64
+}
@@ -0,0 +1,40 @@
+package domain
+
+import "time"
+
+type RewardStatus uint8
+
+const (
+	RewardStatusUnknown RewardStatus = iota
+	// RewardStatusInit 下单了还没有付钱
+	RewardStatusInit
+	RewardStatusPaid
+	// RewardStatusFailed 超时没付钱，订单关闭了
+	RewardStatusFailed
+)
+
+// Reward 打赏。Uid 打赏给 Target
+type Reward struct {
+	Id     int64
+	Uid    int64
+	Target RewardTarget
+	// 单位是分
+	Amount int64
+	Status RewardStatus
+	Ctime  time.Time
+}
+
+// RewardTarget 打赏的是哪个资源，钱给谁
+type RewardTarget struct {
+	Biz     string
+	BizId   int64
+	BizName string
+	// 收钱的人，一般是作者
+	Uid int64
+}
+
+// RewardCodeURL 下单之后返回给前端的，用户扫码付钱
+type RewardCodeURL struct {
+	Rid int64
+	URL string
+}
@@ -0,0 +1,17 @@
+package domain
+
+// ArticleSearchHit 搜索结果里面的一篇文章
+type ArticleSearchHit struct {
+	Article     Article
+	Interactive Interactive
+	// 相关度和互动数据综合之后的分数
+	Score float64
+	// 高亮之后的标题和内容片段，已经做过 HTML 转义
+	TitleHighlight   string
+	ContentHighlight string
+}
+
+type ArticleSearchResult struct {
+	Total int
+	Hits  []ArticleSearchHit
+}
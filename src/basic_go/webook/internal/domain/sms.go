@@ -0,0 +1,11 @@
+package domain
+
+// AsyncSms 发送失败了，存下来等着异步重试的短信
+type AsyncSms struct {
+	Id      int64
+	TplId   string
+	Args    []string
+	Numbers []string
+	// RetryMax 最多重试几次
+	RetryMax int
+}
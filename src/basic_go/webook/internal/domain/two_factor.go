@@ -0,0 +1,17 @@
+package domain
+
+// TwoFactor 用户的 TOTP 二次验证配置
+type TwoFactor struct {
+	Uid         int64
+	Secret      string
+	Enabled     bool
+	LastCounter int64
+}
+
+// TwoFactorEnrollment 绑定 TOTP 的时候返回给用户的信息，只会展示这一次
+type TwoFactorEnrollment struct {
+	Secret string
+	// otpauth:// 链接，前端做成二维码
+	URI           string
+	RecoveryCodes []string
+}
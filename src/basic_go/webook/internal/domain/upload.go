@@ -0,0 +1,39 @@
+package domain
+
+import "time"
+
+// 上传的文件用在哪里
+const (
+	UploadBizAvatar  = "avatar"
+	UploadBizArticle = "article"
+)
+
+type UploadStatus uint8
+
+const (
+	UploadStatusUnknown UploadStatus = iota
+	// UploadStatusPending 传上来了，但是还没有被用上。
+	// 一直没用上的会被定时清理掉
+	UploadStatusPending
+	// UploadStatusAttached 已经被用上了，比如设置成了头像，或者文章里面引用了
+	UploadStatusAttached
+)
+
+// Upload 用户上传的文件，目前只有图片
+type Upload struct {
+	Id  int64
+	Uid int64
+	// 对象存储里面的 key
+	Key         string
+	Biz         string
+	BizId       int64
+	Private     bool
+	Size        int64
+	ContentType string
+	Width       int
+	Height      int
+	Status      UploadStatus
+	// 访问的地址，私有的文件是带签名的地址，会过期
+	URL   string
+	Ctime time.Time
+}
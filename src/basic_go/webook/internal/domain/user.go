@@ -2,10 +2,35 @@ package domain
 
 import "time"
 
+type UserRole uint8
+
+const (
+	UserRoleNormal UserRole = iota
+	UserRoleAdmin
+)
+
+type UserStatus uint8
+
+const (
+	UserStatusActive UserStatus = iota
+	// UserStatusDisabled 暂时禁用，比如账号被盗了
+	UserStatusDisabled
+	// UserStatusBanned 违规封禁
+	UserStatusBanned
+	// UserStatusDeleted 用户自己注销了，个人信息已经抹掉
+	UserStatusDeleted
+)
+
 type User struct {
 	Id       int64
 	Email    string
 	Password string
+	Avatar   string
+	Phone    string
+	Role     UserRole
+	Status   UserStatus
+	// 为什么被禁用或者封禁
+	StatusReason string
 
 	//UTC 0 的时区
 	Ctime time.Time
@@ -0,0 +1,53 @@
+package article
+
+import (
+	"basic_go/webook/internal/repository"
+	"basic_go/webook/pkg/mq"
+	"context"
+	"encoding/json"
+	"log"
+)
+
+// InteractiveReadEventConsumer 批量消费阅读事件，更新阅读数
+type InteractiveReadEventConsumer struct {
+	repo   *repository.InteractiveRepository
+	client mq.MQ
+}
+
+func NewInteractiveReadEventConsumer(repo *repository.InteractiveRepository, client mq.MQ) *InteractiveReadEventConsumer {
+	return &InteractiveReadEventConsumer{
+		repo:   repo,
+		client: client,
+	}
+}
+
+// Start 在后台消费，不会阻塞
+func (c *InteractiveReadEventConsumer) Start() error {
+	cg, err := c.client.ConsumerGroup("interactive", mq.DefaultBatchConfig())
+	if err != nil {
+		return err
+	}
+	hdl := mq.WithRetry(c.Consume, c.client.Producer(), mq.DefaultRetryConfig())
+	go func() {
+		err := cg.Consume(context.Background(), []string{TopicReadEvent}, hdl)
+		if err != nil {
+			log.Println("退出了消费循环", err)
+		}
+	}()
+	return nil
+}
+
+func (c *InteractiveReadEventConsumer) Consume(ctx context.Context, msgs []*mq.Message) error {
+	bizs := make([]string, 0, len(msgs))
+	ids := make([]int64, 0, len(msgs))
+	for _, msg := range msgs {
+		var evt ReadEvent
+		err := json.Unmarshal(msg.Value, &evt)
+		if err != nil {
+			return err
+		}
+		bizs = append(bizs, "article")
+		ids = append(ids, evt.Aid)
+	}
+	return c.repo.BatchIncrReadCnt(ctx, bizs, ids)
+}
@@ -0,0 +1,74 @@
+package article
+
+import (
+	"basic_go/webook/pkg/mq"
+	"context"
+	"encoding/json"
+	"strconv"
+)
+
+const (
+	TopicReadEvent   = "article_read"
+	TopicChangeEvent = "article_change"
+)
+
+// 线上库里面的文章发生了什么变化
+const (
+	ChangeTypePublish   = "publish"
+	ChangeTypeUpdate    = "update"
+	ChangeTypeUnpublish = "unpublish"
+)
+
+// ReadEvent 有人看了一篇文章
+type ReadEvent struct {
+	Uid int64 `json:"uid"`
+	Aid int64 `json:"aid"`
+}
+
+// ChangeEvent 发表、修改已发表的文章、撤回的时候发。
+// 撤回的时候只有 Aid 和 AuthorId
+type ChangeEvent struct {
+	Type     string `json:"type"`
+	Aid      int64  `json:"aid"`
+	AuthorId int64  `json:"authorId"`
+	Title    string `json:"title"`
+	Content  string `json:"content"`
+	// 毫秒数
+	Utime int64 `json:"utime"`
+}
+
+type Producer struct {
+	producer mq.Producer
+}
+
+func NewProducer(producer mq.Producer) *Producer {
+	return &Producer{
+		producer: producer,
+	}
+}
+
+func (p *Producer) ProduceReadEvent(ctx context.Context, evt ReadEvent) error {
+	val, err := json.Marshal(evt)
+	if err != nil {
+		return err
+	}
+	return p.producer.Produce(ctx, &mq.Message{
+		Topic: TopicReadEvent,
+		// 同一篇文章的事件落到同一个分区
+		Key:   []byte(strconv.FormatInt(evt.Aid, 10)),
+		Value: val,
+	})
+}
+
+func (p *Producer) ProduceChangeEvent(ctx context.Context, evt ChangeEvent) error {
+	val, err := json.Marshal(evt)
+	if err != nil {
+		return err
+	}
+	return p.producer.Produce(ctx, &mq.Message{
+		Topic: TopicChangeEvent,
+		// 同一篇文章的变化要按顺序消费，不然撤回之后可能又被加回来
+		Key:   []byte(strconv.FormatInt(evt.Aid, 10)),
+		Value: val,
+	})
+}
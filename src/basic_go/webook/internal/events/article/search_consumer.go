@@ -0,0 +1,68 @@
+package article
+
+import (
+	"basic_go/webook/internal/domain"
+	"basic_go/webook/internal/repository"
+	"basic_go/webook/pkg/mq"
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+)
+
+// SearchSyncConsumer 消费文章变更事件，更新搜索索引
+type SearchSyncConsumer struct {
+	repo   *repository.ArticleSearchRepository
+	client mq.MQ
+}
+
+func NewSearchSyncConsumer(repo *repository.ArticleSearchRepository, client mq.MQ) *SearchSyncConsumer {
+	return &SearchSyncConsumer{
+		repo:   repo,
+		client: client,
+	}
+}
+
+// Start 在后台消费，不会阻塞
+func (c *SearchSyncConsumer) Start() error {
+	cg, err := c.client.ConsumerGroup("search", mq.DefaultBatchConfig())
+	if err != nil {
+		return err
+	}
+	hdl := mq.WithRetry(c.Consume, c.client.Producer(), mq.DefaultRetryConfig())
+	go func() {
+		err := cg.Consume(context.Background(), []string{TopicChangeEvent}, hdl)
+		if err != nil {
+			log.Println("退出了消费循环", err)
+		}
+	}()
+	return nil
+}
+
+// Consume 同一篇文章的事件是有序的，按顺序处理就可以
+func (c *SearchSyncConsumer) Consume(ctx context.Context, msgs []*mq.Message) error {
+	for _, msg := range msgs {
+		var evt ChangeEvent
+		err := json.Unmarshal(msg.Value, &evt)
+		if err != nil {
+			return err
+		}
+		switch evt.Type {
+		case ChangeTypePublish, ChangeTypeUpdate:
+			err = c.repo.Upsert(ctx, domain.Article{
+				Id:      evt.Aid,
+				Title:   evt.Title,
+				Content: evt.Content,
+				Author:  domain.Author{Id: evt.AuthorId},
+				Status:  domain.ArticleStatusPublished,
+				Utime:   time.UnixMilli(evt.Utime),
+			})
+		case ChangeTypeUnpublish:
+			err = c.repo.Delete(ctx, evt.Aid)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
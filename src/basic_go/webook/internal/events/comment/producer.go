@@ -0,0 +1,45 @@
+package comment
+
+import (
+	"basic_go/webook/pkg/mq"
+	"context"
+	"encoding/json"
+	"strconv"
+)
+
+const TopicCommentEvent = "comment_create"
+
+// CommentEvent 有人发了评论或者回复
+type CommentEvent struct {
+	Id      int64  `json:"id"`
+	Uid     int64  `json:"uid"`
+	Biz     string `json:"biz"`
+	BizId   int64  `json:"bizId"`
+	Content string `json:"content"`
+	// 资源的作者
+	Owner int64 `json:"owner"`
+	// 回复的是谁的评论，根评论是 0
+	ReplyTo int64 `json:"replyTo"`
+}
+
+type Producer struct {
+	producer mq.Producer
+}
+
+func NewProducer(producer mq.Producer) *Producer {
+	return &Producer{
+		producer: producer,
+	}
+}
+
+func (p *Producer) ProduceCommentEvent(ctx context.Context, evt CommentEvent) error {
+	val, err := json.Marshal(evt)
+	if err != nil {
+		return err
+	}
+	return p.producer.Produce(ctx, &mq.Message{
+		Topic: TopicCommentEvent,
+		Key:   []byte(evt.Biz + ":" + strconv.FormatInt(evt.BizId, 10)),
+		Value: val,
+	})
+}
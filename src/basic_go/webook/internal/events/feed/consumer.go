@@ -0,0 +1,122 @@
+// Package feed 把各个模块的领域事件转成动态
+package feed
+
+import (
+	"basic_go/webook/internal/domain"
+	"basic_go/webook/internal/events/article"
+	"basic_go/webook/internal/events/follow"
+	"basic_go/webook/internal/events/interactive"
+	"basic_go/webook/internal/service"
+	"basic_go/webook/pkg/mq"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+)
+
+type Consumer struct {
+	svc    *service.FeedService
+	client mq.MQ
+}
+
+func NewConsumer(svc *service.FeedService, client mq.MQ) *Consumer {
+	return &Consumer{
+		svc:    svc,
+		client: client,
+	}
+}
+
+// Start 在后台消费，不会阻塞
+func (c *Consumer) Start() error {
+	cg, err := c.client.ConsumerGroup("feed", mq.DefaultBatchConfig())
+	if err != nil {
+		return err
+	}
+	hdl := mq.WithRetry(c.Consume, c.client.Producer(), mq.DefaultRetryConfig())
+	go func() {
+		err := cg.Consume(context.Background(),
+			[]string{article.TopicChangeEvent, follow.TopicFollowEvent, interactive.TopicLikeEvent}, hdl)
+		if err != nil {
+			log.Println("退出了消费循环", err)
+		}
+	}()
+	return nil
+}
+
+func (c *Consumer) Consume(ctx context.Context, msgs []*mq.Message) error {
+	for _, msg := range msgs {
+		evt, ok, err := c.toFeedEvent(msg)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		err = c.svc.CreateFeedEvent(ctx, evt)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// toFeedEvent 返回 false 代表这条消息不需要产生动态
+func (c *Consumer) toFeedEvent(msg *mq.Message) (domain.FeedEvent, bool, error) {
+	switch msg.Topic {
+	case article.TopicChangeEvent:
+		var evt article.ChangeEvent
+		err := json.Unmarshal(msg.Value, &evt)
+		// 只有第一次发表才算动态，修改和撤回都不算
+		if err != nil || evt.Type != article.ChangeTypePublish {
+			return domain.FeedEvent{}, false, err
+		}
+		return domain.FeedEvent{
+			Type:  domain.FeedTypeArticle,
+			Biz:   "article",
+			BizId: evt.Aid,
+			Ext: map[string]string{
+				"uid":   strconv.FormatInt(evt.AuthorId, 10),
+				"aid":   strconv.FormatInt(evt.Aid, 10),
+				"title": evt.Title,
+			},
+			Ctime: time.UnixMilli(evt.Utime),
+		}, true, nil
+	case follow.TopicFollowEvent:
+		var evt follow.FollowEvent
+		err := json.Unmarshal(msg.Value, &evt)
+		if err != nil {
+			return domain.FeedEvent{}, false, err
+		}
+		return domain.FeedEvent{
+			Type:  domain.FeedTypeFollow,
+			Biz:   "follow",
+			BizId: evt.Follower,
+			Ext: map[string]string{
+				"follower": strconv.FormatInt(evt.Follower, 10),
+				"followee": strconv.FormatInt(evt.Followee, 10),
+			},
+		}, true, nil
+	case interactive.TopicLikeEvent:
+		var evt interactive.LikeEvent
+		err := json.Unmarshal(msg.Value, &evt)
+		if err != nil {
+			return domain.FeedEvent{}, false, err
+		}
+		return domain.FeedEvent{
+			Type: domain.FeedTypeLike,
+			// 同一个资源会被很多人点赞，来源要带上是谁点的
+			Biz:   fmt.Sprintf("like:%s:%d", evt.Biz, evt.BizId),
+			BizId: evt.Uid,
+			Ext: map[string]string{
+				"uid":   strconv.FormatInt(evt.Uid, 10),
+				"biz":   evt.Biz,
+				"bizId": strconv.FormatInt(evt.BizId, 10),
+				"owner": strconv.FormatInt(evt.Owner, 10),
+			},
+		}, true, nil
+	default:
+		return domain.FeedEvent{}, false, nil
+	}
+}
@@ -0,0 +1,38 @@
+package follow
+
+import (
+	"basic_go/webook/pkg/mq"
+	"context"
+	"encoding/json"
+	"strconv"
+)
+
+const TopicFollowEvent = "follow_change"
+
+// FollowEvent Follower 关注了 Followee。重复关注不会发
+type FollowEvent struct {
+	Follower int64 `json:"follower"`
+	Followee int64 `json:"followee"`
+}
+
+type Producer struct {
+	producer mq.Producer
+}
+
+func NewProducer(producer mq.Producer) *Producer {
+	return &Producer{
+		producer: producer,
+	}
+}
+
+func (p *Producer) ProduceFollowEvent(ctx context.Context, evt FollowEvent) error {
+	val, err := json.Marshal(evt)
+	if err != nil {
+		return err
+	}
+	return p.producer.Produce(ctx, &mq.Message{
+		Topic: TopicFollowEvent,
+		Key:   []byte(strconv.FormatInt(evt.Followee, 10)),
+		Value: val,
+	})
+}
@@ -0,0 +1,41 @@
+package interactive
+
+import (
+	"basic_go/webook/pkg/mq"
+	"context"
+	"encoding/json"
+	"strconv"
+)
+
+const TopicLikeEvent = "interactive_like"
+
+// LikeEvent Uid 点赞了 Biz + BizId。取消点赞不发
+type LikeEvent struct {
+	Uid   int64  `json:"uid"`
+	Biz   string `json:"biz"`
+	BizId int64  `json:"bizId"`
+	// 资源的作者，下游发通知、写动态的时候不用再查
+	Owner int64 `json:"owner"`
+}
+
+type Producer struct {
+	producer mq.Producer
+}
+
+func NewProducer(producer mq.Producer) *Producer {
+	return &Producer{
+		producer: producer,
+	}
+}
+
+func (p *Producer) ProduceLikeEvent(ctx context.Context, evt LikeEvent) error {
+	val, err := json.Marshal(evt)
+	if err != nil {
+		return err
+	}
+	return p.producer.Produce(ctx, &mq.Message{
+		Topic: TopicLikeEvent,
+		Key:   []byte(evt.Biz + ":" + strconv.FormatInt(evt.BizId, 10)),
+		Value: val,
+	})
+}
@@ -0,0 +1,133 @@
+// Package notification 把各个模块的领域事件转成站内通知
+package notification
+
+import (
+	"basic_go/webook/internal/domain"
+	"basic_go/webook/internal/events/article"
+	"basic_go/webook/internal/events/comment"
+	"basic_go/webook/internal/events/follow"
+	"basic_go/webook/internal/events/interactive"
+	"basic_go/webook/internal/service"
+	"basic_go/webook/pkg/mq"
+	"context"
+	"encoding/json"
+	"log"
+)
+
+type Consumer struct {
+	svc    *service.NotificationService
+	client mq.MQ
+}
+
+func NewConsumer(svc *service.NotificationService, client mq.MQ) *Consumer {
+	return &Consumer{
+		svc:    svc,
+		client: client,
+	}
+}
+
+// Start 在后台消费，不会阻塞
+func (c *Consumer) Start() error {
+	cg, err := c.client.ConsumerGroup("notification", mq.DefaultBatchConfig())
+	if err != nil {
+		return err
+	}
+	hdl := mq.WithRetry(c.Consume, c.client.Producer(), mq.DefaultRetryConfig())
+	go func() {
+		err := cg.Consume(context.Background(), []string{
+			article.TopicChangeEvent,
+			interactive.TopicLikeEvent,
+			comment.TopicCommentEvent,
+			follow.TopicFollowEvent,
+		}, hdl)
+		if err != nil {
+			log.Println("退出了消费循环", err)
+		}
+	}()
+	return nil
+}
+
+func (c *Consumer) Consume(ctx context.Context, msgs []*mq.Message) error {
+	for _, msg := range msgs {
+		n, ok, err := c.toNotification(msg)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		n.EventId = msg.MessageId()
+		err = c.svc.Notify(ctx, n)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// toNotification 返回 false 代表这条消息不需要发通知
+func (c *Consumer) toNotification(msg *mq.Message) (domain.Notification, bool, error) {
+	switch msg.Topic {
+	case article.TopicChangeEvent:
+		var evt article.ChangeEvent
+		err := json.Unmarshal(msg.Value, &evt)
+		if err != nil || evt.Type != article.ChangeTypePublish {
+			return domain.Notification{}, false, err
+		}
+		return domain.Notification{
+			Uid:   evt.AuthorId,
+			Type:  domain.NotificationTypePublished,
+			Biz:   "article",
+			BizId: evt.Aid,
+			Title: evt.Title,
+		}, true, nil
+	case interactive.TopicLikeEvent:
+		var evt interactive.LikeEvent
+		err := json.Unmarshal(msg.Value, &evt)
+		if err != nil {
+			return domain.Notification{}, false, err
+		}
+		return domain.Notification{
+			Uid:    evt.Owner,
+			Type:   domain.NotificationTypeLike,
+			Biz:    evt.Biz,
+			BizId:  evt.BizId,
+			Actors: []int64{evt.Uid},
+		}, true, nil
+	case comment.TopicCommentEvent:
+		var evt comment.CommentEvent
+		err := json.Unmarshal(msg.Value, &evt)
+		if err != nil {
+			return domain.Notification{}, false, err
+		}
+		// 回复通知被回复的人，根评论通知作者
+		n := domain.Notification{
+			Uid:     evt.Owner,
+			Type:    domain.NotificationTypeComment,
+			Biz:     evt.Biz,
+			BizId:   evt.BizId,
+			Actors:  []int64{evt.Uid},
+			Content: evt.Content,
+		}
+		if evt.ReplyTo > 0 {
+			n.Uid = evt.ReplyTo
+			n.Type = domain.NotificationTypeReply
+		}
+		return n, true, nil
+	case follow.TopicFollowEvent:
+		var evt follow.FollowEvent
+		err := json.Unmarshal(msg.Value, &evt)
+		if err != nil {
+			return domain.Notification{}, false, err
+		}
+		return domain.Notification{
+			Uid:    evt.Followee,
+			Type:   domain.NotificationTypeFollow,
+			Biz:    "user",
+			BizId:  evt.Followee,
+			Actors: []int64{evt.Follower},
+		}, true, nil
+	default:
+		return domain.Notification{}, false, nil
+	}
+}
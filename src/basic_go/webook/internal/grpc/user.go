@@ -0,0 +1,236 @@
+// Package grpc 把 service 层的功能通过 gRPC 暴露出去，
+// 以及对应的客户端，客户端实现了和本地一样的 service 接口
+package grpc
+
+import (
+	userv1 "basic_go/webook/api/proto/gen/user/v1"
+	"basic_go/webook/internal/domain"
+	"basic_go/webook/internal/service"
+	"context"
+	"errors"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/protoadapt"
+)
+
+// UserServiceServer 在本地的 service.UserService 外面包一层
+type UserServiceServer struct {
+	userv1.UnimplementedUserServiceServer
+	svc service.UserService
+}
+
+func NewUserServiceServer(svc service.UserService) *UserServiceServer {
+	return &UserServiceServer{
+		svc: svc,
+	}
+}
+
+func (s *UserServiceServer) Register(server grpc.ServiceRegistrar) {
+	userv1.RegisterUserServiceServer(server, s)
+}
+
+func (s *UserServiceServer) Signup(ctx context.Context, req *userv1.SignupRequest) (*userv1.SignupResponse, error) {
+	err := s.svc.Signup(ctx, domain.User{
+		Email:    req.GetEmail(),
+		Password: req.GetPassword(),
+	})
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &userv1.SignupResponse{}, nil
+}
+
+func (s *UserServiceServer) Login(ctx context.Context, req *userv1.LoginRequest) (*userv1.LoginResponse, error) {
+	u, err := s.svc.Login(ctx, req.GetEmail(), req.GetPassword(), req.GetIp())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &userv1.LoginResponse{User: toDTO(u)}, nil
+}
+
+func (s *UserServiceServer) Profile(ctx context.Context, req *userv1.ProfileRequest) (*userv1.ProfileResponse, error) {
+	u, err := s.svc.Profile(ctx, req.GetId())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &userv1.ProfileResponse{User: toDTO(u)}, nil
+}
+
+func (s *UserServiceServer) FindById(ctx context.Context, req *userv1.FindByIdRequest) (*userv1.FindByIdResponse, error) {
+	u, err := s.svc.FindById(ctx, req.GetId())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &userv1.FindByIdResponse{User: toDTO(u)}, nil
+}
+
+func (s *UserServiceServer) FindOrCreateByPhone(ctx context.Context,
+	req *userv1.FindOrCreateByPhoneRequest) (*userv1.FindOrCreateByPhoneResponse, error) {
+	if req.GetPhone() == "" {
+		return nil, status.Error(codes.InvalidArgument, "手机号不能为空")
+	}
+	u, err := s.svc.FindOrCreateByPhone(ctx, req.GetPhone())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &userv1.FindOrCreateByPhoneResponse{User: toDTO(u)}, nil
+}
+
+// UserServiceClient 调用远程的用户服务，和本地调用一样返回 service 包里面的错误
+type UserServiceClient struct {
+	client userv1.UserServiceClient
+}
+
+var _ service.UserService = (*UserServiceClient)(nil)
+
+func NewUserServiceClient(cc grpc.ClientConnInterface) *UserServiceClient {
+	return &UserServiceClient{
+		client: userv1.NewUserServiceClient(cc),
+	}
+}
+
+func (c *UserServiceClient) Signup(ctx context.Context, u domain.User) error {
+	_, err := c.client.Signup(ctx, &userv1.SignupRequest{
+		Email:    u.Email,
+		Password: u.Password,
+	})
+	return fromStatus(err)
+}
+
+func (c *UserServiceClient) Login(ctx context.Context, email string, password string, ip string) (domain.User, error) {
+	resp, err := c.client.Login(ctx, &userv1.LoginRequest{
+		Email:    email,
+		Password: password,
+		Ip:       ip,
+	})
+	if err != nil {
+		return domain.User{}, fromStatus(err)
+	}
+	return toDomain(resp.GetUser()), nil
+}
+
+func (c *UserServiceClient) Profile(ctx context.Context, id int64) (domain.User, error) {
+	resp, err := c.client.Profile(ctx, &userv1.ProfileRequest{Id: id})
+	if err != nil {
+		return domain.User{}, fromStatus(err)
+	}
+	return toDomain(resp.GetUser()), nil
+}
+
+// FindById 远程调用拿不到密码
+func (c *UserServiceClient) FindById(ctx context.Context, id int64) (domain.User, error) {
+	resp, err := c.client.FindById(ctx, &userv1.FindByIdRequest{Id: id})
+	if err != nil {
+		return domain.User{}, fromStatus(err)
+	}
+	return toDomain(resp.GetUser()), nil
+}
+
+func (c *UserServiceClient) FindOrCreateByPhone(ctx context.Context, phone string) (domain.User, error) {
+	resp, err := c.client.FindOrCreateByPhone(ctx, &userv1.FindOrCreateByPhoneRequest{Phone: phone})
+	if err != nil {
+		return domain.User{}, fromStatus(err)
+	}
+	return toDomain(resp.GetUser()), nil
+}
+
+func toDTO(u domain.User) *userv1.User {
+	return &userv1.User{
+		Id:           u.Id,
+		Email:        u.Email,
+		Phone:        u.Phone,
+		Avatar:       u.Avatar,
+		Role:         int32(u.Role),
+		Status:       int32(u.Status),
+		StatusReason: u.StatusReason,
+		Ctime:        u.Ctime.UnixMilli(),
+	}
+}
+
+func toDomain(u *userv1.User) domain.User {
+	return domain.User{
+		Id:           u.GetId(),
+		Email:        u.GetEmail(),
+		Phone:        u.GetPhone(),
+		Avatar:       u.GetAvatar(),
+		Role:         domain.UserRole(u.GetRole()),
+		Status:       domain.UserStatus(u.GetStatus()),
+		StatusReason: u.GetStatusReason(),
+		Ctime:        time.UnixMilli(u.GetCtime()),
+	}
+}
+
+// 业务错误和 gRPC 错误码、ErrorReason 的对应关系
+var errReasons = []struct {
+	err    error
+	code   codes.Code
+	reason userv1.ErrorReason
+}{
+	{service.ErrDuplicateEmail, codes.AlreadyExists, userv1.ErrorReason_ERROR_REASON_DUPLICATE_EMAIL},
+	{service.ErrInvalidUserOrPassword, codes.Unauthenticated, userv1.ErrorReason_ERROR_REASON_INVALID_USER_OR_PASSWORD},
+	{service.ErrLoginLocked, codes.ResourceExhausted, userv1.ErrorReason_ERROR_REASON_LOGIN_LOCKED},
+	{service.ErrLoginTooFrequent, codes.ResourceExhausted, userv1.ErrorReason_ERROR_REASON_LOGIN_TOO_FREQUENT},
+	{service.ErrUserDisabled, codes.PermissionDenied, userv1.ErrorReason_ERROR_REASON_USER_DISABLED},
+	{service.ErrUserBanned, codes.PermissionDenied, userv1.ErrorReason_ERROR_REASON_USER_BANNED},
+	{service.ErrUserNotFound, codes.NotFound, userv1.ErrorReason_ERROR_REASON_USER_NOT_FOUND},
+}
+
+// toStatus 业务错误带上 ErrorDetail，别的错误都当成内部错误
+func toStatus(err error) error {
+	var policyErr domain.PasswordPolicyError
+	if errors.As(err, &policyErr) {
+		detail := &userv1.ErrorDetail{Reason: userv1.ErrorReason_ERROR_REASON_PASSWORD_POLICY}
+		for _, v := range policyErr.Violations {
+			detail.Violations = append(detail.Violations, &userv1.PasswordViolation{Code: v.Code, Msg: v.Msg})
+		}
+		return withDetail(codes.InvalidArgument, err, detail)
+	}
+	for _, r := range errReasons {
+		if err == r.err {
+			return withDetail(r.code, err, &userv1.ErrorDetail{Reason: r.reason})
+		}
+	}
+	return status.Error(codes.Internal, err.Error())
+}
+
+func withDetail(code codes.Code, err error, detail *userv1.ErrorDetail) error {
+	st, e := status.New(code, err.Error()).WithDetails(protoadapt.MessageV1Of(detail))
+	if e != nil {
+		return status.Error(code, err.Error())
+	}
+	return st.Err()
+}
+
+// fromStatus 还原成 toStatus 之前的业务错误，还原不了的原样返回
+func fromStatus(err error) error {
+	if err == nil {
+		return nil
+	}
+	st, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
+	for _, d := range st.Details() {
+		detail, ok := d.(*userv1.ErrorDetail)
+		if !ok {
+			continue
+		}
+		if detail.GetReason() == userv1.ErrorReason_ERROR_REASON_PASSWORD_POLICY {
+			var policyErr domain.PasswordPolicyError
+			for _, v := range detail.GetViolations() {
+				policyErr.Violations = append(policyErr.Violations,
+					domain.PasswordViolation{Code: v.GetCode(), Msg: v.GetMsg()})
+			}
+			return policyErr
+		}
+		for _, r := range errReasons {
+			if detail.GetReason() == r.reason {
+				return r.err
+			}
+		}
+	}
+	return err
+}
@@ -0,0 +1,125 @@
+package grpc
+
+import (
+	"basic_go/webook/internal/domain"
+	"basic_go/webook/internal/repository"
+	"basic_go/webook/internal/repository/cache"
+	"basic_go/webook/internal/repository/dao"
+	"basic_go/webook/internal/repository/dao/daotest"
+	"basic_go/webook/internal/service"
+	"basic_go/webook/pkg/hasher"
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+	"gorm.io/gorm"
+)
+
+// newTestClient 用内存里面的 bufconn 起一个真的 gRPC 服务，返回连到它的客户端
+func newTestClient(t *testing.T) (*UserServiceClient, *gorm.DB) {
+	db := daotest.OpenDB(t, &dao.User{})
+	guard := service.NewLoginGuard(repository.NewLoginAttemptRepository(cache.NewMemoryLoginAttemptCache(nil)),
+		service.DefaultLoginGuardConfig())
+	local := service.NewLocalUserService(repository.NewUserRepository(dao.NewUserDAO(db)), guard,
+		domain.DefaultPasswordPolicy(), hasher.NewBcrypt(bcrypt.MinCost))
+
+	lis := bufconn.Listen(1 << 20)
+	server := grpc.NewServer()
+	NewUserServiceServer(local).Register(server)
+	go func() {
+		_ = server.Serve(lis)
+	}()
+	t.Cleanup(server.Stop)
+
+	cc, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, s string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = cc.Close()
+	})
+	return NewUserServiceClient(cc), db
+}
+
+func TestUserService(t *testing.T) {
+	client, db := newTestClient(t)
+	ctx := context.Background()
+	const password = "hello#world123"
+
+	require.NoError(t, client.Signup(ctx, domain.User{Email: "a@qq.com", Password: password}))
+
+	testCases := []struct {
+		name    string
+		call    func() error
+		wantErr error
+	}{
+		{
+			name: "邮箱冲突",
+			call: func() error {
+				return client.Signup(ctx, domain.User{Email: "a@qq.com", Password: password})
+			},
+			wantErr: service.ErrDuplicateEmail,
+		},
+		{
+			name: "密码错误",
+			call: func() error {
+				_, err := client.Login(ctx, "a@qq.com", "wrong#password1", "127.0.0.1")
+				return err
+			},
+			wantErr: service.ErrInvalidUserOrPassword,
+		},
+		{
+			name: "用户不存在",
+			call: func() error {
+				_, err := client.FindById(ctx, 100)
+				return err
+			},
+			wantErr: service.ErrUserNotFound,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.wantErr, tc.call())
+		})
+	}
+
+	// 密码策略的错误带着具体原因
+	err := client.Signup(ctx, domain.User{Email: "b@qq.com", Password: "123"})
+	var policyErr domain.PasswordPolicyError
+	require.True(t, errors.As(err, &policyErr))
+	assert.NotEmpty(t, policyErr.Violations)
+
+	u, err := client.Login(ctx, "a@qq.com", password, "127.0.0.1")
+	require.NoError(t, err)
+	assert.Equal(t, "a@qq.com", u.Email)
+	// 密码不会传出去
+	assert.Empty(t, u.Password)
+
+	p, err := client.Profile(ctx, u.Id)
+	require.NoError(t, err)
+	assert.Equal(t, u.Email, p.Email)
+	assert.Equal(t, u.Ctime.UnixMilli(), p.Ctime.UnixMilli())
+
+	// 第二次是同一个用户
+	pu, err := client.FindOrCreateByPhone(ctx, "13800000000")
+	require.NoError(t, err)
+	assert.Equal(t, "13800000000", pu.Phone)
+	assert.Empty(t, pu.Email)
+	pu2, err := client.FindOrCreateByPhone(ctx, "13800000000")
+	require.NoError(t, err)
+	assert.Equal(t, pu.Id, pu2.Id)
+
+	require.NoError(t, db.Model(&dao.User{}).Where("id = ?", pu.Id).
+		Update("status", domain.UserStatusBanned).Error)
+	_, err = client.FindOrCreateByPhone(ctx, "13800000000")
+	assert.Equal(t, service.ErrUserBanned, err)
+}
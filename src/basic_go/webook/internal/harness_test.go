@@ -0,0 +1,141 @@
+package main
+
+import (
+	"basic_go/webook/internal/repository/dao"
+	"basic_go/webook/internal/repository/dao/daotest"
+	"basic_go/webook/internal/web"
+	"basic_go/webook/pkg/mq/memory"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	goredis "github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// testApp 集成测试用的完整服务：initApp 搭出来的 gin，
+// 数据库换成 SQLite 内存数据库，Redis 换成 miniredis，消息队列用内存的，不依赖任何外部服务。
+// handler、消费者和定时任务都在不同的 goroutine 里面，跑的时候要带上 -race：
+//
+//	go test -race ./webook/internal/
+type testApp struct {
+	t      *testing.T
+	server *gin.Engine
+	db     *gorm.DB
+	redis  *miniredis.Miniredis
+}
+
+func newTestApp(t *testing.T) *testApp {
+	return newTestAppWithAuth(t, authConfig{Mode: web.AuthModeJWT})
+}
+
+// newTestAppWithAuth session 存在 Redis 里面的话用的也是 miniredis
+func newTestAppWithAuth(t *testing.T, authCfg authConfig) *testApp {
+	gin.SetMode(gin.TestMode)
+	db := daotest.OpenDB(t)
+	require.NoError(t, dao.InitTables(db))
+
+	mr := miniredis.RunT(t)
+	redisClient := goredis.NewClient(&goredis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() {
+		_ = redisClient.Close()
+	})
+
+	// 定时任务不启动
+	server, _ := initApp(db, redisClient, memory.NewBroker(16), authCfg, initSessionStore(authCfg, db, mr.Addr()))
+	return &testApp{
+		t:      t,
+		server: server,
+		db:     db,
+		redis:  mr,
+	}
+}
+
+// client 模拟一个前端，登录之后自动带上 token 和 cookie，响应里面有新的就换成新的
+func (a *testApp) client() *testClient {
+	return &testClient{app: a}
+}
+
+type testClient struct {
+	app     *testApp
+	token   string
+	cookies map[string]*http.Cookie
+	// Accept-Language，不设置就不带
+	lang string
+}
+
+// do body 不是 nil 的话按 JSON 发送
+func (c *testClient) do(method string, path string, body any) *httptest.ResponseRecorder {
+	return c.doWithHeader(method, path, body, nil)
+}
+
+// doWithHeader 额外带上 header
+func (c *testClient) doWithHeader(method string, path string, body any, header http.Header) *httptest.ResponseRecorder {
+	var reader *bytes.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		require.NoError(c.app.t, err)
+		reader = bytes.NewReader(data)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+	req := httptest.NewRequest(method, path, reader)
+	req.Header.Set("Content-Type", "application/json")
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	if c.lang != "" {
+		req.Header.Set("Accept-Language", c.lang)
+	}
+	for k, vs := range header {
+		for _, v := range vs {
+			req.Header.Add(k, v)
+		}
+	}
+	for _, cookie := range c.cookies {
+		req.AddCookie(cookie)
+	}
+	resp := httptest.NewRecorder()
+	c.app.server.ServeHTTP(resp, req)
+	if token := resp.Header().Get("x-jwt-token"); token != "" {
+		c.token = token
+	}
+	for _, cookie := range resp.Result().Cookies() {
+		if c.cookies == nil {
+			c.cookies = make(map[string]*http.Cookie)
+		}
+		c.cookies[cookie.Name] = cookie
+	}
+	return resp
+}
+
+func (c *testClient) signup(email string, password string) *httptest.ResponseRecorder {
+	return c.do(http.MethodPost, "/users/signup", map[string]string{
+		"email":           email,
+		"password":        password,
+		"confirmPassword": password,
+	})
+}
+
+func (c *testClient) login(email string, password string) *httptest.ResponseRecorder {
+	return c.do(http.MethodPost, "/users/login", map[string]string{
+		"email":    email,
+		"password": password,
+	})
+}
+
+// mustLogin 注册并且登录，返回已经带上登录态的 client
+func (a *testApp) mustLogin(email string, password string) *testClient {
+	c := a.client()
+	resp := c.signup(email, password)
+	require.Equal(a.t, "hello 欢迎注册", resp.Body.String())
+	resp = c.login(email, password)
+	require.Equal(a.t, "登录成功", resp.Body.String())
+	require.True(a.t, c.token != "" || len(c.cookies) > 0)
+	return c
+}
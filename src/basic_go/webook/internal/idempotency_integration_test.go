@@ -0,0 +1,88 @@
+package main
+
+import (
+	"basic_go/webook/internal/web"
+	"basic_go/webook/internal/web/middleware"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIdempotentPublish(t *testing.T) {
+	app := newTestApp(t)
+	c := app.mustLogin("a@qq.com", testPassword)
+	header := http.Header{}
+	header.Set(middleware.HeaderIdempotencyKey, "publish-1")
+	art := web.ArticleReq{Title: "标题", Content: "内容"}
+
+	first := c.doWithHeader(http.MethodPost, "/articles/publish", art, header)
+	require.Equal(t, http.StatusOK, first.Code)
+	assert.Empty(t, first.Header().Get(middleware.HeaderIdempotentReplayed))
+
+	// 重试拿到的是第一次的响应，文章 ID 一样
+	retry := c.doWithHeader(http.MethodPost, "/articles/publish", art, header)
+	require.Equal(t, http.StatusOK, retry.Code)
+	assert.Equal(t, "true", retry.Header().Get(middleware.HeaderIdempotentReplayed))
+	assert.JSONEq(t, first.Body.String(), retry.Body.String())
+	assert.Equal(t, 1, countArticles(t, c))
+
+	// 同一个 key 换了内容
+	resp := c.doWithHeader(http.MethodPost, "/articles/publish", web.ArticleReq{Title: "别的标题"}, header)
+	assert.Equal(t, http.StatusUnprocessableEntity, resp.Code)
+	assert.Equal(t, 1, countArticles(t, c))
+
+	// 不带 key 的还是每次都执行
+	c.do(http.MethodPost, "/articles/publish", art)
+	assert.Equal(t, 2, countArticles(t, c))
+
+	// key 是按照用户区分的
+	other := app.mustLogin("b@qq.com", testPassword)
+	resp = other.doWithHeader(http.MethodPost, "/articles/publish", art, header)
+	assert.Empty(t, resp.Header().Get(middleware.HeaderIdempotentReplayed))
+	assert.Equal(t, 1, countArticles(t, other))
+}
+
+func TestIdempotentSignup(t *testing.T) {
+	app := newTestApp(t)
+	header := http.Header{}
+	header.Set(middleware.HeaderIdempotencyKey, "signup-1")
+	body := map[string]string{
+		"email":           "a@qq.com",
+		"password":        testPassword,
+		"confirmPassword": testPassword,
+	}
+	resp := app.client().doWithHeader(http.MethodPost, "/users/signup", body, header)
+	assert.Equal(t, "hello 欢迎注册", resp.Body.String())
+	// 不会变成邮箱冲突
+	resp = app.client().doWithHeader(http.MethodPost, "/users/signup", body, header)
+	assert.Equal(t, "hello 欢迎注册", resp.Body.String())
+	assert.Equal(t, "true", resp.Header().Get(middleware.HeaderIdempotentReplayed))
+}
+
+// TestIdempotentLogin 登录不走幂等，重试也要拿到新的 token
+func TestIdempotentLogin(t *testing.T) {
+	app := newTestApp(t)
+	app.mustLogin("a@qq.com", testPassword)
+	header := http.Header{}
+	header.Set(middleware.HeaderIdempotencyKey, "login-1")
+	body := map[string]string{"email": "a@qq.com", "password": testPassword}
+	for i := 0; i < 2; i++ {
+		resp := app.client().doWithHeader(http.MethodPost, "/users/login", body, header)
+		assert.Equal(t, "登录成功", resp.Body.String())
+		assert.Empty(t, resp.Header().Get(middleware.HeaderIdempotentReplayed))
+		assert.NotEmpty(t, resp.Header().Get("x-jwt-token"))
+	}
+}
+
+func countArticles(t *testing.T, c *testClient) int {
+	resp := c.do(http.MethodPost, "/articles/list", map[string]int{"offset": 0, "limit": 100})
+	require.Equal(t, http.StatusOK, resp.Code)
+	var res struct {
+		Data []web.ArticleVO `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &res))
+	return len(res.Data)
+}
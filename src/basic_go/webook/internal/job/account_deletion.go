@@ -0,0 +1,32 @@
+package job
+
+import (
+	"basic_go/webook/internal/domain"
+	"basic_go/webook/internal/service"
+	"context"
+	"time"
+)
+
+// AccountDeletionJob 定时处理冷静期已经过了的注销申请
+type AccountDeletionJob struct {
+	svc     *service.AccountDeletionService
+	timeout time.Duration
+}
+
+func NewAccountDeletionJob(svc *service.AccountDeletionService) *AccountDeletionJob {
+	return &AccountDeletionJob{
+		svc:     svc,
+		timeout: time.Minute * 10,
+	}
+}
+
+func (j *AccountDeletionJob) Name() string {
+	return "account_deletion"
+}
+
+// Exec 执行一次，由 Scheduler 调度
+func (j *AccountDeletionJob) Exec(ctx context.Context, _ domain.Job) error {
+	ctx, cancel := context.WithTimeout(ctx, j.timeout)
+	defer cancel()
+	return j.svc.ExecuteDue(ctx)
+}
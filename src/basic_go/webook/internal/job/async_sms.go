@@ -0,0 +1,32 @@
+package job
+
+import (
+	"basic_go/webook/internal/domain"
+	"basic_go/webook/internal/service/sms/async"
+	"context"
+	"time"
+)
+
+// AsyncSmsJob 定时重试发送失败了的短信
+type AsyncSmsJob struct {
+	svc     *async.Service
+	timeout time.Duration
+}
+
+func NewAsyncSmsJob(svc *async.Service) *AsyncSmsJob {
+	return &AsyncSmsJob{
+		svc:     svc,
+		timeout: time.Minute,
+	}
+}
+
+func (j *AsyncSmsJob) Name() string {
+	return "sms_async"
+}
+
+// Exec 执行一次，由 Scheduler 调度
+func (j *AsyncSmsJob) Exec(ctx context.Context, _ domain.Job) error {
+	ctx, cancel := context.WithTimeout(ctx, j.timeout)
+	defer cancel()
+	return j.svc.RetryPending(ctx)
+}
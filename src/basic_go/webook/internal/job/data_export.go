@@ -0,0 +1,56 @@
+package job
+
+import (
+	"basic_go/webook/internal/domain"
+	"basic_go/webook/internal/service"
+	"context"
+	"time"
+)
+
+// DataExportJob 定时打包用户申请导出的数据
+type DataExportJob struct {
+	svc     *service.DataExportService
+	timeout time.Duration
+}
+
+func NewDataExportJob(svc *service.DataExportService) *DataExportJob {
+	return &DataExportJob{
+		svc:     svc,
+		timeout: time.Minute * 10,
+	}
+}
+
+func (j *DataExportJob) Name() string {
+	return "data_export"
+}
+
+// Exec 执行一次，由 Scheduler 调度
+func (j *DataExportJob) Exec(ctx context.Context, _ domain.Job) error {
+	ctx, cancel := context.WithTimeout(ctx, j.timeout)
+	defer cancel()
+	return j.svc.BuildPending(ctx)
+}
+
+// DataExportSweepJob 定时清理过了保留期的导出文件
+type DataExportSweepJob struct {
+	svc     *service.DataExportService
+	timeout time.Duration
+}
+
+func NewDataExportSweepJob(svc *service.DataExportService) *DataExportSweepJob {
+	return &DataExportSweepJob{
+		svc:     svc,
+		timeout: time.Minute * 10,
+	}
+}
+
+func (j *DataExportSweepJob) Name() string {
+	return "data_export_sweep"
+}
+
+// Exec 执行一次，由 Scheduler 调度
+func (j *DataExportSweepJob) Exec(ctx context.Context, _ domain.Job) error {
+	ctx, cancel := context.WithTimeout(ctx, j.timeout)
+	defer cancel()
+	return j.svc.SweepExpired(ctx)
+}
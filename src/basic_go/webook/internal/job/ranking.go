@@ -0,0 +1,32 @@
+package job
+
+import (
+	"basic_go/webook/internal/domain"
+	"basic_go/webook/internal/service"
+	"context"
+	"time"
+)
+
+// RankingJob 定时重新计算热榜
+type RankingJob struct {
+	svc     *service.RankingService
+	timeout time.Duration
+}
+
+func NewRankingJob(svc *service.RankingService) *RankingJob {
+	return &RankingJob{
+		svc:     svc,
+		timeout: time.Minute,
+	}
+}
+
+func (j *RankingJob) Name() string {
+	return "ranking"
+}
+
+// Exec 执行一次，由 Scheduler 调度
+func (j *RankingJob) Exec(ctx context.Context, _ domain.Job) error {
+	ctx, cancel := context.WithTimeout(ctx, j.timeout)
+	defer cancel()
+	return j.svc.RankTopN(ctx)
+}
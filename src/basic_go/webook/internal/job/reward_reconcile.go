@@ -0,0 +1,33 @@
+// Package job 后台定时运行的任务
+package job
+
+import (
+	"basic_go/webook/internal/domain"
+	"basic_go/webook/internal/service"
+	"context"
+	"time"
+)
+
+// RewardReconcileJob 定时处理卡在待支付状态的打赏订单
+type RewardReconcileJob struct {
+	svc     *service.RewardService
+	timeout time.Duration
+}
+
+func NewRewardReconcileJob(svc *service.RewardService) *RewardReconcileJob {
+	return &RewardReconcileJob{
+		svc:     svc,
+		timeout: time.Minute,
+	}
+}
+
+func (j *RewardReconcileJob) Name() string {
+	return "reward_reconcile"
+}
+
+// Exec 执行一次，由 Scheduler 调度
+func (j *RewardReconcileJob) Exec(ctx context.Context, _ domain.Job) error {
+	ctx, cancel := context.WithTimeout(ctx, j.timeout)
+	defer cancel()
+	return j.svc.Reconcile(ctx)
+}
@@ -0,0 +1,168 @@
+package job
+
+import (
+	"basic_go/webook/internal/domain"
+	"basic_go/webook/internal/service"
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// Executor 真正干活的，任务表里面按名字找
+type Executor interface {
+	Name() string
+	// Exec 执行一次。任务被别的实例抢走的时候 ctx 会被取消，要尽快返回
+	Exec(ctx context.Context, j domain.Job) error
+}
+
+type SchedulerConfig struct {
+	// PollInterval 没有任务可以抢的时候，隔多久再试
+	PollInterval time.Duration
+	// HeartbeatInterval 跑任务的时候多久续约一次，要比 CronJobConfig.HeartbeatTimeout 小很多
+	HeartbeatInterval time.Duration
+	// MaxConcurrent 一个实例最多同时跑几个任务
+	MaxConcurrent int
+	// DBTimeout 抢占、续约、释放的超时时间
+	DBTimeout time.Duration
+}
+
+func DefaultSchedulerConfig() SchedulerConfig {
+	return SchedulerConfig{
+		PollInterval:      time.Second * 5,
+		HeartbeatInterval: time.Second * 10,
+		MaxConcurrent:     4,
+		DBTimeout:         time.Second * 3,
+	}
+}
+
+// Scheduler 不停地抢任务来跑，跑的过程中续约，跑完了放回去
+type Scheduler struct {
+	svc       *service.CronJobService
+	cfg       SchedulerConfig
+	mu        sync.RWMutex
+	executors map[string]Executor
+	limiter   chan struct{}
+}
+
+func NewScheduler(svc *service.CronJobService, cfg SchedulerConfig) *Scheduler {
+	return &Scheduler{
+		svc:       svc,
+		cfg:       cfg,
+		executors: make(map[string]Executor),
+		limiter:   make(chan struct{}, cfg.MaxConcurrent),
+	}
+}
+
+func (s *Scheduler) RegisterExecutor(e Executor) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.executors[e.Name()] = e
+}
+
+// AddJob 注册执行器，并且在任务表里面加一个同名的任务，已经有了就不加
+func (s *Scheduler) AddJob(ctx context.Context, e Executor, expression string) error {
+	s.RegisterExecutor(e)
+	return s.svc.AddJob(ctx, domain.Job{
+		Name:       e.Name(),
+		Executor:   e.Name(),
+		Expression: expression,
+	})
+}
+
+// Start 在后台调度，直到 ctx 被取消
+func (s *Scheduler) Start(ctx context.Context) {
+	go s.Schedule(ctx)
+}
+
+// Schedule 调度，直到 ctx 被取消。已经在跑的任务会等它们结束才返回
+func (s *Scheduler) Schedule(ctx context.Context) {
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case s.limiter <- struct{}{}:
+		}
+		dctx, cancel := context.WithTimeout(ctx, s.cfg.DBTimeout)
+		j, err := s.svc.Preempt(dctx)
+		cancel()
+		if err != nil {
+			<-s.limiter
+			if err != service.ErrNoJob && ctx.Err() == nil {
+				log.Println("抢占任务失败", err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(s.cfg.PollInterval):
+			}
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-s.limiter
+				wg.Done()
+			}()
+			s.run(ctx, j)
+		}()
+	}
+}
+
+func (s *Scheduler) run(ctx context.Context, j domain.Job) {
+	s.mu.RLock()
+	e, ok := s.executors[j.Executor]
+	s.mu.RUnlock()
+	if !ok {
+		// 可能是新版本加的执行器，这个实例还是老版本。放回去算好下一次的时间，不然会一直抢到它
+		log.Println("找不到执行器", j.Name, j.Executor)
+	} else {
+		jobCtx, cancel := context.WithCancel(ctx)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			s.heartbeat(jobCtx, cancel, j)
+		}()
+		err := e.Exec(jobCtx, j)
+		if err != nil {
+			log.Println("执行任务失败", j.Name, err)
+		}
+		cancel()
+		<-done
+	}
+	// ctx 可能已经取消了，释放要用新的 ctx
+	dctx, cancel := context.WithTimeout(context.Background(), s.cfg.DBTimeout)
+	defer cancel()
+	err := s.svc.Release(dctx, j)
+	if err != nil && err != service.ErrJobLost {
+		// 释放失败了也没关系，续约超时之后会被重新抢占
+		log.Println("释放任务失败", j.Name, err)
+	}
+}
+
+// heartbeat 定时续约，任务被别人抢走了就调用 cancel 让执行器停下来
+func (s *Scheduler) heartbeat(ctx context.Context, cancel context.CancelFunc, j domain.Job) {
+	ticker := time.NewTicker(s.cfg.HeartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			dctx, dcancel := context.WithTimeout(ctx, s.cfg.DBTimeout)
+			err := s.svc.Heartbeat(dctx, j)
+			dcancel()
+			if err == service.ErrJobLost {
+				log.Println("任务被别的实例抢走了", j.Name)
+				cancel()
+				return
+			}
+			if err != nil && ctx.Err() == nil {
+				// 偶尔失败一次不要紧，超时之前续上就行
+				log.Println("任务续约失败", j.Name, err)
+			}
+		}
+	}
+}
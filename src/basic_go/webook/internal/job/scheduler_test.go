@@ -0,0 +1,77 @@
+package job
+
+import (
+	"basic_go/webook/internal/domain"
+	"basic_go/webook/internal/repository"
+	"basic_go/webook/internal/repository/dao"
+	"basic_go/webook/internal/repository/dao/daotest"
+	"basic_go/webook/internal/service"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type funcExecutor struct {
+	name string
+	fn   func(ctx context.Context, j domain.Job) error
+}
+
+func (e funcExecutor) Name() string {
+	return e.name
+}
+
+func (e funcExecutor) Exec(ctx context.Context, j domain.Job) error {
+	return e.fn(ctx, j)
+}
+
+func TestScheduler(t *testing.T) {
+	db := daotest.OpenDB(t, &dao.Job{})
+	svc := service.NewCronJobService(repository.NewJobRepository(dao.NewJobDAO(db)), service.CronJobConfig{
+		Owner:            "a",
+		HeartbeatTimeout: time.Minute,
+	})
+	sch := NewScheduler(svc, SchedulerConfig{
+		PollInterval:      time.Millisecond * 10,
+		HeartbeatInterval: time.Millisecond * 10,
+		MaxConcurrent:     2,
+		DBTimeout:         time.Second,
+	})
+
+	ran := make(chan domain.Job, 1)
+	require.NoError(t, sch.AddJob(context.Background(), funcExecutor{name: "ranking",
+		fn: func(ctx context.Context, j domain.Job) error {
+			// 任务跑着的时候被别的实例抢走了，ctx 会被取消
+			require.NoError(t, db.Model(&dao.Job{}).Where("id = ?", j.Id).
+				Update("owner", "b").Error)
+			<-ctx.Done()
+			ran <- j
+			return ctx.Err()
+		}}, "@every 1h"))
+	// 让它马上就能被抢到
+	require.NoError(t, db.Model(&dao.Job{}).Where("name = ?", "ranking").
+		Update("next_time", time.Now().UnixMilli()-1).Error)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		sch.Schedule(ctx)
+		close(done)
+	}()
+	select {
+	case j := <-ran:
+		assert.Equal(t, "a", j.Owner)
+	case <-time.After(time.Second * 5):
+		t.Fatal("任务没有被调度")
+	}
+	cancel()
+	<-done
+
+	// 已经不归 a 了，a 不会把它放回去
+	var j dao.Job
+	require.NoError(t, db.Where("name = ?", "ranking").First(&j).Error)
+	assert.Equal(t, "b", j.Owner)
+	assert.Equal(t, uint8(domain.JobStatusRunning), j.Status)
+}
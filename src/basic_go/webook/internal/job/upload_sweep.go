@@ -0,0 +1,32 @@
+package job
+
+import (
+	"basic_go/webook/internal/domain"
+	"basic_go/webook/internal/service"
+	"context"
+	"time"
+)
+
+// UploadSweepJob 定时清理上传之后一直没用上的文件
+type UploadSweepJob struct {
+	svc     *service.UploadService
+	timeout time.Duration
+}
+
+func NewUploadSweepJob(svc *service.UploadService) *UploadSweepJob {
+	return &UploadSweepJob{
+		svc:     svc,
+		timeout: time.Minute * 10,
+	}
+}
+
+func (j *UploadSweepJob) Name() string {
+	return "upload_sweep"
+}
+
+// Exec 执行一次，由 Scheduler 调度
+func (j *UploadSweepJob) Exec(ctx context.Context, _ domain.Job) error {
+	ctx, cancel := context.WithTimeout(ctx, j.timeout)
+	defer cancel()
+	return j.svc.SweepOrphans(ctx)
+}
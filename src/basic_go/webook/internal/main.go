@@ -1,17 +1,60 @@
 package main
 
 import (
+	"basic_go/webook/internal/domain"
+	"basic_go/webook/internal/events/article"
+	"basic_go/webook/internal/events/comment"
+	"basic_go/webook/internal/events/feed"
+	"basic_go/webook/internal/events/follow"
+	"basic_go/webook/internal/events/interactive"
+	"basic_go/webook/internal/events/notification"
+	webookgrpc "basic_go/webook/internal/grpc"
+	"basic_go/webook/internal/job"
 	"basic_go/webook/internal/repository"
+	"basic_go/webook/internal/repository/cache"
 	"basic_go/webook/internal/repository/dao"
 	"basic_go/webook/internal/service"
+	"basic_go/webook/internal/service/sms"
+	"basic_go/webook/internal/service/sms/async"
+	"basic_go/webook/internal/service/sms/failover"
+	smsmemory "basic_go/webook/internal/service/sms/memory"
+	"basic_go/webook/internal/service/sms/ratelimit"
 	"basic_go/webook/internal/web"
 	"basic_go/webook/internal/web/middleware"
+	"basic_go/webook/pkg/hasher"
+	"basic_go/webook/pkg/idempotency"
+	"basic_go/webook/pkg/limiter"
+	"basic_go/webook/pkg/migrator/connpool"
+	migratorevents "basic_go/webook/pkg/migrator/events"
+	"basic_go/webook/pkg/migrator/events/fixer"
+	"basic_go/webook/pkg/migrator/scheduler"
+	"basic_go/webook/pkg/mq"
+	"basic_go/webook/pkg/mq/memory"
+	"basic_go/webook/pkg/objstore"
+	"basic_go/webook/pkg/objstore/local"
+	"basic_go/webook/pkg/payment"
+	"basic_go/webook/pkg/payment/fake"
+	"basic_go/webook/pkg/search"
+	"basic_go/webook/pkg/totp"
+	"context"
+	"crypto/rand"
+	"fmt"
+	"log"
+	"net"
+	"os"
 	"strings"
 	"time"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-contrib/sessions"
+	"github.com/gin-contrib/sessions/cookie"
+	gormsessions "github.com/gin-contrib/sessions/gorm"
+	"github.com/gin-contrib/sessions/memstore"
 	"github.com/gin-contrib/sessions/redis"
+	goredis "github.com/redis/go-redis/v9"
+	"golang.org/x/crypto/bcrypt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
 
@@ -21,24 +64,315 @@ import (
 func main() {
 
 	db := initDB()
+	redisClient := initRedis()
 
-	server := initWebServer()
-	initUserHdl(db, server)
+	client := initMQ()
+
+	authCfg := loadAuthConfig()
+	server, sch := initApp(db, redisClient, client, authCfg, initSessionStore(authCfg, db, redisAddr))
+	sch.Start(context.Background())
 	server.Run(":8080")
 }
 
-func initUserHdl(db *gorm.DB, server *gin.Engine) {
+// initApp 注册所有的路由和定时任务，定时任务要调用方自己 Start
+func initApp(db *gorm.DB, redisClient goredis.Cmdable, client mq.MQ,
+	authCfg authConfig, store sessions.Store) (*gin.Engine, *job.Scheduler) {
+	// 禁用账号的时候要让他已经登录的地方全部下线，登录校验的时候要用
+	sessSvc := service.NewSessionService(repository.NewSessionRepository(cache.NewRedisSessionCache(redisClient)))
+	server := initWebServer(sessSvc, authCfg.Mode, store, idempotency.NewRedisStore(redisClient))
+	// 迁移 users 表的时候打开
+	//db = initUserMigration(db, client)
+	us, tfs, fr := initUserHdl(db, redisClient, sessSvc, authCfg.Mode, client, server)
+	sch := initScheduler(db)
+	objStore := initObjStore(server)
+	uploadSvc := initUploadHdl(db, us, objStore, sch, server)
+	as := initArticleHdl(db, redisClient, uploadSvc, sch, client, server)
+	initFeedHdl(db, fr, client, server)
+	initPrivacyHdl(db, us, tfs, sessSvc, as, fr, objStore, sch, server)
+	initSMSService(db, redisClient, sch)
+	// 线上不对外暴露接口文档
+	if !isProd() {
+		err := web.RegisterDocs(server)
+		if err != nil {
+			panic(err)
+		}
+	}
+	return server, sch
+}
+
+// initArticleHdl 注销账号的时候要处理文章，所以返回出去
+func initArticleHdl(db *gorm.DB, redisClient goredis.Cmdable, uploadSvc *service.UploadService,
+	sch *job.Scheduler, client mq.MQ, server *gin.Engine) *service.ArticleService {
+	ar := repository.NewArticleRepository(dao.NewArticleDAO(db))
+	as := service.NewArticleService(ar, article.NewProducer(client.Producer()))
+	ir := repository.NewInteractiveRepository(dao.NewInteractiveDAO(db))
+	is := service.NewInteractiveService(ir, interactive.NewProducer(client.Producer()))
+
+	err := article.NewInteractiveReadEventConsumer(ir, client).Start()
+	if err != nil {
+		panic(err)
+	}
+
+	hdl := web.NewArticleHandler(as, is, uploadSvc)
+	hdl.RegisterRoutes(server)
+
+	initSearchHdl(ar, ir, client, server)
+	initRankingHdl(ar, ir, redisClient, sch, server)
+
+	cs := service.NewCommentService(repository.NewCommentRepository(dao.NewCommentDAO(db)), ar,
+		comment.NewProducer(client.Producer()))
+	web.NewCommentHandler(cs).RegisterRoutes(server)
+
+	ns := service.NewNotificationService(repository.NewNotificationRepository(dao.NewNotificationDAO(db)), ar)
+	err = notification.NewConsumer(ns, client).Start()
+	if err != nil {
+		panic(err)
+	}
+	web.NewNotificationHandler(ns).RegisterRoutes(server)
+
+	initRewardHdl(db, as, sch, server)
+	return as
+}
+
+func initRewardHdl(db *gorm.DB, as *service.ArticleService, sch *job.Scheduler, server *gin.Engine) {
+	accSvc := service.NewAccountService(repository.NewAccountRepository(dao.NewAccountDAO(db)))
+	web.NewAccountHandler(accSvc).RegisterRoutes(server)
+	gateway := initPaymentGateway(server)
+	if gateway == nil {
+		log.Println("没有配置支付网关，不开放打赏")
+		return
+	}
+	rs := service.NewRewardService(repository.NewRewardRepository(dao.NewRewardDAO(db)), gateway,
+		accSvc, service.DefaultRewardConfig())
+	addJob(sch, job.NewRewardReconcileJob(rs), "@every 1m")
+	web.NewRewardHandler(rs, as, gateway).RegisterRoutes(server)
+}
+
+// initPaymentGateway 线上要换成真正的支付网关，还没有接入之前返回 nil，不开放打赏。
+// 假的网关谁调 /fake_pay/pay 都能假装付了钱，只能在本地开发的时候用
+func initPaymentGateway(server *gin.Engine) payment.Gateway {
+	if isProd() {
+		return nil
+	}
+	// WEBOOK_FAKE_PAY_SECRET 回调的签名密钥
+	gateway := fake.NewGateway(loadSecret("WEBOOK_FAKE_PAY_SECRET"), "http://localhost:8080/reward/notify")
+	gateway.RegisterRoutes(server.Group("/fake_pay"))
+	return gateway
+}
+
+func initRankingHdl(ar *repository.ArticleRepository, ir *repository.InteractiveRepository,
+	redisClient goredis.Cmdable, sch *job.Scheduler, server *gin.Engine) {
+	// Redis 里面的要比计算的间隔长，任务失败几次也还有数据；本地的短一点，各个实例很快就能看到新的热榜
+	rr := repository.NewRankingRepository(cache.NewRedisRankingCache(redisClient, time.Minute*30),
+		cache.NewLocalRankingCache(time.Minute))
+	rs := service.NewRankingService(ar, ir, rr, service.DefaultRankingConfig())
+	addJob(sch, job.NewRankingJob(rs), "@every 3m")
+	web.NewRankingHandler(rs).RegisterRoutes(server)
+}
+
+// initObjStore 上传的文件和导出的数据都放在这里
+func initObjStore(server *gin.Engine) objstore.Store {
+	// 本地存磁盘，通过 /objects 访问；上线换成 s3.NewStore 对接 MinIO 或者云厂商的对象存储。
+	// WEBOOK_OBJSTORE_SECRET 私有文件链接的签名密钥，部署多个实例的话必须配成一样的
+	store := local.NewStore("./uploads", "http://localhost:8080/objects",
+		loadSecret("WEBOOK_OBJSTORE_SECRET"), service.PublicUploadPrefix())
+	store.RegisterRoutes(server.Group("/objects"))
+	//store, err := s3.NewStore(s3.Config{
+	//	Endpoint:  "localhost:9000",
+	//	AccessKey: "minioadmin",
+	//	SecretKey: "minioadmin",
+	//	Bucket:    "webook",
+	//})
+	//if err != nil {
+	//	panic(err)
+	//}
+	return store
+}
+
+func initUploadHdl(db *gorm.DB, us *service.LocalUserService, store objstore.Store, sch *job.Scheduler,
+	server *gin.Engine) *service.UploadService {
+	cfg := service.DefaultUploadConfig()
+	svc := service.NewUploadService(repository.NewUploadRepository(dao.NewUploadDAO(db)), store, cfg)
+	addJob(sch, job.NewUploadSweepJob(svc), "@hourly")
+	// multipart 除了文件还有别的字段，多留 1M
+	web.NewUploadHandler(svc, us, cfg.MaxSize+1<<20).RegisterRoutes(server)
+	return svc
+}
+
+// initPrivacyHdl 注销账号和导出数据
+func initPrivacyHdl(db *gorm.DB, us *service.LocalUserService, tfs *service.TwoFactorService,
+	sessSvc *service.SessionService, as *service.ArticleService, fr *repository.FollowRepository,
+	store objstore.Store, sch *job.Scheduler, server *gin.Engine) {
+	ds := service.NewAccountDeletionService(repository.NewAccountDeletionRepository(dao.NewAccountDeletionDAO(db)),
+		us, as, tfs, sessSvc, service.DefaultAccountDeletionConfig())
+	addJob(sch, job.NewAccountDeletionJob(ds), "@every 10m")
+
+	es := service.NewDataExportService(repository.NewDataExportRepository(dao.NewDataExportDAO(db)),
+		repository.NewUserRepository(dao.NewUserDAO(db)),
+		repository.NewArticleRepository(dao.NewArticleDAO(db)),
+		repository.NewCommentRepository(dao.NewCommentDAO(db)),
+		repository.NewInteractiveRepository(dao.NewInteractiveDAO(db)),
+		fr, store, service.DefaultDataExportConfig())
+	addJob(sch, job.NewDataExportJob(es), "@every 1m")
+	addJob(sch, job.NewDataExportSweepJob(es), "@hourly")
+
+	web.NewPrivacyHandler(ds, es).RegisterRoutes(server)
+}
+
+func initSearchHdl(ar *repository.ArticleRepository, ir *repository.InteractiveRepository,
+	client mq.MQ, server *gin.Engine) {
+	sr := repository.NewArticleSearchRepository(search.NewMemoryIndex(repository.ArticleSearchFields()))
+	ss := service.NewArticleSearchService(sr, ar, ir)
+	// 先开始消费再全量加载，加载过程中的变更不会丢
+	err := article.NewSearchSyncConsumer(sr, client).Start()
+	if err != nil {
+		panic(err)
+	}
+	go func() {
+		err := ss.Rebuild(context.Background())
+		if err != nil {
+			log.Println("加载搜索索引失败", err)
+		}
+	}()
+
+	hdl := web.NewSearchHandler(ss)
+	hdl.RegisterRoutes(server)
+}
+
+// initUserHdl 用户、二次验证和关注关系别的模块也要用，所以返回出去
+func initUserHdl(db *gorm.DB, redisClient goredis.Cmdable, sessSvc *service.SessionService,
+	authMode web.AuthMode, client mq.MQ, server *gin.Engine) (*service.LocalUserService,
+	*service.TwoFactorService, *repository.FollowRepository) {
 	ud := dao.NewUserDAO(db)
 	ur := repository.NewUserRepository(ud)
-	us := service.NewUserService(ur)
+	lr := repository.NewLoginAttemptRepository(cache.NewRedisLoginAttemptCache(redisClient))
+	guard := service.NewLoginGuard(lr, service.DefaultLoginGuardConfig())
+	h := hasher.NewChain(hasher.NewArgon2id(hasher.DefaultArgon2idParams()),
+		// 老用户的密码都是 bcrypt 的，登录成功之后会换成 argon2id
+		hasher.NewBcrypt(bcrypt.DefaultCost))
+	policy := domain.DefaultPasswordPolicy()
+	// 请求参数的校验和服务里面用同一个密码策略，注册的时候可以直接告诉前端哪个字段不对
+	err := web.InitValidator(policy)
+	if err != nil {
+		panic(err)
+	}
+	us := service.NewLocalUserService(ur, guard, policy, h)
+	tfr := repository.NewTwoFactorRepository(dao.NewTwoFactorDAO(db))
+	tfs := service.NewTwoFactorService(tfr, lr, service.DefaultTwoFactorLimit(), totp.New(nil), "webook")
 
-	hdl := web.NewUserHandler(us)
+	fr := repository.NewFollowRepository(dao.NewFollowDAO(db), cache.NewRedisFollowCache(redisClient))
+	fs := service.NewFollowService(fr, ur, follow.NewProducer(client.Producer()))
+
+	hdl := web.NewUserHandler(initUserService(us), tfs, fs, authMode)
 	hdl.RegisterRoutes(server)
+	web.NewFollowHandler(fs).RegisterRoutes(server)
+
+	adminSvc := service.NewAdminService(us, sessSvc, repository.NewAuditLogRepository(dao.NewAuditLogDAO(db)))
+	web.NewAdminHandler(adminSvc).RegisterRoutes(server)
 
 	//server.POST("/users/signup", hdl.SignUp)
 	//server.POST("/users/login", hdl.Login)
 	//server.POST("/users/edit", hdl.Edit)
 	//server.GET("/users/profile", hdl.Profile)
+	return us, tfs, fr
+}
+
+// startInternalServer 运维用的接口单独监听一个端口，只绑定内网地址，不经过登录校验。
+// 路由要在调用之前注册好。WEBOOK_INTERNAL_ADDR 不配的话只监听本机
+func startInternalServer(server *gin.Engine) {
+	addr := os.Getenv("WEBOOK_INTERNAL_ADDR")
+	if addr == "" {
+		addr = "127.0.0.1:8081"
+	}
+	go func() {
+		err := server.Run(addr)
+		if err != nil {
+			log.Println("内网服务退出了", err)
+		}
+	}()
+}
+
+// grpcConfig 用户服务的 gRPC 配置，用环境变量配
+type grpcConfig struct {
+	// WEBOOK_GRPC_ADDR 对外提供 gRPC 服务的地址，比如 :8090，不配就不启动
+	Addr string
+	// WEBOOK_USER_GRPC_TARGET 配了的话，登录注册这些调用走远程的用户服务
+	UserTarget string
+}
+
+func loadGRPCConfig() grpcConfig {
+	return grpcConfig{
+		Addr:       os.Getenv("WEBOOK_GRPC_ADDR"),
+		UserTarget: os.Getenv("WEBOOK_USER_GRPC_TARGET"),
+	}
+}
+
+// initUserService 按照配置决定用本地的用户服务还是远程的，
+// 本地的用户服务也可以通过 gRPC 给别的服务用
+func initUserService(local *service.LocalUserService) service.UserService {
+	cfg := loadGRPCConfig()
+	if cfg.Addr != "" {
+		lis, err := net.Listen("tcp", cfg.Addr)
+		if err != nil {
+			panic(err)
+		}
+		server := grpc.NewServer()
+		webookgrpc.NewUserServiceServer(local).Register(server)
+		go func() {
+			err := server.Serve(lis)
+			if err != nil {
+				log.Println("gRPC 服务退出了", err)
+			}
+		}()
+	}
+	if cfg.UserTarget == "" {
+		return local
+	}
+	// 内网调用，先不加 TLS
+	cc, err := grpc.NewClient(cfg.UserTarget, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		panic(err)
+	}
+	return webookgrpc.NewUserServiceClient(cc)
+}
+
+func initFeedHdl(db *gorm.DB, fr *repository.FollowRepository, client mq.MQ, server *gin.Engine) {
+	fs := service.NewFeedService(repository.NewFeedRepository(dao.NewFeedDAO(db)), fr,
+		service.DefaultFeedConfig())
+	err := feed.NewConsumer(fs, client).Start()
+	if err != nil {
+		panic(err)
+	}
+	web.NewFeedHandler(fs).RegisterRoutes(server)
+}
+
+// initScheduler 定时任务通过数据库抢占，多个实例部署的时候同一个任务只会在一个实例上面跑
+func initScheduler(db *gorm.DB) *job.Scheduler {
+	hostname, _ := os.Hostname()
+	svc := service.NewCronJobService(repository.NewJobRepository(dao.NewJobDAO(db)), service.CronJobConfig{
+		Owner:            fmt.Sprintf("%s-%d", hostname, os.Getpid()),
+		HeartbeatTimeout: time.Minute,
+	})
+	return job.NewScheduler(svc, job.DefaultSchedulerConfig())
+}
+
+// addJob 任务表里面没有这个任务就加上，表达式以任务表里面的为准
+func addJob(sch *job.Scheduler, e job.Executor, expression string) {
+	err := sch.AddJob(context.Background(), e, expression)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// initSMSService 从里到外：多个服务商互相兜底，限流，失败了存数据库异步重试。
+// 验证码登录接入的时候把它传给用得到的服务
+func initSMSService(db *gorm.DB, redisClient goredis.Cmdable, sch *job.Scheduler) sms.Service {
+	// 本地开发不真的发，上线换成各个服务商的实现，按优先级排好
+	var svc sms.Service = failover.NewService([]sms.Service{smsmemory.NewService()}, failover.StrategyPriority)
+	svc = ratelimit.NewService(svc, limiter.NewRedisSlidingWindowLimiter(redisClient, time.Second, 100), "sms:limit")
+	as := async.NewService(svc, repository.NewAsyncSmsRepository(dao.NewAsyncSmsDAO(db)), async.DefaultConfig())
+	addJob(sch, job.NewAsyncSmsJob(as), "@every 1m")
+	return as
 }
 
 func initDB() *gorm.DB {
@@ -54,16 +388,77 @@ func initDB() *gorm.DB {
 	return db
 }
 
-func initWebServer() *gin.Engine {
+const redisAddr = "localhost:6379"
+
+func initRedis() goredis.Cmdable {
+	return goredis.NewClient(&goredis.Options{
+		Addr: redisAddr,
+	})
+}
+
+// initUserMigration 返回双写的 DB，业务代码用它替换原本的 DB。
+// /migrator/users 下面的接口用来切换双写模式和启动校验，挂在单独的内网端口上，对外的 server 访问不到
+func initUserMigration(src *gorm.DB, client mq.MQ) *gorm.DB {
+	dst, err := gorm.Open(mysql.Open("root:root@tcp(localhost:13316)/webook_user"))
+	if err != nil {
+		panic(err)
+	}
+	err = dst.AutoMigrate(&dao.User{})
+	if err != nil {
+		panic(err)
+	}
+	pool, err := connpool.NewDoubleWritePool(src, dst, connpool.PatternSrcOnly)
+	if err != nil {
+		panic(err)
+	}
+	db, err := gorm.Open(mysql.New(mysql.Config{
+		Conn: pool,
+	}))
+	if err != nil {
+		panic(err)
+	}
+
+	const topic = "migrator_users"
+	producer := migratorevents.NewProducer(client.Producer(), topic)
+	sch := scheduler.NewScheduler[dao.User](src, dst, pool, producer)
+	internal := gin.Default()
+	sch.RegisterRoutes(internal.Group("/migrator/users"))
+	startInternalServer(internal)
+	err = fixer.NewConsumer[dao.User](client, topic, src, dst).Start()
+	if err != nil {
+		panic(err)
+	}
+	return db
+}
+
+func initMQ() mq.MQ {
+	// 本地跑用内存实现，不需要启动 kafka
+	return memory.NewBroker(1024)
+
+	//client, err := kafka.NewClient([]string{"localhost:9094"})
+	//if err != nil {
+	//	panic(err)
+	//}
+	//return client
+}
+
+func initWebServer(sessSvc *service.SessionService, authMode web.AuthMode, store sessions.Store,
+	idemStore idempotency.Store) *gin.Engine {
 	server := gin.Default()
+	// 默认 gin 信任所有代理，谁都可以伪造 X-Forwarded-For，登录限流按 IP 算就没用了。
+	// 前面有 Nginx 之类的话用 WEBOOK_TRUSTED_PROXIES 配上它们的地址，逗号分隔；不配就直接用连接的地址
+	err := server.SetTrustedProxies(loadTrustedProxies())
+	if err != nil {
+		panic(err)
+	}
 
 	server.Use(cors.New(cors.Config{
 		//AllowAllOrigins:  true,
 		//AllowOrigins:     []string{"https://localhost:3000"},
 		//AllowMethods:     []string{"PUT", "PATCH"}, //不用配，允许所有方法就可以
-		AllowHeaders: []string{"Content-Type", "Authorization"},
+		AllowHeaders: []string{"Content-Type", "Authorization", middleware.HeaderIdempotencyKey},
 		// 这个是允许前端访问你的后端响应中带的头部
-		ExposeHeaders: []string{"x-jwt-token"},
+		ExposeHeaders: []string{"x-jwt-token", "x-2fa-token", middleware.HeaderIdempotentReplayed},
 		//AllowHeaders:     []string{"content-type"},
 
 		//ExposeHeaders:    []string{"Content-Length"},
@@ -80,34 +475,120 @@ func initWebServer() *gin.Engine {
 		println("这是我的middleware")
 	})
 
-	useJWT(server)
-	//useSession(server)
+	if authMode == web.AuthModeSession {
+		useSession(server, sessSvc, store)
+	} else {
+		useJWT(server, sessSvc)
+	}
+	// 要在登录校验后面，按照用户区分
+	idem := &middleware.IdempotencyMiddlewareBuilder{
+		Store: idemStore,
+		// 只管重复执行会多出数据的接口。登录之类的本来就可以重试，而且 token 在响应头里面，重放不了
+		Paths:          []string{"/users/signup", "/articles/publish", "/reward/article"},
+		Expiration:     time.Hour * 24,
+		LockExpiration: time.Minute,
+	}
+	server.Use(idem.Build())
 
 	return server
 }
 
-func useJWT(server *gin.Engine) {
-	login := middleware.LoginJWTMiddlewareBuilder{}
+func useJWT(server *gin.Engine, sessSvc *service.SessionService) {
+	login := middleware.LoginJWTMiddlewareBuilder{Sessions: sessSvc}
 	server.Use(login.CheckLogin())
 }
 
-func useSession(server *gin.Engine) {
-	login := &middleware.LoginMiddlewareBuilder{}
-	// 存储数据的，也就是你的 userId 存哪里
-	// 刚开始先直接存 cookie
-	//store := cookie.NewStore([]byte("secret"))
-
-	// 基于内存的实现，第一个参数是 authentication key ,用于身份认证,最好是32 或者 64 位
-	// 第二个参数是 encryption key，加密数据用的
-	//store := memstore.NewStore([]byte("qfqwbxb9i5C9G_fXL:UNfU>Pm0MVyh7?*):E}WUNX2v4ww=^!k9K~j:1fXc!1VrF"),
-	//	[]byte("aNaL?A*dqgo#oE3aPjmU,AE:D1bxNtPtK4P%,kXp.*Auqpd>}c!>iun=M?AhA5XW"))
+func useSession(server *gin.Engine, sessSvc *service.SessionService, store sessions.Store) {
+	login := &middleware.LoginMiddlewareBuilder{Sessions: sessSvc}
+	// sessions.Sessions("ssid", store) 是初始化
+	server.Use(sessions.Sessions("ssid", store), login.CheckLogin())
+}
 
-	store, err := redis.NewStore(16, "tcp", "localhost:6379", "", "",
-		[]byte("qfqwbxb9i5C9G_fXL:UNfU>Pm0MVyh7?*):E}WUNX2v4ww=^!k9K~j:1fXc!1VrF"),
-		[]byte("qfqwbxb9i5C9G_fXL:UNfU>Pm0MVyh7?*):E}WUNX2v4ww=^!k9K~j:1fXc!1VrA"))
-	if err != nil {
+// loadSecret 签名密钥从环境变量 env 读，不配就每次启动随机生成一个。
+// 不能写死在代码里面，代码是公开的，谁都能伪造签名
+func loadSecret(env string) []byte {
+	secret := []byte(os.Getenv(env))
+	if len(secret) > 0 {
+		return secret
+	}
+	secret = make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
 		panic(err)
 	}
-	server.Use(sessions.Sessions("ssid", store), login.CheckLogin())
-	// sessions.Sessions("ssid", store) 是初始化
+	return secret
+}
+
+func loadTrustedProxies() []string {
+	val := os.Getenv("WEBOOK_TRUSTED_PROXIES")
+	if val == "" {
+		return nil
+	}
+	return strings.Split(val, ",")
+}
+
+// isProd WEBOOK_PROFILE 线上配成 prod，本地开发用的东西都不能暴露出去
+func isProd() bool {
+	return os.Getenv("WEBOOK_PROFILE") == "prod"
+}
+
+// authConfig 登录态的配置，用环境变量配
+type authConfig struct {
+	// WEBOOK_AUTH_MODE jwt 或者 session，不配就是 jwt
+	Mode web.AuthMode
+	// WEBOOK_SESSION_STORE session 存在哪里：cookie、memory、redis 或者 gorm，不配就是 redis。
+	// cookie 不用存服务端，但是数据都在客户端；memory 只能单实例用
+	SessionStore string
+}
+
+func loadAuthConfig() authConfig {
+	cfg := authConfig{
+		Mode:         web.AuthMode(os.Getenv("WEBOOK_AUTH_MODE")),
+		SessionStore: os.Getenv("WEBOOK_SESSION_STORE"),
+	}
+	if cfg.Mode == "" {
+		cfg.Mode = web.AuthModeJWT
+	}
+	if cfg.SessionStore == "" {
+		cfg.SessionStore = "redis"
+	}
+	return cfg
+}
+
+var (
+	// sessionAuthKey 用于身份认证，最好是 32 或者 64 位
+	sessionAuthKey = []byte("qfqwbxb9i5C9G_fXL:UNfU>Pm0MVyh7?*):E}WUNX2v4ww=^!k9K~j:1fXc!1VrF")
+	// sessionEncryptKey 加密数据用的，AES 只支持 16、24 或者 32 位
+	sessionEncryptKey = []byte("aNaL?A*dqgo#oE3aPjmU,AE:D1bxNtPt")
+)
+
+// initSessionStore 用 JWT 的时候不需要 session，返回 nil
+func initSessionStore(cfg authConfig, db *gorm.DB, redisAddr string) sessions.Store {
+	if cfg.Mode != web.AuthModeSession {
+		return nil
+	}
+	var store sessions.Store
+	switch cfg.SessionStore {
+	case "cookie":
+		store = cookie.NewStore(sessionAuthKey, sessionEncryptKey)
+	case "memory":
+		store = memstore.NewStore(sessionAuthKey, sessionEncryptKey)
+	case "gorm":
+		// 会自动建 sessions 表，并且定期清理过期的
+		store = gormsessions.NewStore(db, true, sessionAuthKey, sessionEncryptKey)
+	case "redis":
+		var err error
+		store, err = redis.NewStore(16, "tcp", redisAddr, "", "", sessionAuthKey, sessionEncryptKey)
+		if err != nil {
+			panic(err)
+		}
+	default:
+		panic("不支持的 session 存储：" + cfg.SessionStore)
+	}
+	store.Options(sessions.Options{
+		Path: "/",
+		// 登录校验的时候会续期
+		MaxAge:   15 * 60,
+		HttpOnly: true,
+	})
+	return store
 }
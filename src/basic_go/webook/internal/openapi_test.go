@@ -0,0 +1,83 @@
+package main
+
+import (
+	"basic_go/webook/internal/web"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestOpenAPICoverage 注册了路由就要在 Operations 里面写上文档，文档里面也不能有不存在的接口
+func TestOpenAPICoverage(t *testing.T) {
+	app := newTestApp(t)
+	spec, err := web.NewOpenAPISpec()
+	require.NoError(t, err)
+	require.NoError(t, spec.Validate(context.Background()))
+
+	registered := make(map[string]bool)
+	for _, r := range app.server.Routes() {
+		// 文档自己的路由，还有本地开发才有的假支付
+		if strings.HasPrefix(r.Path, "/docs") || strings.HasPrefix(r.Path, "/fake_pay") {
+			continue
+		}
+		registered[r.Method+" "+r.Path] = true
+		path, _ := web.OpenAPIPath(r.Path)
+		item := spec.Paths.Value(path)
+		if item == nil || item.GetOperation(r.Method) == nil {
+			t.Errorf("%s %s 没有写文档", r.Method, r.Path)
+		}
+	}
+	for _, op := range web.Operations() {
+		assert.True(t, registered[op.Method+" "+op.Path], "%s %s 没有注册路由", op.Method, op.Path)
+	}
+}
+
+func TestOpenAPIDocs(t *testing.T) {
+	app := newTestApp(t)
+	c := app.client()
+
+	// 不用登录也能看
+	resp := c.do(http.MethodGet, "/docs", nil)
+	require.Equal(t, http.StatusOK, resp.Code)
+	assert.Contains(t, resp.Body.String(), "/docs/openapi.json")
+
+	resp = c.do(http.MethodGet, "/docs/openapi.json", nil)
+	require.Equal(t, http.StatusOK, resp.Code)
+	var spec struct {
+		Paths map[string]map[string]struct {
+			RequestBody struct {
+				Content map[string]struct {
+					Schema struct {
+						Properties map[string]any `json:"properties"`
+					} `json:"schema"`
+				} `json:"content"`
+			} `json:"requestBody"`
+		} `json:"paths"`
+	}
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &spec))
+	signup := spec.Paths["/users/signup"]["post"].RequestBody.Content["application/json"].Schema.Properties
+	assert.Contains(t, signup, "email")
+	assert.Contains(t, signup, "password")
+	assert.Contains(t, signup, "confirmPassword")
+	assert.Contains(t, spec.Paths, "/articles/detail/{id}")
+}
+
+// TestProdProfile 线上不能有接口文档和假的支付
+func TestProdProfile(t *testing.T) {
+	t.Setenv("WEBOOK_PROFILE", "prod")
+	app := newTestApp(t)
+	c := app.mustLogin("a@qq.com", testPassword)
+	for _, path := range []string{"/docs", "/fake_pay/pay", "/reward/article"} {
+		method := http.MethodPost
+		if path == "/docs" {
+			method = http.MethodGet
+		}
+		resp := c.do(method, path, map[string]string{"outTradeNo": "reward_1"})
+		assert.Equal(t, http.StatusNotFound, resp.Code, path)
+	}
+}
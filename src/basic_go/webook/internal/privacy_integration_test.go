@@ -0,0 +1,95 @@
+package main
+
+import (
+	"basic_go/webook/internal/domain"
+	"basic_go/webook/internal/web"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccountDeletion(t *testing.T) {
+	app := newTestApp(t)
+	c := app.mustLogin("a@qq.com", testPassword)
+
+	// 没申请过
+	var status struct {
+		Code int
+		Data *web.AccountDeletionVO
+	}
+	decode(t, c.do(http.MethodGet, "/users/delete/status", nil), &status)
+	assert.Equal(t, 0, status.Code)
+	assert.Nil(t, status.Data)
+
+	resp := c.do(http.MethodPost, "/users/delete", web.DeleteAccountReq{Password: testPassword, ArticlePolicy: "delete"})
+	res := decodeFieldErrors(t, resp.Body.Bytes())
+	require.Len(t, res.Data, 1)
+	assert.Equal(t, "articlePolicy", res.Data[0].Field)
+
+	var result web.Result
+	decode(t, c.do(http.MethodPost, "/users/delete",
+		web.DeleteAccountReq{Password: testPassword + "1", ArticlePolicy: "unpublish"}), &result)
+	assert.Equal(t, web.Result{Code: 4, Msg: "密码不对"}, result)
+
+	var created struct {
+		Code int
+		Data web.AccountDeletionVO
+	}
+	decode(t, c.do(http.MethodPost, "/users/delete",
+		web.DeleteAccountReq{Password: testPassword, ArticlePolicy: "reattribute"}), &created)
+	require.Equal(t, 0, created.Code)
+	assert.Equal(t, uint8(domain.AccountDeletionStatusPending), created.Data.Status)
+	assert.Equal(t, "reattribute", created.Data.ArticlePolicy)
+	decode(t, c.do(http.MethodPost, "/users/delete",
+		web.DeleteAccountReq{Password: testPassword, ArticlePolicy: "reattribute"}), &result)
+	assert.Equal(t, web.Result{Code: 4, Msg: "已经申请注销了"}, result)
+
+	decode(t, c.do(http.MethodGet, "/users/delete/status", nil), &status)
+	require.NotNil(t, status.Data)
+	assert.Equal(t, created.Data, *status.Data)
+
+	// 冷静期里面还能正常用，也能取消
+	decode(t, c.do(http.MethodPost, "/users/delete/cancel", nil), &result)
+	assert.Equal(t, 0, result.Code)
+	decode(t, c.do(http.MethodPost, "/users/delete/cancel", nil), &result)
+	assert.Equal(t, web.Result{Code: 4, Msg: "没有可以取消的注销申请"}, result)
+	decode(t, c.do(http.MethodGet, "/users/delete/status", nil), &status)
+	require.NotNil(t, status.Data)
+	assert.Equal(t, uint8(domain.AccountDeletionStatusCancelled), status.Data.Status)
+}
+
+func TestDataExport(t *testing.T) {
+	app := newTestApp(t)
+	c := app.mustLogin("a@qq.com", testPassword)
+
+	var export struct {
+		Code int
+		Data web.DataExportVO
+	}
+	decode(t, c.do(http.MethodPost, "/users/export", nil), &export)
+	require.Equal(t, 0, export.Code)
+	assert.Equal(t, uint8(domain.DataExportStatusPending), export.Data.Status)
+	id := export.Data.Id
+
+	// 还在排队，返回同一个
+	decode(t, c.do(http.MethodPost, "/users/export", nil), &export)
+	assert.Equal(t, id, export.Data.Id)
+	decode(t, c.do(http.MethodPost, "/users/export/detail", web.ExportDetailReq{Id: id}), &export)
+	assert.Equal(t, uint8(domain.DataExportStatusPending), export.Data.Status)
+	assert.Empty(t, export.Data.URL)
+
+	other := app.mustLogin("b@qq.com", testPassword)
+	var result web.Result
+	decode(t, other.do(http.MethodPost, "/users/export/detail", web.ExportDetailReq{Id: id}), &result)
+	assert.Equal(t, web.Result{Code: 4, Msg: "导出记录不存在"}, result)
+}
+
+// decode JSON 接口都是 HTTP 200，结果在 Result 里面
+func decode(t *testing.T, resp *httptest.ResponseRecorder, v any) {
+	require.Equal(t, http.StatusOK, resp.Code)
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), v), resp.Body.String())
+}
@@ -0,0 +1,86 @@
+package repository
+
+import (
+	"basic_go/webook/internal/domain"
+	"basic_go/webook/internal/repository/dao"
+	"context"
+	"time"
+)
+
+var (
+	ErrInsufficientBalance = dao.ErrInsufficientBalance
+	ErrDuplicatePosting    = dao.ErrDuplicatePosting
+)
+
+type AccountRepository struct {
+	dao *dao.AccountDAO
+}
+
+func NewAccountRepository(dao *dao.AccountDAO) *AccountRepository {
+	return &AccountRepository{
+		dao: dao,
+	}
+}
+
+// Post 同一个 Biz + BizId 已经记过账的返回 ErrDuplicatePosting
+func (repo *AccountRepository) Post(ctx context.Context, p domain.Posting) error {
+	return repo.dao.Post(ctx, dao.AccountTransaction{
+		Biz:    p.Biz,
+		BizId:  p.BizId,
+		Amount: p.Amount,
+		Memo:   p.Memo,
+	}, repo.toEntityKey(p.Debit), repo.toEntityKey(p.Credit))
+}
+
+// Balance 还没有账户的返回 0
+func (repo *AccountRepository) Balance(ctx context.Context, key domain.AccountKey) (int64, error) {
+	a, err := repo.dao.FindAccount(ctx, repo.toEntityKey(key))
+	if err == dao.ErrRecordNotFound {
+		return 0, nil
+	}
+	return a.Balance, err
+}
+
+func (repo *AccountRepository) FindEntries(ctx context.Context, key domain.AccountKey,
+	q domain.StatementQuery) ([]domain.AccountEntry, error) {
+	var start, end int64
+	if !q.Start.IsZero() {
+		start = q.Start.UnixMilli()
+	}
+	if !q.End.IsZero() {
+		end = q.End.UnixMilli()
+	}
+	es, err := repo.dao.FindEntries(ctx, repo.toEntityKey(key), start, end, q.MaxId, q.Limit)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]domain.AccountEntry, 0, len(es))
+	for _, e := range es {
+		res = append(res, repo.entryToDomain(e))
+	}
+	return res, nil
+}
+
+func (repo *AccountRepository) toEntityKey(key domain.AccountKey) dao.AccountKey {
+	return dao.AccountKey{
+		Uid:  key.Uid,
+		Type: uint8(key.Type),
+	}
+}
+
+func (repo *AccountRepository) entryToDomain(e dao.AccountEntry) domain.AccountEntry {
+	return domain.AccountEntry{
+		Id: e.Id,
+		Account: domain.AccountKey{
+			Uid:  e.Uid,
+			Type: domain.AccountType(e.Type),
+		},
+		Biz:       e.Biz,
+		BizId:     e.BizId,
+		Direction: domain.EntryDirection(e.Direction),
+		Amount:    e.Amount,
+		Balance:   e.Balance,
+		Memo:      e.Memo,
+		Ctime:     time.UnixMilli(e.Ctime),
+	}
+}
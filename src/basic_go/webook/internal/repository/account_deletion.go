@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"basic_go/webook/internal/domain"
+	"basic_go/webook/internal/repository/dao"
+	"context"
+	"time"
+)
+
+var ErrAccountDeletionNotFound = dao.ErrRecordNotFound
+
+type AccountDeletionRepository struct {
+	dao *dao.AccountDeletionDAO
+}
+
+func NewAccountDeletionRepository(dao *dao.AccountDeletionDAO) *AccountDeletionRepository {
+	return &AccountDeletionRepository{
+		dao: dao,
+	}
+}
+
+// Save 新的申请，之前取消过的会被覆盖
+func (repo *AccountDeletionRepository) Save(ctx context.Context, d domain.AccountDeletion) error {
+	return repo.dao.Upsert(ctx, dao.AccountDeletion{
+		Uid:           d.Uid,
+		ArticlePolicy: uint8(d.ArticlePolicy),
+		ScheduledAt:   d.ScheduledAt.UnixMilli(),
+	})
+}
+
+func (repo *AccountDeletionRepository) FindByUid(ctx context.Context, uid int64) (domain.AccountDeletion, error) {
+	d, err := repo.dao.FindByUid(ctx, uid)
+	if err != nil {
+		return domain.AccountDeletion{}, err
+	}
+	return repo.toDomain(d), nil
+}
+
+// Cancel 返回 false 代表没有在冷静期里面的申请
+func (repo *AccountDeletionRepository) Cancel(ctx context.Context, uid int64) (bool, error) {
+	return repo.dao.Cancel(ctx, uid)
+}
+
+func (repo *AccountDeletionRepository) FindDue(ctx context.Context, now time.Time,
+	startId int64, limit int) ([]domain.AccountDeletion, error) {
+	ds, err := repo.dao.FindDue(ctx, now.UnixMilli(), startId, limit)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]domain.AccountDeletion, 0, len(ds))
+	for _, d := range ds {
+		res = append(res, repo.toDomain(d))
+	}
+	return res, nil
+}
+
+// MarkProcessing 返回 false 代表已经被取消了
+func (repo *AccountDeletionRepository) MarkProcessing(ctx context.Context, id int64) (bool, error) {
+	return repo.dao.MarkProcessing(ctx, id)
+}
+
+func (repo *AccountDeletionRepository) MarkDone(ctx context.Context, id int64) error {
+	return repo.dao.MarkDone(ctx, id)
+}
+
+func (repo *AccountDeletionRepository) toDomain(d dao.AccountDeletion) domain.AccountDeletion {
+	return domain.AccountDeletion{
+		Id:            d.Id,
+		Uid:           d.Uid,
+		ArticlePolicy: domain.ArticlePolicy(d.ArticlePolicy),
+		Status:        domain.AccountDeletionStatus(d.Status),
+		ScheduledAt:   time.UnixMilli(d.ScheduledAt),
+		Ctime:         time.UnixMilli(d.Ctime),
+		Utime:         time.UnixMilli(d.Utime),
+	}
+}
@@ -0,0 +1,150 @@
+package repository
+
+import (
+	"basic_go/webook/internal/domain"
+	"basic_go/webook/internal/repository/dao"
+	"context"
+	"time"
+)
+
+var ErrArticleNotFound = dao.ErrArticleNotFound
+
+type ArticleRepository struct {
+	dao *dao.ArticleDAO
+}
+
+func NewArticleRepository(dao *dao.ArticleDAO) *ArticleRepository {
+	return &ArticleRepository{
+		dao: dao,
+	}
+}
+
+func (repo *ArticleRepository) Create(ctx context.Context, art domain.Article) (int64, error) {
+	return repo.dao.Insert(ctx, repo.toEntity(art))
+}
+
+func (repo *ArticleRepository) Update(ctx context.Context, art domain.Article) error {
+	return repo.dao.UpdateById(ctx, repo.toEntity(art))
+}
+
+func (repo *ArticleRepository) Sync(ctx context.Context, art domain.Article) (int64, error) {
+	return repo.dao.Sync(ctx, repo.toEntity(art))
+}
+
+func (repo *ArticleRepository) SyncStatus(ctx context.Context, uid int64, id int64, status domain.ArticleStatus) error {
+	return repo.dao.SyncStatus(ctx, uid, id, status.ToUint8())
+}
+
+func (repo *ArticleRepository) TransferAuthor(ctx context.Context, from int64, to int64) error {
+	return repo.dao.TransferAuthor(ctx, from, to)
+}
+
+func (repo *ArticleRepository) GetByAuthor(ctx context.Context, uid int64, offset int, limit int) ([]domain.Article, error) {
+	arts, err := repo.dao.GetByAuthor(ctx, uid, offset, limit)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]domain.Article, 0, len(arts))
+	for _, art := range arts {
+		res = append(res, repo.toDomain(art))
+	}
+	return res, nil
+}
+
+func (repo *ArticleRepository) GetById(ctx context.Context, id int64) (domain.Article, error) {
+	art, err := repo.dao.GetById(ctx, id)
+	if err == dao.ErrRecordNotFound {
+		return domain.Article{}, ErrArticleNotFound
+	}
+	if err != nil {
+		return domain.Article{}, err
+	}
+	return repo.toDomain(art), nil
+}
+
+func (repo *ArticleRepository) GetPubById(ctx context.Context, id int64) (domain.Article, error) {
+	art, err := repo.dao.GetPubById(ctx, id)
+	if err == dao.ErrRecordNotFound {
+		return domain.Article{}, ErrArticleNotFound
+	}
+	if err != nil {
+		return domain.Article{}, err
+	}
+	return repo.toDomain(dao.Article(art)), nil
+}
+
+// GetPubByIds 返回的是 ID 到文章的映射，找不到的不在里面
+func (repo *ArticleRepository) GetPubByIds(ctx context.Context, ids []int64) (map[int64]domain.Article, error) {
+	arts, err := repo.dao.GetPubByIds(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	res := make(map[int64]domain.Article, len(arts))
+	for _, art := range arts {
+		res[art.Id] = repo.toDomain(dao.Article(art))
+	}
+	return res, nil
+}
+
+// ListPub 按照 ID 从小到大遍历已发表的文章
+func (repo *ArticleRepository) ListPub(ctx context.Context, startId int64, limit int) ([]domain.Article, error) {
+	arts, err := repo.dao.ListPub(ctx, startId, limit, domain.ArticleStatusPublished.ToUint8())
+	if err != nil {
+		return nil, err
+	}
+	res := make([]domain.Article, 0, len(arts))
+	for _, art := range arts {
+		res = append(res, repo.toDomain(dao.Article(art)))
+	}
+	return res, nil
+}
+
+// ListPubByAuthor 按照 ID 从小到大遍历 uid 已发表的文章
+func (repo *ArticleRepository) ListPubByAuthor(ctx context.Context, uid int64, startId int64, limit int) ([]domain.Article, error) {
+	arts, err := repo.dao.ListPubByAuthor(ctx, uid, startId, limit, domain.ArticleStatusPublished.ToUint8())
+	if err != nil {
+		return nil, err
+	}
+	res := make([]domain.Article, 0, len(arts))
+	for _, art := range arts {
+		res = append(res, repo.toDomain(dao.Article(art)))
+	}
+	return res, nil
+}
+
+// ListPubSince 按照 ID 从小到大遍历 since 之后有过改动的已发表的文章
+func (repo *ArticleRepository) ListPubSince(ctx context.Context, since time.Time, startId int64, limit int) ([]domain.Article, error) {
+	arts, err := repo.dao.ListPubSince(ctx, since.UnixMilli(), startId, limit, domain.ArticleStatusPublished.ToUint8())
+	if err != nil {
+		return nil, err
+	}
+	res := make([]domain.Article, 0, len(arts))
+	for _, art := range arts {
+		res = append(res, repo.toDomain(dao.Article(art)))
+	}
+	return res, nil
+}
+
+func (repo *ArticleRepository) toEntity(art domain.Article) dao.Article {
+	return dao.Article{
+		Id:       art.Id,
+		Title:    art.Title,
+		Content:  art.Content,
+		AuthorId: art.Author.Id,
+		Status:   art.Status.ToUint8(),
+	}
+}
+
+func (repo *ArticleRepository) toDomain(art dao.Article) domain.Article {
+	return domain.Article{
+		Id:      art.Id,
+		Title:   art.Title,
+		Content: art.Content,
+		Author: domain.Author{
+			Id: art.AuthorId,
+		},
+		Status: domain.ArticleStatus(art.Status),
+		Ctime:  time.UnixMilli(art.Ctime),
+		Utime:  time.UnixMilli(art.Utime),
+	}
+}
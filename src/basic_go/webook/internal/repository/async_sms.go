@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"basic_go/webook/internal/domain"
+	"basic_go/webook/internal/repository/dao"
+	"context"
+	"encoding/json"
+	"time"
+)
+
+var ErrNoAsyncSms = dao.ErrRecordNotFound
+
+type AsyncSmsRepository struct {
+	dao *dao.AsyncSmsDAO
+}
+
+func NewAsyncSmsRepository(dao *dao.AsyncSmsDAO) *AsyncSmsRepository {
+	return &AsyncSmsRepository{
+		dao: dao,
+	}
+}
+
+type asyncSmsConfig struct {
+	TplId   string
+	Args    []string
+	Numbers []string
+}
+
+func (repo *AsyncSmsRepository) Add(ctx context.Context, s domain.AsyncSms) error {
+	cfg, err := json.Marshal(asyncSmsConfig{
+		TplId:   s.TplId,
+		Args:    s.Args,
+		Numbers: s.Numbers,
+	})
+	if err != nil {
+		return err
+	}
+	return repo.dao.Insert(ctx, dao.AsyncSms{
+		Config:   string(cfg),
+		RetryMax: s.RetryMax,
+	})
+}
+
+// PreemptWaiting 抢一条上一次尝试早于 before 的，exclude 里面的不抢，没有的话返回 ErrNoAsyncSms
+func (repo *AsyncSmsRepository) PreemptWaiting(ctx context.Context, before time.Time, exclude []int64) (domain.AsyncSms, error) {
+	s, err := repo.dao.PreemptWaiting(ctx, before.UnixMilli(), exclude)
+	if err != nil {
+		return domain.AsyncSms{}, err
+	}
+	var cfg asyncSmsConfig
+	err = json.Unmarshal([]byte(s.Config), &cfg)
+	if err != nil {
+		return domain.AsyncSms{}, err
+	}
+	return domain.AsyncSms{
+		Id:       s.Id,
+		TplId:    cfg.TplId,
+		Args:     cfg.Args,
+		Numbers:  cfg.Numbers,
+		RetryMax: s.RetryMax,
+	}, nil
+}
+
+func (repo *AsyncSmsRepository) ReportResult(ctx context.Context, id int64, err error) error {
+	if err == nil {
+		return repo.dao.MarkSuccess(ctx, id)
+	}
+	return repo.dao.MarkFailed(ctx, id)
+}
@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"basic_go/webook/internal/domain"
+	"basic_go/webook/internal/repository/dao"
+	"context"
+	"encoding/json"
+	"time"
+)
+
+type AuditLogRepository struct {
+	dao *dao.AuditLogDAO
+}
+
+func NewAuditLogRepository(dao *dao.AuditLogDAO) *AuditLogRepository {
+	return &AuditLogRepository{
+		dao: dao,
+	}
+}
+
+func (repo *AuditLogRepository) Create(ctx context.Context, l domain.AuditLog) (int64, error) {
+	detail, err := json.Marshal(l.Detail)
+	if err != nil {
+		return 0, err
+	}
+	return repo.dao.Insert(ctx, dao.AuditLog{
+		OperatorId: l.OperatorId,
+		TargetType: l.TargetType,
+		TargetId:   l.TargetId,
+		Action:     l.Action,
+		Reason:     l.Reason,
+		Detail:     string(detail),
+		Ip:         l.Ip,
+	})
+}
+
+func (repo *AuditLogRepository) Find(ctx context.Context, q domain.AuditLogQuery) ([]domain.AuditLog, error) {
+	ls, err := repo.dao.Find(ctx, q.OperatorId, q.TargetType, q.TargetId, q.MaxId, q.Limit)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]domain.AuditLog, 0, len(ls))
+	for _, l := range ls {
+		res = append(res, repo.toDomain(l))
+	}
+	return res, nil
+}
+
+func (repo *AuditLogRepository) toDomain(l dao.AuditLog) domain.AuditLog {
+	var detail map[string]any
+	// 解析不了也不影响查看别的字段
+	_ = json.Unmarshal([]byte(l.Detail), &detail)
+	return domain.AuditLog{
+		Id:         l.Id,
+		OperatorId: l.OperatorId,
+		TargetType: l.TargetType,
+		TargetId:   l.TargetId,
+		Action:     l.Action,
+		Reason:     l.Reason,
+		Detail:     detail,
+		Ip:         l.Ip,
+		Ctime:      time.UnixMilli(l.Ctime),
+	}
+}
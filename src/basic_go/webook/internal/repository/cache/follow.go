@@ -0,0 +1,91 @@
+package cache
+
+import (
+	"context"
+	_ "embed"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+//go:embed lua/incr_follow_cnt.lua
+var luaIncrFollowCnt string
+
+// ErrKeyNotExist 缓存里面没有
+var ErrKeyNotExist = redis.Nil
+
+const (
+	fieldFollowerCnt = "follower_cnt"
+	fieldFolloweeCnt = "followee_cnt"
+)
+
+// FollowStats 粉丝数和关注数
+type FollowStats struct {
+	Followers int64
+	Followees int64
+}
+
+type FollowCache interface {
+	// GetStats 没有缓存的时候返回 ErrKeyNotExist
+	GetStats(ctx context.Context, uid int64) (FollowStats, error)
+	SetStats(ctx context.Context, uid int64, stats FollowStats) error
+	// Follow follower 关注了 followee，两个人的计数都要改。没有缓存的不会改
+	Follow(ctx context.Context, follower, followee int64) error
+	Unfollow(ctx context.Context, follower, followee int64) error
+}
+
+type RedisFollowCache struct {
+	client     redis.Cmdable
+	expiration time.Duration
+}
+
+func NewRedisFollowCache(client redis.Cmdable) *RedisFollowCache {
+	return &RedisFollowCache{
+		client:     client,
+		expiration: time.Hour * 24,
+	}
+}
+
+func (c *RedisFollowCache) GetStats(ctx context.Context, uid int64) (FollowStats, error) {
+	vals, err := c.client.HGetAll(ctx, c.key(uid)).Result()
+	if err != nil {
+		return FollowStats{}, err
+	}
+	if len(vals) == 0 {
+		return FollowStats{}, ErrKeyNotExist
+	}
+	var res FollowStats
+	res.Followers, _ = strconv.ParseInt(vals[fieldFollowerCnt], 10, 64)
+	res.Followees, _ = strconv.ParseInt(vals[fieldFolloweeCnt], 10, 64)
+	return res, nil
+}
+
+func (c *RedisFollowCache) SetStats(ctx context.Context, uid int64, stats FollowStats) error {
+	key := c.key(uid)
+	pipe := c.client.TxPipeline()
+	pipe.HSet(ctx, key, fieldFollowerCnt, stats.Followers, fieldFolloweeCnt, stats.Followees)
+	pipe.Expire(ctx, key, c.expiration)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (c *RedisFollowCache) Follow(ctx context.Context, follower, followee int64) error {
+	return c.incr(ctx, follower, followee, 1)
+}
+
+func (c *RedisFollowCache) Unfollow(ctx context.Context, follower, followee int64) error {
+	return c.incr(ctx, follower, followee, -1)
+}
+
+func (c *RedisFollowCache) incr(ctx context.Context, follower, followee int64, delta int) error {
+	err := c.client.Eval(ctx, luaIncrFollowCnt, []string{c.key(follower)}, fieldFolloweeCnt, delta).Err()
+	if err != nil {
+		return err
+	}
+	return c.client.Eval(ctx, luaIncrFollowCnt, []string{c.key(followee)}, fieldFollowerCnt, delta).Err()
+}
+
+func (c *RedisFollowCache) key(uid int64) string {
+	return "follow:stats:" + strconv.FormatInt(uid, 10)
+}
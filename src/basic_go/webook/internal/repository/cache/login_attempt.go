@@ -0,0 +1,86 @@
+package cache
+
+import (
+	"context"
+	_ "embed"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	//go:embed lua/incr_login_failure.lua
+	luaIncrLoginFailure string
+	//go:embed lua/block_login.lua
+	luaBlockLogin string
+)
+
+// LoginAttempt 某个 key（账号或者 IP）的登录失败记录
+type LoginAttempt struct {
+	// 计数窗口内的失败次数
+	Failures int64
+	// 在这个时间之前都不允许登录，零值代表没有被限制
+	BlockedUntil time.Time
+}
+
+// LoginAttemptCache 记录登录失败的情况
+type LoginAttemptCache interface {
+	Get(ctx context.Context, key string) (LoginAttempt, error)
+	// IncrFailure 失败次数 +1，返回最新的失败次数。
+	// window 是计数窗口，从第一次失败开始算
+	IncrFailure(ctx context.Context, key string, window time.Duration) (int64, error)
+	// Block 在 until 之前禁止 key 登录
+	Block(ctx context.Context, key string, until time.Time) error
+	// Delete 清空 key 的失败记录，包括锁定状态
+	Delete(ctx context.Context, key string) error
+}
+
+type RedisLoginAttemptCache struct {
+	client redis.Cmdable
+}
+
+func NewRedisLoginAttemptCache(client redis.Cmdable) *RedisLoginAttemptCache {
+	return &RedisLoginAttemptCache{
+		client: client,
+	}
+}
+
+func (c *RedisLoginAttemptCache) Get(ctx context.Context, key string) (LoginAttempt, error) {
+	vals, err := c.client.HMGet(ctx, c.key(key), "cnt", "until").Result()
+	if err != nil {
+		return LoginAttempt{}, err
+	}
+	var res LoginAttempt
+	if cnt, ok := vals[0].(string); ok {
+		res.Failures, err = strconv.ParseInt(cnt, 10, 64)
+		if err != nil {
+			return LoginAttempt{}, err
+		}
+	}
+	if until, ok := vals[1].(string); ok {
+		ms, err := strconv.ParseInt(until, 10, 64)
+		if err != nil {
+			return LoginAttempt{}, err
+		}
+		res.BlockedUntil = time.UnixMilli(ms)
+	}
+	return res, nil
+}
+
+func (c *RedisLoginAttemptCache) IncrFailure(ctx context.Context, key string, window time.Duration) (int64, error) {
+	return c.client.Eval(ctx, luaIncrLoginFailure, []string{c.key(key)}, window.Milliseconds()).Int64()
+}
+
+func (c *RedisLoginAttemptCache) Block(ctx context.Context, key string, until time.Time) error {
+	return c.client.Eval(ctx, luaBlockLogin, []string{c.key(key)},
+		until.UnixMilli(), time.Now().UnixMilli()).Err()
+}
+
+func (c *RedisLoginAttemptCache) Delete(ctx context.Context, key string) error {
+	return c.client.Del(ctx, c.key(key)).Err()
+}
+
+func (c *RedisLoginAttemptCache) key(key string) string {
+	return "login_attempt:" + key
+}
@@ -0,0 +1,88 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryLoginAttemptCache 基于内存的实现，主要是测试用，
+// 多实例部署的时候不能用它
+type MemoryLoginAttemptCache struct {
+	mu      sync.Mutex
+	records map[string]*memoryLoginAttempt
+	// 方便测试的时候控制时间
+	now func() time.Time
+}
+
+type memoryLoginAttempt struct {
+	LoginAttempt
+	expireAt time.Time
+}
+
+func NewMemoryLoginAttemptCache(now func() time.Time) *MemoryLoginAttemptCache {
+	if now == nil {
+		now = time.Now
+	}
+	return &MemoryLoginAttemptCache{
+		records: make(map[string]*memoryLoginAttempt),
+		now:     now,
+	}
+}
+
+func (c *MemoryLoginAttemptCache) Get(ctx context.Context, key string) (LoginAttempt, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	r, ok := c.get(key)
+	if !ok {
+		return LoginAttempt{}, nil
+	}
+	return r.LoginAttempt, nil
+}
+
+func (c *MemoryLoginAttemptCache) IncrFailure(ctx context.Context, key string, window time.Duration) (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	r, ok := c.get(key)
+	if !ok {
+		r = &memoryLoginAttempt{expireAt: c.now().Add(window)}
+		c.records[key] = r
+	}
+	r.Failures++
+	return r.Failures, nil
+}
+
+func (c *MemoryLoginAttemptCache) Block(ctx context.Context, key string, until time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	r, ok := c.get(key)
+	if !ok {
+		r = &memoryLoginAttempt{}
+		c.records[key] = r
+	}
+	r.BlockedUntil = until
+	if r.expireAt.Before(until) {
+		r.expireAt = until
+	}
+	return nil
+}
+
+func (c *MemoryLoginAttemptCache) Delete(ctx context.Context, key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.records, key)
+	return nil
+}
+
+// get 调用者要持有锁
+func (c *MemoryLoginAttemptCache) get(key string) (*memoryLoginAttempt, bool) {
+	r, ok := c.records[key]
+	if !ok {
+		return nil, false
+	}
+	if !r.expireAt.After(c.now()) {
+		delete(c.records, key)
+		return nil, false
+	}
+	return r, true
+}
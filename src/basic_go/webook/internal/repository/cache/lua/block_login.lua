@@ -0,0 +1,13 @@
+-- 设置禁止登录的截止时间
+local key = KEYS[1]
+-- 截止时间，UTC 0 的毫秒数。until 是 Lua 的关键字，不能当变量名
+local deadline = tonumber(ARGV[1])
+-- 当前时间，UTC 0 的毫秒数
+local now = tonumber(ARGV[2])
+redis.call("hset", key, "until", deadline)
+local ttl = tonumber(redis.call("pttl", key))
+-- 过期时间至少要覆盖到截止时间，不然锁还没结束，记录就没了
+if ttl < deadline - now then
+    redis.call("pexpire", key, deadline - now)
+end
+return 0
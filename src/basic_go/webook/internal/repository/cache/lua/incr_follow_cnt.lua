@@ -0,0 +1,9 @@
+-- 缓存里面有才加，没有的话下次查的时候会从数据库重新加载
+local key = KEYS[1]
+local field = ARGV[1]
+local delta = tonumber(ARGV[2])
+if redis.call("EXISTS", key) == 1 then
+    redis.call("HINCRBY", key, field, delta)
+    return 1
+end
+return 0
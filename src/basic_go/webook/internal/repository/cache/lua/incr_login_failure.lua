@@ -0,0 +1,12 @@
+-- 登录失败次数 +1
+local key = KEYS[1]
+-- 计数窗口，单位毫秒
+local window = tonumber(ARGV[1])
+local cnt = redis.call("hincrby", key, "cnt", 1)
+local ttl = tonumber(redis.call("pttl", key))
+-- 第一次失败，或者 key 没有过期时间，才设置窗口
+-- 避免每次失败都把窗口往后推
+if cnt == 1 or ttl < 0 then
+    redis.call("pexpire", key, window)
+end
+return cnt
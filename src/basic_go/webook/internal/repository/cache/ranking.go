@@ -0,0 +1,101 @@
+package cache
+
+import (
+	"basic_go/webook/internal/domain"
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrRankingExpired 本地缓存过期了，或者还没有
+var ErrRankingExpired = errors.New("本地缓存的热榜过期了")
+
+// RankingCache 放算好的热榜，按分数从高到低
+type RankingCache interface {
+	Set(ctx context.Context, arts []domain.HotArticle) error
+	Get(ctx context.Context) ([]domain.HotArticle, error)
+}
+
+// RedisRankingCache 热榜算好之后放在 Redis 里面，所有实例共享
+type RedisRankingCache struct {
+	client redis.Cmdable
+	// 要比计算热榜的间隔长，任务偶尔失败几次也还有数据
+	expiration time.Duration
+}
+
+func NewRedisRankingCache(client redis.Cmdable, expiration time.Duration) *RedisRankingCache {
+	return &RedisRankingCache{
+		client:     client,
+		expiration: expiration,
+	}
+}
+
+func (c *RedisRankingCache) Set(ctx context.Context, arts []domain.HotArticle) error {
+	val, err := json.Marshal(arts)
+	if err != nil {
+		return err
+	}
+	return c.client.Set(ctx, c.key(), val, c.expiration).Err()
+}
+
+// Get 没有缓存的时候返回 ErrKeyNotExist
+func (c *RedisRankingCache) Get(ctx context.Context) ([]domain.HotArticle, error) {
+	val, err := c.client.Get(ctx, c.key()).Bytes()
+	if err != nil {
+		return nil, err
+	}
+	var arts []domain.HotArticle
+	err = json.Unmarshal(val, &arts)
+	return arts, err
+}
+
+func (c *RedisRankingCache) key() string {
+	return "ranking:hot_articles"
+}
+
+// LocalRankingCache 本地缓存一份，大部分请求不用访问 Redis。
+// 过期了的数据不会马上删掉，Redis 出问题的时候还能拿来用
+type LocalRankingCache struct {
+	mu         sync.RWMutex
+	arts       []domain.HotArticle
+	deadline   time.Time
+	expiration time.Duration
+}
+
+func NewLocalRankingCache(expiration time.Duration) *LocalRankingCache {
+	return &LocalRankingCache{
+		expiration: expiration,
+	}
+}
+
+func (c *LocalRankingCache) Set(ctx context.Context, arts []domain.HotArticle) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.arts = arts
+	c.deadline = time.Now().Add(c.expiration)
+	return nil
+}
+
+// Get 过期了或者还没有的时候返回 ErrRankingExpired
+func (c *LocalRankingCache) Get(ctx context.Context) ([]domain.HotArticle, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.arts == nil || time.Now().After(c.deadline) {
+		return nil, ErrRankingExpired
+	}
+	return c.arts, nil
+}
+
+// ForceGet 不管有没有过期都返回，一次都没有设置过的返回 ErrRankingExpired
+func (c *LocalRankingCache) ForceGet(ctx context.Context) ([]domain.HotArticle, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.arts == nil {
+		return nil, ErrRankingExpired
+	}
+	return c.arts, nil
+}
@@ -0,0 +1,77 @@
+package cache
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// SessionCache 记录用户的登录态从什么时候开始失效，
+// 在这个时间之前登录拿到的 token 或者 session 都不能用了
+type SessionCache interface {
+	Revoke(ctx context.Context, uid int64, at time.Time) error
+	// RevokedAt 没有失效过的返回零值
+	RevokedAt(ctx context.Context, uid int64) (time.Time, error)
+}
+
+type RedisSessionCache struct {
+	client redis.Cmdable
+}
+
+func NewRedisSessionCache(client redis.Cmdable) *RedisSessionCache {
+	return &RedisSessionCache{
+		client: client,
+	}
+}
+
+// Revoke 不设置过期时间，JWT 会一直续期，旧的 token 什么时候彻底失效不好说
+func (c *RedisSessionCache) Revoke(ctx context.Context, uid int64, at time.Time) error {
+	return c.client.Set(ctx, c.key(uid), at.UnixMilli(), 0).Err()
+}
+
+func (c *RedisSessionCache) RevokedAt(ctx context.Context, uid int64) (time.Time, error) {
+	val, err := c.client.Get(ctx, c.key(uid)).Result()
+	if err == redis.Nil {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	ms, err := strconv.ParseInt(val, 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.UnixMilli(ms), nil
+}
+
+func (c *RedisSessionCache) key(uid int64) string {
+	return "user:sessions_revoked:" + strconv.FormatInt(uid, 10)
+}
+
+// MemorySessionCache 基于内存的实现，主要是测试用，多实例部署的时候不能用它
+type MemorySessionCache struct {
+	mu      sync.RWMutex
+	revoked map[int64]time.Time
+}
+
+func NewMemorySessionCache() *MemorySessionCache {
+	return &MemorySessionCache{
+		revoked: make(map[int64]time.Time),
+	}
+}
+
+func (c *MemorySessionCache) Revoke(ctx context.Context, uid int64, at time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.revoked[uid] = at
+	return nil
+}
+
+func (c *MemorySessionCache) RevokedAt(ctx context.Context, uid int64) (time.Time, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.revoked[uid], nil
+}
@@ -0,0 +1,119 @@
+package repository
+
+import (
+	"basic_go/webook/internal/domain"
+	"basic_go/webook/internal/repository/dao"
+	"context"
+	"time"
+)
+
+var ErrCommentNotFound = dao.ErrRecordNotFound
+
+type CommentRepository struct {
+	dao *dao.CommentDAO
+}
+
+func NewCommentRepository(dao *dao.CommentDAO) *CommentRepository {
+	return &CommentRepository{
+		dao: dao,
+	}
+}
+
+func (repo *CommentRepository) Create(ctx context.Context, c domain.Comment) (int64, error) {
+	return repo.dao.Insert(ctx, repo.toEntity(c))
+}
+
+func (repo *CommentRepository) Delete(ctx context.Context, c domain.Comment) error {
+	_, err := repo.dao.Delete(ctx, repo.toEntity(c))
+	return err
+}
+
+func (repo *CommentRepository) FindById(ctx context.Context, id int64) (domain.Comment, error) {
+	c, err := repo.dao.FindById(ctx, id)
+	if err != nil {
+		return domain.Comment{}, err
+	}
+	return repo.toDomain(c), nil
+}
+
+// FindRoots 根评论，每条都带上前 replyLimit 条回复和回复总数
+func (repo *CommentRepository) FindRoots(ctx context.Context, biz string, bizId int64,
+	maxId int64, limit int, replyLimit int) ([]domain.Comment, error) {
+	roots, err := repo.dao.FindRoots(ctx, biz, bizId, maxId, limit)
+	if err != nil || len(roots) == 0 {
+		return nil, err
+	}
+	ids := make([]int64, 0, len(roots))
+	for _, r := range roots {
+		ids = append(ids, r.Id)
+	}
+	cnts, err := repo.dao.CountReplies(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]domain.Comment, 0, len(roots))
+	for _, r := range roots {
+		c := repo.toDomain(r)
+		c.ReplyCnt = cnts[r.Id]
+		if c.ReplyCnt > 0 && replyLimit > 0 {
+			// 一页最多几十条根评论，一条一条查也可以接受
+			c.Replies, err = repo.FindReplies(ctx, r.Id, 0, replyLimit)
+			if err != nil {
+				return nil, err
+			}
+		}
+		res = append(res, c)
+	}
+	return res, nil
+}
+
+func (repo *CommentRepository) FindReplies(ctx context.Context, rootId int64, minId int64, limit int) ([]domain.Comment, error) {
+	cs, err := repo.dao.FindReplies(ctx, rootId, minId, limit)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]domain.Comment, 0, len(cs))
+	for _, c := range cs {
+		res = append(res, repo.toDomain(c))
+	}
+	return res, nil
+}
+
+// FindByUid uid 发的评论和回复，按照 ID 从小到大
+func (repo *CommentRepository) FindByUid(ctx context.Context, uid int64, startId int64, limit int) ([]domain.Comment, error) {
+	cs, err := repo.dao.FindByUid(ctx, uid, startId, limit)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]domain.Comment, 0, len(cs))
+	for _, c := range cs {
+		res = append(res, repo.toDomain(c))
+	}
+	return res, nil
+}
+
+func (repo *CommentRepository) toEntity(c domain.Comment) dao.Comment {
+	return dao.Comment{
+		Id:       c.Id,
+		Uid:      c.Uid,
+		Biz:      c.Biz,
+		BizId:    c.BizId,
+		RootId:   c.RootId,
+		ParentId: c.ParentId,
+		Content:  c.Content,
+	}
+}
+
+func (repo *CommentRepository) toDomain(c dao.Comment) domain.Comment {
+	return domain.Comment{
+		Id:       c.Id,
+		Uid:      c.Uid,
+		Biz:      c.Biz,
+		BizId:    c.BizId,
+		RootId:   c.RootId,
+		ParentId: c.ParentId,
+		Content:  c.Content,
+		Ctime:    time.UnixMilli(c.Ctime),
+		Utime:    time.UnixMilli(c.Utime),
+	}
+}
@@ -0,0 +1,204 @@
+package dao
+
+import (
+	"context"
+	"errors"
+	"math"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrInsufficientBalance = errors.New("余额不足")
+	ErrDuplicatePosting    = errors.New("这笔业务已经记过账了")
+)
+
+// 账户类型，和 domain.AccountType 的值一样
+const (
+	AccountTypeUser     uint8 = 1
+	AccountTypePlatform uint8 = 2
+)
+
+// 流水的方向，和 domain.EntryDirection 的值一样
+const (
+	EntryDirectionDebit  uint8 = 1
+	EntryDirectionCredit uint8 = 2
+)
+
+type AccountDAO struct {
+	db *gorm.DB
+}
+
+func NewAccountDAO(db *gorm.DB) *AccountDAO {
+	return &AccountDAO{
+		db: db,
+	}
+}
+
+// Post 记一笔账：debit 的余额减少 amount，credit 的余额增加 amount，
+// 两条流水和两个账户的余额在一个事务里面改。
+// 同一个 biz + bizId 已经记过的返回 ErrDuplicatePosting；
+// 用户账户扣成负数的返回 ErrInsufficientBalance，整个事务回滚
+func (dao *AccountDAO) Post(ctx context.Context, txn AccountTransaction,
+	debit AccountKey, credit AccountKey) error {
+	return dao.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now().UnixMilli()
+		txn.Ctime = now
+		res := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&txn)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrDuplicatePosting
+		}
+
+		legs := []struct {
+			key       AccountKey
+			direction uint8
+		}{
+			{key: debit, direction: EntryDirectionDebit},
+			{key: credit, direction: EntryDirectionCredit},
+		}
+		// 按照同样的顺序锁账户，两笔方向相反的转账同时进来也不会死锁
+		if lessAccountKey(credit, debit) {
+			legs[0], legs[1] = legs[1], legs[0]
+		}
+		for _, leg := range legs {
+			acc, err := dao.lockAccount(tx, leg.key, now)
+			if err != nil {
+				return err
+			}
+			delta := txn.Amount
+			if leg.direction == EntryDirectionDebit {
+				delta = -delta
+			}
+			balance := acc.Balance + delta
+			if balance < 0 && acc.Type == AccountTypeUser {
+				return ErrInsufficientBalance
+			}
+			err = tx.Model(&Account{}).Where("id = ?", acc.Id).
+				Updates(map[string]any{
+					"balance": balance,
+					"utime":   now,
+				}).Error
+			if err != nil {
+				return err
+			}
+			err = tx.Create(&AccountEntry{
+				TxnId:     txn.Id,
+				AccountId: acc.Id,
+				Uid:       acc.Uid,
+				Type:      acc.Type,
+				Biz:       txn.Biz,
+				BizId:     txn.BizId,
+				Direction: leg.direction,
+				Amount:    txn.Amount,
+				Balance:   balance,
+				Memo:      txn.Memo,
+				Ctime:     now,
+			}).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// lockAccount 账户不存在就先创建，然后加锁读出来
+func (dao *AccountDAO) lockAccount(tx *gorm.DB, key AccountKey, now int64) (Account, error) {
+	err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&Account{
+		Uid:   key.Uid,
+		Type:  key.Type,
+		Ctime: now,
+		Utime: now,
+	}).Error
+	if err != nil {
+		return Account{}, err
+	}
+	var acc Account
+	err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("uid = ? AND type = ?", key.Uid, key.Type).
+		First(&acc).Error
+	return acc, err
+}
+
+func (dao *AccountDAO) FindAccount(ctx context.Context, key AccountKey) (Account, error) {
+	var acc Account
+	err := dao.db.WithContext(ctx).
+		Where("uid = ? AND type = ?", key.Uid, key.Type).
+		First(&acc).Error
+	return acc, err
+}
+
+// FindEntries 按 ID 倒序查某个账户的流水，start 和 end 是 0 的时候不限制
+func (dao *AccountDAO) FindEntries(ctx context.Context, key AccountKey,
+	start, end int64, maxId int64, limit int) ([]AccountEntry, error) {
+	if maxId <= 0 {
+		maxId = math.MaxInt64
+	}
+	query := dao.db.WithContext(ctx).
+		Where("uid = ? AND type = ? AND id < ?", key.Uid, key.Type, maxId)
+	if start > 0 {
+		query = query.Where("ctime >= ?", start)
+	}
+	if end > 0 {
+		query = query.Where("ctime < ?", end)
+	}
+	var es []AccountEntry
+	err := query.Order("id DESC").Limit(limit).Find(&es).Error
+	return es, err
+}
+
+type AccountKey struct {
+	Uid  int64
+	Type uint8
+}
+
+func lessAccountKey(a, b AccountKey) bool {
+	if a.Type != b.Type {
+		return a.Type < b.Type
+	}
+	return a.Uid < b.Uid
+}
+
+// Account 账户，余额的单位是分。
+// 余额等于所有贷方流水减去所有借方流水，所有账户的余额加起来永远是 0
+type Account struct {
+	Id      int64 `gorm:"primaryKey,autoIncrement"`
+	Uid     int64 `gorm:"uniqueIndex:account_uid_type"`
+	Type    uint8 `gorm:"uniqueIndex:account_uid_type"`
+	Balance int64
+	Ctime   int64
+	Utime   int64
+}
+
+// AccountTransaction 一笔记账，唯一索引保证同一笔业务只记一次
+type AccountTransaction struct {
+	Id     int64  `gorm:"primaryKey,autoIncrement"`
+	Biz    string `gorm:"type:varchar(128);uniqueIndex:account_txn_biz_id"`
+	BizId  int64  `gorm:"uniqueIndex:account_txn_biz_id"`
+	Amount int64
+	Memo   string `gorm:"type:varchar(256)"`
+	Ctime  int64
+}
+
+// AccountEntry 流水，一笔记账有借贷两条。只插入，不修改也不删除
+type AccountEntry struct {
+	Id        int64 `gorm:"primaryKey,autoIncrement"`
+	TxnId     int64 `gorm:"index"`
+	AccountId int64
+	// 冗余账户的 Uid 和 Type，查流水不用再查一次账户
+	Uid       int64  `gorm:"index:account_entry_uid_type_ctime"`
+	Type      uint8  `gorm:"index:account_entry_uid_type_ctime"`
+	Biz       string `gorm:"type:varchar(128)"`
+	BizId     int64
+	Direction uint8
+	Amount    int64
+	// 记完这一笔之后的余额
+	Balance int64
+	Memo    string `gorm:"type:varchar(256)"`
+	Ctime   int64  `gorm:"index:account_entry_uid_type_ctime"`
+}
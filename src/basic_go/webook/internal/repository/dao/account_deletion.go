@@ -0,0 +1,106 @@
+package dao
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 和 domain.AccountDeletionStatus 的值一样
+const (
+	accountDeletionStatusPending    uint8 = 1
+	accountDeletionStatusCancelled  uint8 = 2
+	accountDeletionStatusProcessing uint8 = 3
+	accountDeletionStatusDone       uint8 = 4
+)
+
+type AccountDeletionDAO struct {
+	db *gorm.DB
+}
+
+func NewAccountDeletionDAO(db *gorm.DB) *AccountDeletionDAO {
+	return &AccountDeletionDAO{
+		db: db,
+	}
+}
+
+// Upsert 一个用户只有一条，取消了之后再申请就覆盖掉
+func (dao *AccountDeletionDAO) Upsert(ctx context.Context, d AccountDeletion) error {
+	now := time.Now().UnixMilli()
+	d.Status = accountDeletionStatusPending
+	d.Ctime = now
+	d.Utime = now
+	return dao.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "uid"}},
+		DoUpdates: clause.Assignments(map[string]any{
+			"article_policy": d.ArticlePolicy,
+			"status":         d.Status,
+			"scheduled_at":   d.ScheduledAt,
+			"utime":          now,
+		}),
+	}).Create(&d).Error
+}
+
+func (dao *AccountDeletionDAO) FindByUid(ctx context.Context, uid int64) (AccountDeletion, error) {
+	var d AccountDeletion
+	err := dao.db.WithContext(ctx).Where("uid = ?", uid).First(&d).Error
+	return d, err
+}
+
+// Cancel 只有冷静期里面的能取消，返回 false 代表没有能取消的
+func (dao *AccountDeletionDAO) Cancel(ctx context.Context, uid int64) (bool, error) {
+	res := dao.db.WithContext(ctx).Model(&AccountDeletion{}).
+		Where("uid = ? AND status = ?", uid, accountDeletionStatusPending).
+		Updates(map[string]any{
+			"status": accountDeletionStatusCancelled,
+			"utime":  time.Now().UnixMilli(),
+		})
+	return res.RowsAffected > 0, res.Error
+}
+
+// FindDue 冷静期已经过了的，按 ID 遍历。处理到一半失败了的也在里面，会再处理一次
+func (dao *AccountDeletionDAO) FindDue(ctx context.Context, now int64, startId int64, limit int) ([]AccountDeletion, error) {
+	var ds []AccountDeletion
+	err := dao.db.WithContext(ctx).
+		// []uint8 会被当成二进制，只能一个一个写
+		Where("status IN (?, ?) AND scheduled_at <= ? AND id > ?",
+			accountDeletionStatusPending, accountDeletionStatusProcessing, now, startId).
+		Order("id").Limit(limit).
+		Find(&ds).Error
+	return ds, err
+}
+
+// MarkProcessing 开始处理，之后就不能取消了。返回 false 代表刚好被取消了
+func (dao *AccountDeletionDAO) MarkProcessing(ctx context.Context, id int64) (bool, error) {
+	res := dao.db.WithContext(ctx).Model(&AccountDeletion{}).
+		Where("id = ? AND status IN (?, ?)", id,
+			accountDeletionStatusPending, accountDeletionStatusProcessing).
+		Updates(map[string]any{
+			"status": accountDeletionStatusProcessing,
+			"utime":  time.Now().UnixMilli(),
+		})
+	return res.RowsAffected > 0, res.Error
+}
+
+func (dao *AccountDeletionDAO) MarkDone(ctx context.Context, id int64) error {
+	return dao.db.WithContext(ctx).Model(&AccountDeletion{}).
+		Where("id = ? AND status = ?", id, accountDeletionStatusProcessing).
+		Updates(map[string]any{
+			"status": accountDeletionStatusDone,
+			"utime":  time.Now().UnixMilli(),
+		}).Error
+}
+
+// AccountDeletion 注销申请
+type AccountDeletion struct {
+	Id            int64 `gorm:"primaryKey,autoIncrement"`
+	Uid           int64 `gorm:"uniqueIndex"`
+	ArticlePolicy uint8
+	Status        uint8 `gorm:"index:account_deletion_status_scheduled"`
+	// 冷静期结束的时间，UTC 0 的毫秒数
+	ScheduledAt int64 `gorm:"index:account_deletion_status_scheduled"`
+	Ctime       int64
+	Utime       int64
+}
@@ -0,0 +1,62 @@
+package dao
+
+import (
+	"basic_go/webook/internal/repository/dao/daotest"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccountDAO_Post(t *testing.T) {
+	db := daotest.OpenDB(t, &Account{}, &AccountTransaction{}, &AccountEntry{})
+	dao := NewAccountDAO(db)
+	ctx := context.Background()
+	platform := AccountKey{Type: AccountTypePlatform}
+	user1 := AccountKey{Uid: 1, Type: AccountTypeUser}
+	user2 := AccountKey{Uid: 2, Type: AccountTypeUser}
+
+	require.NoError(t, dao.Post(ctx, AccountTransaction{Biz: "reward", BizId: 1, Amount: 100}, platform, user1))
+	// 同一笔业务只记一次
+	err := dao.Post(ctx, AccountTransaction{Biz: "reward", BizId: 1, Amount: 100}, platform, user1)
+	assert.Equal(t, ErrDuplicatePosting, err)
+	require.NoError(t, dao.Post(ctx, AccountTransaction{Biz: "transfer", BizId: 1, Amount: 30}, user1, user2))
+
+	// 用户账户不能扣成负数，整个事务回滚
+	err = dao.Post(ctx, AccountTransaction{Biz: "transfer", BizId: 2, Amount: 100}, user1, user2)
+	assert.Equal(t, ErrInsufficientBalance, err)
+	var cnt int64
+	require.NoError(t, db.Model(&AccountTransaction{}).Where("biz = ? AND biz_id = ?", "transfer", 2).Count(&cnt).Error)
+	assert.Equal(t, int64(0), cnt)
+
+	balances := map[AccountKey]int64{platform: -100, user1: 70, user2: 30}
+	for key, want := range balances {
+		acc, err := dao.FindAccount(ctx, key)
+		require.NoError(t, err)
+		assert.Equal(t, want, acc.Balance)
+	}
+	// 借贷平衡，所有账户加起来是 0
+	var sum int64
+	require.NoError(t, db.Model(&Account{}).Select("SUM(balance)").Scan(&sum).Error)
+	assert.Equal(t, int64(0), sum)
+
+	es, err := dao.FindEntries(ctx, user1, 0, 0, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, es, 2)
+	assert.Equal(t, EntryDirectionDebit, es[0].Direction)
+	assert.Equal(t, int64(70), es[0].Balance)
+	assert.Equal(t, EntryDirectionCredit, es[1].Direction)
+	assert.Equal(t, int64(100), es[1].Balance)
+
+	// 翻页
+	es, err = dao.FindEntries(ctx, user1, 0, 0, es[0].Id, 10)
+	require.NoError(t, err)
+	require.Len(t, es, 1)
+	assert.Equal(t, "reward", es[0].Biz)
+
+	// 时间过滤
+	es, err = dao.FindEntries(ctx, user1, es[0].Ctime+1000_000, 0, 0, 10)
+	require.NoError(t, err)
+	assert.Len(t, es, 0)
+}
@@ -0,0 +1,207 @@
+package dao
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrArticleNotFound = errors.New("文章不存在，或者不是你的")
+
+type ArticleDAO struct {
+	db *gorm.DB
+}
+
+func NewArticleDAO(db *gorm.DB) *ArticleDAO {
+	return &ArticleDAO{
+		db: db,
+	}
+}
+
+func (dao *ArticleDAO) Insert(ctx context.Context, art Article) (int64, error) {
+	now := time.Now().UnixMilli()
+	art.Ctime = now
+	art.Utime = now
+	err := dao.db.WithContext(ctx).Create(&art).Error
+	return art.Id, err
+}
+
+// UpdateById 只能更新自己的文章
+func (dao *ArticleDAO) UpdateById(ctx context.Context, art Article) error {
+	return dao.updateById(dao.db.WithContext(ctx), art)
+}
+
+func (dao *ArticleDAO) updateById(tx *gorm.DB, art Article) error {
+	res := tx.Model(&Article{}).
+		Where("id = ? AND author_id = ?", art.Id, art.AuthorId).
+		Updates(map[string]any{
+			"title":   art.Title,
+			"content": art.Content,
+			"status":  art.Status,
+			"utime":   time.Now().UnixMilli(),
+		})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrArticleNotFound
+	}
+	return nil
+}
+
+// Sync 保存制作库，并且同步到线上库，在同一个事务里面
+func (dao *ArticleDAO) Sync(ctx context.Context, art Article) (int64, error) {
+	err := dao.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now().UnixMilli()
+		if art.Id > 0 {
+			err := dao.updateById(tx, art)
+			if err != nil {
+				return err
+			}
+		} else {
+			art.Ctime = now
+			art.Utime = now
+			err := tx.Create(&art).Error
+			if err != nil {
+				return err
+			}
+		}
+		pubArt := PublishedArticle(art)
+		pubArt.Ctime = now
+		pubArt.Utime = now
+		return tx.Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "id"}},
+			DoUpdates: clause.Assignments(map[string]any{
+				"title":   pubArt.Title,
+				"content": pubArt.Content,
+				"status":  pubArt.Status,
+				"utime":   now,
+			}),
+		}).Create(&pubArt).Error
+	})
+	return art.Id, err
+}
+
+// SyncStatus 制作库和线上库一起改状态
+func (dao *ArticleDAO) SyncStatus(ctx context.Context, uid int64, id int64, status uint8) error {
+	return dao.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now().UnixMilli()
+		res := tx.Model(&Article{}).
+			Where("id = ? AND author_id = ?", id, uid).
+			Updates(map[string]any{
+				"status": status,
+				"utime":  now,
+			})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrArticleNotFound
+		}
+		return tx.Model(&PublishedArticle{}).
+			Where("id = ?", id).
+			Updates(map[string]any{
+				"status": status,
+				"utime":  now,
+			}).Error
+	})
+}
+
+// TransferAuthor 把 from 的文章全部转给 to，制作库和线上库一起改
+func (dao *ArticleDAO) TransferAuthor(ctx context.Context, from int64, to int64) error {
+	return dao.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now().UnixMilli()
+		err := tx.Model(&Article{}).
+			Where("author_id = ?", from).
+			Updates(map[string]any{
+				"author_id": to,
+				"utime":     now,
+			}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Model(&PublishedArticle{}).
+			Where("author_id = ?", from).
+			Updates(map[string]any{
+				"author_id": to,
+				"utime":     now,
+			}).Error
+	})
+}
+
+func (dao *ArticleDAO) GetByAuthor(ctx context.Context, uid int64, offset int, limit int) ([]Article, error) {
+	var arts []Article
+	err := dao.db.WithContext(ctx).Where("author_id = ?", uid).
+		Offset(offset).Limit(limit).
+		Order("utime DESC").
+		Find(&arts).Error
+	return arts, err
+}
+
+func (dao *ArticleDAO) GetById(ctx context.Context, id int64) (Article, error) {
+	var art Article
+	err := dao.db.WithContext(ctx).Where("id = ?", id).First(&art).Error
+	return art, err
+}
+
+func (dao *ArticleDAO) GetPubById(ctx context.Context, id int64) (PublishedArticle, error) {
+	var art PublishedArticle
+	err := dao.db.WithContext(ctx).Where("id = ?", id).First(&art).Error
+	return art, err
+}
+
+// GetPubByIds 不保证顺序，找不到的直接跳过
+func (dao *ArticleDAO) GetPubByIds(ctx context.Context, ids []int64) ([]PublishedArticle, error) {
+	var arts []PublishedArticle
+	err := dao.db.WithContext(ctx).Where("id IN ?", ids).Find(&arts).Error
+	return arts, err
+}
+
+// ListPubByAuthor 按照 ID 遍历线上库里面 uid 的文章，startId 是上一批最后一篇的 ID
+func (dao *ArticleDAO) ListPubByAuthor(ctx context.Context, uid int64, startId int64, limit int, status uint8) ([]PublishedArticle, error) {
+	var arts []PublishedArticle
+	err := dao.db.WithContext(ctx).
+		Where("author_id = ? AND id > ? AND status = ?", uid, startId, status).
+		Order("id").Limit(limit).
+		Find(&arts).Error
+	return arts, err
+}
+
+// ListPub 按照 ID 遍历线上库已发表的文章，startId 是上一批最后一篇的 ID
+func (dao *ArticleDAO) ListPub(ctx context.Context, startId int64, limit int, status uint8) ([]PublishedArticle, error) {
+	var arts []PublishedArticle
+	err := dao.db.WithContext(ctx).
+		Where("id > ? AND status = ?", startId, status).
+		Order("id").Limit(limit).
+		Find(&arts).Error
+	return arts, err
+}
+
+// ListPubSince 按照 ID 遍历 utime 不早于 since 的已发表的文章，startId 是上一批最后一篇的 ID
+func (dao *ArticleDAO) ListPubSince(ctx context.Context, since int64, startId int64, limit int, status uint8) ([]PublishedArticle, error) {
+	var arts []PublishedArticle
+	err := dao.db.WithContext(ctx).
+		Where("utime >= ? AND id > ? AND status = ?", since, startId, status).
+		Order("id").Limit(limit).
+		Find(&arts).Error
+	return arts, err
+}
+
+// Article 制作库，作者自己编辑的
+type Article struct {
+	Id    int64  `gorm:"primaryKey,autoIncrement"`
+	Title string `gorm:"type:varchar(4096)"`
+	// 文章内容可能很长
+	Content  string `gorm:"type:BLOB"`
+	AuthorId int64  `gorm:"index"`
+	Status   uint8
+	// UTC 0 的毫秒数
+	Ctime int64
+	Utime int64 `gorm:"index"`
+}
+
+// PublishedArticle 线上库，读者看到的。和 Article 结构一样，但是是不同的表
+type PublishedArticle Article
@@ -0,0 +1,91 @@
+package dao
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	asyncSmsStatusWaiting uint8 = iota + 1
+	asyncSmsStatusSuccess
+	// asyncSmsStatusFailed 重试次数用完了还是失败
+	asyncSmsStatusFailed
+)
+
+type AsyncSmsDAO struct {
+	db *gorm.DB
+}
+
+func NewAsyncSmsDAO(db *gorm.DB) *AsyncSmsDAO {
+	return &AsyncSmsDAO{
+		db: db,
+	}
+}
+
+func (dao *AsyncSmsDAO) Insert(ctx context.Context, s AsyncSms) error {
+	now := time.Now().UnixMilli()
+	s.Status = asyncSmsStatusWaiting
+	s.Ctime = now
+	s.Utime = now
+	return dao.db.WithContext(ctx).Create(&s).Error
+}
+
+// PreemptWaiting 抢一条等着重试的，utime 早于 before 的才抢，
+// 抢到之后更新 utime 并且重试次数 +1，别的实例在 before 之前就不会再抢到它。
+// 抢到之后挂了的，过一会儿会被重新抢到。exclude 里面的不抢。没有的话返回 ErrRecordNotFound
+func (dao *AsyncSmsDAO) PreemptWaiting(ctx context.Context, before int64, exclude []int64) (AsyncSms, error) {
+	var s AsyncSms
+	err := dao.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		query := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("status = ? AND utime < ?", asyncSmsStatusWaiting, before)
+		if len(exclude) > 0 {
+			query = query.Where("id NOT IN ?", exclude)
+		}
+		err := query.First(&s).Error
+		if err != nil {
+			return err
+		}
+		s.Utime = time.Now().UnixMilli()
+		s.RetryCnt++
+		return tx.Model(&AsyncSms{}).Where("id = ?", s.Id).
+			Updates(map[string]any{
+				"retry_cnt": s.RetryCnt,
+				"utime":     s.Utime,
+			}).Error
+	})
+	return s, err
+}
+
+// MarkSuccess 重试成功了
+func (dao *AsyncSmsDAO) MarkSuccess(ctx context.Context, id int64) error {
+	return dao.db.WithContext(ctx).Model(&AsyncSms{}).
+		Where("id = ?", id).
+		Updates(map[string]any{
+			"status": asyncSmsStatusSuccess,
+			"utime":  time.Now().UnixMilli(),
+		}).Error
+}
+
+// MarkFailed 重试又失败了。次数用完了就不再重试，没用完的等着下一次被抢到
+func (dao *AsyncSmsDAO) MarkFailed(ctx context.Context, id int64) error {
+	return dao.db.WithContext(ctx).Model(&AsyncSms{}).
+		Where("id = ? AND retry_cnt >= retry_max", id).
+		Updates(map[string]any{
+			"status": asyncSmsStatusFailed,
+			"utime":  time.Now().UnixMilli(),
+		}).Error
+}
+
+// AsyncSms 等着异步重试的短信，Config 是 JSON
+type AsyncSms struct {
+	Id       int64  `gorm:"primaryKey,autoIncrement"`
+	Config   string `gorm:"type:text"`
+	RetryCnt int
+	RetryMax int
+	Status   uint8 `gorm:"index:async_sms_status_utime"`
+	Ctime    int64
+	Utime    int64 `gorm:"index:async_sms_status_utime"`
+}
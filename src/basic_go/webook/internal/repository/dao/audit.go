@@ -0,0 +1,58 @@
+package dao
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type AuditLogDAO struct {
+	db *gorm.DB
+}
+
+func NewAuditLogDAO(db *gorm.DB) *AuditLogDAO {
+	return &AuditLogDAO{
+		db: db,
+	}
+}
+
+// Insert 审计日志只插入，没有修改和删除的方法
+func (dao *AuditLogDAO) Insert(ctx context.Context, l AuditLog) (int64, error) {
+	l.Ctime = time.Now().UnixMilli()
+	err := dao.db.WithContext(ctx).Create(&l).Error
+	return l.Id, err
+}
+
+// Find 按 ID 倒序查，operatorId 和 targetId 是 0 的时候不限制
+func (dao *AuditLogDAO) Find(ctx context.Context, operatorId int64, targetType string,
+	targetId int64, maxId int64, limit int) ([]AuditLog, error) {
+	if maxId <= 0 {
+		maxId = math.MaxInt64
+	}
+	query := dao.db.WithContext(ctx).Where("id < ?", maxId)
+	if operatorId > 0 {
+		query = query.Where("operator_id = ?", operatorId)
+	}
+	if targetId > 0 {
+		query = query.Where("target_type = ? AND target_id = ?", targetType, targetId)
+	}
+	var ls []AuditLog
+	err := query.Order("id DESC").Limit(limit).Find(&ls).Error
+	return ls, err
+}
+
+// AuditLog 管理员的操作记录
+type AuditLog struct {
+	Id         int64  `gorm:"primaryKey,autoIncrement"`
+	OperatorId int64  `gorm:"index"`
+	TargetType string `gorm:"type:varchar(64);index:audit_target"`
+	TargetId   int64  `gorm:"index:audit_target"`
+	Action     string `gorm:"type:varchar(64)"`
+	Reason     string `gorm:"type:varchar(512)"`
+	// 操作的细节，JSON
+	Detail string `gorm:"type:text"`
+	Ip     string `gorm:"type:varchar(64)"`
+	Ctime  int64
+}
@@ -0,0 +1,173 @@
+package dao
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type CommentDAO struct {
+	db *gorm.DB
+}
+
+func NewCommentDAO(db *gorm.DB) *CommentDAO {
+	return &CommentDAO{
+		db: db,
+	}
+}
+
+// Insert 插入评论，同时给资源的评论数加一
+func (dao *CommentDAO) Insert(ctx context.Context, c Comment) (int64, error) {
+	now := time.Now().UnixMilli()
+	c.Ctime = now
+	c.Utime = now
+	err := dao.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Create(&c).Error
+		if err != nil {
+			return err
+		}
+		return incrCommentCnt(tx, c.Biz, c.BizId, 1, now)
+	})
+	return c.Id, err
+}
+
+// Delete 删除评论和它下面所有的回复，评论数减去删掉的条数。返回删掉了多少条
+func (dao *CommentDAO) Delete(ctx context.Context, c Comment) (int64, error) {
+	var cnt int64
+	err := dao.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		ids := []int64{c.Id}
+		if c.RootId == 0 {
+			// 根评论，整棵树都删掉
+			res := tx.Where("id = ? OR root_id = ?", c.Id, c.Id).Delete(&Comment{})
+			if res.Error != nil {
+				return res.Error
+			}
+			cnt = res.RowsAffected
+		} else {
+			// 回复，同一棵树下面的都捞出来，找到它的子孙
+			var replies []Comment
+			err := tx.Select("id", "parent_id").
+				Where("root_id = ?", c.RootId).
+				Find(&replies).Error
+			if err != nil {
+				return err
+			}
+			ids = descendants(c.Id, replies)
+			res := tx.Where("id IN ?", ids).Delete(&Comment{})
+			if res.Error != nil {
+				return res.Error
+			}
+			cnt = res.RowsAffected
+		}
+		if cnt == 0 {
+			return nil
+		}
+		return incrCommentCnt(tx, c.Biz, c.BizId, -cnt, time.Now().UnixMilli())
+	})
+	return cnt, err
+}
+
+// descendants 返回 id 自己和它所有的子孙
+func descendants(id int64, replies []Comment) []int64 {
+	children := make(map[int64][]int64, len(replies))
+	for _, r := range replies {
+		children[r.ParentId] = append(children[r.ParentId], r.Id)
+	}
+	res := []int64{id}
+	for i := 0; i < len(res); i++ {
+		res = append(res, children[res[i]]...)
+	}
+	return res
+}
+
+func incrCommentCnt(tx *gorm.DB, biz string, bizId int64, delta int64, now int64) error {
+	return tx.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "biz"}, {Name: "biz_id"}},
+		DoUpdates: clause.Assignments(map[string]any{
+			"comment_cnt": gorm.Expr("`comment_cnt` + ?", delta),
+			"utime":       now,
+		}),
+	}).Create(&Interactive{
+		Biz:        biz,
+		BizId:      bizId,
+		CommentCnt: delta,
+		Ctime:      now,
+		Utime:      now,
+	}).Error
+}
+
+func (dao *CommentDAO) FindById(ctx context.Context, id int64) (Comment, error) {
+	var c Comment
+	err := dao.db.WithContext(ctx).Where("id = ?", id).First(&c).Error
+	return c, err
+}
+
+// FindRoots 根评论按照 ID 从大到小，也就是新的在前面。maxId 是上一页最后一条的 ID
+func (dao *CommentDAO) FindRoots(ctx context.Context, biz string, bizId int64, maxId int64, limit int) ([]Comment, error) {
+	var cs []Comment
+	err := dao.db.WithContext(ctx).
+		Where("biz = ? AND biz_id = ? AND root_id = 0 AND id < ?", biz, bizId, maxId).
+		Order("id DESC").Limit(limit).
+		Find(&cs).Error
+	return cs, err
+}
+
+// FindReplies 回复按照 ID 从小到大，也就是按时间顺序。minId 是上一页最后一条的 ID
+func (dao *CommentDAO) FindReplies(ctx context.Context, rootId int64, minId int64, limit int) ([]Comment, error) {
+	var cs []Comment
+	err := dao.db.WithContext(ctx).
+		Where("root_id = ? AND id > ?", rootId, minId).
+		Order("id").Limit(limit).
+		Find(&cs).Error
+	return cs, err
+}
+
+// FindByUid uid 发的评论和回复，按照 ID 从小到大。startId 是上一页最后一条的 ID
+func (dao *CommentDAO) FindByUid(ctx context.Context, uid int64, startId int64, limit int) ([]Comment, error) {
+	var cs []Comment
+	err := dao.db.WithContext(ctx).
+		Where("uid = ? AND id > ?", uid, startId).
+		Order("id").Limit(limit).
+		Find(&cs).Error
+	return cs, err
+}
+
+// CountReplies 每个根评论下面有多少回复
+func (dao *CommentDAO) CountReplies(ctx context.Context, rootIds []int64) (map[int64]int64, error) {
+	type result struct {
+		RootId int64
+		Cnt    int64
+	}
+	var rs []result
+	err := dao.db.WithContext(ctx).Model(&Comment{}).
+		Select("root_id, COUNT(*) AS cnt").
+		Where("root_id IN ?", rootIds).
+		Group("root_id").
+		Scan(&rs).Error
+	if err != nil {
+		return nil, err
+	}
+	res := make(map[int64]int64, len(rs))
+	for _, r := range rs {
+		res[r.RootId] = r.Cnt
+	}
+	return res, nil
+}
+
+// Comment 评论和回复放在同一张表里面。
+// 根评论的 RootId 和 ParentId 都是 0，回复的 RootId 是所在的根评论，ParentId 是回复的那一条
+type Comment struct {
+	Id int64 `gorm:"primaryKey,autoIncrement"`
+	// 导出用户数据的时候按照用户找
+	Uid int64 `gorm:"index"`
+	// 列表页按照资源找根评论
+	Biz      string `gorm:"type:varchar(128);index:comment_biz_type_id"`
+	BizId    int64  `gorm:"index:comment_biz_type_id"`
+	RootId   int64  `gorm:"index"`
+	ParentId int64
+	Content  string `gorm:"type:text"`
+	Ctime    int64
+	Utime    int64
+}
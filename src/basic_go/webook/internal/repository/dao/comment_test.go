@@ -0,0 +1,61 @@
+package dao
+
+import (
+	"basic_go/webook/internal/repository/dao/daotest"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommentDAO_Delete(t *testing.T) {
+	db := daotest.OpenDB(t, &Comment{}, &Interactive{})
+
+	dao := NewCommentDAO(db)
+	ctx := context.Background()
+	insert := func(rootId, parentId int64) int64 {
+		id, err := dao.Insert(ctx, Comment{Uid: 1, Biz: "article", BizId: 1,
+			RootId: rootId, ParentId: parentId, Content: "评论"})
+		require.NoError(t, err)
+		return id
+	}
+	commentCnt := func() int64 {
+		var intr Interactive
+		require.NoError(t, db.Where("biz = ? AND biz_id = ?", "article", 1).First(&intr).Error)
+		return intr.CommentCnt
+	}
+
+	// root
+	//  ├─ a
+	//  │  └─ b
+	//  └─ c
+	// other
+	root := insert(0, 0)
+	a := insert(root, root)
+	insert(root, a)
+	c := insert(root, root)
+	other := insert(0, 0)
+	assert.Equal(t, int64(5), commentCnt())
+
+	// 删除 a 会把 b 一起删掉，c 还在
+	cnt, err := dao.Delete(ctx, Comment{Id: a, Biz: "article", BizId: 1, RootId: root, ParentId: root})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), cnt)
+	assert.Equal(t, int64(3), commentCnt())
+	replies, err := dao.FindReplies(ctx, root, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, replies, 1)
+	assert.Equal(t, c, replies[0].Id)
+
+	// 删除根评论，整棵树都没了
+	cnt, err = dao.Delete(ctx, Comment{Id: root, Biz: "article", BizId: 1})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), cnt)
+	assert.Equal(t, int64(1), commentCnt())
+
+	roots, err := dao.FindRoots(ctx, "article", 1, other+1, 10)
+	require.NoError(t, err)
+	require.Len(t, roots, 1)
+	assert.Equal(t, other, roots[0].Id)
+}
@@ -0,0 +1,23 @@
+// Package daotest 测试用的数据库，DAO 和上面各层的测试都用这一个
+package daotest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// OpenDB 建好 models 这些表的 SQLite 内存数据库。
+// 打开了 TranslateError，唯一索引冲突和 MySQL 一样会转成 gorm.ErrDuplicatedKey
+func OpenDB(t *testing.T, models ...any) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{TranslateError: true})
+	require.NoError(t, err)
+	// 内存数据库每个连接都是独立的，只能用一个连接
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	sqlDB.SetMaxOpenConns(1)
+	require.NoError(t, db.AutoMigrate(models...))
+	return db
+}
@@ -0,0 +1,121 @@
+package dao
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 和 domain.DataExportStatus 的值一样
+const (
+	dataExportStatusPending uint8 = 1
+	dataExportStatusDone    uint8 = 2
+	dataExportStatusFailed  uint8 = 3
+	dataExportStatusExpired uint8 = 4
+)
+
+type DataExportDAO struct {
+	db *gorm.DB
+}
+
+func NewDataExportDAO(db *gorm.DB) *DataExportDAO {
+	return &DataExportDAO{
+		db: db,
+	}
+}
+
+func (dao *DataExportDAO) Insert(ctx context.Context, e DataExport) (int64, error) {
+	now := time.Now().UnixMilli()
+	e.Status = dataExportStatusPending
+	e.Ctime = now
+	// 0 的话马上就能被抢到
+	e.Utime = 0
+	err := dao.db.WithContext(ctx).Create(&e).Error
+	return e.Id, err
+}
+
+func (dao *DataExportDAO) FindById(ctx context.Context, id int64) (DataExport, error) {
+	var e DataExport
+	err := dao.db.WithContext(ctx).Where("id = ?", id).First(&e).Error
+	return e, err
+}
+
+// FindLatest uid 最近的一次导出
+func (dao *DataExportDAO) FindLatest(ctx context.Context, uid int64) (DataExport, error) {
+	var e DataExport
+	err := dao.db.WithContext(ctx).Where("uid = ?", uid).Order("id DESC").First(&e).Error
+	return e, err
+}
+
+// PreemptPending 抢一条等着打包的，utime 早于 before 的才抢，抢到之后更新 utime。
+// 打包到一半挂了的，过一会儿会被重新抢到。没有的话返回 ErrRecordNotFound
+func (dao *DataExportDAO) PreemptPending(ctx context.Context, before int64) (DataExport, error) {
+	var e DataExport
+	err := dao.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("status = ? AND utime < ?", dataExportStatusPending, before).
+			Order("id").
+			First(&e).Error
+		if err != nil {
+			return err
+		}
+		e.Utime = time.Now().UnixMilli()
+		return tx.Model(&DataExport{}).Where("id = ?", e.Id).
+			Update("utime", e.Utime).Error
+	})
+	return e, err
+}
+
+func (dao *DataExportDAO) MarkDone(ctx context.Context, id int64, key string, size int64) error {
+	return dao.db.WithContext(ctx).Model(&DataExport{}).
+		Where("id = ? AND status = ?", id, dataExportStatusPending).
+		Updates(map[string]any{
+			"status": dataExportStatusDone,
+			"key":    key,
+			"size":   size,
+			"utime":  time.Now().UnixMilli(),
+		}).Error
+}
+
+func (dao *DataExportDAO) MarkFailed(ctx context.Context, id int64) error {
+	return dao.db.WithContext(ctx).Model(&DataExport{}).
+		Where("id = ? AND status = ?", id, dataExportStatusPending).
+		Updates(map[string]any{
+			"status": dataExportStatusFailed,
+			"utime":  time.Now().UnixMilli(),
+		}).Error
+}
+
+// FindDoneBefore 打包好了、utime 早于 before 的，按 ID 遍历
+func (dao *DataExportDAO) FindDoneBefore(ctx context.Context, before int64, startId int64, limit int) ([]DataExport, error) {
+	var es []DataExport
+	err := dao.db.WithContext(ctx).
+		Where("status = ? AND utime < ? AND id > ?", dataExportStatusDone, before, startId).
+		Order("id").Limit(limit).
+		Find(&es).Error
+	return es, err
+}
+
+func (dao *DataExportDAO) MarkExpired(ctx context.Context, id int64) error {
+	return dao.db.WithContext(ctx).Model(&DataExport{}).
+		Where("id = ? AND status = ?", id, dataExportStatusDone).
+		Updates(map[string]any{
+			"status": dataExportStatusExpired,
+			"utime":  time.Now().UnixMilli(),
+		}).Error
+}
+
+// DataExport 用户数据导出，打包好的 ZIP 放在对象存储里面
+type DataExport struct {
+	Id     int64 `gorm:"primaryKey,autoIncrement"`
+	Uid    int64 `gorm:"index"`
+	Status uint8 `gorm:"index:data_export_status_utime"`
+	// 对象存储里面的 key，打包好了才有
+	Key   string `gorm:"type:varchar(256)"`
+	Size  int64
+	Ctime int64
+	// 等着打包的时候是上一次被抢到的时间，打包好了之后就是打包好的时间
+	Utime int64 `gorm:"index:data_export_status_utime"`
+}
@@ -0,0 +1,77 @@
+package dao
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type FeedDAO struct {
+	db *gorm.DB
+}
+
+func NewFeedDAO(db *gorm.DB) *FeedDAO {
+	return &FeedDAO{
+		db: db,
+	}
+}
+
+// CreatePushEvents 批量写收件箱。(uid, biz, biz_id) 已经有了的跳过，消费者重试的时候不会重复
+func (dao *FeedDAO) CreatePushEvents(ctx context.Context, evts []FeedPushEvent) error {
+	if len(evts) == 0 {
+		return nil
+	}
+	return dao.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).
+		CreateInBatches(evts, 200).Error
+}
+
+// CreatePullEvent 写发件箱，已经有了就跳过
+func (dao *FeedDAO) CreatePullEvent(ctx context.Context, evt FeedPullEvent) error {
+	return dao.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&evt).Error
+}
+
+// FindPushEvents uid 收件箱里面排在 (maxTime, maxId) 后面的，按 (ctime, id) 倒序。
+// 同一毫秒可能有好几条，只按 ctime 翻页会漏掉
+func (dao *FeedDAO) FindPushEvents(ctx context.Context, uid int64, maxTime int64, maxId int64, limit int) ([]FeedPushEvent, error) {
+	var evts []FeedPushEvent
+	err := dao.db.WithContext(ctx).
+		Where("uid = ? AND (ctime < ? OR (ctime = ? AND id < ?))", uid, maxTime, maxTime, maxId).
+		Order("ctime DESC, id DESC").Limit(limit).
+		Find(&evts).Error
+	return evts, err
+}
+
+// FindPullEvents 这些人发件箱里面排在 (maxTime, maxId) 后面的，按 (ctime, id) 倒序
+func (dao *FeedDAO) FindPullEvents(ctx context.Context, uids []int64, maxTime int64, maxId int64, limit int) ([]FeedPullEvent, error) {
+	var evts []FeedPullEvent
+	err := dao.db.WithContext(ctx).
+		Where("uid IN ? AND (ctime < ? OR (ctime = ? AND id < ?))", uids, maxTime, maxTime, maxId).
+		Order("ctime DESC, id DESC").Limit(limit).
+		Find(&evts).Error
+	return evts, err
+}
+
+// FeedPushEvent 收件箱，推模型。Uid 是收到动态的人
+type FeedPushEvent struct {
+	Id    int64  `gorm:"primaryKey,autoIncrement"`
+	Uid   int64  `gorm:"index:push_uid_ctime;uniqueIndex:push_uid_biz"`
+	Type  string `gorm:"type:varchar(32)"`
+	Biz   string `gorm:"type:varchar(128);uniqueIndex:push_uid_biz"`
+	BizId int64  `gorm:"uniqueIndex:push_uid_biz"`
+	// JSON 格式的扩展字段
+	Content string `gorm:"type:text"`
+	Ctime   int64  `gorm:"index:push_uid_ctime"`
+}
+
+// FeedPullEvent 发件箱，拉模型。Uid 是发出动态的人
+type FeedPullEvent struct {
+	Id      int64  `gorm:"primaryKey,autoIncrement"`
+	Uid     int64  `gorm:"index:pull_uid_ctime;uniqueIndex:pull_uid_biz"`
+	Type    string `gorm:"type:varchar(32)"`
+	Biz     string `gorm:"type:varchar(128);uniqueIndex:pull_uid_biz"`
+	BizId   int64  `gorm:"uniqueIndex:pull_uid_biz"`
+	Content string `gorm:"type:text"`
+	Ctime   int64  `gorm:"index:pull_uid_ctime"`
+}
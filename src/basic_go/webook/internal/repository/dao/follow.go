@@ -0,0 +1,116 @@
+package dao
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 取消关注不删数据，只改状态
+const (
+	FollowStatusActive   uint8 = 1
+	FollowStatusInactive uint8 = 2
+)
+
+type FollowDAO struct {
+	db *gorm.DB
+}
+
+func NewFollowDAO(db *gorm.DB) *FollowDAO {
+	return &FollowDAO{
+		db: db,
+	}
+}
+
+// Follow 返回 true 代表之前没有关注，这次真的改了数据
+func (dao *FollowDAO) Follow(ctx context.Context, follower, followee int64) (bool, error) {
+	now := time.Now().UnixMilli()
+	db := dao.db.WithContext(ctx)
+	// 以前关注过又取消了
+	res := db.Model(&FollowRelation{}).
+		Where("follower = ? AND followee = ? AND status = ?", follower, followee, FollowStatusInactive).
+		Updates(map[string]any{
+			"status": FollowStatusActive,
+			"utime":  now,
+		})
+	if res.Error != nil || res.RowsAffected > 0 {
+		return res.RowsAffected > 0, res.Error
+	}
+	// 从来没有关注过。已经关注了的话什么都不做
+	res = db.Clauses(clause.OnConflict{DoNothing: true}).Create(&FollowRelation{
+		Follower: follower,
+		Followee: followee,
+		Status:   FollowStatusActive,
+		Ctime:    now,
+		Utime:    now,
+	})
+	return res.RowsAffected > 0, res.Error
+}
+
+// Unfollow 返回 true 代表之前是关注的
+func (dao *FollowDAO) Unfollow(ctx context.Context, follower, followee int64) (bool, error) {
+	res := dao.db.WithContext(ctx).Model(&FollowRelation{}).
+		Where("follower = ? AND followee = ? AND status = ?", follower, followee, FollowStatusActive).
+		Updates(map[string]any{
+			"status": FollowStatusInactive,
+			"utime":  time.Now().UnixMilli(),
+		})
+	return res.RowsAffected > 0, res.Error
+}
+
+func (dao *FollowDAO) IsFollowing(ctx context.Context, follower, followee int64) (bool, error) {
+	var cnt int64
+	err := dao.db.WithContext(ctx).Model(&FollowRelation{}).
+		Where("follower = ? AND followee = ? AND status = ?", follower, followee, FollowStatusActive).
+		Count(&cnt).Error
+	return cnt > 0, err
+}
+
+// FindFollowers uid 的粉丝，按关注的先后倒序。maxId 是上一页最后一条的 ID
+func (dao *FollowDAO) FindFollowers(ctx context.Context, uid int64, maxId int64, limit int) ([]FollowRelation, error) {
+	var rs []FollowRelation
+	err := dao.db.WithContext(ctx).
+		Where("followee = ? AND status = ? AND id < ?", uid, FollowStatusActive, maxId).
+		Order("id DESC").Limit(limit).
+		Find(&rs).Error
+	return rs, err
+}
+
+// FindFollowees uid 关注的人，按关注的先后倒序。maxId 是上一页最后一条的 ID
+func (dao *FollowDAO) FindFollowees(ctx context.Context, uid int64, maxId int64, limit int) ([]FollowRelation, error) {
+	var rs []FollowRelation
+	err := dao.db.WithContext(ctx).
+		Where("follower = ? AND status = ? AND id < ?", uid, FollowStatusActive, maxId).
+		Order("id DESC").Limit(limit).
+		Find(&rs).Error
+	return rs, err
+}
+
+func (dao *FollowDAO) CountFollowers(ctx context.Context, uid int64) (int64, error) {
+	var cnt int64
+	err := dao.db.WithContext(ctx).Model(&FollowRelation{}).
+		Where("followee = ? AND status = ?", uid, FollowStatusActive).
+		Count(&cnt).Error
+	return cnt, err
+}
+
+func (dao *FollowDAO) CountFollowees(ctx context.Context, uid int64) (int64, error) {
+	var cnt int64
+	err := dao.db.WithContext(ctx).Model(&FollowRelation{}).
+		Where("follower = ? AND status = ?", uid, FollowStatusActive).
+		Count(&cnt).Error
+	return cnt, err
+}
+
+// FollowRelation 一条关注关系。
+// 查关注列表用 follower 开头的联合唯一索引，查粉丝列表用 followee 上面的索引
+type FollowRelation struct {
+	Id       int64 `gorm:"primaryKey,autoIncrement"`
+	Follower int64 `gorm:"uniqueIndex:follower_followee"`
+	Followee int64 `gorm:"uniqueIndex:follower_followee;index"`
+	Status   uint8
+	Ctime    int64
+	Utime    int64
+}
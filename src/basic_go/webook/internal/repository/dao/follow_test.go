@@ -0,0 +1,60 @@
+package dao
+
+import (
+	"basic_go/webook/internal/repository/dao/daotest"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFollowDAO(t *testing.T) {
+	db := daotest.OpenDB(t, &FollowRelation{})
+	dao := NewFollowDAO(db)
+	ctx := context.Background()
+
+	changed, err := dao.Follow(ctx, 1, 2)
+	require.NoError(t, err)
+	assert.True(t, changed)
+	// 重复关注不会改数据，计数也就不会多加
+	changed, err = dao.Follow(ctx, 1, 2)
+	require.NoError(t, err)
+	assert.False(t, changed)
+	_, err = dao.Follow(ctx, 3, 2)
+	require.NoError(t, err)
+
+	cnt, err := dao.CountFollowers(ctx, 2)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), cnt)
+	followers, err := dao.FindFollowers(ctx, 2, 1<<62, 1)
+	require.NoError(t, err)
+	require.Len(t, followers, 1)
+	assert.Equal(t, int64(3), followers[0].Follower)
+	// 翻到下一页
+	followers, err = dao.FindFollowers(ctx, 2, followers[0].Id, 10)
+	require.NoError(t, err)
+	require.Len(t, followers, 1)
+	assert.Equal(t, int64(1), followers[0].Follower)
+
+	changed, err = dao.Unfollow(ctx, 1, 2)
+	require.NoError(t, err)
+	assert.True(t, changed)
+	changed, err = dao.Unfollow(ctx, 1, 2)
+	require.NoError(t, err)
+	assert.False(t, changed)
+	ok, err := dao.IsFollowing(ctx, 1, 2)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	// 取消之后再关注，复用原来那一条
+	changed, err = dao.Follow(ctx, 1, 2)
+	require.NoError(t, err)
+	assert.True(t, changed)
+	ok, err = dao.IsFollowing(ctx, 1, 2)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	cnt, err = dao.CountFollowees(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), cnt)
+}
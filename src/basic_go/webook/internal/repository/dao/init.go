@@ -4,5 +4,9 @@ import "gorm.io/gorm"
 
 func InitTables(db *gorm.DB) error {
 	// 严格来说，这不是一个好的实践
-	return db.AutoMigrate(&User{})
+	return db.AutoMigrate(&User{}, &TwoFactor{}, &RecoveryCode{},
+		&Article{}, &PublishedArticle{}, &Interactive{}, &UserLikeBiz{}, &Comment{},
+		&FollowRelation{}, &FeedPushEvent{}, &FeedPullEvent{},
+		&Notification{}, &Reward{}, &Account{}, &AccountTransaction{}, &AccountEntry{}, &Upload{}, &AuditLog{}, &Job{}, &AsyncSms{},
+		&AccountDeletion{}, &DataExport{})
 }
@@ -0,0 +1,183 @@
+package dao
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type InteractiveDAO struct {
+	db *gorm.DB
+}
+
+func NewInteractiveDAO(db *gorm.DB) *InteractiveDAO {
+	return &InteractiveDAO{
+		db: db,
+	}
+}
+
+// BatchIncrReadCnt 用一条 INSERT ... ON DUPLICATE KEY UPDATE 批量增加阅读数。
+// 调用者要先把同一个资源的合并起来，cnts 就是每个资源要加多少
+func (dao *InteractiveDAO) BatchIncrReadCnt(ctx context.Context, bizs []string, bizIds []int64, cnts []int64) error {
+	if len(bizs) == 0 {
+		return nil
+	}
+	now := time.Now().UnixMilli()
+	intrs := make([]Interactive, 0, len(bizs))
+	for i := range bizs {
+		intrs = append(intrs, Interactive{
+			Biz:     bizs[i],
+			BizId:   bizIds[i],
+			ReadCnt: cnts[i],
+			Ctime:   now,
+			Utime:   now,
+		})
+	}
+	return dao.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "biz"}, {Name: "biz_id"}},
+		DoUpdates: clause.Assignments(map[string]any{
+			"read_cnt": gorm.Expr("`read_cnt` + VALUES(`read_cnt`)"),
+			"utime":    now,
+		}),
+	}).Create(&intrs).Error
+}
+
+func (dao *InteractiveDAO) Get(ctx context.Context, biz string, bizId int64) (Interactive, error) {
+	var intr Interactive
+	err := dao.db.WithContext(ctx).
+		Where("biz = ? AND biz_id = ?", biz, bizId).
+		First(&intr).Error
+	return intr, err
+}
+
+func (dao *InteractiveDAO) GetByIds(ctx context.Context, biz string, bizIds []int64) ([]Interactive, error) {
+	var intrs []Interactive
+	err := dao.db.WithContext(ctx).
+		Where("biz = ? AND biz_id IN ?", biz, bizIds).
+		Find(&intrs).Error
+	return intrs, err
+}
+
+// InsertLikeInfo 点赞，同时点赞数加一。返回 false 代表已经点过赞了，什么都没有改
+func (dao *InteractiveDAO) InsertLikeInfo(ctx context.Context, biz string, bizId int64, uid int64) (bool, error) {
+	now := time.Now().UnixMilli()
+	changed := false
+	err := dao.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// 以前点过又取消了
+		res := tx.Model(&UserLikeBiz{}).
+			Where("uid = ? AND biz = ? AND biz_id = ? AND status = ?", uid, biz, bizId, LikeStatusInactive).
+			Updates(map[string]any{
+				"status": LikeStatusActive,
+				"utime":  now,
+			})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			res = tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&UserLikeBiz{
+				Uid:    uid,
+				Biz:    biz,
+				BizId:  bizId,
+				Status: LikeStatusActive,
+				Ctime:  now,
+				Utime:  now,
+			})
+			if res.Error != nil {
+				return res.Error
+			}
+		}
+		if res.RowsAffected == 0 {
+			return nil
+		}
+		changed = true
+		return incrLikeCnt(tx, biz, bizId, 1, now)
+	})
+	return changed, err
+}
+
+// DeleteLikeInfo 取消点赞，同时点赞数减一。返回 false 代表本来就没有点赞
+func (dao *InteractiveDAO) DeleteLikeInfo(ctx context.Context, biz string, bizId int64, uid int64) (bool, error) {
+	now := time.Now().UnixMilli()
+	changed := false
+	err := dao.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&UserLikeBiz{}).
+			Where("uid = ? AND biz = ? AND biz_id = ? AND status = ?", uid, biz, bizId, LikeStatusActive).
+			Updates(map[string]any{
+				"status": LikeStatusInactive,
+				"utime":  now,
+			})
+		if res.Error != nil || res.RowsAffected == 0 {
+			return res.Error
+		}
+		changed = true
+		return incrLikeCnt(tx, biz, bizId, -1, now)
+	})
+	return changed, err
+}
+
+func (dao *InteractiveDAO) Liked(ctx context.Context, biz string, bizId int64, uid int64) (bool, error) {
+	var cnt int64
+	err := dao.db.WithContext(ctx).Model(&UserLikeBiz{}).
+		Where("uid = ? AND biz = ? AND biz_id = ? AND status = ?", uid, biz, bizId, LikeStatusActive).
+		Count(&cnt).Error
+	return cnt > 0, err
+}
+
+// FindLikesByUid uid 点过赞的，不包括取消了的，按照 ID 从小到大。startId 是上一页最后一条的 ID
+func (dao *InteractiveDAO) FindLikesByUid(ctx context.Context, uid int64, startId int64, limit int) ([]UserLikeBiz, error) {
+	var ls []UserLikeBiz
+	err := dao.db.WithContext(ctx).
+		Where("uid = ? AND status = ? AND id > ?", uid, LikeStatusActive, startId).
+		Order("id").Limit(limit).
+		Find(&ls).Error
+	return ls, err
+}
+
+func incrLikeCnt(tx *gorm.DB, biz string, bizId int64, delta int64, now int64) error {
+	return tx.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "biz"}, {Name: "biz_id"}},
+		DoUpdates: clause.Assignments(map[string]any{
+			"like_cnt": gorm.Expr("`like_cnt` + ?", delta),
+			"utime":    now,
+		}),
+	}).Create(&Interactive{
+		Biz:     biz,
+		BizId:   bizId,
+		LikeCnt: delta,
+		Ctime:   now,
+		Utime:   now,
+	}).Error
+}
+
+// 取消点赞不删数据，只改状态
+const (
+	LikeStatusActive   uint8 = 1
+	LikeStatusInactive uint8 = 2
+)
+
+// UserLikeBiz 谁给哪个资源点了赞
+type UserLikeBiz struct {
+	Id     int64  `gorm:"primaryKey,autoIncrement"`
+	Uid    int64  `gorm:"uniqueIndex:uid_biz_type_id"`
+	Biz    string `gorm:"type:varchar(128);uniqueIndex:uid_biz_type_id"`
+	BizId  int64  `gorm:"uniqueIndex:uid_biz_type_id"`
+	Status uint8
+	Ctime  int64
+	Utime  int64
+}
+
+// Interactive 阅读数、点赞数、收藏数放在一张表里面
+type Interactive struct {
+	Id int64 `gorm:"primaryKey,autoIncrement"`
+	// 联合唯一索引，biz 在前面是因为查询基本上都会带上 biz
+	Biz        string `gorm:"type:varchar(128);uniqueIndex:biz_type_id"`
+	BizId      int64  `gorm:"uniqueIndex:biz_type_id"`
+	ReadCnt    int64
+	LikeCnt    int64
+	CollectCnt int64
+	CommentCnt int64
+	Ctime      int64
+	Utime      int64
+}
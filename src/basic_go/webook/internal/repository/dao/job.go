@@ -0,0 +1,144 @@
+package dao
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrNoJob = errors.New("没有可以执行的任务")
+	// ErrJobLost 任务已经不归自己了，一般是续约不及时被别的实例抢走了
+	ErrJobLost = errors.New("任务已经被别的实例抢走了")
+)
+
+// 和 domain.JobStatus 的值一样
+const (
+	jobStatusWaiting uint8 = 1
+	jobStatusRunning uint8 = 2
+	jobStatusPaused  uint8 = 3
+)
+
+type JobDAO struct {
+	db *gorm.DB
+}
+
+func NewJobDAO(db *gorm.DB) *JobDAO {
+	return &JobDAO{
+		db: db,
+	}
+}
+
+// Insert 同名的任务已经有了就什么都不做，改表达式之类的直接改表
+func (dao *JobDAO) Insert(ctx context.Context, j Job) error {
+	now := time.Now().UnixMilli()
+	j.Ctime = now
+	j.Utime = now
+	return dao.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&j).Error
+}
+
+// Preempt 抢一个到时间了的任务，或者是 utime 早于 staleBefore 的运行中的任务，
+// 后者说明原来的实例很久没有续约了，多半是挂了。
+// 靠 version 做乐观锁，抢失败了说明被别的实例抢走了，接着找下一个
+func (dao *JobDAO) Preempt(ctx context.Context, owner string, staleBefore int64) (Job, error) {
+	db := dao.db.WithContext(ctx)
+	for {
+		if err := ctx.Err(); err != nil {
+			return Job{}, err
+		}
+		now := time.Now().UnixMilli()
+		var j Job
+		err := db.Where("(status = ? AND next_time <= ?) OR (status = ? AND utime < ?)",
+			jobStatusWaiting, now, jobStatusRunning, staleBefore).
+			First(&j).Error
+		if err == gorm.ErrRecordNotFound {
+			return Job{}, ErrNoJob
+		}
+		if err != nil {
+			return Job{}, err
+		}
+		res := db.Model(&Job{}).
+			Where("id = ? AND version = ?", j.Id, j.Version).
+			Updates(map[string]any{
+				"status":  jobStatusRunning,
+				"owner":   owner,
+				"version": j.Version + 1,
+				"utime":   now,
+			})
+		if res.Error != nil {
+			return Job{}, res.Error
+		}
+		if res.RowsAffected == 1 {
+			j.Status = jobStatusRunning
+			j.Owner = owner
+			j.Version++
+			j.Utime = now
+			return j, nil
+		}
+	}
+}
+
+// Heartbeat 续约，任务已经不归 owner 了返回 ErrJobLost。
+// version 是 Preempt 返回的版本，同一个 owner 重新抢到之后，老的那次运行也不能再续约
+func (dao *JobDAO) Heartbeat(ctx context.Context, id int64, owner string, version int64) error {
+	res := dao.db.WithContext(ctx).Model(&Job{}).
+		Where("id = ? AND owner = ? AND version = ? AND status = ?", id, owner, version, jobStatusRunning).
+		Update("utime", time.Now().UnixMilli())
+	return jobResult(res)
+}
+
+// Release 跑完了，放回去等下一次。任务已经不归 owner 了返回 ErrJobLost
+func (dao *JobDAO) Release(ctx context.Context, id int64, owner string, version int64, nextTime int64) error {
+	res := dao.db.WithContext(ctx).Model(&Job{}).
+		Where("id = ? AND owner = ? AND version = ? AND status = ?", id, owner, version, jobStatusRunning).
+		Updates(map[string]any{
+			"status":    jobStatusWaiting,
+			"owner":     "",
+			"version":   gorm.Expr("version + 1"),
+			"next_time": nextTime,
+			"utime":     time.Now().UnixMilli(),
+		})
+	return jobResult(res)
+}
+
+// Pause 暂停任务，不会再被调度，比如表达式写错了算不出下一次的时间。
+// 改好之后直接改表恢复。任务已经不归 owner 了返回 ErrJobLost
+func (dao *JobDAO) Pause(ctx context.Context, id int64, owner string, version int64) error {
+	res := dao.db.WithContext(ctx).Model(&Job{}).
+		Where("id = ? AND owner = ? AND version = ? AND status = ?", id, owner, version, jobStatusRunning).
+		Updates(map[string]any{
+			"status":  jobStatusPaused,
+			"owner":   "",
+			"version": gorm.Expr("version + 1"),
+			"utime":   time.Now().UnixMilli(),
+		})
+	return jobResult(res)
+}
+
+func jobResult(res *gorm.DB) error {
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrJobLost
+	}
+	return nil
+}
+
+// Job 定时任务。运行中的任务 utime 就是最后一次续约的时间
+type Job struct {
+	Id         int64  `gorm:"primaryKey,autoIncrement"`
+	Name       string `gorm:"type:varchar(128);uniqueIndex"`
+	Executor   string `gorm:"type:varchar(128)"`
+	Cfg        string `gorm:"type:text"`
+	Expression string `gorm:"type:varchar(128)"`
+	Status     uint8  `gorm:"index:job_status_next_time"`
+	Version    int64
+	NextTime   int64  `gorm:"index:job_status_next_time"`
+	Owner      string `gorm:"type:varchar(128)"`
+	Ctime      int64
+	Utime      int64
+}
@@ -0,0 +1,83 @@
+package dao
+
+import (
+	"basic_go/webook/internal/repository/dao/daotest"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJobDAO_Preempt(t *testing.T) {
+	db := daotest.OpenDB(t, &Job{})
+	dao := NewJobDAO(db)
+	ctx := context.Background()
+	now := time.Now().UnixMilli()
+	require.NoError(t, dao.Insert(ctx, Job{Name: "ranking", Executor: "ranking",
+		Expression: "@every 1m", Status: jobStatusWaiting, NextTime: now - 1}))
+	// 同名的不会覆盖
+	require.NoError(t, dao.Insert(ctx, Job{Name: "ranking", Executor: "other",
+		Expression: "@every 1h", Status: jobStatusWaiting, NextTime: now - 1}))
+	// 还没到时间的、暂停了的都不会被抢到
+	require.NoError(t, dao.Insert(ctx, Job{Name: "later", Status: jobStatusWaiting, NextTime: now + 60_000}))
+	require.NoError(t, dao.Insert(ctx, Job{Name: "paused", Status: jobStatusPaused, NextTime: now - 1}))
+
+	j, err := dao.Preempt(ctx, "a", now-60_000)
+	require.NoError(t, err)
+	assert.Equal(t, "ranking", j.Executor)
+	assert.Equal(t, "a", j.Owner)
+	assert.Equal(t, int64(1), j.Version)
+	// 已经被 a 抢走了
+	_, err = dao.Preempt(ctx, "b", now-60_000)
+	assert.Equal(t, ErrNoJob, err)
+
+	require.NoError(t, dao.Heartbeat(ctx, j.Id, "a", 1))
+	assert.Equal(t, ErrJobLost, dao.Heartbeat(ctx, j.Id, "b", 1))
+
+	// a 很久没有续约，b 把任务抢过去，a 就不能再续约和释放了
+	j, err = dao.Preempt(ctx, "b", time.Now().UnixMilli()+1)
+	require.NoError(t, err)
+	assert.Equal(t, "b", j.Owner)
+	assert.Equal(t, int64(2), j.Version)
+	assert.Equal(t, ErrJobLost, dao.Heartbeat(ctx, j.Id, "a", 1))
+	assert.Equal(t, ErrJobLost, dao.Release(ctx, j.Id, "a", 1, now))
+
+	// b 自己又把它抢回来了，之前那一次运行也不能再续约和释放
+	j, err = dao.Preempt(ctx, "b", time.Now().UnixMilli()+1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), j.Version)
+	assert.Equal(t, ErrJobLost, dao.Heartbeat(ctx, j.Id, "b", 2))
+	assert.Equal(t, ErrJobLost, dao.Release(ctx, j.Id, "b", 2, now))
+
+	next := now + 60_000
+	require.NoError(t, dao.Release(ctx, j.Id, "b", 3, next))
+	var got Job
+	require.NoError(t, db.First(&got, j.Id).Error)
+	assert.Equal(t, jobStatusWaiting, got.Status)
+	assert.Equal(t, next, got.NextTime)
+	assert.Equal(t, "", got.Owner)
+	assert.Equal(t, int64(4), got.Version)
+	_, err = dao.Preempt(ctx, "a", now-60_000)
+	assert.Equal(t, ErrNoJob, err)
+}
+
+func TestJobDAO_Pause(t *testing.T) {
+	db := daotest.OpenDB(t, &Job{})
+	dao := NewJobDAO(db)
+	ctx := context.Background()
+	now := time.Now().UnixMilli()
+	require.NoError(t, dao.Insert(ctx, Job{Name: "bad", Expression: "@every", Status: jobStatusWaiting, NextTime: now - 1}))
+	j, err := dao.Preempt(ctx, "a", now-60_000)
+	require.NoError(t, err)
+	assert.Equal(t, ErrJobLost, dao.Pause(ctx, j.Id, "b", j.Version))
+	require.NoError(t, dao.Pause(ctx, j.Id, "a", j.Version))
+
+	var got Job
+	require.NoError(t, db.First(&got, j.Id).Error)
+	assert.Equal(t, jobStatusPaused, got.Status)
+	// 续约超时了也不会再被抢到
+	_, err = dao.Preempt(ctx, "b", time.Now().UnixMilli()+60_000)
+	assert.Equal(t, ErrNoJob, err)
+}
@@ -0,0 +1,150 @@
+package dao
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	NotificationStatusUnread uint8 = 1
+	NotificationStatusRead   uint8 = 2
+)
+
+type NotificationDAO struct {
+	db *gorm.DB
+	// 合并的时候最多保留最近几个人
+	maxActors int
+}
+
+func NewNotificationDAO(db *gorm.DB) *NotificationDAO {
+	return &NotificationDAO{
+		db:        db,
+		maxActors: 3,
+	}
+}
+
+// Insert 直接插入一条新的通知，同一个事件已经插入过了就什么都不做
+func (dao *NotificationDAO) Insert(ctx context.Context, n Notification) error {
+	now := time.Now().UnixMilli()
+	n.Status = NotificationStatusUnread
+	n.Ctime = now
+	n.Utime = now
+	return dao.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&n).Error
+}
+
+// Aggregate 有同一个目标同一种还没读的通知，就把 actor 合并进去；没有就插入一条新的。
+// 合并进去的事件重复了靠 actor 去重，插入的事件重复了靠 event_id 去重
+func (dao *NotificationDAO) Aggregate(ctx context.Context, n Notification, actor int64) error {
+	return dao.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now().UnixMilli()
+		var old Notification
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("uid = ? AND type = ? AND biz = ? AND biz_id = ? AND status = ?",
+				n.Uid, n.Type, n.Biz, n.BizId, NotificationStatusUnread).
+			First(&old).Error
+		if err == gorm.ErrRecordNotFound {
+			actors, err := json.Marshal([]int64{actor})
+			if err != nil {
+				return err
+			}
+			n.Actors = string(actors)
+			n.ActorCnt = 1
+			n.Status = NotificationStatusUnread
+			n.Ctime = now
+			n.Utime = now
+			return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&n).Error
+		}
+		if err != nil {
+			return err
+		}
+		var actors []int64
+		err = json.Unmarshal([]byte(old.Actors), &actors)
+		if err != nil {
+			return err
+		}
+		cnt := old.ActorCnt
+		// 最近刚出现过的就不重复算了，比如点赞之后取消再点
+		if idx := slices.Index(actors, actor); idx >= 0 {
+			actors = slices.Delete(actors, idx, idx+1)
+		} else {
+			cnt++
+		}
+		actors = append([]int64{actor}, actors...)
+		if len(actors) > dao.maxActors {
+			actors = actors[:dao.maxActors]
+		}
+		val, err := json.Marshal(actors)
+		if err != nil {
+			return err
+		}
+		updates := map[string]any{
+			"actors":    string(val),
+			"actor_cnt": cnt,
+			"utime":     now,
+		}
+		if n.Content != "" {
+			updates["content"] = n.Content
+		}
+		if n.Title != "" {
+			updates["title"] = n.Title
+		}
+		return tx.Model(&Notification{}).Where("id = ?", old.Id).Updates(updates).Error
+	})
+}
+
+// FindByUid 最近有更新的在前面
+func (dao *NotificationDAO) FindByUid(ctx context.Context, uid int64, offset int, limit int) ([]Notification, error) {
+	var ns []Notification
+	err := dao.db.WithContext(ctx).
+		Where("uid = ?", uid).
+		Order("utime DESC").
+		Offset(offset).Limit(limit).
+		Find(&ns).Error
+	return ns, err
+}
+
+func (dao *NotificationDAO) CountUnread(ctx context.Context, uid int64) (int64, error) {
+	var cnt int64
+	err := dao.db.WithContext(ctx).Model(&Notification{}).
+		Where("uid = ? AND status = ?", uid, NotificationStatusUnread).
+		Count(&cnt).Error
+	return cnt, err
+}
+
+// MarkRead 只能标记自己的通知
+func (dao *NotificationDAO) MarkRead(ctx context.Context, uid int64, id int64) error {
+	return dao.db.WithContext(ctx).Model(&Notification{}).
+		Where("id = ? AND uid = ? AND status = ?", id, uid, NotificationStatusUnread).
+		Update("status", NotificationStatusRead).Error
+}
+
+func (dao *NotificationDAO) MarkAllRead(ctx context.Context, uid int64) error {
+	return dao.db.WithContext(ctx).Model(&Notification{}).
+		Where("uid = ? AND status = ?", uid, NotificationStatusUnread).
+		Update("status", NotificationStatusRead).Error
+}
+
+// Notification 站内通知。
+// 合并的时候按照 (uid, type, biz, biz_id, status) 找还没读的那一条。
+// EventId 是插入这一条的事件，(uid, event_id) 唯一，事件重放的时候不会重复插入
+type Notification struct {
+	Id      int64  `gorm:"primaryKey,autoIncrement"`
+	EventId string `gorm:"type:varchar(128);uniqueIndex:notification_uid_event"`
+	Uid     int64  `gorm:"index:notification_uid_target;uniqueIndex:notification_uid_event"`
+	Type    string `gorm:"type:varchar(32);index:notification_uid_target"`
+	Biz     string `gorm:"type:varchar(128);index:notification_uid_target"`
+	BizId   int64  `gorm:"index:notification_uid_target"`
+	Title   string `gorm:"type:varchar(4096)"`
+	// JSON 数组，最近的几个人
+	Actors   string `gorm:"type:varchar(256)"`
+	ActorCnt int64
+	Content  string `gorm:"type:text"`
+	Status   uint8  `gorm:"index:notification_uid_target"`
+	Ctime    int64
+	Utime    int64
+}
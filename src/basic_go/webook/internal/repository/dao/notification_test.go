@@ -0,0 +1,74 @@
+package dao
+
+import (
+	"basic_go/webook/internal/repository/dao/daotest"
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNotificationDAO_Aggregate(t *testing.T) {
+	db := daotest.OpenDB(t, &Notification{})
+	dao := NewNotificationDAO(db)
+	ctx := context.Background()
+	like := Notification{Uid: 1, Type: "like", Biz: "article", BizId: 10}
+
+	for i, actor := range []int64{2, 3, 4, 5, 3} {
+		like.EventId = fmt.Sprintf("evt-%d", i)
+		require.NoError(t, dao.Aggregate(ctx, like, actor))
+	}
+	ns, err := dao.FindByUid(ctx, 1, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, ns, 1)
+	// 3 重复出现，只挪到最前面，不重复计数
+	assert.Equal(t, int64(4), ns[0].ActorCnt)
+	assert.Equal(t, "[3,5,4]", ns[0].Actors)
+	cnt, err := dao.CountUnread(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), cnt)
+
+	// 读了之后再有人点赞，是一条新的通知
+	require.NoError(t, dao.MarkAllRead(ctx, 1))
+	like.EventId = "evt-5"
+	require.NoError(t, dao.Aggregate(ctx, like, 6))
+	ns, err = dao.FindByUid(ctx, 1, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, ns, 2)
+	cnt, err = dao.CountUnread(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), cnt)
+
+	// 别人的通知标记不了
+	require.NoError(t, dao.MarkRead(ctx, 2, ns[0].Id))
+	cnt, err = dao.CountUnread(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), cnt)
+}
+
+// TestNotificationDAO_Insert 同一个事件重放不会重复插入
+func TestNotificationDAO_Insert(t *testing.T) {
+	db := daotest.OpenDB(t, &Notification{})
+	dao := NewNotificationDAO(db)
+	ctx := context.Background()
+	n := Notification{EventId: "evt-1", Uid: 1, Type: "article_published", Biz: "article", BizId: 10}
+	require.NoError(t, dao.Insert(ctx, n))
+	require.NoError(t, dao.Insert(ctx, n))
+	n.EventId = "evt-2"
+	require.NoError(t, dao.Insert(ctx, n))
+	cnt, err := dao.CountUnread(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), cnt)
+
+	// 已经读了的通知，合并的事件重放也不会变成一条新的
+	require.NoError(t, dao.MarkAllRead(ctx, 1))
+	like := Notification{EventId: "evt-3", Uid: 1, Type: "like", Biz: "article", BizId: 10}
+	require.NoError(t, dao.Aggregate(ctx, like, 2))
+	require.NoError(t, dao.MarkAllRead(ctx, 1))
+	require.NoError(t, dao.Aggregate(ctx, like, 2))
+	cnt, err = dao.CountUnread(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), cnt)
+}
@@ -0,0 +1,69 @@
+package dao
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type RewardDAO struct {
+	db *gorm.DB
+}
+
+func NewRewardDAO(db *gorm.DB) *RewardDAO {
+	return &RewardDAO{
+		db: db,
+	}
+}
+
+func (dao *RewardDAO) Insert(ctx context.Context, r Reward) (int64, error) {
+	now := time.Now().UnixMilli()
+	r.Ctime = now
+	r.Utime = now
+	err := dao.db.WithContext(ctx).Create(&r).Error
+	return r.Id, err
+}
+
+func (dao *RewardDAO) GetById(ctx context.Context, id int64) (Reward, error) {
+	var r Reward
+	err := dao.db.WithContext(ctx).Where("id = ?", id).First(&r).Error
+	return r, err
+}
+
+// UpdateStatus 只有当前是 from 状态才会改，返回 false 代表没有改。
+// 回调和对账可能同时处理一个订单，靠这个保证状态只流转一次
+func (dao *RewardDAO) UpdateStatus(ctx context.Context, id int64, from uint8, to uint8) (bool, error) {
+	res := dao.db.WithContext(ctx).Model(&Reward{}).
+		Where("id = ? AND status = ?", id, from).
+		Updates(map[string]any{
+			"status": to,
+			"utime":  time.Now().UnixMilli(),
+		})
+	return res.RowsAffected > 0, res.Error
+}
+
+// FindByStatus 找 ctime 早于 before 的某个状态的订单，按 ID 遍历
+func (dao *RewardDAO) FindByStatus(ctx context.Context, status uint8, before int64, startId int64, limit int) ([]Reward, error) {
+	var rs []Reward
+	err := dao.db.WithContext(ctx).
+		Where("status = ? AND ctime < ? AND id > ?", status, before, startId).
+		Order("id").Limit(limit).
+		Find(&rs).Error
+	return rs, err
+}
+
+// Reward 打赏订单，金额的单位是分
+type Reward struct {
+	Id        int64  `gorm:"primaryKey,autoIncrement"`
+	Biz       string `gorm:"type:varchar(128)"`
+	BizId     int64
+	BizName   string `gorm:"type:varchar(1024)"`
+	TargetUid int64  `gorm:"index"`
+	Uid       int64  `gorm:"index"`
+	Amount    int64
+	// 对账的时候按状态和时间找
+	Status uint8 `gorm:"index:status_ctime"`
+	Ctime  int64 `gorm:"index:status_ctime"`
+	Utime  int64
+}
@@ -0,0 +1,122 @@
+package dao
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type TwoFactorDAO struct {
+	db *gorm.DB
+}
+
+func NewTwoFactorDAO(db *gorm.DB) *TwoFactorDAO {
+	return &TwoFactorDAO{
+		db: db,
+	}
+}
+
+func (dao *TwoFactorDAO) FindByUid(ctx context.Context, uid int64) (TwoFactor, error) {
+	var tf TwoFactor
+	err := dao.db.WithContext(ctx).Where("uid = ?", uid).First(&tf).Error
+	return tf, err
+}
+
+// Upsert 重新绑定，旧的密钥和恢复码都作废
+func (dao *TwoFactorDAO) Upsert(ctx context.Context, tf TwoFactor, codes []RecoveryCode) error {
+	now := time.Now().UnixNano()
+	tf.Ctime = now
+	tf.Utime = now
+	return dao.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "uid"}},
+			DoUpdates: clause.Assignments(map[string]any{
+				"secret":       tf.Secret,
+				"enabled":      false,
+				"last_counter": 0,
+				"utime":        now,
+			}),
+		}).Create(&tf).Error
+		if err != nil {
+			return err
+		}
+		err = tx.Where("uid = ?", tf.Uid).Delete(&RecoveryCode{}).Error
+		if err != nil {
+			return err
+		}
+		for i := range codes {
+			codes[i].Uid = tf.Uid
+			codes[i].Ctime = now
+			codes[i].Utime = now
+		}
+		return tx.Create(&codes).Error
+	})
+}
+
+func (dao *TwoFactorDAO) UpdateEnabled(ctx context.Context, uid int64, enabled bool) error {
+	return dao.db.WithContext(ctx).Model(&TwoFactor{}).Where("uid = ?", uid).
+		Updates(map[string]any{
+			"enabled": enabled,
+			"utime":   time.Now().UnixNano(),
+		}).Error
+}
+
+// UseCounter 只有 counter 比上一次用过的大才能更新成功，防止验证码被重放
+func (dao *TwoFactorDAO) UseCounter(ctx context.Context, uid int64, counter int64) (bool, error) {
+	res := dao.db.WithContext(ctx).Model(&TwoFactor{}).
+		Where("uid = ? AND last_counter < ?", uid, counter).
+		Updates(map[string]any{
+			"last_counter": counter,
+			"utime":        time.Now().UnixNano(),
+		})
+	return res.RowsAffected > 0, res.Error
+}
+
+// UseRecoveryCode 恢复码只能用一次
+func (dao *TwoFactorDAO) UseRecoveryCode(ctx context.Context, uid int64, hash string) (bool, error) {
+	now := time.Now().UnixNano()
+	res := dao.db.WithContext(ctx).Model(&RecoveryCode{}).
+		Where("uid = ? AND code_hash = ? AND used_at = 0", uid, hash).
+		Updates(map[string]any{
+			"used_at": now,
+			"utime":   now,
+		})
+	return res.RowsAffected > 0, res.Error
+}
+
+func (dao *TwoFactorDAO) Delete(ctx context.Context, uid int64) error {
+	return dao.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("uid = ?", uid).Delete(&RecoveryCode{}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Where("uid = ?", uid).Delete(&TwoFactor{}).Error
+	})
+}
+
+// TwoFactor 用户的 TOTP 配置，一个用户一条
+type TwoFactor struct {
+	Id  int64 `gorm:"primaryKey,autoIncrement"`
+	Uid int64 `gorm:"unique"`
+	// base32 编码的密钥
+	Secret string
+	// 绑定之后要校验一次验证码才会启用
+	Enabled bool
+	// 上一次用过的 TOTP 计数器
+	LastCounter int64
+	Ctime       int64
+	Utime       int64
+}
+
+type RecoveryCode struct {
+	Id  int64 `gorm:"primaryKey,autoIncrement"`
+	Uid int64 `gorm:"index:idx_uid_code"`
+	// 只存 SHA256，不存明文
+	CodeHash string `gorm:"type:varchar(64);index:idx_uid_code"`
+	// 0 代表还没用过
+	UsedAt int64
+	Ctime  int64
+	Utime  int64
+}
@@ -0,0 +1,83 @@
+package dao
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type UploadDAO struct {
+	db *gorm.DB
+}
+
+func NewUploadDAO(db *gorm.DB) *UploadDAO {
+	return &UploadDAO{
+		db: db,
+	}
+}
+
+func (dao *UploadDAO) Insert(ctx context.Context, u Upload) (int64, error) {
+	now := time.Now().UnixMilli()
+	u.Ctime = now
+	u.Utime = now
+	err := dao.db.WithContext(ctx).Create(&u).Error
+	return u.Id, err
+}
+
+func (dao *UploadDAO) FindByKey(ctx context.Context, key string) (Upload, error) {
+	var u Upload
+	err := dao.db.WithContext(ctx).Where("`key` = ?", key).First(&u).Error
+	return u, err
+}
+
+// Attach 把 uid 自己上传的 keys 标记成已经用上了，别人上传的不会改
+func (dao *UploadDAO) Attach(ctx context.Context, uid int64, keys []string, biz string, bizId int64) error {
+	return dao.db.WithContext(ctx).Model(&Upload{}).
+		Where("uid = ? AND `key` IN ? AND biz = ?", uid, keys, biz).
+		Updates(map[string]any{
+			"status": uploadStatusAttached,
+			"biz_id": bizId,
+			"utime":  time.Now().UnixMilli(),
+		}).Error
+}
+
+// FindPending 找 ctime 早于 before 还没用上的文件，按 ID 遍历
+func (dao *UploadDAO) FindPending(ctx context.Context, before int64, startId int64, limit int) ([]Upload, error) {
+	var us []Upload
+	err := dao.db.WithContext(ctx).
+		Where("status = ? AND ctime < ? AND id > ?", uploadStatusPending, before, startId).
+		Order("id").Limit(limit).
+		Find(&us).Error
+	return us, err
+}
+
+// DeletePending 只删还没用上的，清理的过程中刚好被用上了就不删
+func (dao *UploadDAO) DeletePending(ctx context.Context, id int64) (bool, error) {
+	res := dao.db.WithContext(ctx).
+		Where("id = ? AND status = ?", id, uploadStatusPending).
+		Delete(&Upload{})
+	return res.RowsAffected > 0, res.Error
+}
+
+// 和 domain.UploadStatus 的值一样
+const (
+	uploadStatusPending  uint8 = 1
+	uploadStatusAttached uint8 = 2
+)
+
+type Upload struct {
+	Id          int64  `gorm:"primaryKey,autoIncrement"`
+	Uid         int64  `gorm:"index"`
+	Key         string `gorm:"type:varchar(256);uniqueIndex"`
+	Biz         string `gorm:"type:varchar(128)"`
+	BizId       int64
+	Private     bool
+	Size        int64
+	ContentType string `gorm:"type:varchar(128)"`
+	Width       int
+	Height      int
+	Status      uint8 `gorm:"index:upload_status_ctime"`
+	Ctime       int64 `gorm:"index:upload_status_ctime"`
+	Utime       int64
+}
@@ -1,8 +1,11 @@
 package dao
 
 import (
+	"basic_go/webook/pkg/migrator"
 	"context"
+	"database/sql"
 	"errors"
+	"strings"
 	"time"
 
 	"github.com/go-sql-driver/mysql"
@@ -11,7 +14,9 @@ import (
 
 // 预定义错误
 var (
-	ErrDuplicateEmail = errors.New("邮箱冲突")
+	// ErrDuplicateUser 邮箱或者手机号的唯一索引冲突
+	ErrDuplicateUser  = errors.New("邮箱或者手机号冲突")
+	ErrDuplicateEmail = ErrDuplicateUser
 	ErrRecordNotFound = gorm.ErrRecordNotFound
 )
 
@@ -28,10 +33,14 @@ func (dao *UserDAO) Insert(ctx context.Context, u User) error {
 	if me, ok := err.(*mysql.MySQLError); ok {
 		const duplicateErr uint16 = 1062
 		if me.Number == duplicateErr {
-			//用户冲突，邮箱冲突(唯一索引冲突)
-			return ErrDuplicateEmail
+			//用户冲突，邮箱或者手机号冲突(唯一索引冲突)
+			return ErrDuplicateUser
 		}
 	}
+	// 打开了 TranslateError 的话，各种数据库的唯一索引冲突都会转成这个错误
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return ErrDuplicateUser
+	}
 	return err
 }
 
@@ -41,6 +50,84 @@ func (dao *UserDAO) FindByEmail(ctx context.Context, email string) (User, error)
 	return u, err
 }
 
+func (dao *UserDAO) FindByPhone(ctx context.Context, phone string) (User, error) {
+	var u User
+	err := dao.db.WithContext(ctx).Where("phone = ?", phone).First(&u).Error
+	return u, err
+}
+
+func (dao *UserDAO) FindById(ctx context.Context, id int64) (User, error) {
+	var u User
+	err := dao.db.WithContext(ctx).Where("id = ?", id).First(&u).Error
+	return u, err
+}
+
+func (dao *UserDAO) UpdatePassword(ctx context.Context, id int64, password string) error {
+	return dao.db.WithContext(ctx).Model(&User{}).Where("id = ?", id).
+		Updates(map[string]any{
+			"password": password,
+			"utime":    time.Now().UnixNano(),
+		}).Error
+}
+
+func (dao *UserDAO) UpdateAvatar(ctx context.Context, id int64, avatar string) error {
+	return dao.db.WithContext(ctx).Model(&User{}).Where("id = ?", id).
+		Updates(map[string]any{
+			"avatar": avatar,
+			"utime":  time.Now().UnixNano(),
+		}).Error
+}
+
+func (dao *UserDAO) UpdateStatus(ctx context.Context, id int64, status uint8, reason string) error {
+	return dao.db.WithContext(ctx).Model(&User{}).Where("id = ?", id).
+		Updates(map[string]any{
+			"status":        status,
+			"status_reason": reason,
+			"utime":         time.Now().UnixNano(),
+		}).Error
+}
+
+// Anonymize 注销的时候抹掉个人信息，邮箱和手机号置空之后可以被别人重新注册。
+// 行还留着，评论、关注这些数据还关联着这个 ID
+func (dao *UserDAO) Anonymize(ctx context.Context, id int64, status uint8, reason string) error {
+	return dao.db.WithContext(ctx).Model(&User{}).Where("id = ?", id).
+		Updates(map[string]any{
+			"email":         sql.NullString{},
+			"phone":         sql.NullString{},
+			"password":      "",
+			"avatar":        "",
+			"status":        status,
+			"status_reason": reason,
+			"utime":         time.Now().UnixNano(),
+		}).Error
+}
+
+// Search 按邮箱或者手机号的前缀找用户，两个都传的时候要同时满足，按 ID 倒序
+func (dao *UserDAO) Search(ctx context.Context, email string, phone string,
+	offset int, limit int) ([]User, int64, error) {
+	query := dao.db.WithContext(ctx).Model(&User{})
+	if email != "" {
+		query = query.Where("email LIKE ? ESCAPE '!'", escapeLike(email)+"%")
+	}
+	if phone != "" {
+		query = query.Where("phone LIKE ? ESCAPE '!'", escapeLike(phone)+"%")
+	}
+	var total int64
+	err := query.Count(&total).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	var us []User
+	err = query.Order("id DESC").Offset(offset).Limit(limit).Find(&us).Error
+	return us, total, err
+}
+
+// escapeLike 用户输入的 % 和 _ 不能当成通配符。
+// 转义字符用 !，MySQL 和 SQLite 对反斜杠的处理不一样
+func escapeLike(s string) string {
+	return strings.NewReplacer(`!`, `!!`, `%`, `!%`, `_`, `!_`).Replace(s)
+}
+
 func NewUserDAO(db *gorm.DB) *UserDAO {
 	return &UserDAO{
 		db: db,
@@ -49,9 +136,18 @@ func NewUserDAO(db *gorm.DB) *UserDAO {
 
 type User struct {
 	Id int64 `gorm:"primaryKey,autoIncrement"`
-	// 唯一索引
-	Email    string `gorm:"unique"`
+	// 唯一索引，手机号注册的用户没有邮箱，是 NULL
+	Email    sql.NullString `gorm:"type:varchar(256);unique"`
 	Password string
+	// 头像的地址
+	Avatar string `gorm:"type:varchar(512)"`
+	// 唯一索引，没有绑定手机号的是 NULL
+	Phone sql.NullString `gorm:"type:varchar(32);unique"`
+	// 0 是普通用户，1 是管理员
+	Role uint8
+	// 0 是正常，1 是禁用，2 是封禁，3 是已注销
+	Status       uint8
+	StatusReason string `gorm:"type:varchar(512)"`
 
 	// 时区， UTC 0 的毫秒数
 	// 创建时间
@@ -59,3 +155,17 @@ type User struct {
 	// 更新时间
 	Utime int64
 }
+
+// ID 实现 migrator.Entity，迁移 users 表用
+func (u User) ID() int64 {
+	return u.Id
+}
+
+func (u User) GetUtime() int64 {
+	return u.Utime
+}
+
+func (u User) CompareTo(dst migrator.Entity) bool {
+	val, ok := dst.(User)
+	return ok && u == val
+}
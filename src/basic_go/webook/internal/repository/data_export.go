@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"basic_go/webook/internal/domain"
+	"basic_go/webook/internal/repository/dao"
+	"context"
+	"time"
+)
+
+var (
+	ErrDataExportNotFound = dao.ErrRecordNotFound
+	ErrNoPendingExport    = dao.ErrRecordNotFound
+)
+
+type DataExportRepository struct {
+	dao *dao.DataExportDAO
+}
+
+func NewDataExportRepository(dao *dao.DataExportDAO) *DataExportRepository {
+	return &DataExportRepository{
+		dao: dao,
+	}
+}
+
+func (repo *DataExportRepository) Create(ctx context.Context, e domain.DataExport) (int64, error) {
+	return repo.dao.Insert(ctx, dao.DataExport{
+		Uid: e.Uid,
+	})
+}
+
+func (repo *DataExportRepository) FindById(ctx context.Context, id int64) (domain.DataExport, error) {
+	e, err := repo.dao.FindById(ctx, id)
+	if err != nil {
+		return domain.DataExport{}, err
+	}
+	return repo.toDomain(e), nil
+}
+
+func (repo *DataExportRepository) FindLatest(ctx context.Context, uid int64) (domain.DataExport, error) {
+	e, err := repo.dao.FindLatest(ctx, uid)
+	if err != nil {
+		return domain.DataExport{}, err
+	}
+	return repo.toDomain(e), nil
+}
+
+// PreemptPending 抢一条上一次被抢到早于 before 的，没有的话返回 ErrNoPendingExport
+func (repo *DataExportRepository) PreemptPending(ctx context.Context, before time.Time) (domain.DataExport, error) {
+	e, err := repo.dao.PreemptPending(ctx, before.UnixMilli())
+	if err != nil {
+		return domain.DataExport{}, err
+	}
+	return repo.toDomain(e), nil
+}
+
+func (repo *DataExportRepository) MarkDone(ctx context.Context, id int64, key string, size int64) error {
+	return repo.dao.MarkDone(ctx, id, key, size)
+}
+
+func (repo *DataExportRepository) MarkFailed(ctx context.Context, id int64) error {
+	return repo.dao.MarkFailed(ctx, id)
+}
+
+// FindDoneBefore 打包好的时间早于 before 的
+func (repo *DataExportRepository) FindDoneBefore(ctx context.Context, before time.Time,
+	startId int64, limit int) ([]domain.DataExport, error) {
+	es, err := repo.dao.FindDoneBefore(ctx, before.UnixMilli(), startId, limit)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]domain.DataExport, 0, len(es))
+	for _, e := range es {
+		res = append(res, repo.toDomain(e))
+	}
+	return res, nil
+}
+
+func (repo *DataExportRepository) MarkExpired(ctx context.Context, id int64) error {
+	return repo.dao.MarkExpired(ctx, id)
+}
+
+func (repo *DataExportRepository) toDomain(e dao.DataExport) domain.DataExport {
+	return domain.DataExport{
+		Id:     e.Id,
+		Uid:    e.Uid,
+		Status: domain.DataExportStatus(e.Status),
+		Key:    e.Key,
+		Size:   e.Size,
+		Ctime:  time.UnixMilli(e.Ctime),
+		Utime:  time.UnixMilli(e.Utime),
+	}
+}
@@ -0,0 +1,103 @@
+package repository
+
+import (
+	"basic_go/webook/internal/domain"
+	"basic_go/webook/internal/repository/dao"
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+)
+
+type FeedRepository struct {
+	dao *dao.FeedDAO
+}
+
+func NewFeedRepository(dao *dao.FeedDAO) *FeedRepository {
+	return &FeedRepository{
+		dao: dao,
+	}
+}
+
+// CreatePushEvents 写到每个 evt.Uid 的收件箱
+func (repo *FeedRepository) CreatePushEvents(ctx context.Context, evts []domain.FeedEvent) error {
+	entities := make([]dao.FeedPushEvent, 0, len(evts))
+	for _, evt := range evts {
+		content, err := json.Marshal(evt.Ext)
+		if err != nil {
+			return err
+		}
+		entities = append(entities, dao.FeedPushEvent{
+			Uid:     evt.Uid,
+			Type:    evt.Type,
+			Biz:     evt.Biz,
+			BizId:   evt.BizId,
+			Content: string(content),
+			Ctime:   evt.Ctime.UnixMilli(),
+		})
+	}
+	return repo.dao.CreatePushEvents(ctx, entities)
+}
+
+// CreatePullEvent 写到 evt.Uid 的发件箱
+func (repo *FeedRepository) CreatePullEvent(ctx context.Context, evt domain.FeedEvent) error {
+	content, err := json.Marshal(evt.Ext)
+	if err != nil {
+		return err
+	}
+	return repo.dao.CreatePullEvent(ctx, dao.FeedPullEvent{
+		Uid:     evt.Uid,
+		Type:    evt.Type,
+		Biz:     evt.Biz,
+		BizId:   evt.BizId,
+		Content: string(content),
+		Ctime:   evt.Ctime.UnixMilli(),
+	})
+}
+
+func (repo *FeedRepository) FindPushEvents(ctx context.Context, uid int64, maxTime time.Time, maxId int64, limit int) ([]domain.FeedEvent, error) {
+	evts, err := repo.dao.FindPushEvents(ctx, uid, maxTime.UnixMilli(), maxId, limit)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]domain.FeedEvent, 0, len(evts))
+	for _, evt := range evts {
+		res = append(res, repo.toDomain(evt.Id, evt.Uid, evt.Type, evt.Biz, evt.BizId, evt.Content, evt.Ctime))
+	}
+	return res, nil
+}
+
+func (repo *FeedRepository) FindPullEvents(ctx context.Context, uids []int64, maxTime time.Time, maxId int64, limit int) ([]domain.FeedEvent, error) {
+	if len(uids) == 0 {
+		return nil, nil
+	}
+	evts, err := repo.dao.FindPullEvents(ctx, uids, maxTime.UnixMilli(), maxId, limit)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]domain.FeedEvent, 0, len(evts))
+	for _, evt := range evts {
+		e := repo.toDomain(evt.Id, evt.Uid, evt.Type, evt.Biz, evt.BizId, evt.Content, evt.Ctime)
+		e.Pulled = true
+		res = append(res, e)
+	}
+	return res, nil
+}
+
+func (repo *FeedRepository) toDomain(id, uid int64, typ, biz string, bizId int64, content string, ctime int64) domain.FeedEvent {
+	var ext map[string]string
+	err := json.Unmarshal([]byte(content), &ext)
+	if err != nil {
+		// 坏了一条不影响别的
+		log.Println("解析动态内容失败", id, err)
+	}
+	return domain.FeedEvent{
+		Id:    id,
+		Uid:   uid,
+		Type:  typ,
+		Biz:   biz,
+		BizId: bizId,
+		Ext:   ext,
+		Ctime: time.UnixMilli(ctime),
+	}
+}
@@ -0,0 +1,107 @@
+package repository
+
+import (
+	"basic_go/webook/internal/domain"
+	"basic_go/webook/internal/repository/cache"
+	"basic_go/webook/internal/repository/dao"
+	"context"
+	"log"
+	"time"
+)
+
+type FollowRepository struct {
+	dao   *dao.FollowDAO
+	cache cache.FollowCache
+}
+
+func NewFollowRepository(dao *dao.FollowDAO, c cache.FollowCache) *FollowRepository {
+	return &FollowRepository{
+		dao:   dao,
+		cache: c,
+	}
+}
+
+// Follow 返回 true 代表之前没有关注
+func (repo *FollowRepository) Follow(ctx context.Context, follower, followee int64) (bool, error) {
+	changed, err := repo.dao.Follow(ctx, follower, followee)
+	if err != nil || !changed {
+		return changed, err
+	}
+	err = repo.cache.Follow(ctx, follower, followee)
+	if err != nil {
+		// 缓存里面的计数会不准，等过期了就好了
+		log.Println("更新关注数缓存失败", err)
+	}
+	return true, nil
+}
+
+// Unfollow 返回 true 代表之前是关注的
+func (repo *FollowRepository) Unfollow(ctx context.Context, follower, followee int64) (bool, error) {
+	changed, err := repo.dao.Unfollow(ctx, follower, followee)
+	if err != nil || !changed {
+		return changed, err
+	}
+	err = repo.cache.Unfollow(ctx, follower, followee)
+	if err != nil {
+		log.Println("更新关注数缓存失败", err)
+	}
+	return true, nil
+}
+
+func (repo *FollowRepository) IsFollowing(ctx context.Context, follower, followee int64) (bool, error) {
+	return repo.dao.IsFollowing(ctx, follower, followee)
+}
+
+func (repo *FollowRepository) FindFollowers(ctx context.Context, uid int64, maxId int64, limit int) ([]domain.FollowRelation, error) {
+	rs, err := repo.dao.FindFollowers(ctx, uid, maxId, limit)
+	if err != nil {
+		return nil, err
+	}
+	return repo.toDomains(rs), nil
+}
+
+func (repo *FollowRepository) FindFollowees(ctx context.Context, uid int64, maxId int64, limit int) ([]domain.FollowRelation, error) {
+	rs, err := repo.dao.FindFollowees(ctx, uid, maxId, limit)
+	if err != nil {
+		return nil, err
+	}
+	return repo.toDomains(rs), nil
+}
+
+// GetStats 先查缓存，没有再去数据库数一遍
+func (repo *FollowRepository) GetStats(ctx context.Context, uid int64) (domain.FollowStats, error) {
+	s, err := repo.cache.GetStats(ctx, uid)
+	if err == nil {
+		return domain.FollowStats{Uid: uid, Followers: s.Followers, Followees: s.Followees}, nil
+	}
+	if err != cache.ErrKeyNotExist {
+		// Redis 出问题了也还能从数据库查
+		log.Println("查询关注数缓存失败", err)
+	}
+	followers, err := repo.dao.CountFollowers(ctx, uid)
+	if err != nil {
+		return domain.FollowStats{}, err
+	}
+	followees, err := repo.dao.CountFollowees(ctx, uid)
+	if err != nil {
+		return domain.FollowStats{}, err
+	}
+	err = repo.cache.SetStats(ctx, uid, cache.FollowStats{Followers: followers, Followees: followees})
+	if err != nil {
+		log.Println("回写关注数缓存失败", err)
+	}
+	return domain.FollowStats{Uid: uid, Followers: followers, Followees: followees}, nil
+}
+
+func (repo *FollowRepository) toDomains(rs []dao.FollowRelation) []domain.FollowRelation {
+	res := make([]domain.FollowRelation, 0, len(rs))
+	for _, r := range rs {
+		res = append(res, domain.FollowRelation{
+			Id:       r.Id,
+			Follower: r.Follower,
+			Followee: r.Followee,
+			Ctime:    time.UnixMilli(r.Ctime),
+		})
+	}
+	return res
+}
@@ -0,0 +1,117 @@
+package repository
+
+import (
+	"basic_go/webook/internal/domain"
+	"basic_go/webook/internal/repository/dao"
+	"context"
+	"time"
+)
+
+type InteractiveRepository struct {
+	dao *dao.InteractiveDAO
+}
+
+func NewInteractiveRepository(dao *dao.InteractiveDAO) *InteractiveRepository {
+	return &InteractiveRepository{
+		dao: dao,
+	}
+}
+
+// BatchIncrReadCnt bizs 和 bizIds 一一对应，同一个资源可以出现多次
+func (repo *InteractiveRepository) BatchIncrReadCnt(ctx context.Context, bizs []string, bizIds []int64) error {
+	type key struct {
+		biz   string
+		bizId int64
+	}
+	// 先在内存里面合并，同一个资源只更新一次
+	cnts := make(map[key]int64, len(bizs))
+	order := make([]key, 0, len(bizs))
+	for i := range bizs {
+		k := key{biz: bizs[i], bizId: bizIds[i]}
+		if _, ok := cnts[k]; !ok {
+			order = append(order, k)
+		}
+		cnts[k]++
+	}
+	mergedBizs := make([]string, 0, len(order))
+	mergedIds := make([]int64, 0, len(order))
+	mergedCnts := make([]int64, 0, len(order))
+	for _, k := range order {
+		mergedBizs = append(mergedBizs, k.biz)
+		mergedIds = append(mergedIds, k.bizId)
+		mergedCnts = append(mergedCnts, cnts[k])
+	}
+	return repo.dao.BatchIncrReadCnt(ctx, mergedBizs, mergedIds, mergedCnts)
+}
+
+// Get 还没有人互动过的资源，返回全是 0 的数据
+func (repo *InteractiveRepository) Get(ctx context.Context, biz string, bizId int64) (domain.Interactive, error) {
+	intr, err := repo.dao.Get(ctx, biz, bizId)
+	if err == dao.ErrRecordNotFound {
+		return domain.Interactive{Biz: biz, BizId: bizId}, nil
+	}
+	if err != nil {
+		return domain.Interactive{}, err
+	}
+	return repo.toDomain(intr), nil
+}
+
+// GetByIds 每个 bizId 都会有数据，没有人互动过的全是 0
+func (repo *InteractiveRepository) GetByIds(ctx context.Context, biz string, bizIds []int64) (map[int64]domain.Interactive, error) {
+	intrs, err := repo.dao.GetByIds(ctx, biz, bizIds)
+	if err != nil {
+		return nil, err
+	}
+	res := make(map[int64]domain.Interactive, len(bizIds))
+	for _, id := range bizIds {
+		res[id] = domain.Interactive{Biz: biz, BizId: id}
+	}
+	for _, intr := range intrs {
+		res[intr.BizId] = repo.toDomain(intr)
+	}
+	return res, nil
+}
+
+// IncrLike 返回 false 代表已经点过赞了
+func (repo *InteractiveRepository) IncrLike(ctx context.Context, biz string, bizId int64, uid int64) (bool, error) {
+	return repo.dao.InsertLikeInfo(ctx, biz, bizId, uid)
+}
+
+// DecrLike 返回 false 代表本来就没有点赞
+func (repo *InteractiveRepository) DecrLike(ctx context.Context, biz string, bizId int64, uid int64) (bool, error) {
+	return repo.dao.DeleteLikeInfo(ctx, biz, bizId, uid)
+}
+
+func (repo *InteractiveRepository) Liked(ctx context.Context, biz string, bizId int64, uid int64) (bool, error) {
+	return repo.dao.Liked(ctx, biz, bizId, uid)
+}
+
+// FindLikesByUid uid 点过的赞，按照 ID 从小到大
+func (repo *InteractiveRepository) FindLikesByUid(ctx context.Context, uid int64, startId int64, limit int) ([]domain.UserLike, error) {
+	ls, err := repo.dao.FindLikesByUid(ctx, uid, startId, limit)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]domain.UserLike, 0, len(ls))
+	for _, l := range ls {
+		res = append(res, domain.UserLike{
+			Id:    l.Id,
+			Uid:   l.Uid,
+			Biz:   l.Biz,
+			BizId: l.BizId,
+			Ctime: time.UnixMilli(l.Ctime),
+		})
+	}
+	return res, nil
+}
+
+func (repo *InteractiveRepository) toDomain(intr dao.Interactive) domain.Interactive {
+	return domain.Interactive{
+		Biz:        intr.Biz,
+		BizId:      intr.BizId,
+		ReadCnt:    intr.ReadCnt,
+		LikeCnt:    intr.LikeCnt,
+		CollectCnt: intr.CollectCnt,
+		CommentCnt: intr.CommentCnt,
+	}
+}
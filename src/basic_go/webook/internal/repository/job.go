@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"basic_go/webook/internal/domain"
+	"basic_go/webook/internal/repository/dao"
+	"context"
+	"time"
+)
+
+var (
+	ErrNoJob   = dao.ErrNoJob
+	ErrJobLost = dao.ErrJobLost
+)
+
+type JobRepository struct {
+	dao *dao.JobDAO
+}
+
+func NewJobRepository(dao *dao.JobDAO) *JobRepository {
+	return &JobRepository{
+		dao: dao,
+	}
+}
+
+func (repo *JobRepository) Create(ctx context.Context, j domain.Job) error {
+	return repo.dao.Insert(ctx, dao.Job{
+		Name:       j.Name,
+		Executor:   j.Executor,
+		Cfg:        j.Cfg,
+		Expression: j.Expression,
+		Status:     uint8(j.Status),
+		NextTime:   j.NextTime.UnixMilli(),
+	})
+}
+
+func (repo *JobRepository) Preempt(ctx context.Context, owner string, staleBefore time.Time) (domain.Job, error) {
+	j, err := repo.dao.Preempt(ctx, owner, staleBefore.UnixMilli())
+	if err != nil {
+		return domain.Job{}, err
+	}
+	return domain.Job{
+		Id:         j.Id,
+		Name:       j.Name,
+		Executor:   j.Executor,
+		Cfg:        j.Cfg,
+		Expression: j.Expression,
+		Status:     domain.JobStatus(j.Status),
+		Version:    j.Version,
+		NextTime:   time.UnixMilli(j.NextTime),
+		Owner:      j.Owner,
+	}, nil
+}
+
+func (repo *JobRepository) Heartbeat(ctx context.Context, id int64, owner string, version int64) error {
+	return repo.dao.Heartbeat(ctx, id, owner, version)
+}
+
+func (repo *JobRepository) Release(ctx context.Context, id int64, owner string, version int64, nextTime time.Time) error {
+	return repo.dao.Release(ctx, id, owner, version, nextTime.UnixMilli())
+}
+
+func (repo *JobRepository) Pause(ctx context.Context, id int64, owner string, version int64) error {
+	return repo.dao.Pause(ctx, id, owner, version)
+}
@@ -0,0 +1,41 @@
+package repository
+
+import (
+	"basic_go/webook/internal/domain"
+	"basic_go/webook/internal/repository/cache"
+	"context"
+	"time"
+)
+
+type LoginAttemptRepository struct {
+	cache cache.LoginAttemptCache
+}
+
+func NewLoginAttemptRepository(c cache.LoginAttemptCache) *LoginAttemptRepository {
+	return &LoginAttemptRepository{
+		cache: c,
+	}
+}
+
+func (repo *LoginAttemptRepository) Find(ctx context.Context, key string) (domain.LoginAttempt, error) {
+	a, err := repo.cache.Get(ctx, key)
+	if err != nil {
+		return domain.LoginAttempt{}, err
+	}
+	return domain.LoginAttempt{
+		Failures:     a.Failures,
+		BlockedUntil: a.BlockedUntil,
+	}, nil
+}
+
+func (repo *LoginAttemptRepository) IncrFailure(ctx context.Context, key string, window time.Duration) (int64, error) {
+	return repo.cache.IncrFailure(ctx, key, window)
+}
+
+func (repo *LoginAttemptRepository) Block(ctx context.Context, key string, until time.Time) error {
+	return repo.cache.Block(ctx, key, until)
+}
+
+func (repo *LoginAttemptRepository) Delete(ctx context.Context, key string) error {
+	return repo.cache.Delete(ctx, key)
+}
@@ -0,0 +1,91 @@
+package repository
+
+import (
+	"basic_go/webook/internal/domain"
+	"basic_go/webook/internal/repository/dao"
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+)
+
+type NotificationRepository struct {
+	dao *dao.NotificationDAO
+}
+
+func NewNotificationRepository(dao *dao.NotificationDAO) *NotificationRepository {
+	return &NotificationRepository{
+		dao: dao,
+	}
+}
+
+// Save 能合并的合并，不能合并的插入新的。n.Actors 只看第一个
+func (repo *NotificationRepository) Save(ctx context.Context, n domain.Notification) error {
+	entity := dao.Notification{
+		EventId: n.EventId,
+		Uid:     n.Uid,
+		Type:    n.Type,
+		Biz:     n.Biz,
+		BizId:   n.BizId,
+		Title:   n.Title,
+		Content: n.Content,
+	}
+	if !n.Aggregatable() || len(n.Actors) == 0 {
+		actors, err := json.Marshal(n.Actors)
+		if err != nil {
+			return err
+		}
+		entity.Actors = string(actors)
+		entity.ActorCnt = int64(len(n.Actors))
+		return repo.dao.Insert(ctx, entity)
+	}
+	return repo.dao.Aggregate(ctx, entity, n.Actors[0])
+}
+
+func (repo *NotificationRepository) FindByUid(ctx context.Context, uid int64, offset int, limit int) ([]domain.Notification, error) {
+	ns, err := repo.dao.FindByUid(ctx, uid, offset, limit)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]domain.Notification, 0, len(ns))
+	for _, n := range ns {
+		res = append(res, repo.toDomain(n))
+	}
+	return res, nil
+}
+
+func (repo *NotificationRepository) CountUnread(ctx context.Context, uid int64) (int64, error) {
+	return repo.dao.CountUnread(ctx, uid)
+}
+
+func (repo *NotificationRepository) MarkRead(ctx context.Context, uid int64, id int64) error {
+	return repo.dao.MarkRead(ctx, uid, id)
+}
+
+func (repo *NotificationRepository) MarkAllRead(ctx context.Context, uid int64) error {
+	return repo.dao.MarkAllRead(ctx, uid)
+}
+
+func (repo *NotificationRepository) toDomain(n dao.Notification) domain.Notification {
+	var actors []int64
+	if n.Actors != "" {
+		err := json.Unmarshal([]byte(n.Actors), &actors)
+		if err != nil {
+			log.Println("解析通知的 actors 失败", n.Id, err)
+		}
+	}
+	return domain.Notification{
+		Id:       n.Id,
+		Uid:      n.Uid,
+		Type:     n.Type,
+		Biz:      n.Biz,
+		BizId:    n.BizId,
+		Title:    n.Title,
+		Actors:   actors,
+		ActorCnt: n.ActorCnt,
+		Content:  n.Content,
+		Read:     n.Status == dao.NotificationStatusRead,
+		Ctime:    time.UnixMilli(n.Ctime),
+		Utime:    time.UnixMilli(n.Utime),
+	}
+}
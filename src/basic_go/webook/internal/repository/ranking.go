@@ -0,0 +1,52 @@
+package repository
+
+import (
+	"basic_go/webook/internal/domain"
+	"basic_go/webook/internal/repository/cache"
+	"context"
+	"log"
+)
+
+// RankingRepository 热榜先查本地缓存，再查 Redis。
+// Redis 出问题的时候用本地过期了的数据兜底，热榜旧一点没关系
+type RankingRepository struct {
+	redis cache.RankingCache
+	local *cache.LocalRankingCache
+}
+
+func NewRankingRepository(redis cache.RankingCache, local *cache.LocalRankingCache) *RankingRepository {
+	return &RankingRepository{
+		redis: redis,
+		local: local,
+	}
+}
+
+// ReplaceTopN 本地缓存一定能设置成功，先设置本地的
+func (repo *RankingRepository) ReplaceTopN(ctx context.Context, arts []domain.HotArticle) error {
+	_ = repo.local.Set(ctx, arts)
+	return repo.redis.Set(ctx, arts)
+}
+
+// GetTopN 还没有算过热榜的时候返回空的
+func (repo *RankingRepository) GetTopN(ctx context.Context) ([]domain.HotArticle, error) {
+	arts, err := repo.local.Get(ctx)
+	if err == nil {
+		return arts, nil
+	}
+	arts, err = repo.redis.Get(ctx)
+	if err == nil {
+		_ = repo.local.Set(ctx, arts)
+		return arts, nil
+	}
+	if err != cache.ErrKeyNotExist {
+		log.Println("从 Redis 查询热榜失败，用本地的旧数据", err)
+	}
+	arts, lerr := repo.local.ForceGet(ctx)
+	if lerr == nil {
+		return arts, nil
+	}
+	if err == cache.ErrKeyNotExist {
+		return []domain.HotArticle{}, nil
+	}
+	return nil, err
+}
@@ -0,0 +1,74 @@
+package repository
+
+import (
+	"basic_go/webook/internal/domain"
+	"basic_go/webook/internal/repository/dao"
+	"context"
+	"time"
+)
+
+var ErrRewardNotFound = dao.ErrRecordNotFound
+
+type RewardRepository struct {
+	dao *dao.RewardDAO
+}
+
+func NewRewardRepository(dao *dao.RewardDAO) *RewardRepository {
+	return &RewardRepository{
+		dao: dao,
+	}
+}
+
+func (repo *RewardRepository) Create(ctx context.Context, r domain.Reward) (int64, error) {
+	return repo.dao.Insert(ctx, dao.Reward{
+		Biz:       r.Target.Biz,
+		BizId:     r.Target.BizId,
+		BizName:   r.Target.BizName,
+		TargetUid: r.Target.Uid,
+		Uid:       r.Uid,
+		Amount:    r.Amount,
+		Status:    uint8(r.Status),
+	})
+}
+
+func (repo *RewardRepository) GetById(ctx context.Context, id int64) (domain.Reward, error) {
+	r, err := repo.dao.GetById(ctx, id)
+	if err != nil {
+		return domain.Reward{}, err
+	}
+	return repo.toDomain(r), nil
+}
+
+// UpdateStatus 返回 false 代表当前不是 from 状态，没有改
+func (repo *RewardRepository) UpdateStatus(ctx context.Context, id int64, from, to domain.RewardStatus) (bool, error) {
+	return repo.dao.UpdateStatus(ctx, id, uint8(from), uint8(to))
+}
+
+func (repo *RewardRepository) FindByStatus(ctx context.Context, status domain.RewardStatus,
+	before time.Time, startId int64, limit int) ([]domain.Reward, error) {
+	rs, err := repo.dao.FindByStatus(ctx, uint8(status), before.UnixMilli(), startId, limit)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]domain.Reward, 0, len(rs))
+	for _, r := range rs {
+		res = append(res, repo.toDomain(r))
+	}
+	return res, nil
+}
+
+func (repo *RewardRepository) toDomain(r dao.Reward) domain.Reward {
+	return domain.Reward{
+		Id:  r.Id,
+		Uid: r.Uid,
+		Target: domain.RewardTarget{
+			Biz:     r.Biz,
+			BizId:   r.BizId,
+			BizName: r.BizName,
+			Uid:     r.TargetUid,
+		},
+		Amount: r.Amount,
+		Status: domain.RewardStatus(r.Status),
+		Ctime:  time.UnixMilli(r.Ctime),
+	}
+}
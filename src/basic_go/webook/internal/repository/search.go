@@ -0,0 +1,72 @@
+package repository
+
+import (
+	"basic_go/webook/internal/domain"
+	"basic_go/webook/pkg/search"
+	"context"
+	"html"
+	"regexp"
+)
+
+const (
+	searchFieldTitle   = "title"
+	searchFieldContent = "content"
+)
+
+// 文章内容是富文本，建索引之前去掉标签
+var htmlTagRegex = regexp.MustCompile(`<[^>]*>`)
+
+// ArticleSearchRepository 文章的搜索索引，只放已发表的文章
+type ArticleSearchRepository struct {
+	index search.Index
+}
+
+func NewArticleSearchRepository(index search.Index) *ArticleSearchRepository {
+	return &ArticleSearchRepository{
+		index: index,
+	}
+}
+
+// ArticleSearchFields 文章索引的字段配置，标题的权重比内容高
+func ArticleSearchFields() map[string]search.FieldConfig {
+	return map[string]search.FieldConfig{
+		searchFieldTitle:   {Weight: 2},
+		searchFieldContent: {Weight: 1, Snippet: 120},
+	}
+}
+
+func (repo *ArticleSearchRepository) Upsert(ctx context.Context, art domain.Article) error {
+	return repo.index.Upsert(ctx, search.Document{
+		Id: art.Id,
+		Fields: map[string]string{
+			searchFieldTitle:   art.Title,
+			searchFieldContent: plainText(art.Content),
+		},
+	})
+}
+
+func (repo *ArticleSearchRepository) Delete(ctx context.Context, id int64) error {
+	return repo.index.Delete(ctx, id)
+}
+
+// Search 返回的文章里面只有 Id，Score 是纯粹的相关度
+func (repo *ArticleSearchRepository) Search(ctx context.Context, keyword string, limit int) (domain.ArticleSearchResult, error) {
+	res, err := repo.index.Search(ctx, keyword, limit)
+	if err != nil {
+		return domain.ArticleSearchResult{}, err
+	}
+	hits := make([]domain.ArticleSearchHit, 0, len(res.Hits))
+	for _, h := range res.Hits {
+		hits = append(hits, domain.ArticleSearchHit{
+			Article:          domain.Article{Id: h.Id},
+			Score:            h.Score,
+			TitleHighlight:   h.Highlights[searchFieldTitle],
+			ContentHighlight: h.Highlights[searchFieldContent],
+		})
+	}
+	return domain.ArticleSearchResult{Total: res.Total, Hits: hits}, nil
+}
+
+func plainText(content string) string {
+	return html.UnescapeString(htmlTagRegex.ReplaceAllString(content, " "))
+}
@@ -0,0 +1,25 @@
+package repository
+
+import (
+	"basic_go/webook/internal/repository/cache"
+	"context"
+	"time"
+)
+
+type SessionRepository struct {
+	cache cache.SessionCache
+}
+
+func NewSessionRepository(c cache.SessionCache) *SessionRepository {
+	return &SessionRepository{
+		cache: c,
+	}
+}
+
+func (repo *SessionRepository) Revoke(ctx context.Context, uid int64, at time.Time) error {
+	return repo.cache.Revoke(ctx, uid, at)
+}
+
+func (repo *SessionRepository) RevokedAt(ctx context.Context, uid int64) (time.Time, error) {
+	return repo.cache.RevokedAt(ctx, uid)
+}
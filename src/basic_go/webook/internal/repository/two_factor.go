@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"basic_go/webook/internal/domain"
+	"basic_go/webook/internal/repository/dao"
+	"context"
+)
+
+var ErrTwoFactorNotFound = dao.ErrRecordNotFound
+
+type TwoFactorRepository struct {
+	dao *dao.TwoFactorDAO
+}
+
+func NewTwoFactorRepository(dao *dao.TwoFactorDAO) *TwoFactorRepository {
+	return &TwoFactorRepository{
+		dao: dao,
+	}
+}
+
+func (repo *TwoFactorRepository) FindByUid(ctx context.Context, uid int64) (domain.TwoFactor, error) {
+	tf, err := repo.dao.FindByUid(ctx, uid)
+	if err != nil {
+		return domain.TwoFactor{}, err
+	}
+	return domain.TwoFactor{
+		Uid:         tf.Uid,
+		Secret:      tf.Secret,
+		Enabled:     tf.Enabled,
+		LastCounter: tf.LastCounter,
+	}, nil
+}
+
+// Save codeHashes 是恢复码的哈希
+func (repo *TwoFactorRepository) Save(ctx context.Context, tf domain.TwoFactor, codeHashes []string) error {
+	codes := make([]dao.RecoveryCode, 0, len(codeHashes))
+	for _, h := range codeHashes {
+		codes = append(codes, dao.RecoveryCode{CodeHash: h})
+	}
+	return repo.dao.Upsert(ctx, dao.TwoFactor{
+		Uid:    tf.Uid,
+		Secret: tf.Secret,
+	}, codes)
+}
+
+func (repo *TwoFactorRepository) Enable(ctx context.Context, uid int64) error {
+	return repo.dao.UpdateEnabled(ctx, uid, true)
+}
+
+func (repo *TwoFactorRepository) UseCounter(ctx context.Context, uid int64, counter int64) (bool, error) {
+	return repo.dao.UseCounter(ctx, uid, counter)
+}
+
+func (repo *TwoFactorRepository) UseRecoveryCode(ctx context.Context, uid int64, hash string) (bool, error) {
+	return repo.dao.UseRecoveryCode(ctx, uid, hash)
+}
+
+func (repo *TwoFactorRepository) Delete(ctx context.Context, uid int64) error {
+	return repo.dao.Delete(ctx, uid)
+}
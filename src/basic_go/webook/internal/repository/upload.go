@@ -0,0 +1,82 @@
+package repository
+
+import (
+	"basic_go/webook/internal/domain"
+	"basic_go/webook/internal/repository/dao"
+	"context"
+	"time"
+)
+
+var ErrUploadNotFound = dao.ErrRecordNotFound
+
+type UploadRepository struct {
+	dao *dao.UploadDAO
+}
+
+func NewUploadRepository(dao *dao.UploadDAO) *UploadRepository {
+	return &UploadRepository{
+		dao: dao,
+	}
+}
+
+func (repo *UploadRepository) Create(ctx context.Context, u domain.Upload) (int64, error) {
+	return repo.dao.Insert(ctx, dao.Upload{
+		Uid:         u.Uid,
+		Key:         u.Key,
+		Biz:         u.Biz,
+		BizId:       u.BizId,
+		Private:     u.Private,
+		Size:        u.Size,
+		ContentType: u.ContentType,
+		Width:       u.Width,
+		Height:      u.Height,
+		Status:      uint8(u.Status),
+	})
+}
+
+func (repo *UploadRepository) FindByKey(ctx context.Context, key string) (domain.Upload, error) {
+	u, err := repo.dao.FindByKey(ctx, key)
+	if err != nil {
+		return domain.Upload{}, err
+	}
+	return repo.toDomain(u), nil
+}
+
+func (repo *UploadRepository) Attach(ctx context.Context, uid int64, keys []string, biz string, bizId int64) error {
+	return repo.dao.Attach(ctx, uid, keys, biz, bizId)
+}
+
+func (repo *UploadRepository) FindPending(ctx context.Context, before time.Time,
+	startId int64, limit int) ([]domain.Upload, error) {
+	us, err := repo.dao.FindPending(ctx, before.UnixMilli(), startId, limit)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]domain.Upload, 0, len(us))
+	for _, u := range us {
+		res = append(res, repo.toDomain(u))
+	}
+	return res, nil
+}
+
+// DeletePending 返回 false 代表已经被用上了，没有删
+func (repo *UploadRepository) DeletePending(ctx context.Context, id int64) (bool, error) {
+	return repo.dao.DeletePending(ctx, id)
+}
+
+func (repo *UploadRepository) toDomain(u dao.Upload) domain.Upload {
+	return domain.Upload{
+		Id:          u.Id,
+		Uid:         u.Uid,
+		Key:         u.Key,
+		Biz:         u.Biz,
+		BizId:       u.BizId,
+		Private:     u.Private,
+		Size:        u.Size,
+		ContentType: u.ContentType,
+		Width:       u.Width,
+		Height:      u.Height,
+		Status:      domain.UploadStatus(u.Status),
+		Ctime:       time.UnixMilli(u.Ctime),
+	}
+}
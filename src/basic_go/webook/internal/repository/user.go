@@ -4,12 +4,15 @@ import (
 	"basic_go/webook/internal/domain"
 	"basic_go/webook/internal/repository/dao"
 	"context"
+	"database/sql"
+	"time"
 
 	"gorm.io/gorm"
 )
 
 var (
 	ErrDuplicateEmail = dao.ErrDuplicateEmail
+	ErrDuplicateUser  = dao.ErrDuplicateUser
 	ErrUserNotFound   = gorm.ErrRecordNotFound
 )
 
@@ -24,10 +27,7 @@ func NewUserRepository(dao *dao.UserDAO) *UserRepository {
 }
 
 func (repo *UserRepository) Create(ctx context.Context, u domain.User) error {
-	return repo.dao.Insert(ctx, dao.User{
-		Email:    u.Email,
-		Password: u.Password,
-	})
+	return repo.dao.Insert(ctx, repo.toEntity(u))
 }
 
 func (repo *UserRepository) FindByEmail(ctx context.Context, email string) (domain.User, error) {
@@ -38,10 +38,77 @@ func (repo *UserRepository) FindByEmail(ctx context.Context, email string) (doma
 	return repo.toDomain(u), nil
 }
 
+func (repo *UserRepository) FindByPhone(ctx context.Context, phone string) (domain.User, error) {
+	u, err := repo.dao.FindByPhone(ctx, phone)
+	if err != nil {
+		return domain.User{}, err
+	}
+	return repo.toDomain(u), nil
+}
+
+func (repo *UserRepository) FindById(ctx context.Context, id int64) (domain.User, error) {
+	u, err := repo.dao.FindById(ctx, id)
+	if err != nil {
+		return domain.User{}, err
+	}
+	return repo.toDomain(u), nil
+}
+
+func (repo *UserRepository) UpdatePassword(ctx context.Context, id int64, password string) error {
+	return repo.dao.UpdatePassword(ctx, id, password)
+}
+
+func (repo *UserRepository) UpdateAvatar(ctx context.Context, id int64, avatar string) error {
+	return repo.dao.UpdateAvatar(ctx, id, avatar)
+}
+
+func (repo *UserRepository) UpdateStatus(ctx context.Context, id int64, status domain.UserStatus, reason string) error {
+	return repo.dao.UpdateStatus(ctx, id, uint8(status), reason)
+}
+
+// Anonymize 抹掉邮箱、手机号、密码和头像，状态改成已注销
+func (repo *UserRepository) Anonymize(ctx context.Context, id int64, reason string) error {
+	return repo.dao.Anonymize(ctx, id, uint8(domain.UserStatusDeleted), reason)
+}
+
+func (repo *UserRepository) Search(ctx context.Context, email string, phone string,
+	offset int, limit int) ([]domain.User, int64, error) {
+	us, total, err := repo.dao.Search(ctx, email, phone, offset, limit)
+	if err != nil {
+		return nil, 0, err
+	}
+	res := make([]domain.User, 0, len(us))
+	for _, u := range us {
+		res = append(res, repo.toDomain(u))
+	}
+	return res, total, nil
+}
+
+func (repo *UserRepository) toEntity(u domain.User) dao.User {
+	return dao.User{
+		Email: sql.NullString{
+			String: u.Email,
+			Valid:  u.Email != "",
+		},
+		Phone: sql.NullString{
+			String: u.Phone,
+			Valid:  u.Phone != "",
+		},
+		Password: u.Password,
+	}
+}
+
 func (repo *UserRepository) toDomain(u dao.User) domain.User {
 	return domain.User{
-		Id:       u.Id,
-		Email:    u.Email,
-		Password: u.Password,
+		Id:           u.Id,
+		Email:        u.Email.String,
+		Password:     u.Password,
+		Avatar:       u.Avatar,
+		Phone:        u.Phone.String,
+		Role:         domain.UserRole(u.Role),
+		Status:       domain.UserStatus(u.Status),
+		StatusReason: u.StatusReason,
+		// users 表存的是纳秒
+		Ctime: time.Unix(0, u.Ctime),
 	}
 }
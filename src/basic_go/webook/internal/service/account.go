@@ -0,0 +1,51 @@
+package service
+
+import (
+	"basic_go/webook/internal/domain"
+	"basic_go/webook/internal/repository"
+	"context"
+	"errors"
+)
+
+var (
+	ErrInsufficientBalance = repository.ErrInsufficientBalance
+	ErrInvalidPosting      = errors.New("记账参数不对")
+)
+
+// AccountService 复式记账。每一笔都是从一个账户转到另一个账户，
+// 借贷两边一起记，流水记了就不能改，要冲正就再记一笔反方向的
+type AccountService struct {
+	repo *repository.AccountRepository
+}
+
+func NewAccountService(repo *repository.AccountRepository) *AccountService {
+	return &AccountService{
+		repo: repo,
+	}
+}
+
+// Post 记账。同一个 Biz + BizId 重复调用只会记一次，重复的直接返回 nil
+func (svc *AccountService) Post(ctx context.Context, p domain.Posting) error {
+	if p.Amount <= 0 || p.Biz == "" || p.Debit == p.Credit {
+		return ErrInvalidPosting
+	}
+	err := svc.repo.Post(ctx, p)
+	if err == repository.ErrDuplicatePosting {
+		return nil
+	}
+	return err
+}
+
+// Balance 用户账户的余额，单位是分
+func (svc *AccountService) Balance(ctx context.Context, uid int64) (int64, error) {
+	return svc.repo.Balance(ctx, domain.UserAccount(uid))
+}
+
+func (svc *AccountService) AccountBalance(ctx context.Context, key domain.AccountKey) (int64, error) {
+	return svc.repo.Balance(ctx, key)
+}
+
+// Statement 用户账户的流水，新的在前面
+func (svc *AccountService) Statement(ctx context.Context, uid int64, q domain.StatementQuery) ([]domain.AccountEntry, error) {
+	return svc.repo.FindEntries(ctx, domain.UserAccount(uid), q)
+}
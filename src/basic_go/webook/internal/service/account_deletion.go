@@ -0,0 +1,157 @@
+package service
+
+import (
+	"basic_go/webook/internal/domain"
+	"basic_go/webook/internal/repository"
+	"context"
+	"errors"
+	"log"
+	"time"
+)
+
+var (
+	ErrAccountDeletionNotFound  = repository.ErrAccountDeletionNotFound
+	ErrDeletionAlreadyRequested = errors.New("已经申请注销了")
+	ErrNoPendingDeletion        = errors.New("没有可以取消的注销申请")
+	ErrInvalidArticlePolicy     = errors.New("文章的处理方式不对")
+)
+
+type AccountDeletionConfig struct {
+	// 冷静期，申请之后过了这么久才真的注销，这期间可以取消
+	GracePeriod time.Duration
+	// 选了保留文章的，文章转给这个账号。
+	// 上线之前建一个专门的「已注销用户」账号把 ID 配上，0 就是不属于任何人
+	ReattributeTo int64
+}
+
+func DefaultAccountDeletionConfig() AccountDeletionConfig {
+	return AccountDeletionConfig{
+		GracePeriod: time.Hour * 24 * 15,
+	}
+}
+
+// AccountDeletionService 用户自己注销账号。
+// 申请之后有冷静期，过了冷静期由定时任务处理文章、抹掉个人信息、让所有登录的地方下线
+type AccountDeletionService struct {
+	repo         *repository.AccountDeletionRepository
+	userSvc      *LocalUserService
+	artSvc       *ArticleService
+	twoFactorSvc *TwoFactorService
+	sessSvc      *SessionService
+	cfg          AccountDeletionConfig
+}
+
+func NewAccountDeletionService(repo *repository.AccountDeletionRepository, userSvc *LocalUserService,
+	artSvc *ArticleService, twoFactorSvc *TwoFactorService, sessSvc *SessionService,
+	cfg AccountDeletionConfig) *AccountDeletionService {
+	return &AccountDeletionService{
+		repo:         repo,
+		userSvc:      userSvc,
+		artSvc:       artSvc,
+		twoFactorSvc: twoFactorSvc,
+		sessSvc:      sessSvc,
+		cfg:          cfg,
+	}
+}
+
+// Request 申请注销，要再输入一次密码。密码不对返回 ErrInvalidUserOrPassword
+func (svc *AccountDeletionService) Request(ctx context.Context, uid int64, password string,
+	policy domain.ArticlePolicy) (domain.AccountDeletion, error) {
+	if policy != domain.ArticlePolicyUnpublish && policy != domain.ArticlePolicyReattribute {
+		return domain.AccountDeletion{}, ErrInvalidArticlePolicy
+	}
+	err := svc.userSvc.CheckPassword(ctx, uid, password)
+	if err != nil {
+		return domain.AccountDeletion{}, err
+	}
+	old, err := svc.repo.FindByUid(ctx, uid)
+	switch {
+	case err == nil:
+		if old.Status == domain.AccountDeletionStatusPending ||
+			old.Status == domain.AccountDeletionStatusProcessing {
+			return domain.AccountDeletion{}, ErrDeletionAlreadyRequested
+		}
+	case err != repository.ErrAccountDeletionNotFound:
+		return domain.AccountDeletion{}, err
+	}
+	now := time.Now()
+	d := domain.AccountDeletion{
+		Uid:           uid,
+		ArticlePolicy: policy,
+		Status:        domain.AccountDeletionStatusPending,
+		ScheduledAt:   now.Add(svc.cfg.GracePeriod),
+		Ctime:         now,
+		Utime:         now,
+	}
+	return d, svc.repo.Save(ctx, d)
+}
+
+// Cancel 冷静期里面取消，冷静期过了就不能取消了
+func (svc *AccountDeletionService) Cancel(ctx context.Context, uid int64) error {
+	ok, err := svc.repo.Cancel(ctx, uid)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrNoPendingDeletion
+	}
+	return nil
+}
+
+// Status 最近一次申请，没有申请过返回 ErrAccountDeletionNotFound
+func (svc *AccountDeletionService) Status(ctx context.Context, uid int64) (domain.AccountDeletion, error) {
+	return svc.repo.FindByUid(ctx, uid)
+}
+
+// ExecuteDue 处理冷静期已经过了的申请，某一个失败了不影响别的，下一次还会再处理
+func (svc *AccountDeletionService) ExecuteDue(ctx context.Context) error {
+	const batchSize = 100
+	startId := int64(0)
+	for {
+		ds, err := svc.repo.FindDue(ctx, time.Now(), startId, batchSize)
+		if err != nil {
+			return err
+		}
+		for _, d := range ds {
+			err = svc.execute(ctx, d)
+			if err != nil {
+				log.Println("注销账号失败", d.Uid, err)
+			}
+		}
+		if len(ds) < batchSize {
+			return nil
+		}
+		startId = ds[len(ds)-1].Id
+	}
+}
+
+// execute 每一步都可以重复执行，中间失败了下一次从头再来一遍
+func (svc *AccountDeletionService) execute(ctx context.Context, d domain.AccountDeletion) error {
+	// 先改状态，改成功了就不能再取消了
+	ok, err := svc.repo.MarkProcessing(ctx, d.Id)
+	if err != nil || !ok {
+		return err
+	}
+	switch d.ArticlePolicy {
+	case domain.ArticlePolicyReattribute:
+		err = svc.artSvc.TransferAll(ctx, d.Uid, svc.cfg.ReattributeTo)
+	default:
+		err = svc.artSvc.WithdrawAll(ctx, d.Uid)
+	}
+	if err != nil {
+		return err
+	}
+	err = svc.twoFactorSvc.Remove(ctx, d.Uid)
+	if err != nil {
+		return err
+	}
+	err = svc.userSvc.Anonymize(ctx, d.Uid)
+	if err != nil {
+		return err
+	}
+	err = svc.sessSvc.RevokeAll(ctx, d.Uid)
+	if err != nil {
+		return err
+	}
+	return svc.repo.MarkDone(ctx, d.Id)
+}
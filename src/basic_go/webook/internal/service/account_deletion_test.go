@@ -0,0 +1,132 @@
+package service
+
+import (
+	"basic_go/webook/internal/domain"
+	events "basic_go/webook/internal/events/article"
+	"basic_go/webook/internal/repository"
+	"basic_go/webook/internal/repository/cache"
+	"basic_go/webook/internal/repository/dao"
+	"basic_go/webook/internal/repository/dao/daotest"
+	"basic_go/webook/pkg/hasher"
+	"basic_go/webook/pkg/mq/memory"
+	"basic_go/webook/pkg/totp"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestAccountDeletionService(t *testing.T) {
+	db := daotest.OpenDB(t, &dao.User{}, &dao.TwoFactor{}, &dao.RecoveryCode{},
+		&dao.Article{}, &dao.PublishedArticle{}, &dao.AccountDeletion{})
+	guard := NewLoginGuard(repository.NewLoginAttemptRepository(cache.NewMemoryLoginAttemptCache(nil)),
+		DefaultLoginGuardConfig())
+	userSvc := NewLocalUserService(repository.NewUserRepository(dao.NewUserDAO(db)), guard,
+		domain.DefaultPasswordPolicy(), hasher.NewBcrypt(bcrypt.MinCost))
+	artSvc := NewArticleService(repository.NewArticleRepository(dao.NewArticleDAO(db)),
+		events.NewProducer(memory.NewBroker(16).Producer()))
+	tfSvc := NewTwoFactorService(repository.NewTwoFactorRepository(dao.NewTwoFactorDAO(db)),
+		repository.NewLoginAttemptRepository(cache.NewMemoryLoginAttemptCache(nil)), DefaultTwoFactorLimit(),
+		totp.New(nil), "webook")
+	sessSvc := NewSessionService(repository.NewSessionRepository(cache.NewMemorySessionCache()))
+	cfg := DefaultAccountDeletionConfig()
+	cfg.ReattributeTo = 99
+	svc := NewAccountDeletionService(repository.NewAccountDeletionRepository(dao.NewAccountDeletionDAO(db)),
+		userSvc, artSvc, tfSvc, sessSvc, cfg)
+	ctx := context.Background()
+
+	const password = "hello#world123"
+	emails := []string{"a@qq.com", "b@qq.com"}
+	for _, email := range emails {
+		require.NoError(t, userSvc.Signup(ctx, domain.User{Email: email, Password: password}))
+	}
+	// 每个人一篇已发表的，一篇草稿
+	pubIds := make(map[int64]int64)
+	for uid := int64(1); uid <= 2; uid++ {
+		id, err := artSvc.Publish(ctx, domain.Article{Title: "标题", Content: "内容", Author: domain.Author{Id: uid}})
+		require.NoError(t, err)
+		pubIds[uid] = id
+		_, err = artSvc.Save(ctx, domain.Article{Title: "草稿", Content: "内容", Author: domain.Author{Id: uid}})
+		require.NoError(t, err)
+	}
+	_, err := tfSvc.Enroll(ctx, domain.User{Id: 1, Email: emails[0]})
+	require.NoError(t, err)
+
+	_, err = svc.Request(ctx, 1, "wrong#password1", domain.ArticlePolicyUnpublish)
+	assert.Equal(t, ErrInvalidUserOrPassword, err)
+	_, err = svc.Request(ctx, 1, password, domain.ArticlePolicyUnknown)
+	assert.Equal(t, ErrInvalidArticlePolicy, err)
+	_, err = svc.Status(ctx, 1)
+	assert.Equal(t, ErrAccountDeletionNotFound, err)
+
+	d, err := svc.Request(ctx, 1, password, domain.ArticlePolicyReattribute)
+	require.NoError(t, err)
+	assert.Equal(t, domain.AccountDeletionStatusPending, d.Status)
+	assert.WithinDuration(t, time.Now().Add(cfg.GracePeriod), d.ScheduledAt, time.Second)
+	_, err = svc.Request(ctx, 1, password, domain.ArticlePolicyReattribute)
+	assert.Equal(t, ErrDeletionAlreadyRequested, err)
+
+	// 取消了之后可以重新申请，换一个文章的处理方式
+	require.NoError(t, svc.Cancel(ctx, 1))
+	assert.Equal(t, ErrNoPendingDeletion, svc.Cancel(ctx, 1))
+	d, err = svc.Status(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, domain.AccountDeletionStatusCancelled, d.Status)
+	_, err = svc.Request(ctx, 1, password, domain.ArticlePolicyUnpublish)
+	require.NoError(t, err)
+	_, err = svc.Request(ctx, 2, password, domain.ArticlePolicyReattribute)
+	require.NoError(t, err)
+
+	// 冷静期还没过，什么都不会做
+	require.NoError(t, svc.ExecuteDue(ctx))
+	d, err = svc.Status(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, domain.AccountDeletionStatusPending, d.Status)
+
+	loginTime := time.Now().Add(-time.Second)
+	require.NoError(t, db.Model(&dao.AccountDeletion{}).Where("1 = 1").
+		Update("scheduled_at", time.Now().Add(-time.Minute).UnixMilli()).Error)
+	require.NoError(t, svc.ExecuteDue(ctx))
+
+	for uid := int64(1); uid <= 2; uid++ {
+		d, err = svc.Status(ctx, uid)
+		require.NoError(t, err)
+		assert.Equal(t, domain.AccountDeletionStatusDone, d.Status)
+		// 过了冷静期就不能取消了
+		assert.Equal(t, ErrNoPendingDeletion, svc.Cancel(ctx, uid))
+
+		u, err := userSvc.FindById(ctx, uid)
+		require.NoError(t, err)
+		assert.Equal(t, domain.UserStatusDeleted, u.Status)
+		assert.Empty(t, u.Email)
+		assert.Empty(t, u.Password)
+		valid, err := sessSvc.Valid(ctx, uid, loginTime)
+		require.NoError(t, err)
+		assert.False(t, valid)
+		_, err = userSvc.Login(ctx, emails[uid-1], password, "127.0.0.1")
+		assert.Equal(t, ErrInvalidUserOrPassword, err)
+	}
+	enabled, err := tfSvc.Enabled(ctx, 1)
+	require.NoError(t, err)
+	assert.False(t, enabled)
+
+	// 1 选的是撤回
+	_, err = artSvc.GetPublished(ctx, pubIds[1])
+	assert.Equal(t, ErrArticleNotFound, err)
+	// 2 选的是保留，作者换成了匿名账号
+	art, err := artSvc.GetPublished(ctx, pubIds[2])
+	require.NoError(t, err)
+	assert.Equal(t, int64(99), art.Author.Id)
+	arts, err := artSvc.GetByAuthor(ctx, 2, 0, 10)
+	require.NoError(t, err)
+	assert.Empty(t, arts)
+	arts, err = artSvc.GetByAuthor(ctx, 99, 0, 10)
+	require.NoError(t, err)
+	assert.Len(t, arts, 2)
+
+	// 邮箱空出来了，可以重新注册
+	require.NoError(t, userSvc.Signup(ctx, domain.User{Email: emails[0], Password: password}))
+}
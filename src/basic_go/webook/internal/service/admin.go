@@ -0,0 +1,151 @@
+package service
+
+import (
+	"basic_go/webook/internal/domain"
+	"basic_go/webook/internal/repository"
+	"context"
+	"errors"
+	"log"
+)
+
+var (
+	ErrOperateSelf   = errors.New("不能对自己操作")
+	ErrInvalidStatus = errors.New("状态不对")
+)
+
+// AdminOperator 谁在操作，记审计日志用
+type AdminOperator struct {
+	Uid int64
+	Ip  string
+}
+
+// AdminService 管理后台。所有改动都要记审计日志
+type AdminService struct {
+	userSvc  *LocalUserService
+	sessSvc  *SessionService
+	auditLog *repository.AuditLogRepository
+}
+
+func NewAdminService(userSvc *LocalUserService, sessSvc *SessionService,
+	auditLog *repository.AuditLogRepository) *AdminService {
+	return &AdminService{
+		userSvc:  userSvc,
+		sessSvc:  sessSvc,
+		auditLog: auditLog,
+	}
+}
+
+// IsAdmin 每次都查数据库，撤销管理员马上就能生效
+func (svc *AdminService) IsAdmin(ctx context.Context, uid int64) (bool, error) {
+	u, err := svc.userSvc.FindById(ctx, uid)
+	if err == ErrUserNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return u.Role == domain.UserRoleAdmin && u.Status == domain.UserStatusActive, nil
+}
+
+func (svc *AdminService) SearchUsers(ctx context.Context, email string, phone string,
+	offset int, limit int) ([]domain.User, int64, error) {
+	return svc.userSvc.Search(ctx, email, phone, offset, limit)
+}
+
+// UpdateUserStatus 禁用、封禁或者恢复，禁用和封禁会让这个用户所有登录的地方下线
+func (svc *AdminService) UpdateUserStatus(ctx context.Context, op AdminOperator, uid int64,
+	status domain.UserStatus, reason string) error {
+	var action string
+	switch status {
+	case domain.UserStatusActive:
+		action = domain.AuditActionUserEnable
+	case domain.UserStatusDisabled:
+		action = domain.AuditActionUserDisable
+	case domain.UserStatusBanned:
+		action = domain.AuditActionUserBan
+	default:
+		return ErrInvalidStatus
+	}
+	if uid == op.Uid {
+		return ErrOperateSelf
+	}
+	u, err := svc.userSvc.FindById(ctx, uid)
+	if err != nil {
+		return err
+	}
+	// 注销了的个人信息都没了，恢复了也登录不了
+	if u.Status == domain.UserStatusDeleted {
+		return ErrUserDeleted
+	}
+	err = svc.userSvc.UpdateStatus(ctx, uid, status, reason)
+	if err != nil {
+		return err
+	}
+	if status != domain.UserStatusActive {
+		err = svc.sessSvc.RevokeAll(ctx, uid)
+		if err != nil {
+			return err
+		}
+	}
+	return svc.audit(ctx, op, uid, action, reason, map[string]any{
+		"from": u.Status,
+		"to":   status,
+	})
+}
+
+// ResetPassword 重置成临时密码并且让所有登录的地方下线，返回临时密码，
+// 由管理员通过别的渠道告诉用户
+func (svc *AdminService) ResetPassword(ctx context.Context, op AdminOperator, uid int64, reason string) (string, error) {
+	if uid == op.Uid {
+		return "", ErrOperateSelf
+	}
+	_, err := svc.userSvc.FindById(ctx, uid)
+	if err != nil {
+		return "", err
+	}
+	password, err := svc.userSvc.ResetPassword(ctx, uid)
+	if err != nil {
+		return "", err
+	}
+	err = svc.sessSvc.RevokeAll(ctx, uid)
+	if err != nil {
+		return "", err
+	}
+	// 临时密码不能记到日志里面
+	return password, svc.audit(ctx, op, uid, domain.AuditActionUserResetPassword, reason, nil)
+}
+
+// UnlockUser 解锁登录失败次数太多被锁定的账号
+func (svc *AdminService) UnlockUser(ctx context.Context, op AdminOperator, uid int64, reason string) error {
+	u, err := svc.userSvc.FindById(ctx, uid)
+	if err != nil {
+		return err
+	}
+	err = svc.userSvc.UnlockAccount(ctx, u.Email)
+	if err != nil {
+		return err
+	}
+	return svc.audit(ctx, op, uid, domain.AuditActionUserUnlock, reason, nil)
+}
+
+func (svc *AdminService) AuditLogs(ctx context.Context, q domain.AuditLogQuery) ([]domain.AuditLog, error) {
+	return svc.auditLog.Find(ctx, q)
+}
+
+// audit 操作已经做完了才记，记失败了也要让管理员知道
+func (svc *AdminService) audit(ctx context.Context, op AdminOperator, uid int64,
+	action string, reason string, detail map[string]any) error {
+	_, err := svc.auditLog.Create(ctx, domain.AuditLog{
+		OperatorId: op.Uid,
+		TargetType: domain.AuditTargetUser,
+		TargetId:   uid,
+		Action:     action,
+		Reason:     reason,
+		Detail:     detail,
+		Ip:         op.Ip,
+	})
+	if err != nil {
+		log.Println("记录审计日志失败", op.Uid, action, uid, err)
+	}
+	return err
+}
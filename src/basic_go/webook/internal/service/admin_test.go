@@ -0,0 +1,82 @@
+package service
+
+import (
+	"basic_go/webook/internal/domain"
+	"basic_go/webook/internal/repository"
+	"basic_go/webook/internal/repository/cache"
+	"basic_go/webook/internal/repository/dao"
+	"basic_go/webook/internal/repository/dao/daotest"
+	"basic_go/webook/pkg/hasher"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestAdminService(t *testing.T) {
+	db := daotest.OpenDB(t, &dao.User{}, &dao.AuditLog{})
+	guard := NewLoginGuard(repository.NewLoginAttemptRepository(cache.NewMemoryLoginAttemptCache(nil)),
+		DefaultLoginGuardConfig())
+	userSvc := NewLocalUserService(repository.NewUserRepository(dao.NewUserDAO(db)), guard,
+		domain.DefaultPasswordPolicy(), hasher.NewBcrypt(bcrypt.MinCost))
+	sessSvc := NewSessionService(repository.NewSessionRepository(cache.NewMemorySessionCache()))
+	svc := NewAdminService(userSvc, sessSvc, repository.NewAuditLogRepository(dao.NewAuditLogDAO(db)))
+	ctx := context.Background()
+
+	const password = "hello#world123"
+	for _, email := range []string{"admin@qq.com", "a_b@qq.com", "axb@qq.com"} {
+		require.NoError(t, userSvc.Signup(ctx, domain.User{Email: email, Password: password}))
+	}
+	require.NoError(t, db.Model(&dao.User{}).Where("id = ?", 1).Update("role", domain.UserRoleAdmin).Error)
+	ok, err := svc.IsAdmin(ctx, 1)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	ok, err = svc.IsAdmin(ctx, 2)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	// _ 不能当成通配符
+	us, total, err := svc.SearchUsers(ctx, "a_", "", 0, 10)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), total)
+	require.Len(t, us, 1)
+	assert.Equal(t, "a_b@qq.com", us[0].Email)
+
+	op := AdminOperator{Uid: 1, Ip: "127.0.0.1"}
+	assert.Equal(t, ErrOperateSelf, svc.UpdateUserStatus(ctx, op, 1, domain.UserStatusBanned, "test"))
+
+	loginTime := time.Now().Add(-time.Second)
+	require.NoError(t, svc.UpdateUserStatus(ctx, op, 2, domain.UserStatusDisabled, "被盗号了"))
+	_, err = userSvc.Login(ctx, "a_b@qq.com", password, "127.0.0.1")
+	assert.Equal(t, ErrUserDisabled, err)
+	// 之前登录的地方都下线了
+	valid, err := sessSvc.Valid(ctx, 2, loginTime)
+	require.NoError(t, err)
+	assert.False(t, valid)
+
+	require.NoError(t, svc.UpdateUserStatus(ctx, op, 2, domain.UserStatusActive, ""))
+	newPassword, err := svc.ResetPassword(ctx, op, 2, "用户忘记密码了")
+	require.NoError(t, err)
+	assert.NoError(t, domain.DefaultPasswordPolicy().Check(newPassword, "a_b@qq.com"))
+	_, err = userSvc.Login(ctx, "a_b@qq.com", password, "127.0.0.1")
+	assert.Equal(t, ErrInvalidUserOrPassword, err)
+	_, err = userSvc.Login(ctx, "a_b@qq.com", newPassword, "127.0.0.1")
+	assert.NoError(t, err)
+
+	ls, err := svc.AuditLogs(ctx, domain.AuditLogQuery{TargetType: domain.AuditTargetUser, TargetId: 2, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, ls, 3)
+	assert.Equal(t, domain.AuditActionUserResetPassword, ls[0].Action)
+	assert.Equal(t, domain.AuditActionUserEnable, ls[1].Action)
+	assert.Equal(t, domain.AuditActionUserDisable, ls[2].Action)
+	assert.Equal(t, "被盗号了", ls[2].Reason)
+	assert.Equal(t, float64(domain.UserStatusDisabled), ls[2].Detail["to"])
+	assert.Equal(t, "127.0.0.1", ls[2].Ip)
+
+	ls, err = svc.AuditLogs(ctx, domain.AuditLogQuery{OperatorId: 2, Limit: 10})
+	require.NoError(t, err)
+	assert.Len(t, ls, 0)
+}
@@ -0,0 +1,182 @@
+package service
+
+import (
+	"basic_go/webook/internal/domain"
+	events "basic_go/webook/internal/events/article"
+	"basic_go/webook/internal/repository"
+	"context"
+	"log"
+	"time"
+)
+
+var ErrArticleNotFound = repository.ErrArticleNotFound
+
+type ArticleService struct {
+	repo     *repository.ArticleRepository
+	producer *events.Producer
+}
+
+func NewArticleService(repo *repository.ArticleRepository, producer *events.Producer) *ArticleService {
+	return &ArticleService{
+		repo:     repo,
+		producer: producer,
+	}
+}
+
+// Save 保存草稿，返回文章 ID
+func (svc *ArticleService) Save(ctx context.Context, art domain.Article) (int64, error) {
+	art.Status = domain.ArticleStatusUnpublished
+	if art.Id > 0 {
+		err := svc.repo.Update(ctx, art)
+		return art.Id, err
+	}
+	return svc.repo.Create(ctx, art)
+}
+
+// Publish 发表，同时保存到制作库和线上库
+func (svc *ArticleService) Publish(ctx context.Context, art domain.Article) (int64, error) {
+	art.Status = domain.ArticleStatusPublished
+	// 线上库里面已经是发表状态的，就是修改
+	typ := events.ChangeTypePublish
+	if art.Id > 0 {
+		pub, err := svc.repo.GetPubById(ctx, art.Id)
+		if err == nil && pub.Status == domain.ArticleStatusPublished {
+			typ = events.ChangeTypeUpdate
+		}
+	}
+	id, err := svc.repo.Sync(ctx, art)
+	if err != nil {
+		return id, err
+	}
+	svc.produceChangeEvent(ctx, events.ChangeEvent{
+		Type:     typ,
+		Aid:      id,
+		AuthorId: art.Author.Id,
+		Title:    art.Title,
+		Content:  art.Content,
+		Utime:    time.Now().UnixMilli(),
+	})
+	return id, nil
+}
+
+// Withdraw 撤回，变成仅自己可见
+func (svc *ArticleService) Withdraw(ctx context.Context, uid int64, id int64) error {
+	err := svc.repo.SyncStatus(ctx, uid, id, domain.ArticleStatusPrivate)
+	if err != nil {
+		return err
+	}
+	svc.produceChangeEvent(ctx, events.ChangeEvent{
+		Type:     events.ChangeTypeUnpublish,
+		Aid:      id,
+		AuthorId: uid,
+		Utime:    time.Now().UnixMilli(),
+	})
+	return nil
+}
+
+// WithdrawAll 撤回 uid 所有已发表的文章，注销的时候用
+func (svc *ArticleService) WithdrawAll(ctx context.Context, uid int64) error {
+	arts, err := svc.listPubByAuthor(ctx, uid)
+	if err != nil {
+		return err
+	}
+	for _, art := range arts {
+		err = svc.Withdraw(ctx, uid, art.Id)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// TransferAll 把 from 的文章全部转给 to，注销的时候用。
+// 已发表的要发更新事件，搜索那边的作者也要跟着改
+func (svc *ArticleService) TransferAll(ctx context.Context, from int64, to int64) error {
+	arts, err := svc.listPubByAuthor(ctx, from)
+	if err != nil {
+		return err
+	}
+	err = svc.repo.TransferAuthor(ctx, from, to)
+	if err != nil {
+		return err
+	}
+	for _, art := range arts {
+		svc.produceChangeEvent(ctx, events.ChangeEvent{
+			Type:     events.ChangeTypeUpdate,
+			Aid:      art.Id,
+			AuthorId: to,
+			Title:    art.Title,
+			Content:  art.Content,
+			Utime:    time.Now().UnixMilli(),
+		})
+	}
+	return nil
+}
+
+// listPubByAuthor 先全部找出来再改，转给别人之后按 uid 就找不到了
+func (svc *ArticleService) listPubByAuthor(ctx context.Context, uid int64) ([]domain.Article, error) {
+	const batchSize = 100
+	var res []domain.Article
+	startId := int64(0)
+	for {
+		arts, err := svc.repo.ListPubByAuthor(ctx, uid, startId, batchSize)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, arts...)
+		if len(arts) < batchSize {
+			return res, nil
+		}
+		startId = arts[len(arts)-1].Id
+	}
+}
+
+// produceChangeEvent 数据库已经改成功了，事件发不出去也不能让请求失败，只记日志
+func (svc *ArticleService) produceChangeEvent(ctx context.Context, evt events.ChangeEvent) {
+	err := svc.producer.ProduceChangeEvent(ctx, evt)
+	if err != nil {
+		log.Println("发送文章变更事件失败", evt.Type, evt.Aid, err)
+	}
+}
+
+func (svc *ArticleService) GetByAuthor(ctx context.Context, uid int64, offset int, limit int) ([]domain.Article, error) {
+	return svc.repo.GetByAuthor(ctx, uid, offset, limit)
+}
+
+func (svc *ArticleService) GetById(ctx context.Context, id int64) (domain.Article, error) {
+	return svc.repo.GetById(ctx, id)
+}
+
+// GetPublished 找已发表的文章，不算阅读，点赞之类的操作用
+func (svc *ArticleService) GetPublished(ctx context.Context, id int64) (domain.Article, error) {
+	art, err := svc.repo.GetPubById(ctx, id)
+	if err == repository.ErrArticleNotFound ||
+		(err == nil && art.Status != domain.ArticleStatusPublished) {
+		return domain.Article{}, ErrArticleNotFound
+	}
+	return art, err
+}
+
+// GetPubById 读者看文章，uid 是读者。阅读数是异步更新的
+func (svc *ArticleService) GetPubById(ctx context.Context, id int64, uid int64) (domain.Article, error) {
+	art, err := svc.repo.GetPubById(ctx, id)
+	if err == repository.ErrArticleNotFound {
+		return domain.Article{}, ErrArticleNotFound
+	}
+	if err != nil {
+		return domain.Article{}, err
+	}
+	if art.Status != domain.ArticleStatusPublished {
+		return domain.Article{}, ErrArticleNotFound
+	}
+	go func() {
+		// 不能用 ctx，请求结束之后它就被取消了
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		err := svc.producer.ProduceReadEvent(ctx, events.ReadEvent{Uid: uid, Aid: id})
+		if err != nil {
+			log.Println("发送阅读事件失败", err)
+		}
+	}()
+	return art, nil
+}
@@ -0,0 +1,147 @@
+package service
+
+import (
+	"basic_go/webook/internal/domain"
+	events "basic_go/webook/internal/events/comment"
+	"basic_go/webook/internal/repository"
+	"context"
+	"errors"
+	"log"
+	"math"
+)
+
+var (
+	ErrCommentNotFound   = errors.New("评论不存在")
+	ErrCommentNoAuth     = errors.New("只有评论者和作者可以删除评论")
+	ErrUnsupportedBiz    = errors.New("不支持评论这种资源")
+	ErrInvalidCommentArg = errors.New("回复的评论和资源对不上")
+)
+
+// 列表页每条根评论预先加载几条回复，更多的要用户点开再加载
+const commentPreloadReplies = 3
+
+type CommentService struct {
+	repo     *repository.CommentRepository
+	artRepo  *repository.ArticleRepository
+	producer *events.Producer
+}
+
+func NewCommentService(repo *repository.CommentRepository, artRepo *repository.ArticleRepository,
+	producer *events.Producer) *CommentService {
+	return &CommentService{
+		repo:     repo,
+		artRepo:  artRepo,
+		producer: producer,
+	}
+}
+
+// Create 发评论或者回复。ParentId > 0 就是回复
+func (svc *CommentService) Create(ctx context.Context, c domain.Comment) (int64, error) {
+	var replyTo int64
+	if c.ParentId > 0 {
+		parent, err := svc.repo.FindById(ctx, c.ParentId)
+		if err == repository.ErrCommentNotFound {
+			return 0, ErrCommentNotFound
+		}
+		if err != nil {
+			return 0, err
+		}
+		if parent.Biz != c.Biz || parent.BizId != c.BizId {
+			return 0, ErrInvalidCommentArg
+		}
+		c.RootId = parent.RootId
+		if c.RootId == 0 {
+			c.RootId = parent.Id
+		}
+		replyTo = parent.Uid
+	} else {
+		c.RootId = 0
+	}
+	owner, err := svc.checkCommentable(ctx, c.Biz, c.BizId)
+	if err != nil {
+		return 0, err
+	}
+	id, err := svc.repo.Create(ctx, c)
+	if err != nil {
+		return 0, err
+	}
+	err = svc.producer.ProduceCommentEvent(ctx, events.CommentEvent{
+		Id:      id,
+		Uid:     c.Uid,
+		Biz:     c.Biz,
+		BizId:   c.BizId,
+		Content: c.Content,
+		Owner:   owner,
+		ReplyTo: replyTo,
+	})
+	if err != nil {
+		log.Println("发送评论事件失败", err)
+	}
+	return id, nil
+}
+
+// Delete 评论者自己或者资源的作者才能删，回复会一起删掉
+func (svc *CommentService) Delete(ctx context.Context, uid int64, id int64) error {
+	c, err := svc.repo.FindById(ctx, id)
+	if err == repository.ErrCommentNotFound {
+		return ErrCommentNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if c.Uid != uid {
+		owner, err := svc.owner(ctx, c.Biz, c.BizId)
+		if err != nil {
+			return err
+		}
+		if owner != uid {
+			return ErrCommentNoAuth
+		}
+	}
+	return svc.repo.Delete(ctx, c)
+}
+
+// List 根评论，maxId 是上一页最后一条的 ID，第一页传 0
+func (svc *CommentService) List(ctx context.Context, biz string, bizId int64, maxId int64, limit int) ([]domain.Comment, error) {
+	if maxId <= 0 {
+		maxId = math.MaxInt64
+	}
+	return svc.repo.FindRoots(ctx, biz, bizId, maxId, limit, commentPreloadReplies)
+}
+
+// Replies 加载更多回复，minId 是已经加载的最后一条回复的 ID
+func (svc *CommentService) Replies(ctx context.Context, rootId int64, minId int64, limit int) ([]domain.Comment, error) {
+	return svc.repo.FindReplies(ctx, rootId, minId, limit)
+}
+
+// checkCommentable 资源要存在，文章要是已发表的。返回资源的作者
+func (svc *CommentService) checkCommentable(ctx context.Context, biz string, bizId int64) (int64, error) {
+	switch biz {
+	case "article":
+		art, err := svc.artRepo.GetPubById(ctx, bizId)
+		if err == repository.ErrArticleNotFound ||
+			(err == nil && art.Status != domain.ArticleStatusPublished) {
+			return 0, ErrArticleNotFound
+		}
+		return art.Author.Id, err
+	default:
+		return 0, ErrUnsupportedBiz
+	}
+}
+
+// owner 资源的作者。文章撤回了作者也能删评论，所以查的是制作库
+func (svc *CommentService) owner(ctx context.Context, biz string, bizId int64) (int64, error) {
+	switch biz {
+	case "article":
+		art, err := svc.artRepo.GetById(ctx, bizId)
+		if err == repository.ErrArticleNotFound {
+			return 0, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		return art.Author.Id, nil
+	default:
+		return 0, nil
+	}
+}
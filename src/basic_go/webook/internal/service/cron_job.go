@@ -0,0 +1,68 @@
+package service
+
+import (
+	"basic_go/webook/internal/domain"
+	"basic_go/webook/internal/repository"
+	"context"
+	"time"
+)
+
+var (
+	ErrNoJob   = repository.ErrNoJob
+	ErrJobLost = repository.ErrJobLost
+)
+
+type CronJobConfig struct {
+	// Owner 当前实例的标识，每个实例都要不一样
+	Owner string
+	// HeartbeatTimeout 运行中的任务超过这么久没有续约，就当作原来的实例挂了，别的实例可以抢过去
+	HeartbeatTimeout time.Duration
+}
+
+// CronJobService 多个实例通过数据库抢任务，保证同一个任务同一时刻只在一个实例上面跑
+type CronJobService struct {
+	repo *repository.JobRepository
+	cfg  CronJobConfig
+}
+
+func NewCronJobService(repo *repository.JobRepository, cfg CronJobConfig) *CronJobService {
+	return &CronJobService{
+		repo: repo,
+		cfg:  cfg,
+	}
+}
+
+// AddJob 同名的任务已经有了就什么都不做
+func (svc *CronJobService) AddJob(ctx context.Context, j domain.Job) error {
+	next, err := j.Next(time.Now())
+	if err != nil {
+		return err
+	}
+	j.NextTime = next
+	j.Status = domain.JobStatusWaiting
+	return svc.repo.Create(ctx, j)
+}
+
+// Preempt 抢一个可以执行的任务，没有的话返回 ErrNoJob
+func (svc *CronJobService) Preempt(ctx context.Context) (domain.Job, error) {
+	return svc.repo.Preempt(ctx, svc.cfg.Owner, time.Now().Add(-svc.cfg.HeartbeatTimeout))
+}
+
+// Heartbeat 任务跑的过程中要定时续约，返回 ErrJobLost 说明任务被别人抢走了，应该停下来
+func (svc *CronJobService) Heartbeat(ctx context.Context, j domain.Job) error {
+	return svc.repo.Heartbeat(ctx, j.Id, svc.cfg.Owner, j.Version)
+}
+
+// Release 跑完了放回去，按照表达式算好下一次执行的时间。
+// 表达式不对的话直接暂停，不然每次续约超时之后都会被重新抢到再跑一遍
+func (svc *CronJobService) Release(ctx context.Context, j domain.Job) error {
+	next, err := j.Next(time.Now())
+	if err != nil {
+		perr := svc.repo.Pause(ctx, j.Id, svc.cfg.Owner, j.Version)
+		if perr != nil {
+			return perr
+		}
+		return err
+	}
+	return svc.repo.Release(ctx, j.Id, svc.cfg.Owner, j.Version, next)
+}
@@ -0,0 +1,353 @@
+package service
+
+import (
+	"archive/zip"
+	"basic_go/webook/internal/domain"
+	"basic_go/webook/internal/repository"
+	"basic_go/webook/pkg/objstore"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"time"
+)
+
+var ErrDataExportNotFound = repository.ErrDataExportNotFound
+
+type DataExportConfig struct {
+	// 打包好的文件保留多久，过了就删掉，要重新申请
+	RetainFor time.Duration
+	// 下载地址的有效期
+	SignExpire time.Duration
+	// 打包了这么久还没好，就当作那个实例挂了，重新打包
+	BuildTimeout time.Duration
+}
+
+func DefaultDataExportConfig() DataExportConfig {
+	return DataExportConfig{
+		RetainFor:    time.Hour * 24 * 7,
+		SignExpire:   time.Minute * 15,
+		BuildTimeout: time.Minute * 10,
+	}
+}
+
+// DataExportService 用户导出自己的数据：个人信息、文章、评论、点赞和关注。
+// 申请之后由定时任务打包成 ZIP 放到对象存储的私有目录，打包好了给带签名的下载地址
+type DataExportService struct {
+	repo        *repository.DataExportRepository
+	userRepo    *repository.UserRepository
+	artRepo     *repository.ArticleRepository
+	commentRepo *repository.CommentRepository
+	intrRepo    *repository.InteractiveRepository
+	followRepo  *repository.FollowRepository
+	store       objstore.Store
+	cfg         DataExportConfig
+}
+
+func NewDataExportService(repo *repository.DataExportRepository, userRepo *repository.UserRepository,
+	artRepo *repository.ArticleRepository, commentRepo *repository.CommentRepository,
+	intrRepo *repository.InteractiveRepository, followRepo *repository.FollowRepository,
+	store objstore.Store, cfg DataExportConfig) *DataExportService {
+	return &DataExportService{
+		repo:        repo,
+		userRepo:    userRepo,
+		artRepo:     artRepo,
+		commentRepo: commentRepo,
+		intrRepo:    intrRepo,
+		followRepo:  followRepo,
+		store:       store,
+		cfg:         cfg,
+	}
+}
+
+// Request 申请导出。已经有一个在排队的就直接返回它，不会重复打包
+func (svc *DataExportService) Request(ctx context.Context, uid int64) (domain.DataExport, error) {
+	e, err := svc.repo.FindLatest(ctx, uid)
+	if err == nil && e.Status == domain.DataExportStatusPending {
+		return e, nil
+	}
+	if err != nil && err != repository.ErrDataExportNotFound {
+		return domain.DataExport{}, err
+	}
+	now := time.Now()
+	e = domain.DataExport{
+		Uid:    uid,
+		Status: domain.DataExportStatusPending,
+		Ctime:  now,
+		Utime:  now,
+	}
+	e.Id, err = svc.repo.Create(ctx, e)
+	return e, err
+}
+
+// Detail 只能看自己的。打包好了的会带上下载地址
+func (svc *DataExportService) Detail(ctx context.Context, uid int64, id int64) (domain.DataExport, error) {
+	e, err := svc.repo.FindById(ctx, id)
+	if err != nil {
+		return domain.DataExport{}, err
+	}
+	if e.Uid != uid {
+		return domain.DataExport{}, ErrDataExportNotFound
+	}
+	if e.Status != domain.DataExportStatusDone {
+		return e, nil
+	}
+	// 定时任务还没来得及清理的，也当作已经过期了
+	if time.Since(e.Utime) > svc.cfg.RetainFor {
+		e.Status = domain.DataExportStatusExpired
+		return e, nil
+	}
+	e.URL, err = svc.store.SignURL(ctx, e.Key, svc.cfg.SignExpire)
+	return e, err
+}
+
+// BuildPending 一个一个抢排队的来打包，直到没有了
+func (svc *DataExportService) BuildPending(ctx context.Context) error {
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		e, err := svc.repo.PreemptPending(ctx, time.Now().Add(-svc.cfg.BuildTimeout))
+		if err == repository.ErrNoPendingExport {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		key, size, err := svc.build(ctx, e.Uid)
+		if err != nil {
+			log.Println("打包用户数据失败", e.Id, err)
+			err = svc.repo.MarkFailed(ctx, e.Id)
+		} else {
+			err = svc.repo.MarkDone(ctx, e.Id, key, size)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// SweepExpired 删掉过了保留期的文件
+func (svc *DataExportService) SweepExpired(ctx context.Context) error {
+	const batchSize = 100
+	before := time.Now().Add(-svc.cfg.RetainFor)
+	startId := int64(0)
+	for {
+		es, err := svc.repo.FindDoneBefore(ctx, before, startId, batchSize)
+		if err != nil {
+			return err
+		}
+		for _, e := range es {
+			// 先删文件再改状态，改状态失败了下一次再删一遍也没关系
+			err = svc.store.Delete(ctx, e.Key)
+			if err == nil {
+				err = svc.repo.MarkExpired(ctx, e.Id)
+			}
+			if err != nil {
+				log.Println("清理导出的文件失败", e.Key, err)
+			}
+		}
+		if len(es) < batchSize {
+			return nil
+		}
+		startId = es[len(es)-1].Id
+	}
+}
+
+// 导出的文件里面的格式，字段名字是给用户看的，不要随便改
+type exportProfile struct {
+	Id     int64     `json:"id"`
+	Email  string    `json:"email"`
+	Phone  string    `json:"phone"`
+	Avatar string    `json:"avatar"`
+	Ctime  time.Time `json:"ctime"`
+}
+
+type exportArticle struct {
+	Id      int64     `json:"id"`
+	Title   string    `json:"title"`
+	Content string    `json:"content"`
+	Status  uint8     `json:"status"`
+	Ctime   time.Time `json:"ctime"`
+	Utime   time.Time `json:"utime"`
+}
+
+type exportComment struct {
+	Id       int64     `json:"id"`
+	Biz      string    `json:"biz"`
+	BizId    int64     `json:"bizId"`
+	RootId   int64     `json:"rootId"`
+	ParentId int64     `json:"parentId"`
+	Content  string    `json:"content"`
+	Ctime    time.Time `json:"ctime"`
+}
+
+type exportInteractions struct {
+	Likes     []exportLike   `json:"likes"`
+	Followees []exportFollow `json:"followees"`
+}
+
+type exportLike struct {
+	Biz   string    `json:"biz"`
+	BizId int64     `json:"bizId"`
+	Ctime time.Time `json:"ctime"`
+}
+
+type exportFollow struct {
+	Uid   int64     `json:"uid"`
+	Ctime time.Time `json:"ctime"`
+}
+
+// build 打包成 ZIP 传到对象存储，返回 key 和大小。
+// 一个用户的数据不会太多，直接在内存里面打包
+func (svc *DataExportService) build(ctx context.Context, uid int64) (string, int64, error) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, f := range []struct {
+		name  string
+		fetch func(ctx context.Context, uid int64) (any, error)
+	}{
+		{"profile.json", svc.exportProfile},
+		{"articles.json", svc.exportArticles},
+		{"comments.json", svc.exportComments},
+		{"interactions.json", svc.exportInteractions},
+	} {
+		data, err := f.fetch(ctx, uid)
+		if err != nil {
+			return "", 0, err
+		}
+		w, err := zw.Create(f.name)
+		if err != nil {
+			return "", 0, err
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(data)
+		if err != nil {
+			return "", 0, err
+		}
+	}
+	err := zw.Close()
+	if err != nil {
+		return "", 0, err
+	}
+	key, err := svc.newKey(uid)
+	if err != nil {
+		return "", 0, err
+	}
+	size := int64(buf.Len())
+	return key, size, svc.store.Put(ctx, key, &buf, size, "application/zip")
+}
+
+func (svc *DataExportService) exportProfile(ctx context.Context, uid int64) (any, error) {
+	u, err := svc.userRepo.FindById(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+	return exportProfile{
+		Id:     u.Id,
+		Email:  u.Email,
+		Phone:  u.Phone,
+		Avatar: u.Avatar,
+		Ctime:  u.Ctime,
+	}, nil
+}
+
+// exportArticles 制作库里面的，包括草稿和撤回了的
+func (svc *DataExportService) exportArticles(ctx context.Context, uid int64) (any, error) {
+	const batchSize = 100
+	res := []exportArticle{}
+	for offset := 0; ; offset += batchSize {
+		arts, err := svc.artRepo.GetByAuthor(ctx, uid, offset, batchSize)
+		if err != nil {
+			return nil, err
+		}
+		for _, art := range arts {
+			res = append(res, exportArticle{
+				Id:      art.Id,
+				Title:   art.Title,
+				Content: art.Content,
+				Status:  art.Status.ToUint8(),
+				Ctime:   art.Ctime,
+				Utime:   art.Utime,
+			})
+		}
+		if len(arts) < batchSize {
+			return res, nil
+		}
+	}
+}
+
+func (svc *DataExportService) exportComments(ctx context.Context, uid int64) (any, error) {
+	const batchSize = 100
+	res := []exportComment{}
+	startId := int64(0)
+	for {
+		cs, err := svc.commentRepo.FindByUid(ctx, uid, startId, batchSize)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range cs {
+			res = append(res, exportComment{
+				Id:       c.Id,
+				Biz:      c.Biz,
+				BizId:    c.BizId,
+				RootId:   c.RootId,
+				ParentId: c.ParentId,
+				Content:  c.Content,
+				Ctime:    c.Ctime,
+			})
+		}
+		if len(cs) < batchSize {
+			return res, nil
+		}
+		startId = cs[len(cs)-1].Id
+	}
+}
+
+func (svc *DataExportService) exportInteractions(ctx context.Context, uid int64) (any, error) {
+	const batchSize = 100
+	res := exportInteractions{Likes: []exportLike{}, Followees: []exportFollow{}}
+	startId := int64(0)
+	for {
+		ls, err := svc.intrRepo.FindLikesByUid(ctx, uid, startId, batchSize)
+		if err != nil {
+			return nil, err
+		}
+		for _, l := range ls {
+			res.Likes = append(res.Likes, exportLike{Biz: l.Biz, BizId: l.BizId, Ctime: l.Ctime})
+		}
+		if len(ls) < batchSize {
+			break
+		}
+		startId = ls[len(ls)-1].Id
+	}
+	maxId := int64(math.MaxInt64)
+	for {
+		rs, err := svc.followRepo.FindFollowees(ctx, uid, maxId, batchSize)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range rs {
+			res.Followees = append(res.Followees, exportFollow{Uid: r.Followee, Ctime: r.Ctime})
+		}
+		if len(rs) < batchSize {
+			return res, nil
+		}
+		maxId = rs[len(rs)-1].Id
+	}
+}
+
+// newKey 生成 private/export/uid/20240102/随机串.zip 这种 key，私有目录要签名才能下载
+func (svc *DataExportService) newKey(uid int64) (string, error) {
+	var buf [16]byte
+	if _, err := rand.Read(buf[:]); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%sexport/%d/%s/%s.zip", uploadPrivatePrefix, uid,
+		time.Now().Format("20060102"), hex.EncodeToString(buf[:])), nil
+}
@@ -0,0 +1,137 @@
+package service
+
+import (
+	"archive/zip"
+	"basic_go/webook/internal/domain"
+	"basic_go/webook/internal/repository"
+	"basic_go/webook/internal/repository/cache"
+	"basic_go/webook/internal/repository/dao"
+	"basic_go/webook/internal/repository/dao/daotest"
+	"basic_go/webook/pkg/objstore"
+	"basic_go/webook/pkg/objstore/local"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDataExportService(t *testing.T) {
+	db := daotest.OpenDB(t, &dao.User{}, &dao.Article{}, &dao.PublishedArticle{}, &dao.Comment{},
+		&dao.Interactive{}, &dao.UserLikeBiz{}, &dao.FollowRelation{}, &dao.DataExport{})
+	rdb := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	store := local.NewStore(t.TempDir(), "http://localhost/objects", []byte("secret"), PublicUploadPrefix())
+	userRepo := repository.NewUserRepository(dao.NewUserDAO(db))
+	artDAO := dao.NewArticleDAO(db)
+	commentDAO := dao.NewCommentDAO(db)
+	intrDAO := dao.NewInteractiveDAO(db)
+	followDAO := dao.NewFollowDAO(db)
+	cfg := DefaultDataExportConfig()
+	svc := NewDataExportService(repository.NewDataExportRepository(dao.NewDataExportDAO(db)), userRepo,
+		repository.NewArticleRepository(artDAO), repository.NewCommentRepository(commentDAO),
+		repository.NewInteractiveRepository(intrDAO),
+		repository.NewFollowRepository(followDAO, cache.NewRedisFollowCache(rdb)), store, cfg)
+	ctx := context.Background()
+
+	require.NoError(t, userRepo.Create(ctx, domain.User{Email: "a@qq.com", Password: "hash"}))
+	require.NoError(t, userRepo.Create(ctx, domain.User{Email: "b@qq.com", Password: "hash"}))
+	_, err := artDAO.Insert(ctx, dao.Article{Title: "我的文章", Content: "内容", AuthorId: 1})
+	require.NoError(t, err)
+	_, err = artDAO.Insert(ctx, dao.Article{Title: "别人的文章", Content: "内容", AuthorId: 2})
+	require.NoError(t, err)
+	_, err = commentDAO.Insert(ctx, dao.Comment{Uid: 1, Biz: "article", BizId: 2, Content: "写得好"})
+	require.NoError(t, err)
+	_, err = commentDAO.Insert(ctx, dao.Comment{Uid: 2, Biz: "article", BizId: 1, Content: "别人的评论"})
+	require.NoError(t, err)
+	_, err = intrDAO.InsertLikeInfo(ctx, "article", 2, 1)
+	require.NoError(t, err)
+	_, err = intrDAO.InsertLikeInfo(ctx, "article", 3, 1)
+	require.NoError(t, err)
+	// 取消了的不导出
+	_, err = intrDAO.DeleteLikeInfo(ctx, "article", 3, 1)
+	require.NoError(t, err)
+	_, err = followDAO.Follow(ctx, 1, 2)
+	require.NoError(t, err)
+
+	e, err := svc.Request(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, domain.DataExportStatusPending, e.Status)
+	// 还在排队的不会再建一个
+	again, err := svc.Request(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, e.Id, again.Id)
+	// 别人的看不到
+	_, err = svc.Detail(ctx, 2, e.Id)
+	assert.Equal(t, ErrDataExportNotFound, err)
+
+	require.NoError(t, svc.BuildPending(ctx))
+	e, err = svc.Detail(ctx, 1, e.Id)
+	require.NoError(t, err)
+	assert.Equal(t, domain.DataExportStatusDone, e.Status)
+	assert.True(t, strings.HasPrefix(e.Key, "private/export/1/"))
+	assert.Contains(t, e.URL, "sign=")
+
+	rc, err := store.Get(ctx, e.Key)
+	require.NoError(t, err)
+	data, err := io.ReadAll(rc)
+	require.NoError(t, err)
+	require.NoError(t, rc.Close())
+	assert.Equal(t, e.Size, int64(len(data)))
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	require.NoError(t, err)
+	files := make(map[string]string)
+	for _, f := range zr.File {
+		r, err := f.Open()
+		require.NoError(t, err)
+		content, err := io.ReadAll(r)
+		require.NoError(t, err)
+		files[f.Name] = string(content)
+	}
+	require.Len(t, files, 4)
+
+	var profile exportProfile
+	require.NoError(t, json.Unmarshal([]byte(files["profile.json"]), &profile))
+	assert.Equal(t, "a@qq.com", profile.Email)
+	// 密码的哈希不能导出去
+	assert.NotContains(t, files["profile.json"], "hash")
+	var arts []exportArticle
+	require.NoError(t, json.Unmarshal([]byte(files["articles.json"]), &arts))
+	require.Len(t, arts, 1)
+	assert.Equal(t, "我的文章", arts[0].Title)
+	var comments []exportComment
+	require.NoError(t, json.Unmarshal([]byte(files["comments.json"]), &comments))
+	require.Len(t, comments, 1)
+	assert.Equal(t, "写得好", comments[0].Content)
+	var intrs exportInteractions
+	require.NoError(t, json.Unmarshal([]byte(files["interactions.json"]), &intrs))
+	require.Len(t, intrs.Likes, 1)
+	assert.Equal(t, int64(2), intrs.Likes[0].BizId)
+	require.Len(t, intrs.Followees, 1)
+	assert.Equal(t, int64(2), intrs.Followees[0].Uid)
+
+	// 打包好了之后再申请就是新的一次
+	again, err = svc.Request(ctx, 1)
+	require.NoError(t, err)
+	assert.NotEqual(t, e.Id, again.Id)
+
+	// 过了保留期，文件删掉，也拿不到下载地址了
+	require.NoError(t, db.Model(&dao.DataExport{}).Where("id = ?", e.Id).
+		Update("utime", time.Now().Add(-cfg.RetainFor-time.Minute).UnixMilli()).Error)
+	e, err = svc.Detail(ctx, 1, e.Id)
+	require.NoError(t, err)
+	assert.Equal(t, domain.DataExportStatusExpired, e.Status)
+	assert.Empty(t, e.URL)
+	require.NoError(t, svc.SweepExpired(ctx))
+	_, err = store.Get(ctx, e.Key)
+	assert.Equal(t, objstore.ErrObjectNotFound, err)
+	e, err = svc.Detail(ctx, 1, e.Id)
+	require.NoError(t, err)
+	assert.Equal(t, domain.DataExportStatusExpired, e.Status)
+}
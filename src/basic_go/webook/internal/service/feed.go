@@ -0,0 +1,194 @@
+package service
+
+import (
+	"basic_go/webook/internal/domain"
+	"basic_go/webook/internal/repository"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+)
+
+var ErrUnknownFeedType = errors.New("未知的动态类型")
+
+type FeedConfig struct {
+	// 粉丝数超过这个值的作者，发文章的时候只写自己的发件箱，粉丝看的时候自己来拉；
+	// 没超过的直接写到每个粉丝的收件箱
+	FanoutThreshold int64
+	// 推的时候一批查多少个粉丝，拉的时候一批查多少个关注的人
+	BatchSize int
+}
+
+func DefaultFeedConfig() FeedConfig {
+	return FeedConfig{
+		FanoutThreshold: 1000,
+		BatchSize:       500,
+	}
+}
+
+// FeedEventHandler 每种动态自己决定写到收件箱还是发件箱
+type FeedEventHandler interface {
+	CreateFeedEvent(ctx context.Context, evt domain.FeedEvent) error
+}
+
+type FeedService struct {
+	repo       *repository.FeedRepository
+	followRepo *repository.FollowRepository
+	cfg        FeedConfig
+	handlers   map[string]FeedEventHandler
+}
+
+func NewFeedService(repo *repository.FeedRepository, followRepo *repository.FollowRepository, cfg FeedConfig) *FeedService {
+	svc := &FeedService{
+		repo:       repo,
+		followRepo: followRepo,
+		cfg:        cfg,
+	}
+	svc.handlers = map[string]FeedEventHandler{
+		domain.FeedTypeArticle: &articleFeedHandler{svc: svc},
+		domain.FeedTypeLike:    &pushFeedHandler{repo: repo, receiver: "owner"},
+		domain.FeedTypeFollow:  &pushFeedHandler{repo: repo, receiver: "followee"},
+	}
+	return svc
+}
+
+// RegisterHandler 新的动态类型在这里注册，已经有的会被覆盖
+func (svc *FeedService) RegisterHandler(typ string, hdl FeedEventHandler) {
+	svc.handlers[typ] = hdl
+}
+
+func (svc *FeedService) CreateFeedEvent(ctx context.Context, evt domain.FeedEvent) error {
+	hdl, ok := svc.handlers[evt.Type]
+	if !ok {
+		return ErrUnknownFeedType
+	}
+	if evt.Ctime.IsZero() {
+		evt.Ctime = time.Now()
+	}
+	return hdl.CreateFeedEvent(ctx, evt)
+}
+
+// GetFeed uid 的动态，收件箱和关注的人的发件箱合在一起，按 domain.FeedCursor 说的顺序排
+func (svc *FeedService) GetFeed(ctx context.Context, uid int64, cursor domain.FeedCursor, limit int) ([]domain.FeedEvent, error) {
+	// 把游标换成两张表各自的 (ctime, id)
+	maxTime, pushMaxId, pullMaxId := cursor.Time, int64(math.MaxInt64), int64(math.MaxInt64)
+	switch {
+	case maxTime.IsZero():
+		maxTime = time.Now()
+	case cursor.Pulled:
+		// 同一个 ctime 的收件箱的动态都在前面，已经看过了
+		pushMaxId, pullMaxId = 0, cursor.Id
+	default:
+		// 同一个 ctime 的发件箱的动态都在后面，还没看过
+		pushMaxId = cursor.Id
+	}
+	evts, err := svc.repo.FindPushEvents(ctx, uid, maxTime, pushMaxId, limit)
+	if err != nil {
+		return nil, err
+	}
+	// 只有大 V 才有发件箱，但是不知道哪些是大 V，所以关注的人都要查一下
+	followId := int64(math.MaxInt64)
+	for {
+		rs, err := svc.followRepo.FindFollowees(ctx, uid, followId, svc.cfg.BatchSize)
+		if err != nil {
+			return nil, err
+		}
+		if len(rs) == 0 {
+			break
+		}
+		uids := make([]int64, 0, len(rs))
+		for _, r := range rs {
+			uids = append(uids, r.Followee)
+		}
+		pulled, err := svc.repo.FindPullEvents(ctx, uids, maxTime, pullMaxId, limit)
+		if err != nil {
+			return nil, err
+		}
+		evts = append(evts, pulled...)
+		if len(rs) < svc.cfg.BatchSize {
+			break
+		}
+		followId = rs[len(rs)-1].Id
+	}
+	sort.SliceStable(evts, func(i, j int) bool {
+		if !evts[i].Ctime.Equal(evts[j].Ctime) {
+			return evts[i].Ctime.After(evts[j].Ctime)
+		}
+		if evts[i].Pulled != evts[j].Pulled {
+			return !evts[i].Pulled
+		}
+		return evts[i].Id > evts[j].Id
+	})
+	if len(evts) > limit {
+		evts = evts[:limit]
+	}
+	return evts, nil
+}
+
+// articleFeedHandler 发文章，粉丝少的推，粉丝多的拉。Ext 里面 uid 是作者
+type articleFeedHandler struct {
+	svc *FeedService
+}
+
+func (h *articleFeedHandler) CreateFeedEvent(ctx context.Context, evt domain.FeedEvent) error {
+	author, err := extInt64(evt.Ext, "uid")
+	if err != nil {
+		return err
+	}
+	stats, err := h.svc.followRepo.GetStats(ctx, author)
+	if err != nil {
+		return err
+	}
+	if stats.Followers > h.svc.cfg.FanoutThreshold {
+		evt.Uid = author
+		return h.svc.repo.CreatePullEvent(ctx, evt)
+	}
+	maxId := int64(math.MaxInt64)
+	for {
+		rs, err := h.svc.followRepo.FindFollowers(ctx, author, maxId, h.svc.cfg.BatchSize)
+		if err != nil {
+			return err
+		}
+		evts := make([]domain.FeedEvent, 0, len(rs))
+		for _, r := range rs {
+			e := evt
+			e.Uid = r.Follower
+			evts = append(evts, e)
+		}
+		err = h.svc.repo.CreatePushEvents(ctx, evts)
+		if err != nil {
+			return err
+		}
+		if len(rs) < h.svc.cfg.BatchSize {
+			return nil
+		}
+		maxId = rs[len(rs)-1].Id
+	}
+}
+
+// pushFeedHandler 只推给一个人的动态，比如点赞推给作者，关注推给被关注的人。
+// receiver 是 Ext 里面哪个字段是收件人
+type pushFeedHandler struct {
+	repo     *repository.FeedRepository
+	receiver string
+}
+
+func (h *pushFeedHandler) CreateFeedEvent(ctx context.Context, evt domain.FeedEvent) error {
+	uid, err := extInt64(evt.Ext, h.receiver)
+	if err != nil {
+		return err
+	}
+	evt.Uid = uid
+	return h.repo.CreatePushEvents(ctx, []domain.FeedEvent{evt})
+}
+
+func extInt64(ext map[string]string, key string) (int64, error) {
+	val, ok := ext[key]
+	if !ok {
+		return 0, fmt.Errorf("动态缺少字段 %s", key)
+	}
+	return strconv.ParseInt(val, 10, 64)
+}
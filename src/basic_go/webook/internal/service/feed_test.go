@@ -0,0 +1,137 @@
+package service
+
+import (
+	"basic_go/webook/internal/domain"
+	"basic_go/webook/internal/repository"
+	"basic_go/webook/internal/repository/cache"
+	"basic_go/webook/internal/repository/dao"
+	"basic_go/webook/internal/repository/dao/daotest"
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// noFollowCache 不缓存，每次都查数据库
+type noFollowCache struct{}
+
+func (noFollowCache) GetStats(ctx context.Context, uid int64) (cache.FollowStats, error) {
+	return cache.FollowStats{}, cache.ErrKeyNotExist
+}
+
+func (noFollowCache) SetStats(ctx context.Context, uid int64, stats cache.FollowStats) error {
+	return nil
+}
+
+func (noFollowCache) Follow(ctx context.Context, follower, followee int64) error {
+	return nil
+}
+
+func (noFollowCache) Unfollow(ctx context.Context, follower, followee int64) error {
+	return nil
+}
+
+func TestFeedService_PushPull(t *testing.T) {
+	db := daotest.OpenDB(t, &dao.FollowRelation{}, &dao.FeedPushEvent{}, &dao.FeedPullEvent{})
+
+	ctx := context.Background()
+	followDAO := dao.NewFollowDAO(db)
+	fr := repository.NewFollowRepository(followDAO, noFollowCache{})
+	feedDAO := dao.NewFeedDAO(db)
+	svc := NewFeedService(repository.NewFeedRepository(feedDAO), fr, FeedConfig{
+		FanoutThreshold: 1,
+		BatchSize:       1,
+	})
+
+	// 作者 10 只有读者 1 一个粉丝，推；作者 20 有两个粉丝，拉
+	for _, r := range [][2]int64{{1, 10}, {1, 20}, {2, 20}} {
+		_, err := fr.Follow(ctx, r[0], r[1])
+		require.NoError(t, err)
+	}
+	now := time.Now()
+	publish := func(author int64, aid int64, ctime time.Time) {
+		err := svc.CreateFeedEvent(ctx, domain.FeedEvent{
+			Type:  domain.FeedTypeArticle,
+			Biz:   "article",
+			BizId: aid,
+			Ext: map[string]string{
+				"uid": strconv.FormatInt(author, 10),
+				"aid": strconv.FormatInt(aid, 10),
+			},
+			Ctime: ctime,
+		})
+		require.NoError(t, err)
+	}
+	publish(10, 1, now.Add(-time.Minute*3))
+	publish(20, 2, now.Add(-time.Minute*2))
+	publish(10, 3, now.Add(-time.Minute))
+	// 消费者重试，收件箱和发件箱都不会重复
+	publish(10, 3, now.Add(-time.Minute))
+	publish(20, 2, now.Add(-time.Minute*2))
+
+	var cnt int64
+	require.NoError(t, db.Model(&dao.FeedPushEvent{}).Count(&cnt).Error)
+	assert.Equal(t, int64(2), cnt)
+	require.NoError(t, db.Model(&dao.FeedPullEvent{}).Count(&cnt).Error)
+	assert.Equal(t, int64(1), cnt)
+
+	aids := func(evts []domain.FeedEvent) []string {
+		res := make([]string, 0, len(evts))
+		for _, evt := range evts {
+			res = append(res, evt.Ext["aid"])
+		}
+		return res
+	}
+	// all 一页 limit 条翻到底
+	all := func(uid int64, limit int) []string {
+		var res []string
+		var cursor domain.FeedCursor
+		for {
+			evts, err := svc.GetFeed(ctx, uid, cursor, limit)
+			require.NoError(t, err)
+			if len(evts) == 0 {
+				return res
+			}
+			res = append(res, aids(evts)...)
+			last := evts[len(evts)-1]
+			cursor = domain.FeedCursor{Time: last.Ctime, Pulled: last.Pulled, Id: last.Id}
+		}
+	}
+	evts, err := svc.GetFeed(ctx, 1, domain.FeedCursor{}, 2)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"3", "2"}, aids(evts))
+	// 下一页
+	last := evts[len(evts)-1]
+	evts, err = svc.GetFeed(ctx, 1, domain.FeedCursor{Time: last.Ctime, Pulled: last.Pulled, Id: last.Id}, 2)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1"}, aids(evts))
+
+	evts, err = svc.GetFeed(ctx, 2, domain.FeedCursor{}, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"2"}, aids(evts))
+
+	// 同一毫秒的动态翻页的时候不会漏掉
+	same := now.Add(-time.Second * 2).Truncate(time.Millisecond)
+	publish(10, 4, same)
+	publish(10, 5, same)
+	publish(10, 6, same)
+	assert.Equal(t, []string{"6", "5", "4", "3", "2", "1"}, all(1, 2))
+
+	// 同一毫秒收件箱和发件箱都有，两边的 ID 是各自分配的，也不会漏掉或者重复
+	same = now.Add(-time.Second).Truncate(time.Millisecond)
+	require.NoError(t, db.Create(&dao.FeedPushEvent{Id: 100, Uid: 1, Type: domain.FeedTypeArticle,
+		Biz: "article", BizId: 8, Content: `{"aid":"8"}`, Ctime: same.UnixMilli()}).Error)
+	require.NoError(t, db.Create([]dao.FeedPullEvent{
+		{Id: 100, Uid: 20, Type: domain.FeedTypeArticle, Biz: "article", BizId: 9,
+			Content: `{"aid":"9"}`, Ctime: same.UnixMilli()},
+		{Id: 99, Uid: 20, Type: domain.FeedTypeArticle, Biz: "article", BizId: 7,
+			Content: `{"aid":"7"}`, Ctime: same.UnixMilli()},
+	}).Error)
+	want := []string{"8", "9", "7", "6", "5", "4", "3", "2", "1"}
+	for _, limit := range []int{1, 2, 3} {
+		assert.Equal(t, want, all(1, limit))
+	}
+}
@@ -0,0 +1,82 @@
+package service
+
+import (
+	"basic_go/webook/internal/domain"
+	events "basic_go/webook/internal/events/follow"
+	"basic_go/webook/internal/repository"
+	"context"
+	"errors"
+	"log"
+	"math"
+)
+
+var ErrFollowSelf = errors.New("不能关注自己")
+
+type FollowService struct {
+	repo     *repository.FollowRepository
+	userRepo *repository.UserRepository
+	producer *events.Producer
+}
+
+func NewFollowService(repo *repository.FollowRepository, userRepo *repository.UserRepository,
+	producer *events.Producer) *FollowService {
+	return &FollowService{
+		repo:     repo,
+		userRepo: userRepo,
+		producer: producer,
+	}
+}
+
+// Follow 重复关注不会报错
+func (svc *FollowService) Follow(ctx context.Context, follower, followee int64) error {
+	if follower == followee {
+		return ErrFollowSelf
+	}
+	_, err := svc.userRepo.FindById(ctx, followee)
+	if err == repository.ErrUserNotFound {
+		return ErrUserNotFound
+	}
+	if err != nil {
+		return err
+	}
+	changed, err := svc.repo.Follow(ctx, follower, followee)
+	if err != nil || !changed {
+		return err
+	}
+	err = svc.producer.ProduceFollowEvent(ctx, events.FollowEvent{Follower: follower, Followee: followee})
+	if err != nil {
+		// 关注已经成功了，事件发不出去只是少一条动态和通知
+		log.Println("发送关注事件失败", err)
+	}
+	return nil
+}
+
+// Unfollow 没有关注过也不会报错
+func (svc *FollowService) Unfollow(ctx context.Context, follower, followee int64) error {
+	_, err := svc.repo.Unfollow(ctx, follower, followee)
+	return err
+}
+
+func (svc *FollowService) IsFollowing(ctx context.Context, follower, followee int64) (bool, error) {
+	return svc.repo.IsFollowing(ctx, follower, followee)
+}
+
+// Followers uid 的粉丝，maxId 是上一页最后一条关系的 ID，第一页传 0
+func (svc *FollowService) Followers(ctx context.Context, uid int64, maxId int64, limit int) ([]domain.FollowRelation, error) {
+	if maxId <= 0 {
+		maxId = math.MaxInt64
+	}
+	return svc.repo.FindFollowers(ctx, uid, maxId, limit)
+}
+
+// Followees uid 关注的人，maxId 是上一页最后一条关系的 ID，第一页传 0
+func (svc *FollowService) Followees(ctx context.Context, uid int64, maxId int64, limit int) ([]domain.FollowRelation, error) {
+	if maxId <= 0 {
+		maxId = math.MaxInt64
+	}
+	return svc.repo.FindFollowees(ctx, uid, maxId, limit)
+}
+
+func (svc *FollowService) Stats(ctx context.Context, uid int64) (domain.FollowStats, error) {
+	return svc.repo.GetStats(ctx, uid)
+}
@@ -0,0 +1,53 @@
+package service
+
+import (
+	"basic_go/webook/internal/domain"
+	events "basic_go/webook/internal/events/interactive"
+	"basic_go/webook/internal/repository"
+	"context"
+	"log"
+)
+
+type InteractiveService struct {
+	repo     *repository.InteractiveRepository
+	producer *events.Producer
+}
+
+func NewInteractiveService(repo *repository.InteractiveRepository, producer *events.Producer) *InteractiveService {
+	return &InteractiveService{
+		repo:     repo,
+		producer: producer,
+	}
+}
+
+func (svc *InteractiveService) Get(ctx context.Context, biz string, bizId int64) (domain.Interactive, error) {
+	return svc.repo.Get(ctx, biz, bizId)
+}
+
+// Like 点赞，重复点赞不会报错。owner 是资源的作者，放到事件里面
+func (svc *InteractiveService) Like(ctx context.Context, biz string, bizId int64, uid int64, owner int64) error {
+	changed, err := svc.repo.IncrLike(ctx, biz, bizId, uid)
+	if err != nil || !changed {
+		return err
+	}
+	err = svc.producer.ProduceLikeEvent(ctx, events.LikeEvent{
+		Uid:   uid,
+		Biz:   biz,
+		BizId: bizId,
+		Owner: owner,
+	})
+	if err != nil {
+		log.Println("发送点赞事件失败", err)
+	}
+	return nil
+}
+
+// CancelLike 取消点赞，没有点过赞也不会报错
+func (svc *InteractiveService) CancelLike(ctx context.Context, biz string, bizId int64, uid int64) error {
+	_, err := svc.repo.DecrLike(ctx, biz, bizId, uid)
+	return err
+}
+
+func (svc *InteractiveService) Liked(ctx context.Context, biz string, bizId int64, uid int64) (bool, error) {
+	return svc.repo.Liked(ctx, biz, bizId, uid)
+}
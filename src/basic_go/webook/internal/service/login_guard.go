@@ -0,0 +1,156 @@
+package service
+
+import (
+	"basic_go/webook/internal/repository"
+	"context"
+	"errors"
+	"strings"
+	"time"
+)
+
+var (
+	ErrLoginLocked      = errors.New("登录失败次数过多，已被临时锁定")
+	ErrLoginTooFrequent = errors.New("登录失败次数过多，请稍后再试")
+)
+
+// LoginLimit 某一个维度（账号或者 IP）的限制
+type LoginLimit struct {
+	// 失败多少次之后开始要求等待，0 代表不等待
+	DelayAfter int64
+	// 第一次等待的时间，之后每多失败一次就翻倍
+	BaseDelay time.Duration
+	// 等待时间的上限
+	MaxDelay time.Duration
+	// 失败多少次之后锁定，0 代表不锁定
+	LockAfter    int64
+	LockDuration time.Duration
+}
+
+// blockFor 失败了 cnt 次之后要禁止登录多久
+func (l LoginLimit) blockFor(cnt int64) time.Duration {
+	if l.LockAfter > 0 && cnt >= l.LockAfter {
+		return l.LockDuration
+	}
+	if l.DelayAfter <= 0 || cnt < l.DelayAfter {
+		return 0
+	}
+	delay := l.BaseDelay
+	for i := l.DelayAfter; i < cnt; i++ {
+		delay = delay * 2
+		if delay >= l.MaxDelay {
+			return l.MaxDelay
+		}
+	}
+	return delay
+}
+
+type LoginGuardConfig struct {
+	// 失败次数的计数窗口，从第一次失败开始算
+	Window  time.Duration
+	Account LoginLimit
+	// IP 后面可能是一整个公司，所以阈值要比账号宽松很多
+	IP LoginLimit
+}
+
+func DefaultLoginGuardConfig() LoginGuardConfig {
+	return LoginGuardConfig{
+		Window: time.Hour,
+		Account: LoginLimit{
+			DelayAfter:   3,
+			BaseDelay:    time.Second,
+			MaxDelay:     time.Minute * 5,
+			LockAfter:    10,
+			LockDuration: time.Minute * 30,
+		},
+		IP: LoginLimit{
+			DelayAfter:   20,
+			BaseDelay:    time.Second,
+			MaxDelay:     time.Minute,
+			LockAfter:    100,
+			LockDuration: time.Minute * 30,
+		},
+	}
+}
+
+// LoginGuard 记录登录失败的次数，按照账号和 IP 两个维度做退避和锁定
+type LoginGuard struct {
+	repo *repository.LoginAttemptRepository
+	cfg  LoginGuardConfig
+	now  func() time.Time
+}
+
+func NewLoginGuard(repo *repository.LoginAttemptRepository, cfg LoginGuardConfig) *LoginGuard {
+	return &LoginGuard{
+		repo: repo,
+		cfg:  cfg,
+		now:  time.Now,
+	}
+}
+
+// Check 检查现在能不能尝试登录
+func (g *LoginGuard) Check(ctx context.Context, email string, ip string) error {
+	now := g.now()
+	for _, d := range g.dimensions(email, ip) {
+		a, err := g.repo.Find(ctx, d.key)
+		if err != nil {
+			return err
+		}
+		if !a.Blocked(now) {
+			continue
+		}
+		if d.limit.LockAfter > 0 && a.Failures >= d.limit.LockAfter {
+			return ErrLoginLocked
+		}
+		return ErrLoginTooFrequent
+	}
+	return nil
+}
+
+// Fail 记录一次失败，必要的时候禁止登录一段时间
+func (g *LoginGuard) Fail(ctx context.Context, email string, ip string) error {
+	for _, d := range g.dimensions(email, ip) {
+		cnt, err := g.repo.IncrFailure(ctx, d.key, g.cfg.Window)
+		if err != nil {
+			return err
+		}
+		dur := d.limit.blockFor(cnt)
+		if dur <= 0 {
+			continue
+		}
+		err = g.repo.Block(ctx, d.key, g.now().Add(dur))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Succeed 登录成功之后清空账号的失败记录。
+// IP 的不清空，不然攻击者拿自己的账号登录一下就能重置计数
+func (g *LoginGuard) Succeed(ctx context.Context, email string) error {
+	return g.repo.Delete(ctx, accountKey(email))
+}
+
+// Unlock 管理员解锁账号
+func (g *LoginGuard) Unlock(ctx context.Context, email string) error {
+	return g.repo.Delete(ctx, accountKey(email))
+}
+
+type loginDimension struct {
+	key   string
+	limit LoginLimit
+}
+
+func (g *LoginGuard) dimensions(email string, ip string) []loginDimension {
+	res := []loginDimension{{key: accountKey(email), limit: g.cfg.Account}}
+	if ip != "" {
+		res = append(res, loginDimension{key: "ip:" + ip, limit: g.cfg.IP})
+	}
+	return res
+}
+
+// accountKey MySQL 比较邮箱不区分大小写，前后的空格也会被忽略，
+// 不统一一下的话换个写法就是一个新的计数
+func accountKey(email string) string {
+	return "account:" + strings.ToLower(strings.TrimSpace(email))
+}
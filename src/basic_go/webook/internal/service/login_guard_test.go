@@ -0,0 +1,102 @@
+package service
+
+import (
+	"basic_go/webook/internal/repository"
+	"basic_go/webook/internal/repository/cache"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoginLimit_blockFor(t *testing.T) {
+	l := LoginLimit{
+		DelayAfter:   3,
+		BaseDelay:    time.Second,
+		MaxDelay:     time.Second * 5,
+		LockAfter:    10,
+		LockDuration: time.Minute,
+	}
+	testCases := []struct {
+		cnt  int64
+		want time.Duration
+	}{
+		{cnt: 1, want: 0},
+		{cnt: 2, want: 0},
+		{cnt: 3, want: time.Second},
+		{cnt: 4, want: time.Second * 2},
+		{cnt: 5, want: time.Second * 4},
+		// 到上限了
+		{cnt: 6, want: time.Second * 5},
+		{cnt: 9, want: time.Second * 5},
+		{cnt: 10, want: time.Minute},
+		{cnt: 100, want: time.Minute},
+	}
+	for _, tc := range testCases {
+		assert.Equal(t, tc.want, l.blockFor(tc.cnt), "cnt=%d", tc.cnt)
+	}
+}
+
+func TestLoginGuard(t *testing.T) {
+	now := time.UnixMilli(1700000000000)
+	clock := func() time.Time { return now }
+	cfg := LoginGuardConfig{
+		Window: time.Hour,
+		Account: LoginLimit{
+			DelayAfter:   2,
+			BaseDelay:    time.Second,
+			MaxDelay:     time.Minute,
+			LockAfter:    4,
+			LockDuration: time.Minute * 30,
+		},
+		IP: LoginLimit{
+			LockAfter:    5,
+			LockDuration: time.Minute * 10,
+		},
+	}
+	c := cache.NewMemoryLoginAttemptCache(clock)
+	g := NewLoginGuard(repository.NewLoginAttemptRepository(c), cfg)
+	g.now = clock
+	ctx := context.Background()
+	const email = "123@qq.com"
+	const ip = "127.0.0.1"
+
+	// 第一次失败，不影响
+	require.NoError(t, g.Fail(ctx, email, ip))
+	assert.NoError(t, g.Check(ctx, email, ip))
+
+	// 第二次失败，要等 1 秒
+	require.NoError(t, g.Fail(ctx, email, ip))
+	assert.Equal(t, ErrLoginTooFrequent, g.Check(ctx, email, ip))
+	now = now.Add(time.Second)
+	assert.NoError(t, g.Check(ctx, email, ip))
+
+	// 第三次失败，要等 2 秒
+	require.NoError(t, g.Fail(ctx, email, ip))
+	now = now.Add(time.Second)
+	assert.Equal(t, ErrLoginTooFrequent, g.Check(ctx, email, ip))
+	now = now.Add(time.Second)
+	assert.NoError(t, g.Check(ctx, email, ip))
+
+	// 第四次失败，锁定。换大小写、加空格也是同一个账号
+	require.NoError(t, g.Fail(ctx, " 123@QQ.com", ip))
+	now = now.Add(time.Minute * 10)
+	assert.Equal(t, ErrLoginLocked, g.Check(ctx, email, ip))
+	assert.Equal(t, ErrLoginLocked, g.Check(ctx, "123@Qq.Com ", "127.0.0.2"))
+
+	// 管理员解锁
+	require.NoError(t, g.Unlock(ctx, "123@QQ.COM"))
+	assert.NoError(t, g.Check(ctx, email, ip))
+
+	// 换个账号继续试，IP 维度被锁
+	require.NoError(t, g.Fail(ctx, "456@qq.com", ip))
+	assert.Equal(t, ErrLoginLocked, g.Check(ctx, "789@qq.com", ip))
+	// 其它 IP 不受影响
+	assert.NoError(t, g.Check(ctx, "789@qq.com", "127.0.0.2"))
+
+	// 计数窗口过了，失败记录都没了
+	now = now.Add(time.Hour)
+	assert.NoError(t, g.Check(ctx, "789@qq.com", ip))
+}
@@ -0,0 +1,52 @@
+package service
+
+import (
+	"basic_go/webook/internal/domain"
+	"basic_go/webook/internal/repository"
+	"context"
+	"log"
+)
+
+type NotificationService struct {
+	repo    *repository.NotificationRepository
+	artRepo *repository.ArticleRepository
+}
+
+func NewNotificationService(repo *repository.NotificationRepository, artRepo *repository.ArticleRepository) *NotificationService {
+	return &NotificationService{
+		repo:    repo,
+		artRepo: artRepo,
+	}
+}
+
+// Notify 自己给自己点赞、评论之类的不通知。文章的标题没有传的话会去查
+func (svc *NotificationService) Notify(ctx context.Context, n domain.Notification) error {
+	if n.Uid <= 0 || (len(n.Actors) > 0 && n.Actors[0] == n.Uid) {
+		return nil
+	}
+	if n.Title == "" && n.Biz == "article" {
+		art, err := svc.artRepo.GetPubById(ctx, n.BizId)
+		if err != nil {
+			// 标题没有也可以发
+			log.Println("查找通知的文章失败", n.BizId, err)
+		}
+		n.Title = art.Title
+	}
+	return svc.repo.Save(ctx, n)
+}
+
+func (svc *NotificationService) List(ctx context.Context, uid int64, offset int, limit int) ([]domain.Notification, error) {
+	return svc.repo.FindByUid(ctx, uid, offset, limit)
+}
+
+func (svc *NotificationService) UnreadCount(ctx context.Context, uid int64) (int64, error) {
+	return svc.repo.CountUnread(ctx, uid)
+}
+
+func (svc *NotificationService) MarkRead(ctx context.Context, uid int64, id int64) error {
+	return svc.repo.MarkRead(ctx, uid, id)
+}
+
+func (svc *NotificationService) MarkAllRead(ctx context.Context, uid int64) error {
+	return svc.repo.MarkAllRead(ctx, uid)
+}
@@ -0,0 +1,155 @@
+package service
+
+import (
+	"basic_go/webook/internal/domain"
+	"basic_go/webook/internal/repository"
+	"container/heap"
+	"context"
+	"math"
+	"sort"
+	"time"
+)
+
+type RankingConfig struct {
+	// TopN 热榜上放多少篇
+	TopN int
+	// BatchSize 每批从数据库里面查多少篇
+	BatchSize int
+	// Window 只看这段时间里面发表的文章
+	Window time.Duration
+	// 点赞比阅读值钱
+	LikeWeight float64
+	ReadWeight float64
+	// Gravity 越大，分数随着时间掉得越快
+	Gravity float64
+}
+
+func DefaultRankingConfig() RankingConfig {
+	return RankingConfig{
+		TopN:       100,
+		BatchSize:  100,
+		Window:     time.Hour * 24 * 7,
+		LikeWeight: 1,
+		ReadWeight: 0.1,
+		Gravity:    1.5,
+	}
+}
+
+type RankingService struct {
+	artRepo  *repository.ArticleRepository
+	intrRepo *repository.InteractiveRepository
+	repo     *repository.RankingRepository
+	cfg      RankingConfig
+	biz      string
+}
+
+func NewRankingService(artRepo *repository.ArticleRepository, intrRepo *repository.InteractiveRepository,
+	repo *repository.RankingRepository, cfg RankingConfig) *RankingService {
+	return &RankingService{
+		artRepo:  artRepo,
+		intrRepo: intrRepo,
+		repo:     repo,
+		cfg:      cfg,
+		biz:      "article",
+	}
+}
+
+// TopN 拿算好的热榜，按分数从高到低
+func (svc *RankingService) TopN(ctx context.Context) ([]domain.HotArticle, error) {
+	return svc.repo.GetTopN(ctx)
+}
+
+// RankTopN 重新算热榜，由定时任务调用
+func (svc *RankingService) RankTopN(ctx context.Context) error {
+	arts, err := svc.rankTopN(ctx, time.Now())
+	if err != nil {
+		return err
+	}
+	return svc.repo.ReplaceTopN(ctx, arts)
+}
+
+// rankTopN 分批遍历最近发表的文章，用一个小顶堆留下分数最高的 N 篇
+func (svc *RankingService) rankTopN(ctx context.Context, now time.Time) ([]domain.HotArticle, error) {
+	since := now.Add(-svc.cfg.Window)
+	h := &hotArticleHeap{}
+	startId := int64(0)
+	for {
+		arts, err := svc.artRepo.ListPubSince(ctx, since, startId, svc.cfg.BatchSize)
+		if err != nil {
+			return nil, err
+		}
+		if len(arts) == 0 {
+			break
+		}
+		ids := make([]int64, 0, len(arts))
+		for _, art := range arts {
+			ids = append(ids, art.Id)
+		}
+		intrs, err := svc.intrRepo.GetByIds(ctx, svc.biz, ids)
+		if err != nil {
+			return nil, err
+		}
+		for _, art := range arts {
+			// 最近改过，但是很早以前发表的，不算
+			if art.Ctime.Before(since) {
+				continue
+			}
+			intr := intrs[art.Id]
+			ha := domain.HotArticle{
+				ReadCnt: intr.ReadCnt,
+				LikeCnt: intr.LikeCnt,
+				Score:   svc.score(intr.LikeCnt, intr.ReadCnt, now.Sub(art.Ctime)),
+			}
+			if h.Len() < svc.cfg.TopN {
+				ha.Article = svc.abstract(art)
+				heap.Push(h, ha)
+				continue
+			}
+			if ha.Score > (*h)[0].Score {
+				ha.Article = svc.abstract(art)
+				(*h)[0] = ha
+				heap.Fix(h, 0)
+			}
+		}
+		if len(arts) < svc.cfg.BatchSize {
+			break
+		}
+		startId = arts[len(arts)-1].Id
+	}
+	res := []domain.HotArticle(*h)
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Score > res[j].Score
+	})
+	return res, nil
+}
+
+// score 类似 Hacker News 的算法，互动越多分数越高，发表越久分数越低
+func (svc *RankingService) score(likeCnt, readCnt int64, age time.Duration) float64 {
+	hours := math.Max(age.Hours(), 0)
+	weight := float64(likeCnt)*svc.cfg.LikeWeight + float64(readCnt)*svc.cfg.ReadWeight + 1
+	return weight / math.Pow(hours+2, svc.cfg.Gravity)
+}
+
+// abstract 热榜只展示摘要，不用存全文
+func (svc *RankingService) abstract(art domain.Article) domain.Article {
+	art.Content = art.Abstract()
+	return art
+}
+
+// hotArticleHeap 按分数的小顶堆，堆顶是留下来的文章里面分数最低的
+type hotArticleHeap []domain.HotArticle
+
+func (h hotArticleHeap) Len() int           { return len(h) }
+func (h hotArticleHeap) Less(i, j int) bool { return h[i].Score < h[j].Score }
+func (h hotArticleHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+
+func (h *hotArticleHeap) Push(x any) {
+	*h = append(*h, x.(domain.HotArticle))
+}
+
+func (h *hotArticleHeap) Pop() any {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
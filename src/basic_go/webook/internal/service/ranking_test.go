@@ -0,0 +1,125 @@
+package service
+
+import (
+	"basic_go/webook/internal/domain"
+	"basic_go/webook/internal/repository"
+	"basic_go/webook/internal/repository/cache"
+	"basic_go/webook/internal/repository/dao"
+	"basic_go/webook/internal/repository/dao/daotest"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRankingCache 可以模拟 Redis 挂了
+type fakeRankingCache struct {
+	arts []domain.HotArticle
+	err  error
+}
+
+func (c *fakeRankingCache) Set(ctx context.Context, arts []domain.HotArticle) error {
+	if c.err != nil {
+		return c.err
+	}
+	c.arts = arts
+	return nil
+}
+
+func (c *fakeRankingCache) Get(ctx context.Context) ([]domain.HotArticle, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+	if c.arts == nil {
+		return nil, cache.ErrKeyNotExist
+	}
+	return c.arts, nil
+}
+
+func TestRankingService_RankTopN(t *testing.T) {
+	db := daotest.OpenDB(t, &dao.PublishedArticle{}, &dao.Interactive{})
+	now := time.Now()
+	hour := time.Hour.Milliseconds()
+	pub := func(id int64, hoursAgo int64, utimeHoursAgo int64) {
+		require.NoError(t, db.Create(&dao.PublishedArticle{Id: id, Title: "t", AuthorId: 1,
+			Status: domain.ArticleStatusPublished.ToUint8(),
+			Ctime:  now.UnixMilli() - hoursAgo*hour, Utime: now.UnixMilli() - utimeHoursAgo*hour}).Error)
+	}
+	intr := func(id int64, likes, reads int64) {
+		require.NoError(t, db.Create(&dao.Interactive{Biz: "article", BizId: id,
+			LikeCnt: likes, ReadCnt: reads}).Error)
+	}
+	pub(1, 1, 1)
+	intr(1, 10, 100)
+	// 互动一样，发表得越早分数越低
+	pub(2, 24, 24)
+	intr(2, 10, 100)
+	// 没有人互动过
+	pub(3, 1, 1)
+	// 互动很多，但是发表太久了
+	pub(4, 24*30, 1)
+	intr(4, 10000, 10000)
+	pub(5, 2, 2)
+	intr(5, 50, 0)
+	// 撤回了的不算
+	require.NoError(t, db.Create(&dao.PublishedArticle{Id: 6, Status: domain.ArticleStatusPrivate.ToUint8(),
+		Ctime: now.UnixMilli(), Utime: now.UnixMilli()}).Error)
+	intr(6, 10000, 10000)
+
+	redis := &fakeRankingCache{}
+	local := cache.NewLocalRankingCache(time.Minute)
+	cfg := DefaultRankingConfig()
+	cfg.TopN = 3
+	// 一批两篇，要查好几批
+	cfg.BatchSize = 2
+	svc := NewRankingService(repository.NewArticleRepository(dao.NewArticleDAO(db)),
+		repository.NewInteractiveRepository(dao.NewInteractiveDAO(db)),
+		repository.NewRankingRepository(redis, local), cfg)
+	ctx := context.Background()
+
+	arts, err := svc.TopN(ctx)
+	require.NoError(t, err)
+	assert.Len(t, arts, 0)
+
+	require.NoError(t, svc.RankTopN(ctx))
+	arts, err = svc.TopN(ctx)
+	require.NoError(t, err)
+	ids := make([]int64, 0, len(arts))
+	for _, a := range arts {
+		ids = append(ids, a.Article.Id)
+	}
+	assert.Equal(t, []int64{5, 1, 3}, ids)
+	assert.Equal(t, int64(10), arts[1].LikeCnt)
+	assert.Equal(t, int64(100), arts[1].ReadCnt)
+	assert.Equal(t, redis.arts, arts)
+}
+
+func TestRankingRepository_Fallback(t *testing.T) {
+	redis := &fakeRankingCache{}
+	// 本地缓存马上过期
+	local := cache.NewLocalRankingCache(0)
+	repo := repository.NewRankingRepository(redis, local)
+	ctx := context.Background()
+	want := []domain.HotArticle{{Article: domain.Article{Id: 1}, Score: 1}}
+	require.NoError(t, repo.ReplaceTopN(ctx, want))
+
+	// 本地过期了，从 Redis 拿
+	redis.arts = []domain.HotArticle{{Article: domain.Article{Id: 2}, Score: 2}}
+	arts, err := repo.GetTopN(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), arts[0].Article.Id)
+
+	// Redis 挂了，用本地过期了的数据
+	redis.err = errors.New("redis 挂了")
+	arts, err = repo.GetTopN(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), arts[0].Article.Id)
+
+	// 本地也没有的话只能报错
+	repo = repository.NewRankingRepository(redis, cache.NewLocalRankingCache(0))
+	_, err = repo.GetTopN(ctx)
+	assert.Equal(t, redis.err, err)
+}
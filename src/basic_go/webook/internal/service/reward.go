@@ -0,0 +1,190 @@
+package service
+
+import (
+	"basic_go/webook/internal/domain"
+	"basic_go/webook/internal/repository"
+	"basic_go/webook/pkg/payment"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrRewardNotFound      = errors.New("打赏订单不存在")
+	ErrInvalidRewardAmount = errors.New("打赏金额不对")
+)
+
+const (
+	rewardBiz            = "reward"
+	rewardOutTradePrefix = "reward_"
+)
+
+type RewardConfig struct {
+	// 下单之后多久还没收到回调，就主动去查一下
+	QueryAfter time.Duration
+	// 下单之后多久还没付钱，就关闭订单
+	CloseAfter time.Duration
+	// 单笔最多打赏多少，单位是分
+	MaxAmount int64
+}
+
+func DefaultRewardConfig() RewardConfig {
+	return RewardConfig{
+		QueryAfter: time.Minute * 5,
+		CloseAfter: time.Minute * 30,
+		MaxAmount:  100000,
+	}
+}
+
+type RewardService struct {
+	repo    *repository.RewardRepository
+	gateway payment.Gateway
+	accSvc  *AccountService
+	cfg     RewardConfig
+}
+
+func NewRewardService(repo *repository.RewardRepository, gateway payment.Gateway,
+	accSvc *AccountService, cfg RewardConfig) *RewardService {
+	return &RewardService{
+		repo:    repo,
+		gateway: gateway,
+		accSvc:  accSvc,
+		cfg:     cfg,
+	}
+}
+
+// PreReward 创建打赏订单，返回付钱用的二维码链接
+func (svc *RewardService) PreReward(ctx context.Context, r domain.Reward) (domain.RewardCodeURL, error) {
+	if r.Amount <= 0 || r.Amount > svc.cfg.MaxAmount {
+		return domain.RewardCodeURL{}, ErrInvalidRewardAmount
+	}
+	r.Status = domain.RewardStatusInit
+	rid, err := svc.repo.Create(ctx, r)
+	if err != nil {
+		return domain.RewardCodeURL{}, err
+	}
+	resp, err := svc.gateway.Prepay(ctx, payment.PrepayRequest{
+		OutTradeNo:  outTradeNo(rid),
+		Description: fmt.Sprintf("打赏-%s", r.Target.BizName),
+		Amount:      r.Amount,
+	})
+	if err != nil {
+		// 订单留在 Init 状态，对账的时候会关掉
+		return domain.RewardCodeURL{}, err
+	}
+	return domain.RewardCodeURL{Rid: rid, URL: resp.CodeURL}, nil
+}
+
+// GetReward 只有打赏的人自己能看
+func (svc *RewardService) GetReward(ctx context.Context, rid int64, uid int64) (domain.Reward, error) {
+	r, err := svc.repo.GetById(ctx, rid)
+	if err == repository.ErrRewardNotFound || (err == nil && r.Uid != uid) {
+		return domain.Reward{}, ErrRewardNotFound
+	}
+	return r, err
+}
+
+// HandleTransaction 处理第三方的回调或者查询结果。
+// 回调可能重复，也可能和对账同时发生，所以状态只会流转一次，入账也是幂等的
+func (svc *RewardService) HandleTransaction(ctx context.Context, txn payment.Transaction) error {
+	rid, err := parseOutTradeNo(txn.OutTradeNo)
+	if err != nil {
+		return err
+	}
+	r, err := svc.repo.GetById(ctx, rid)
+	if err == repository.ErrRewardNotFound {
+		return ErrRewardNotFound
+	}
+	if err != nil {
+		return err
+	}
+	switch txn.Status {
+	case payment.StatusSuccess:
+		if txn.Amount != r.Amount {
+			return fmt.Errorf("%w: 订单 %d 金额 %d，实付 %d", ErrInvalidRewardAmount, rid, r.Amount, txn.Amount)
+		}
+		_, err = svc.repo.UpdateStatus(ctx, rid, domain.RewardStatusInit, domain.RewardStatusPaid)
+		if err != nil {
+			return err
+		}
+		// 不管这次有没有改状态都要入账，上一次可能改了状态但是入账失败了
+		// 用户付的钱先进了平台的账户，再转给作者
+		return svc.accSvc.Post(ctx, domain.Posting{
+			Biz:    rewardBiz,
+			BizId:  rid,
+			Debit:  domain.PlatformAccount,
+			Credit: domain.UserAccount(r.Target.Uid),
+			Amount: r.Amount,
+			Memo:   fmt.Sprintf("打赏 %s %d", r.Target.Biz, r.Target.BizId),
+		})
+	case payment.StatusClosed:
+		_, err = svc.repo.UpdateStatus(ctx, rid, domain.RewardStatusInit, domain.RewardStatusFailed)
+		return err
+	default:
+		// 还没付钱，什么都不用做
+		return nil
+	}
+}
+
+// Reconcile 处理卡在 Init 状态的订单：先去第三方查一下，
+// 已经付了钱的当作收到回调处理，超时还没付钱的关闭
+func (svc *RewardService) Reconcile(ctx context.Context) error {
+	const batchSize = 100
+	now := time.Now()
+	startId := int64(0)
+	for {
+		rs, err := svc.repo.FindByStatus(ctx, domain.RewardStatusInit, now.Add(-svc.cfg.QueryAfter), startId, batchSize)
+		if err != nil {
+			return err
+		}
+		for _, r := range rs {
+			err = svc.reconcileOne(ctx, r, now)
+			if err != nil {
+				// 一个订单出问题不影响别的，下一轮还会再处理
+				log.Println("对账失败", r.Id, err)
+			}
+		}
+		if len(rs) < batchSize {
+			return nil
+		}
+		startId = rs[len(rs)-1].Id
+	}
+}
+
+func (svc *RewardService) reconcileOne(ctx context.Context, r domain.Reward, now time.Time) error {
+	txn, err := svc.gateway.Query(ctx, outTradeNo(r.Id))
+	switch {
+	case err == payment.ErrOrderNotFound:
+		// 预下单就失败了，第三方那边根本没有这个订单
+		txn = payment.Transaction{OutTradeNo: outTradeNo(r.Id), Status: payment.StatusClosed}
+	case err != nil:
+		return err
+	}
+	if txn.Status == payment.StatusNotPay {
+		if now.Sub(r.Ctime) < svc.cfg.CloseAfter {
+			return nil
+		}
+		// 先关第三方的订单，关掉之后用户就付不了钱了，再改我们自己的状态
+		err = svc.gateway.Close(ctx, txn.OutTradeNo)
+		if err != nil {
+			return err
+		}
+		txn.Status = payment.StatusClosed
+	}
+	return svc.HandleTransaction(ctx, txn)
+}
+
+func outTradeNo(rid int64) string {
+	return rewardOutTradePrefix + strconv.FormatInt(rid, 10)
+}
+
+func parseOutTradeNo(no string) (int64, error) {
+	if !strings.HasPrefix(no, rewardOutTradePrefix) {
+		return 0, fmt.Errorf("%w: 订单号 %s", ErrRewardNotFound, no)
+	}
+	return strconv.ParseInt(strings.TrimPrefix(no, rewardOutTradePrefix), 10, 64)
+}
@@ -0,0 +1,102 @@
+package service
+
+import (
+	"basic_go/webook/internal/domain"
+	"basic_go/webook/internal/repository"
+	"basic_go/webook/internal/repository/dao"
+	"basic_go/webook/internal/repository/dao/daotest"
+	"basic_go/webook/pkg/payment"
+	"basic_go/webook/pkg/payment/fake"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestRewardService(t *testing.T, cfg RewardConfig) (*RewardService, *AccountService, *fake.Gateway) {
+	db := daotest.OpenDB(t, &dao.Reward{}, &dao.Account{}, &dao.AccountTransaction{}, &dao.AccountEntry{})
+	gateway := fake.NewGateway([]byte("secret"), "http://localhost/reward/notify")
+	accSvc := NewAccountService(repository.NewAccountRepository(dao.NewAccountDAO(db)))
+	svc := NewRewardService(repository.NewRewardRepository(dao.NewRewardDAO(db)), gateway, accSvc, cfg)
+	return svc, accSvc, gateway
+}
+
+func TestRewardService_HandleTransaction(t *testing.T) {
+	svc, accSvc, gateway := newTestRewardService(t, DefaultRewardConfig())
+	ctx := context.Background()
+
+	codeURL, err := svc.PreReward(ctx, domain.Reward{
+		Uid:    1,
+		Target: domain.RewardTarget{Biz: "article", BizId: 10, BizName: "Go", Uid: 2},
+		Amount: 100,
+	})
+	require.NoError(t, err)
+	assert.NotEmpty(t, codeURL.URL)
+
+	// 回调重复了也只入账一次
+	for i := 0; i < 2; i++ {
+		req, err := gateway.NewNotification(ctx, outTradeNo(codeURL.Rid))
+		require.NoError(t, err)
+		txn, err := gateway.ParseNotification(req)
+		require.NoError(t, err)
+		require.NoError(t, svc.HandleTransaction(ctx, txn))
+	}
+	balance, err := accSvc.Balance(ctx, 2)
+	require.NoError(t, err)
+	assert.Equal(t, int64(100), balance)
+	r, err := svc.GetReward(ctx, codeURL.Rid, 1)
+	require.NoError(t, err)
+	assert.Equal(t, domain.RewardStatusPaid, r.Status)
+
+	// 别人看不到
+	_, err = svc.GetReward(ctx, codeURL.Rid, 2)
+	assert.Equal(t, ErrRewardNotFound, err)
+
+	// 金额对不上的不能入账
+	err = svc.HandleTransaction(ctx, payment.Transaction{
+		OutTradeNo: outTradeNo(codeURL.Rid),
+		Status:     payment.StatusSuccess,
+		Amount:     1,
+	})
+	assert.ErrorIs(t, err, ErrInvalidRewardAmount)
+}
+
+func TestRewardService_Reconcile(t *testing.T) {
+	// 下单之后马上就可以查询和关闭
+	svc, accSvc, gateway := newTestRewardService(t, RewardConfig{MaxAmount: 1000})
+	ctx := context.Background()
+	newReward := func() int64 {
+		codeURL, err := svc.PreReward(ctx, domain.Reward{
+			Uid:    1,
+			Target: domain.RewardTarget{Biz: "article", BizId: 10, Uid: 2},
+			Amount: 100,
+		})
+		require.NoError(t, err)
+		return codeURL.Rid
+	}
+	paid := newReward()
+	unpaid := newReward()
+	// 付了钱，但是回调丢了
+	_, err := gateway.NewNotification(ctx, outTradeNo(paid))
+	require.NoError(t, err)
+	// 只处理 ctime 早于现在的订单，毫秒精度，稍微等一下
+	time.Sleep(time.Millisecond * 2)
+
+	require.NoError(t, svc.Reconcile(ctx))
+
+	r, err := svc.GetReward(ctx, paid, 1)
+	require.NoError(t, err)
+	assert.Equal(t, domain.RewardStatusPaid, r.Status)
+	r, err = svc.GetReward(ctx, unpaid, 1)
+	require.NoError(t, err)
+	assert.Equal(t, domain.RewardStatusFailed, r.Status)
+	txn, err := gateway.Query(ctx, outTradeNo(unpaid))
+	require.NoError(t, err)
+	assert.Equal(t, payment.StatusClosed, txn.Status)
+
+	balance, err := accSvc.Balance(ctx, 2)
+	require.NoError(t, err)
+	assert.Equal(t, int64(100), balance)
+}
@@ -0,0 +1,111 @@
+package service
+
+import (
+	"basic_go/webook/internal/domain"
+	"basic_go/webook/internal/repository"
+	"context"
+	"log"
+	"math"
+	"sort"
+)
+
+const (
+	// 先按相关度取这么多候选，再结合互动数据重新排序。
+	// 再往后翻就没有意义了，所以最多只能翻到这里
+	searchMaxCandidates = 500
+	// 点赞比阅读更能说明文章好，权重高一些
+	searchLikeWeight = 0.3
+	searchReadWeight = 0.1
+)
+
+type ArticleSearchService struct {
+	repo     *repository.ArticleSearchRepository
+	artRepo  *repository.ArticleRepository
+	intrRepo *repository.InteractiveRepository
+}
+
+func NewArticleSearchService(repo *repository.ArticleSearchRepository,
+	artRepo *repository.ArticleRepository,
+	intrRepo *repository.InteractiveRepository) *ArticleSearchService {
+	return &ArticleSearchService{
+		repo:     repo,
+		artRepo:  artRepo,
+		intrRepo: intrRepo,
+	}
+}
+
+// Search 按照相关度和点赞数、阅读数综合排序
+func (svc *ArticleSearchService) Search(ctx context.Context, keyword string, offset int, limit int) (domain.ArticleSearchResult, error) {
+	res, err := svc.repo.Search(ctx, keyword, searchMaxCandidates)
+	if err != nil || len(res.Hits) == 0 {
+		return res, err
+	}
+	// 只有这些候选能翻到，总数也按这个算，不然前端会翻出空页
+	res.Total = min(res.Total, len(res.Hits))
+	ids := make([]int64, 0, len(res.Hits))
+	for _, h := range res.Hits {
+		ids = append(ids, h.Article.Id)
+	}
+	intrs, err := svc.intrRepo.GetByIds(ctx, "article", ids)
+	if err != nil {
+		// 拿不到互动数据就只按相关度排
+		log.Println("查找互动数据失败", err)
+	}
+	for i := range res.Hits {
+		intr := intrs[res.Hits[i].Article.Id]
+		res.Hits[i].Interactive = intr
+		res.Hits[i].Score *= 1 + searchLikeWeight*math.Log1p(float64(intr.LikeCnt)) +
+			searchReadWeight*math.Log1p(float64(intr.ReadCnt))
+	}
+	sort.SliceStable(res.Hits, func(i, j int) bool {
+		return res.Hits[i].Score > res.Hits[j].Score
+	})
+
+	if offset >= len(res.Hits) {
+		res.Hits = nil
+		return res, nil
+	}
+	hits := res.Hits[offset:min(offset+limit, len(res.Hits))]
+	pageIds := make([]int64, 0, len(hits))
+	for _, h := range hits {
+		pageIds = append(pageIds, h.Article.Id)
+	}
+	arts, err := svc.artRepo.GetPubByIds(ctx, pageIds)
+	if err != nil {
+		return domain.ArticleSearchResult{}, err
+	}
+	res.Hits = make([]domain.ArticleSearchHit, 0, len(hits))
+	for _, h := range hits {
+		art, ok := arts[h.Article.Id]
+		// 索引是异步更新的，可能刚撤回还没从索引里面删掉
+		if !ok || art.Status != domain.ArticleStatusPublished {
+			continue
+		}
+		h.Article = art
+		res.Hits = append(res.Hits, h)
+	}
+	return res, nil
+}
+
+// Rebuild 把线上库里面已发表的文章全部加到索引里面。
+// 进程内的索引重启之后就没了，启动的时候要调用一次
+func (svc *ArticleSearchService) Rebuild(ctx context.Context) error {
+	const batchSize = 100
+	startId := int64(0)
+	for {
+		arts, err := svc.artRepo.ListPub(ctx, startId, batchSize)
+		if err != nil {
+			return err
+		}
+		for _, art := range arts {
+			err = svc.repo.Upsert(ctx, art)
+			if err != nil {
+				return err
+			}
+		}
+		if len(arts) < batchSize {
+			return nil
+		}
+		startId = arts[len(arts)-1].Id
+	}
+}
@@ -0,0 +1,41 @@
+package service
+
+import (
+	"basic_go/webook/internal/domain"
+	"basic_go/webook/internal/repository"
+	"basic_go/webook/internal/repository/dao"
+	"basic_go/webook/internal/repository/dao/daotest"
+	"basic_go/webook/pkg/search"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestArticleSearchService_TotalCapped(t *testing.T) {
+	db := daotest.OpenDB(t, &dao.PublishedArticle{}, &dao.Interactive{})
+	repo := repository.NewArticleSearchRepository(search.NewMemoryIndex(repository.ArticleSearchFields()))
+	ctx := context.Background()
+	// 命中的比候选多
+	cnt := searchMaxCandidates + 20
+	for i := 1; i <= cnt; i++ {
+		art := domain.Article{Id: int64(i), Title: "Go 语言入门", Content: "内容"}
+		require.NoError(t, db.Create(&dao.PublishedArticle{Id: art.Id, Title: art.Title, AuthorId: 1,
+			Status: domain.ArticleStatusPublished.ToUint8()}).Error)
+		require.NoError(t, repo.Upsert(ctx, art))
+	}
+	svc := NewArticleSearchService(repo, repository.NewArticleRepository(dao.NewArticleDAO(db)),
+		repository.NewInteractiveRepository(dao.NewInteractiveDAO(db)))
+
+	res, err := svc.Search(ctx, "go", 0, 10)
+	require.NoError(t, err)
+	assert.Equal(t, searchMaxCandidates, res.Total)
+	assert.Len(t, res.Hits, 10)
+
+	// 按 Total 算出来的最后一页是有数据的
+	res, err = svc.Search(ctx, "go", searchMaxCandidates-10, 10)
+	require.NoError(t, err)
+	assert.Equal(t, searchMaxCandidates, res.Total)
+	assert.Len(t, res.Hits, 10)
+}
@@ -0,0 +1,33 @@
+package service
+
+import (
+	"basic_go/webook/internal/repository"
+	"context"
+	"time"
+)
+
+// SessionService 让某个用户已经登录的地方全部下线。
+// JWT 是无状态的，没办法直接删掉，所以记一个失效时间，校验登录态的时候比较登录时间
+type SessionService struct {
+	repo *repository.SessionRepository
+}
+
+func NewSessionService(repo *repository.SessionRepository) *SessionService {
+	return &SessionService{
+		repo: repo,
+	}
+}
+
+// RevokeAll 现在之前的登录全部失效
+func (svc *SessionService) RevokeAll(ctx context.Context, uid int64) error {
+	return svc.repo.Revoke(ctx, uid, time.Now())
+}
+
+// Valid loginTime 是登录的时间，在失效时间之前登录的都不能用了
+func (svc *SessionService) Valid(ctx context.Context, uid int64, loginTime time.Time) (bool, error) {
+	revokedAt, err := svc.repo.RevokedAt(ctx, uid)
+	if err != nil {
+		return false, err
+	}
+	return revokedAt.IsZero() || !loginTime.Before(revokedAt), nil
+}
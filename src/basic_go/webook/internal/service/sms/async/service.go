@@ -0,0 +1,90 @@
+// Package async 服务商出问题或者触发了限流的时候，先把短信存到数据库里面，
+// 之后由定时任务异步重试
+package async
+
+import (
+	"basic_go/webook/internal/domain"
+	"basic_go/webook/internal/repository"
+	"basic_go/webook/internal/service/sms"
+	"context"
+	"log"
+	"time"
+)
+
+type Config struct {
+	// RetryMax 存下来的短信最多重试几次
+	RetryMax int
+	// RetryInterval 两次重试之间至少隔多久，也是抢到之后挂了多久会被重新抢到
+	RetryInterval time.Duration
+}
+
+func DefaultConfig() Config {
+	return Config{
+		RetryMax:      3,
+		RetryInterval: time.Minute,
+	}
+}
+
+type Service struct {
+	svc  sms.Service
+	repo *repository.AsyncSmsRepository
+	cfg  Config
+	now  func() time.Time
+}
+
+func NewService(svc sms.Service, repo *repository.AsyncSmsRepository, cfg Config) *Service {
+	return &Service{
+		svc:  svc,
+		repo: repo,
+		cfg:  cfg,
+		now:  time.Now,
+	}
+}
+
+// Send 同步发送失败了就存下来异步重试，存成功了也算发送成功
+func (s *Service) Send(ctx context.Context, tplId string, args []string, numbers ...string) error {
+	err := s.svc.Send(ctx, tplId, args, numbers...)
+	if err == nil {
+		return nil
+	}
+	log.Println("发送短信失败，转异步重试", err)
+	aerr := s.repo.Add(ctx, domain.AsyncSms{
+		TplId:    tplId,
+		Args:     args,
+		Numbers:  numbers,
+		RetryMax: s.cfg.RetryMax,
+	})
+	if aerr != nil {
+		log.Println("保存异步短信失败", aerr)
+		return err
+	}
+	return nil
+}
+
+// RetryPending 把等着重试的短信都试一遍，由定时任务调用。
+// 一次最多试一遍，这一次刚失败的不会马上又被抢到
+func (s *Service) RetryPending(ctx context.Context) error {
+	before := s.now().Add(-s.cfg.RetryInterval)
+	var seen []int64
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		as, err := s.repo.PreemptWaiting(ctx, before, seen)
+		if err == repository.ErrNoAsyncSms {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		seen = append(seen, as.Id)
+		err = s.svc.Send(ctx, as.TplId, as.Args, as.Numbers...)
+		if err != nil {
+			log.Println("异步重试发送短信失败", as.Id, err)
+		}
+		err = s.repo.ReportResult(ctx, as.Id, err)
+		if err != nil {
+			return err
+		}
+	}
+}
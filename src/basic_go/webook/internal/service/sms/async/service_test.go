@@ -0,0 +1,102 @@
+package async
+
+import (
+	"basic_go/webook/internal/repository"
+	"basic_go/webook/internal/repository/dao"
+	"basic_go/webook/internal/repository/dao/daotest"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeService 按顺序返回 errs 里面的错误，用完了就一直成功
+type fakeService struct {
+	errs []error
+	sent [][]string
+}
+
+func (s *fakeService) Send(ctx context.Context, tplId string, args []string, numbers ...string) error {
+	if len(s.errs) > 0 {
+		err := s.errs[0]
+		s.errs = s.errs[1:]
+		if err != nil {
+			return err
+		}
+	}
+	s.sent = append(s.sent, numbers)
+	return nil
+}
+
+func TestService(t *testing.T) {
+	db := daotest.OpenDB(t, &dao.AsyncSms{})
+
+	errVendor := errors.New("服务商出问题了")
+	inner := &fakeService{}
+	svc := NewService(inner, repository.NewAsyncSmsRepository(dao.NewAsyncSmsDAO(db)),
+		Config{RetryMax: 2, RetryInterval: time.Minute})
+	ctx := context.Background()
+
+	// 直接发送成功的不会存下来
+	require.NoError(t, svc.Send(ctx, "tpl", []string{"1234"}, "111"))
+	// 失败了存下来，对调用方来说算成功
+	inner.errs = []error{errVendor, errVendor}
+	require.NoError(t, svc.Send(ctx, "tpl", []string{"1234"}, "222"))
+	require.NoError(t, svc.Send(ctx, "tpl", []string{"1234"}, "333"))
+	var cnt int64
+	require.NoError(t, db.Model(&dao.AsyncSms{}).Count(&cnt).Error)
+	assert.Equal(t, int64(2), cnt)
+
+	// 222 重试成功，333 重试失败
+	// 库里面的 utime 用的是真实时间，测试里面每次把时钟往后拨过一个重试间隔
+	now := time.Now()
+	svc.now = func() time.Time { return now }
+	inner.sent = nil
+	inner.errs = []error{nil, errVendor}
+	// 还没到重试间隔
+	require.NoError(t, svc.RetryPending(ctx))
+	assert.Empty(t, inner.sent)
+	now = now.Add(time.Minute + time.Second)
+	require.NoError(t, svc.RetryPending(ctx))
+	assert.Equal(t, [][]string{{"222"}}, inner.sent)
+
+	// 333 第二次重试还是失败，次数用完了，不会再重试
+	inner.errs = []error{errVendor}
+	now = now.Add(time.Minute + time.Second)
+	require.NoError(t, svc.RetryPending(ctx))
+	now = now.Add(time.Minute + time.Second)
+	require.NoError(t, svc.RetryPending(ctx))
+	assert.Equal(t, [][]string{{"222"}}, inner.sent)
+
+	var ss []dao.AsyncSms
+	require.NoError(t, db.Order("id").Find(&ss).Error)
+	require.Len(t, ss, 2)
+	assert.Equal(t, 1, ss[0].RetryCnt)
+	assert.Equal(t, 2, ss[1].RetryCnt)
+	assert.NotEqual(t, ss[0].Status, ss[1].Status)
+	_, err := dao.NewAsyncSmsDAO(db).PreemptWaiting(ctx, now.UnixMilli(), nil)
+	assert.Equal(t, dao.ErrRecordNotFound, err)
+}
+
+// TestService_RetryOncePerRun 重试间隔是 0 的时候，刚失败的也不能在同一次里面又被抢到
+func TestService_RetryOncePerRun(t *testing.T) {
+	db := daotest.OpenDB(t, &dao.AsyncSms{})
+	errVendor := errors.New("服务商出问题了")
+	inner := &fakeService{errs: []error{errVendor, errVendor, errVendor}}
+	svc := NewService(inner, repository.NewAsyncSmsRepository(dao.NewAsyncSmsDAO(db)),
+		Config{RetryMax: 3, RetryInterval: 0})
+	now := time.Now().Add(time.Second)
+	svc.now = func() time.Time { return now }
+	ctx := context.Background()
+	require.NoError(t, svc.Send(ctx, "tpl", []string{"1234"}, "111"))
+
+	require.NoError(t, svc.RetryPending(ctx))
+	var s dao.AsyncSms
+	require.NoError(t, db.First(&s).Error)
+	assert.Equal(t, 1, s.RetryCnt)
+	// 下一次还会接着重试
+	assert.Len(t, inner.errs, 1)
+}
@@ -0,0 +1,59 @@
+// Package failover 一个服务商出问题的时候换一个
+package failover
+
+import (
+	"basic_go/webook/internal/service/sms"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync/atomic"
+)
+
+var ErrAllFailed = errors.New("全部服务商都发送失败了")
+
+type Strategy uint8
+
+const (
+	// StrategyRoundRobin 每次从下一个服务商开始，把请求分散到各个服务商
+	StrategyRoundRobin Strategy = iota
+	// StrategyPriority 每次都从第一个开始，前面的失败了才用后面的
+	StrategyPriority
+)
+
+// Service 按照顺序一个一个试，直到有一个发送成功
+type Service struct {
+	svcs     []sms.Service
+	strategy Strategy
+	idx      atomic.Uint64
+}
+
+func NewService(svcs []sms.Service, strategy Strategy) *Service {
+	return &Service{
+		svcs:     svcs,
+		strategy: strategy,
+	}
+}
+
+func (s *Service) Send(ctx context.Context, tplId string, args []string, numbers ...string) error {
+	var start uint64
+	if s.strategy == StrategyRoundRobin {
+		start = s.idx.Add(1) - 1
+	}
+	length := uint64(len(s.svcs))
+	var lastErr error
+	for i := uint64(0); i < length; i++ {
+		svc := s.svcs[(start+i)%length]
+		err := svc.Send(ctx, tplId, args, numbers...)
+		if err == nil {
+			return nil
+		}
+		// 调用方不等了，没必要再试别的
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		log.Println("发送短信失败，换一个服务商", err)
+		lastErr = err
+	}
+	return fmt.Errorf("%w: %v", ErrAllFailed, lastErr)
+}
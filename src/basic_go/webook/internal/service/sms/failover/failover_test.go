@@ -0,0 +1,87 @@
+package failover
+
+import (
+	"basic_go/webook/internal/service/sms"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeService 按顺序返回 errs 里面的错误，用完了就一直成功
+type fakeService struct {
+	errs []error
+	cnt  int
+}
+
+func (s *fakeService) Send(ctx context.Context, tplId string, args []string, numbers ...string) error {
+	s.cnt++
+	if len(s.errs) == 0 {
+		return nil
+	}
+	err := s.errs[0]
+	s.errs = s.errs[1:]
+	return err
+}
+
+func TestService_Send(t *testing.T) {
+	errVendor := errors.New("服务商出问题了")
+	ctx := context.Background()
+
+	t.Run("轮询", func(t *testing.T) {
+		a, b := &fakeService{}, &fakeService{}
+		svc := NewService([]sms.Service{a, b}, StrategyRoundRobin)
+		for i := 0; i < 4; i++ {
+			assert.NoError(t, svc.Send(ctx, "tpl", nil, "123"))
+		}
+		assert.Equal(t, 2, a.cnt)
+		assert.Equal(t, 2, b.cnt)
+	})
+
+	t.Run("按优先级", func(t *testing.T) {
+		a, b := &fakeService{errs: []error{errVendor}}, &fakeService{}
+		svc := NewService([]sms.Service{a, b}, StrategyPriority)
+		// 第一个失败了用第二个
+		assert.NoError(t, svc.Send(ctx, "tpl", nil, "123"))
+		assert.NoError(t, svc.Send(ctx, "tpl", nil, "123"))
+		assert.Equal(t, 2, a.cnt)
+		assert.Equal(t, 1, b.cnt)
+	})
+
+	t.Run("全部失败", func(t *testing.T) {
+		a, b := &fakeService{errs: []error{errVendor}}, &fakeService{errs: []error{errVendor}}
+		svc := NewService([]sms.Service{a, b}, StrategyRoundRobin)
+		err := svc.Send(ctx, "tpl", nil, "123")
+		assert.ErrorIs(t, err, ErrAllFailed)
+	})
+
+	t.Run("调用方不等了", func(t *testing.T) {
+		cctx, cancel := context.WithCancel(ctx)
+		cancel()
+		a, b := &fakeService{errs: []error{context.Canceled}}, &fakeService{}
+		svc := NewService([]sms.Service{a, b}, StrategyPriority)
+		assert.Equal(t, context.Canceled, svc.Send(cctx, "tpl", nil, "123"))
+		assert.Equal(t, 0, b.cnt)
+	})
+}
+
+func TestTimeoutService_Send(t *testing.T) {
+	ctx := context.Background()
+	errVendor := errors.New("手机号不对")
+	a := &fakeService{errs: []error{context.DeadlineExceeded, errVendor,
+		context.DeadlineExceeded, context.DeadlineExceeded}}
+	b := &fakeService{}
+	svc := NewTimeoutService([]sms.Service{a, b}, 2)
+
+	assert.Equal(t, context.DeadlineExceeded, svc.Send(ctx, "tpl", nil, "123"))
+	// 别的错误不算超时，也不会把计数清零
+	assert.Equal(t, errVendor, svc.Send(ctx, "tpl", nil, "123"))
+	assert.Equal(t, context.DeadlineExceeded, svc.Send(ctx, "tpl", nil, "123"))
+	assert.Equal(t, 3, a.cnt)
+	// 连续超时两次了，换成 b
+	assert.NoError(t, svc.Send(ctx, "tpl", nil, "123"))
+	assert.NoError(t, svc.Send(ctx, "tpl", nil, "123"))
+	assert.Equal(t, 3, a.cnt)
+	assert.Equal(t, 2, b.cnt)
+}
@@ -0,0 +1,46 @@
+package failover
+
+import (
+	"basic_go/webook/internal/service/sms"
+	"context"
+	"errors"
+	"sync/atomic"
+)
+
+// TimeoutService 一直用同一个服务商，连续超时 threshold 次之后换下一个。
+// 超时多半是服务商那边出问题了，别的错误比如手机号不对，换了也没用
+type TimeoutService struct {
+	svcs []sms.Service
+	// 当前用的服务商
+	idx atomic.Int32
+	// 当前服务商连续超时的次数
+	cnt       atomic.Int32
+	threshold int32
+}
+
+func NewTimeoutService(svcs []sms.Service, threshold int32) *TimeoutService {
+	return &TimeoutService{
+		svcs:      svcs,
+		threshold: threshold,
+	}
+}
+
+func (s *TimeoutService) Send(ctx context.Context, tplId string, args []string, numbers ...string) error {
+	idx := s.idx.Load()
+	if s.cnt.Load() >= s.threshold {
+		next := (idx + 1) % int32(len(s.svcs))
+		// 并发的时候只有一个能切换成功，别的用切换之后的
+		if s.idx.CompareAndSwap(idx, next) {
+			s.cnt.Store(0)
+		}
+		idx = s.idx.Load()
+	}
+	err := s.svcs[idx].Send(ctx, tplId, args, numbers...)
+	switch {
+	case err == nil:
+		s.cnt.Store(0)
+	case errors.Is(err, context.DeadlineExceeded):
+		s.cnt.Add(1)
+	}
+	return err
+}
@@ -0,0 +1,19 @@
+// Package memory 不真的发短信，打印出来，本地开发用
+package memory
+
+import (
+	"context"
+	"log"
+)
+
+type Service struct {
+}
+
+func NewService() *Service {
+	return &Service{}
+}
+
+func (s *Service) Send(ctx context.Context, tplId string, args []string, numbers ...string) error {
+	log.Println("发送短信", tplId, args, numbers)
+	return nil
+}
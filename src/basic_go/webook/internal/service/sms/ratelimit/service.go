@@ -0,0 +1,39 @@
+// Package ratelimit 给发短信限流，服务商那边按调用次数收钱，也有频率限制
+package ratelimit
+
+import (
+	"basic_go/webook/internal/service/sms"
+	"basic_go/webook/pkg/limiter"
+	"context"
+	"errors"
+	"fmt"
+)
+
+var ErrLimited = errors.New("发送短信太频繁了，触发了限流")
+
+type Service struct {
+	svc     sms.Service
+	limiter limiter.Limiter
+	key     string
+}
+
+// NewService key 是限流用的 key，同一个 key 共享额度
+func NewService(svc sms.Service, limiter limiter.Limiter, key string) *Service {
+	return &Service{
+		svc:     svc,
+		limiter: limiter,
+		key:     key,
+	}
+}
+
+func (s *Service) Send(ctx context.Context, tplId string, args []string, numbers ...string) error {
+	limited, err := s.limiter.Limit(ctx, s.key)
+	if err != nil {
+		// 限流器出问题了，保守一点当作限流了，免得把服务商打挂或者产生大量费用
+		return fmt.Errorf("短信服务判断限流出现问题: %w", err)
+	}
+	if limited {
+		return ErrLimited
+	}
+	return s.svc.Send(ctx, tplId, args, numbers...)
+}
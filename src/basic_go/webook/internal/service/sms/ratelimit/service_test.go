@@ -0,0 +1,51 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeService struct {
+	cnt int
+}
+
+func (s *fakeService) Send(ctx context.Context, tplId string, args []string, numbers ...string) error {
+	s.cnt++
+	return nil
+}
+
+type fakeLimiter struct {
+	limited bool
+	err     error
+}
+
+func (l fakeLimiter) Limit(ctx context.Context, key string) (bool, error) {
+	return l.limited, l.err
+}
+
+func TestService_Send(t *testing.T) {
+	ctx := context.Background()
+	errRedis := errors.New("redis 挂了")
+	testCases := []struct {
+		name    string
+		limiter fakeLimiter
+		wantErr error
+		wantCnt int
+	}{
+		{name: "没有限流", wantCnt: 1},
+		{name: "限流了", limiter: fakeLimiter{limited: true}, wantErr: ErrLimited},
+		// 限流器出问题了也不发
+		{name: "限流器出错", limiter: fakeLimiter{err: errRedis}, wantErr: errRedis},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			inner := &fakeService{}
+			err := NewService(inner, tc.limiter, "sms").Send(ctx, "tpl", nil, "123")
+			assert.ErrorIs(t, err, tc.wantErr)
+			assert.Equal(t, tc.wantCnt, inner.cnt)
+		})
+	}
+}
@@ -0,0 +1,10 @@
+// Package sms 发短信。具体的服务商实现 Service，
+// 子包里面是装饰器，用来组合出重试、切换服务商、限流这些功能
+package sms
+
+import "context"
+
+type Service interface {
+	// Send tplId 是服务商那边的模板，args 是模板里面的参数，按顺序填
+	Send(ctx context.Context, tplId string, args []string, numbers ...string) error
+}
@@ -0,0 +1,292 @@
+package service
+
+import (
+	"basic_go/webook/internal/domain"
+	"basic_go/webook/internal/repository"
+	"basic_go/webook/pkg/totp"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrTwoFactorAlreadyEnabled = errors.New("已经开启了二次验证")
+	ErrTwoFactorNotEnrolled    = errors.New("还没有绑定二次验证")
+	ErrInvalidTwoFactorCode    = errors.New("验证码错误")
+	// ErrTwoFactorTokenInvalid 中间 token 已经用过了，或者失败太多次被作废了
+	ErrTwoFactorTokenInvalid = errors.New("二次验证已失效，请重新登录")
+	// ErrTwoFactorLocked 验证码错太多次了，要等一段时间才能再试
+	ErrTwoFactorLocked = errors.New("验证码错误次数过多，请稍后再试")
+)
+
+const (
+	recoveryCodeCnt = 10
+	// 去掉了容易看错的 0 O 1 I L
+	recoveryCodeAlphabet = "abcdefghjkmnpqrstuvwxyz23456789"
+)
+
+// TwoFactorLimit 输验证码的失败限制。6 位验证码只有一百万种可能，不限制的话很快就能试出来。
+// 登录的第二步、绑定和关闭共用一个按用户的计数
+type TwoFactorLimit struct {
+	// 同一个用户失败多少次之后锁定，手上的中间 token 全部作废
+	MaxFailures int64
+	// 失败次数的计数窗口，也是锁定之后要等多久才能再试
+	Window time.Duration
+}
+
+func DefaultTwoFactorLimit() TwoFactorLimit {
+	return TwoFactorLimit{
+		MaxFailures: 5,
+		Window:      time.Minute * 15,
+	}
+}
+
+type TwoFactorService struct {
+	repo     *repository.TwoFactorRepository
+	attempts *repository.LoginAttemptRepository
+	limit    TwoFactorLimit
+	totp     *totp.TOTP
+	issuer   string
+	now      func() time.Time
+}
+
+func NewTwoFactorService(repo *repository.TwoFactorRepository, attempts *repository.LoginAttemptRepository,
+	limit TwoFactorLimit, t *totp.TOTP, issuer string) *TwoFactorService {
+	return &TwoFactorService{
+		repo:     repo,
+		attempts: attempts,
+		limit:    limit,
+		totp:     t,
+		issuer:   issuer,
+		now:      time.Now,
+	}
+}
+
+// Enroll 生成新的密钥和恢复码。要调用 Activate 校验一次验证码才会真的启用
+func (svc *TwoFactorService) Enroll(ctx context.Context, u domain.User) (domain.TwoFactorEnrollment, error) {
+	tf, err := svc.repo.FindByUid(ctx, u.Id)
+	switch {
+	case err == nil && tf.Enabled:
+		return domain.TwoFactorEnrollment{}, ErrTwoFactorAlreadyEnabled
+	case err != nil && err != repository.ErrTwoFactorNotFound:
+		return domain.TwoFactorEnrollment{}, err
+	}
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return domain.TwoFactorEnrollment{}, err
+	}
+	codes := make([]string, 0, recoveryCodeCnt)
+	hashes := make([]string, 0, recoveryCodeCnt)
+	for i := 0; i < recoveryCodeCnt; i++ {
+		code, err := generateRecoveryCode()
+		if err != nil {
+			return domain.TwoFactorEnrollment{}, err
+		}
+		codes = append(codes, code)
+		hashes = append(hashes, hashRecoveryCode(code))
+	}
+	err = svc.repo.Save(ctx, domain.TwoFactor{
+		Uid:    u.Id,
+		Secret: secret,
+	}, hashes)
+	if err != nil {
+		return domain.TwoFactorEnrollment{}, err
+	}
+	return domain.TwoFactorEnrollment{
+		Secret:        secret,
+		URI:           svc.totp.URI(svc.issuer, u.Email, secret),
+		RecoveryCodes: codes,
+	}, nil
+}
+
+// Activate 用户用 App 扫码之后，输入一次验证码确认绑定成功
+func (svc *TwoFactorService) Activate(ctx context.Context, uid int64, code string) error {
+	tf, err := svc.repo.FindByUid(ctx, uid)
+	if err == repository.ErrTwoFactorNotFound {
+		return ErrTwoFactorNotEnrolled
+	}
+	if err != nil {
+		return err
+	}
+	if tf.Enabled {
+		return ErrTwoFactorAlreadyEnabled
+	}
+	err = svc.limitVerify(ctx, uid, func() error {
+		return svc.verifyTOTP(ctx, tf, code)
+	})
+	if err != nil {
+		return err
+	}
+	return svc.repo.Enable(ctx, uid)
+}
+
+// Enabled 用户有没有开启二次验证
+func (svc *TwoFactorService) Enabled(ctx context.Context, uid int64) (bool, error) {
+	tf, err := svc.repo.FindByUid(ctx, uid)
+	if err == repository.ErrTwoFactorNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return tf.Enabled, nil
+}
+
+// Verify 校验 TOTP 验证码或者恢复码。这里不限制失败次数，对外的接口要用 limitVerify 包一下
+func (svc *TwoFactorService) Verify(ctx context.Context, uid int64, code string) error {
+	tf, err := svc.repo.FindByUid(ctx, uid)
+	if err == repository.ErrTwoFactorNotFound {
+		return ErrTwoFactorNotEnrolled
+	}
+	if err != nil {
+		return err
+	}
+	if !tf.Enabled {
+		return ErrTwoFactorNotEnrolled
+	}
+	code = strings.TrimSpace(code)
+	if len(code) == svc.totp.Digits {
+		return svc.verifyTOTP(ctx, tf, code)
+	}
+	ok, err := svc.repo.UseRecoveryCode(ctx, uid, hashRecoveryCode(code))
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrInvalidTwoFactorCode
+	}
+	return nil
+}
+
+// VerifyLogin 登录的第二步。jti 是中间 token 的 id，expireAt 是它的过期时间。
+// 一个中间 token 只能成功用一次；同一个用户连续失败 MaxFailures 次之后，
+// 所有中间 token 都作废，要等 Window 之后重新走密码登录
+func (svc *TwoFactorService) VerifyLogin(ctx context.Context, uid int64, jti string, expireAt time.Time, code string) error {
+	tokenKey := twoFactorTokenKey(jti)
+	a, err := svc.attempts.Find(ctx, tokenKey)
+	if err != nil {
+		return err
+	}
+	if a.Blocked(svc.now()) {
+		return ErrTwoFactorTokenInvalid
+	}
+	err = svc.limitVerify(ctx, uid, func() error {
+		return svc.Verify(ctx, uid, code)
+	})
+	switch err {
+	case nil:
+		// token 用掉了，在它过期之前都不能再用
+		return svc.attempts.Block(ctx, tokenKey, expireAt)
+	case ErrTwoFactorLocked:
+		return ErrTwoFactorTokenInvalid
+	default:
+		return err
+	}
+}
+
+// Disable 关闭二次验证，要求再输入一次验证码或者恢复码。
+// 失败次数和登录一起算，不然拿到登录态的人可以从这里把验证码试出来
+func (svc *TwoFactorService) Disable(ctx context.Context, uid int64, code string) error {
+	err := svc.limitVerify(ctx, uid, func() error {
+		return svc.Verify(ctx, uid, code)
+	})
+	if err != nil {
+		return err
+	}
+	return svc.repo.Delete(ctx, uid)
+}
+
+// Remove 不用验证码直接删掉，注销账号的时候用
+func (svc *TwoFactorService) Remove(ctx context.Context, uid int64) error {
+	return svc.repo.Delete(ctx, uid)
+}
+
+// limitVerify 调用 verify 校验验证码，同一个用户连续失败 MaxFailures 次之后锁定 Window，
+// 锁定期间直接返回 ErrTwoFactorLocked，验证码对不对都不看
+func (svc *TwoFactorService) limitVerify(ctx context.Context, uid int64, verify func() error) error {
+	now := svc.now()
+	key := twoFactorUidKey(uid)
+	a, err := svc.attempts.Find(ctx, key)
+	if err != nil {
+		return err
+	}
+	if a.Blocked(now) {
+		return ErrTwoFactorLocked
+	}
+	err = verify()
+	switch err {
+	case nil:
+		return svc.attempts.Delete(ctx, key)
+	case ErrInvalidTwoFactorCode:
+		cnt, err := svc.attempts.IncrFailure(ctx, key, svc.limit.Window)
+		if err != nil {
+			return err
+		}
+		if cnt < svc.limit.MaxFailures {
+			return ErrInvalidTwoFactorCode
+		}
+		err = svc.attempts.Block(ctx, key, now.Add(svc.limit.Window))
+		if err != nil {
+			return err
+		}
+		return ErrTwoFactorLocked
+	default:
+		return err
+	}
+}
+
+func (svc *TwoFactorService) verifyTOTP(ctx context.Context, tf domain.TwoFactor, code string) error {
+	counter, ok, err := svc.totp.Validate(tf.Secret, code)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrInvalidTwoFactorCode
+	}
+	// 同一个验证码，或者比上一次用过的更早的验证码，都不能再用
+	ok, err = svc.repo.UseCounter(ctx, tf.Uid, counter)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrInvalidTwoFactorCode
+	}
+	return nil
+}
+
+func twoFactorTokenKey(jti string) string {
+	return "2fa_token:" + jti
+}
+
+func twoFactorUidKey(uid int64) string {
+	return "2fa_uid:" + strconv.FormatInt(uid, 10)
+}
+
+// generateRecoveryCode 生成 xxxxx-xxxxx 格式的恢复码
+func generateRecoveryCode() (string, error) {
+	var sb strings.Builder
+	max := big.NewInt(int64(len(recoveryCodeAlphabet)))
+	for i := 0; i < 10; i++ {
+		if i == 5 {
+			sb.WriteByte('-')
+		}
+		idx, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		sb.WriteByte(recoveryCodeAlphabet[idx.Int64()])
+	}
+	return sb.String(), nil
+}
+
+func hashRecoveryCode(code string) string {
+	code = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
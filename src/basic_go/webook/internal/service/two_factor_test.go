@@ -0,0 +1,116 @@
+package service
+
+import (
+	"basic_go/webook/internal/domain"
+	"basic_go/webook/internal/repository"
+	"basic_go/webook/internal/repository/cache"
+	"basic_go/webook/internal/repository/dao"
+	"basic_go/webook/internal/repository/dao/daotest"
+	"basic_go/webook/pkg/totp"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestTwoFactor 开启了二次验证的用户 1，时间由 now 控制
+func newTestTwoFactor(t *testing.T, now *time.Time) (*TwoFactorService, *totp.TOTP, domain.TwoFactorEnrollment) {
+	db := daotest.OpenDB(t, &dao.TwoFactor{}, &dao.RecoveryCode{})
+	clock := func() time.Time { return *now }
+	tp := totp.New(clock)
+	svc := NewTwoFactorService(repository.NewTwoFactorRepository(dao.NewTwoFactorDAO(db)),
+		repository.NewLoginAttemptRepository(cache.NewMemoryLoginAttemptCache(clock)),
+		TwoFactorLimit{MaxFailures: 3, Window: time.Minute * 15}, tp, "webook")
+	svc.now = clock
+	ctx := context.Background()
+	e, err := svc.Enroll(ctx, domain.User{Id: 1, Email: "123@qq.com"})
+	require.NoError(t, err)
+	code, err := tp.Code(e.Secret, *now)
+	require.NoError(t, err)
+	require.NoError(t, svc.Activate(ctx, 1, code))
+	return svc, tp, e
+}
+
+func TestTwoFactorService_UseCounter(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	svc, tp, e := newTestTwoFactor(t, &now)
+	ctx := context.Background()
+
+	// Activate 用过的验证码不能再拿来登录
+	code, err := tp.Code(e.Secret, now)
+	require.NoError(t, err)
+	assert.Equal(t, ErrInvalidTwoFactorCode, svc.Verify(ctx, 1, code))
+
+	now = now.Add(tp.Period)
+	code, err = tp.Code(e.Secret, now)
+	require.NoError(t, err)
+	require.NoError(t, svc.Verify(ctx, 1, code))
+	// 同一个验证码重放
+	assert.Equal(t, ErrInvalidTwoFactorCode, svc.Verify(ctx, 1, code))
+	// 比用过的更早的验证码，在时钟偏差的范围内也不能用
+	old, err := tp.Code(e.Secret, now.Add(-tp.Period))
+	require.NoError(t, err)
+	assert.Equal(t, ErrInvalidTwoFactorCode, svc.Verify(ctx, 1, old))
+
+	// 恢复码只能用一次
+	require.NoError(t, svc.Verify(ctx, 1, e.RecoveryCodes[0]))
+	assert.Equal(t, ErrInvalidTwoFactorCode, svc.Verify(ctx, 1, e.RecoveryCodes[0]))
+}
+
+func TestTwoFactorService_VerifyLogin(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	svc, tp, e := newTestTwoFactor(t, &now)
+	ctx := context.Background()
+	exp := now.Add(time.Minute * 5)
+	now = now.Add(tp.Period)
+
+	// 成功之后 token 就不能再用了，哪怕验证码是对的
+	require.NoError(t, svc.VerifyLogin(ctx, 1, "jti-1", exp, e.RecoveryCodes[0]))
+	assert.Equal(t, ErrTwoFactorTokenInvalid, svc.VerifyLogin(ctx, 1, "jti-1", exp, e.RecoveryCodes[1]))
+
+	// 失败次数按用户算，换 token 也不会重置
+	assert.Equal(t, ErrInvalidTwoFactorCode, svc.VerifyLogin(ctx, 1, "jti-2", exp, "000000"))
+	assert.Equal(t, ErrInvalidTwoFactorCode, svc.VerifyLogin(ctx, 1, "jti-3", exp, "000000"))
+	assert.Equal(t, ErrTwoFactorTokenInvalid, svc.VerifyLogin(ctx, 1, "jti-3", exp, "000000"))
+	// 作废之后正确的验证码也不行
+	code, err := tp.Code(e.Secret, now)
+	require.NoError(t, err)
+	assert.Equal(t, ErrTwoFactorTokenInvalid, svc.VerifyLogin(ctx, 1, "jti-4", exp, code))
+
+	// 过了窗口期可以重新登录，成功之后失败次数清零
+	now = now.Add(time.Minute * 15)
+	code, err = tp.Code(e.Secret, now)
+	require.NoError(t, err)
+	require.NoError(t, svc.VerifyLogin(ctx, 1, "jti-5", now.Add(time.Minute*5), code))
+	assert.Equal(t, ErrInvalidTwoFactorCode, svc.VerifyLogin(ctx, 1, "jti-6", now.Add(time.Minute*5), "000000"))
+}
+
+func TestTwoFactorService_DisableLimited(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	svc, _, e := newTestTwoFactor(t, &now)
+	ctx := context.Background()
+	exp := now.Add(time.Minute * 5)
+
+	// 关闭和登录共用失败次数，锁定之后正确的恢复码也不行
+	assert.Equal(t, ErrInvalidTwoFactorCode, svc.Disable(ctx, 1, "000000"))
+	assert.Equal(t, ErrInvalidTwoFactorCode, svc.VerifyLogin(ctx, 1, "jti-1", exp, "000000"))
+	assert.Equal(t, ErrTwoFactorLocked, svc.Disable(ctx, 1, "000000"))
+	assert.Equal(t, ErrTwoFactorLocked, svc.Disable(ctx, 1, e.RecoveryCodes[0]))
+	assert.Equal(t, ErrTwoFactorTokenInvalid, svc.VerifyLogin(ctx, 1, "jti-2", exp, e.RecoveryCodes[0]))
+
+	now = now.Add(time.Minute * 15)
+	require.NoError(t, svc.Disable(ctx, 1, e.RecoveryCodes[0]))
+	enabled, err := svc.Enabled(ctx, 1)
+	require.NoError(t, err)
+	assert.False(t, enabled)
+
+	// 绑定的时候也限制
+	_, err = svc.Enroll(ctx, domain.User{Id: 2, Email: "456@qq.com"})
+	require.NoError(t, err)
+	for i := 0; i < 2; i++ {
+		assert.Equal(t, ErrInvalidTwoFactorCode, svc.Activate(ctx, 2, "000000"))
+	}
+	assert.Equal(t, ErrTwoFactorLocked, svc.Activate(ctx, 2, "000000"))
+}
@@ -0,0 +1,229 @@
+package service
+
+import (
+	"basic_go/webook/internal/domain"
+	"basic_go/webook/internal/repository"
+	"basic_go/webook/pkg/objstore"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"log"
+	"net/http"
+	"regexp"
+	"time"
+)
+
+var (
+	ErrFileTooLarge        = errors.New("文件太大了")
+	ErrUnsupportedFileType = errors.New("不支持的文件类型")
+	ErrInvalidImage        = errors.New("图片格式不对，或者尺寸不符合要求")
+	ErrUploadNotFound      = repository.ErrUploadNotFound
+)
+
+// 公开的文件和私有的文件放在不同的前缀下面，对象存储那边按前缀配置访问权限
+const (
+	uploadPublicPrefix  = "public/"
+	uploadPrivatePrefix = "private/"
+)
+
+// 支持的图片类型，按照文件内容判断，不看文件名和请求头
+var uploadImageExts = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+}
+
+// uploadKeyPattern 从文章内容里面找出引用的文件
+var uploadKeyPattern = regexp.MustCompile(`(?:public|private)/[a-z]+/\d{8}/[0-9a-f]{32}\.(?:jpg|png|gif)`)
+
+type UploadConfig struct {
+	// 单个文件最大多少字节
+	MaxSize int64
+	// 图片的宽高最大多少像素
+	MaxWidth  int
+	MaxHeight int
+	// 上传之后多久还没被用上，就当作没用的文件清理掉
+	OrphanTTL time.Duration
+	// 私有文件签名地址的有效期
+	SignExpire time.Duration
+}
+
+func DefaultUploadConfig() UploadConfig {
+	return UploadConfig{
+		MaxSize:    5 << 20,
+		MaxWidth:   8192,
+		MaxHeight:  8192,
+		OrphanTTL:  time.Hour * 24,
+		SignExpire: time.Minute * 15,
+	}
+}
+
+func PublicUploadPrefix() string {
+	return uploadPublicPrefix
+}
+
+type UploadService struct {
+	repo  *repository.UploadRepository
+	store objstore.Store
+	cfg   UploadConfig
+}
+
+func NewUploadService(repo *repository.UploadRepository, store objstore.Store, cfg UploadConfig) *UploadService {
+	return &UploadService{
+		repo:  repo,
+		store: store,
+		cfg:   cfg,
+	}
+}
+
+// UploadImage 上传图片。u 里面只需要 Uid、Biz 和 Private，其余的字段会填好返回
+func (svc *UploadService) UploadImage(ctx context.Context, u domain.Upload, r io.Reader) (domain.Upload, error) {
+	// 多读一个字节，读满了就说明超了
+	data, err := io.ReadAll(io.LimitReader(r, svc.cfg.MaxSize+1))
+	if err != nil {
+		return domain.Upload{}, err
+	}
+	if int64(len(data)) > svc.cfg.MaxSize {
+		return domain.Upload{}, ErrFileTooLarge
+	}
+	u.ContentType = http.DetectContentType(data)
+	ext, ok := uploadImageExts[u.ContentType]
+	if !ok {
+		return domain.Upload{}, ErrUnsupportedFileType
+	}
+	// 只解析头部拿宽高，不用解码整张图片
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || cfg.Width <= 0 || cfg.Height <= 0 ||
+		cfg.Width > svc.cfg.MaxWidth || cfg.Height > svc.cfg.MaxHeight {
+		return domain.Upload{}, ErrInvalidImage
+	}
+	u.Width, u.Height = cfg.Width, cfg.Height
+	u.Size = int64(len(data))
+	u.Key, err = svc.newKey(u, ext)
+	if err != nil {
+		return domain.Upload{}, err
+	}
+	u.Status = domain.UploadStatusPending
+	// 先记下来再传，传失败了也会被当成没用的文件清理掉，不会有对象存储里面有、数据库里面没有的文件
+	u.Id, err = svc.repo.Create(ctx, u)
+	if err != nil {
+		return domain.Upload{}, err
+	}
+	err = svc.store.Put(ctx, u.Key, bytes.NewReader(data), u.Size, u.ContentType)
+	if err != nil {
+		return domain.Upload{}, err
+	}
+	u.URL, err = svc.url(ctx, u)
+	return u, err
+}
+
+// SignURL 私有文件只有上传的人能拿到访问地址
+func (svc *UploadService) SignURL(ctx context.Context, uid int64, key string) (string, error) {
+	u, err := svc.repo.FindByKey(ctx, key)
+	if err != nil {
+		return "", err
+	}
+	if u.Private && u.Uid != uid {
+		return "", ErrUploadNotFound
+	}
+	return svc.url(ctx, u)
+}
+
+// Attach 标记文件已经被用上了，只能用自己上传的、同一个业务的文件
+func (svc *UploadService) Attach(ctx context.Context, uid int64, biz string, bizId int64, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	return svc.repo.Attach(ctx, uid, keys, biz, bizId)
+}
+
+// AttachOne 和 Attach 一样，但是文件不是自己上传的、或者不是这个业务的会返回 ErrUploadNotFound
+func (svc *UploadService) AttachOne(ctx context.Context, uid int64, biz string, bizId int64, key string) (domain.Upload, error) {
+	u, err := svc.repo.FindByKey(ctx, key)
+	if err != nil {
+		return domain.Upload{}, err
+	}
+	if u.Uid != uid || u.Biz != biz {
+		return domain.Upload{}, ErrUploadNotFound
+	}
+	err = svc.repo.Attach(ctx, uid, []string{key}, biz, bizId)
+	if err != nil {
+		return domain.Upload{}, err
+	}
+	u.Status = domain.UploadStatusAttached
+	u.BizId = bizId
+	u.URL, err = svc.url(ctx, u)
+	return u, err
+}
+
+// AttachContent 找出文章内容里面引用的文件，标记成已经用上了
+func (svc *UploadService) AttachContent(ctx context.Context, uid int64, biz string, bizId int64, content string) error {
+	return svc.Attach(ctx, uid, biz, bizId, uploadKeyPattern.FindAllString(content, -1)...)
+}
+
+// URL 根据 key 拿到公开访问的地址
+func (svc *UploadService) URL(key string) string {
+	return svc.store.URL(key)
+}
+
+// SweepOrphans 清理上传之后一直没用上的文件
+func (svc *UploadService) SweepOrphans(ctx context.Context) error {
+	const batchSize = 100
+	before := time.Now().Add(-svc.cfg.OrphanTTL)
+	startId := int64(0)
+	for {
+		us, err := svc.repo.FindPending(ctx, before, startId, batchSize)
+		if err != nil {
+			return err
+		}
+		for _, u := range us {
+			err = svc.sweepOne(ctx, u)
+			if err != nil {
+				log.Println("清理上传的文件失败", u.Key, err)
+			}
+		}
+		if len(us) < batchSize {
+			return nil
+		}
+		startId = us[len(us)-1].Id
+	}
+}
+
+func (svc *UploadService) sweepOne(ctx context.Context, u domain.Upload) error {
+	// 先删记录，删成功了说明这时候还没用上，再删文件。
+	// 反过来的话，删完文件刚好被用上，就会引用一个不存在的文件
+	ok, err := svc.repo.DeletePending(ctx, u.Id)
+	if err != nil || !ok {
+		return err
+	}
+	return svc.store.Delete(ctx, u.Key)
+}
+
+func (svc *UploadService) url(ctx context.Context, u domain.Upload) (string, error) {
+	if u.Private {
+		return svc.store.SignURL(ctx, u.Key, svc.cfg.SignExpire)
+	}
+	return svc.store.URL(u.Key), nil
+}
+
+// newKey 生成 public/article/20240102/随机串.jpg 这种 key
+func (svc *UploadService) newKey(u domain.Upload, ext string) (string, error) {
+	var buf [16]byte
+	if _, err := rand.Read(buf[:]); err != nil {
+		return "", err
+	}
+	prefix := uploadPublicPrefix
+	if u.Private {
+		prefix = uploadPrivatePrefix
+	}
+	return fmt.Sprintf("%s%s/%s/%s%s", prefix, u.Biz,
+		time.Now().Format("20060102"), hex.EncodeToString(buf[:]), ext), nil
+}
@@ -0,0 +1,118 @@
+package service
+
+import (
+	"basic_go/webook/internal/domain"
+	"basic_go/webook/internal/repository"
+	"basic_go/webook/internal/repository/dao"
+	"basic_go/webook/internal/repository/dao/daotest"
+	"basic_go/webook/pkg/objstore"
+	"basic_go/webook/pkg/objstore/local"
+	"bytes"
+	"context"
+	"image"
+	"image/png"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestPNG(t *testing.T, w, h int) []byte {
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, w, h))))
+	return buf.Bytes()
+}
+
+func newTestUploadService(t *testing.T, cfg UploadConfig) (*UploadService, objstore.Store) {
+	db := daotest.OpenDB(t, &dao.Upload{})
+	store := local.NewStore(t.TempDir(), "http://localhost/objects", []byte("secret"), PublicUploadPrefix())
+	return NewUploadService(repository.NewUploadRepository(dao.NewUploadDAO(db)), store, cfg), store
+}
+
+func TestUploadService_UploadImage(t *testing.T) {
+	cfg := DefaultUploadConfig()
+	cfg.MaxSize = 1024
+	cfg.MaxWidth = 100
+	cfg.MaxHeight = 100
+	svc, store := newTestUploadService(t, cfg)
+	ctx := context.Background()
+
+	testCases := []struct {
+		name    string
+		data    []byte
+		wantErr error
+	}{
+		{name: "正常的图片", data: newTestPNG(t, 10, 20)},
+		{name: "不是图片", data: []byte("<html>hello</html>"), wantErr: ErrUnsupportedFileType},
+		{name: "太大了", data: bytes.Repeat([]byte{0}, 1025), wantErr: ErrFileTooLarge},
+		{name: "尺寸太大", data: newTestPNG(t, 101, 1), wantErr: ErrInvalidImage},
+		{
+			name: "假装是图片",
+			// 文件头是 PNG，但是后面不对
+			data:    append([]byte("\x89PNG\r\n\x1a\n"), []byte("broken")...),
+			wantErr: ErrInvalidImage,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			u, err := svc.UploadImage(ctx, domain.Upload{Uid: 1, Biz: domain.UploadBizArticle},
+				bytes.NewReader(tc.data))
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, "image/png", u.ContentType)
+			assert.Equal(t, 10, u.Width)
+			assert.Equal(t, 20, u.Height)
+			assert.True(t, strings.HasPrefix(u.Key, "public/article/"))
+			assert.Equal(t, store.URL(u.Key), u.URL)
+			rc, err := store.Get(ctx, u.Key)
+			require.NoError(t, err)
+			require.NoError(t, rc.Close())
+		})
+	}
+
+	// 私有的给签名地址，别人拿不到
+	u, err := svc.UploadImage(ctx, domain.Upload{Uid: 1, Biz: domain.UploadBizArticle, Private: true},
+		bytes.NewReader(newTestPNG(t, 1, 1)))
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(u.Key, "private/"))
+	assert.Contains(t, u.URL, "sign=")
+	_, err = svc.SignURL(ctx, 2, u.Key)
+	assert.Equal(t, ErrUploadNotFound, err)
+}
+
+func TestUploadService_SweepOrphans(t *testing.T) {
+	cfg := DefaultUploadConfig()
+	cfg.OrphanTTL = 0
+	svc, store := newTestUploadService(t, cfg)
+	ctx := context.Background()
+	upload := func(uid int64) domain.Upload {
+		u, err := svc.UploadImage(ctx, domain.Upload{Uid: uid, Biz: domain.UploadBizArticle},
+			bytes.NewReader(newTestPNG(t, 1, 1)))
+		require.NoError(t, err)
+		return u
+	}
+	used := upload(1)
+	orphan := upload(1)
+	// 别人的图片引用了也不算
+	others := upload(2)
+	content := `<p><img src="` + used.URL + `"><img src="` + others.URL + `"></p>`
+	require.NoError(t, svc.AttachContent(ctx, 1, domain.UploadBizArticle, 10, content))
+
+	// 只清理 ctime 早于现在的，毫秒精度，稍微等一下
+	time.Sleep(time.Millisecond * 2)
+	require.NoError(t, svc.SweepOrphans(ctx))
+
+	rc, err := store.Get(ctx, used.Key)
+	require.NoError(t, err)
+	require.NoError(t, rc.Close())
+	for _, key := range []string{orphan.Key, others.Key} {
+		_, err = store.Get(ctx, key)
+		assert.Equal(t, objstore.ErrObjectNotFound, err)
+		_, err = svc.SignURL(ctx, 1, key)
+		assert.Equal(t, ErrUploadNotFound, err)
+	}
+}
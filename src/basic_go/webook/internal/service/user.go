@@ -3,48 +3,260 @@ package service
 import (
 	"basic_go/webook/internal/domain"
 	"basic_go/webook/internal/repository"
+	"basic_go/webook/pkg/hasher"
 	"context"
+	"crypto/rand"
 	"errors"
-
-	"golang.org/x/crypto/bcrypt"
+	"log"
+	"math/big"
+	"strings"
 )
 
 var (
 	ErrDuplicateEmail        = repository.ErrDuplicateEmail
 	ErrInvalidUserOrPassword = errors.New("用户不存在或者密码错误")
+	ErrUserNotFound          = repository.ErrUserNotFound
+	ErrUserDisabled          = errors.New("账号已被禁用")
+	ErrUserBanned            = errors.New("账号已被封禁")
+	ErrUserDeleted           = errors.New("账号已注销")
 )
 
-type UserService struct {
-	repo *repository.UserRepository
+// UserService 用户的核心功能，别的服务也要用。
+// 本地的实现是 LocalUserService，也可以换成 grpc 包里面的客户端，调用远程的用户服务
+type UserService interface {
+	// Signup 密码不符合策略的时候返回 domain.PasswordPolicyError
+	Signup(ctx context.Context, u domain.User) error
+	// Login ip 是客户端的 IP，用来做按 IP 的失败次数限制
+	Login(ctx context.Context, email string, password string, ip string) (domain.User, error)
+	// Profile 展示用的个人信息，不会带上密码
+	Profile(ctx context.Context, id int64) (domain.User, error)
+	FindById(ctx context.Context, id int64) (domain.User, error)
+	// FindOrCreateByPhone 手机号登录用，没有注册过的自动注册
+	FindOrCreateByPhone(ctx context.Context, phone string) (domain.User, error)
+}
+
+// LocalUserService 除了 UserService 之外，还有管理后台这些只在本地用的功能
+type LocalUserService struct {
+	repo   *repository.UserRepository
+	guard  *LoginGuard
+	policy domain.PasswordPolicy
+	hasher hasher.Hasher
 }
 
-func NewUserService(repo *repository.UserRepository) *UserService {
-	return &UserService{
-		repo: repo,
+var _ UserService = (*LocalUserService)(nil)
+
+func NewLocalUserService(repo *repository.UserRepository, guard *LoginGuard,
+	policy domain.PasswordPolicy, h hasher.Hasher) *LocalUserService {
+	return &LocalUserService{
+		repo:   repo,
+		guard:  guard,
+		policy: policy,
+		hasher: h,
 	}
 }
 
-func (svc *UserService) Signup(ctx context.Context, u domain.User) error {
-	hash, err := bcrypt.GenerateFromPassword([]byte(u.Password), bcrypt.DefaultCost)
+func (svc *LocalUserService) Signup(ctx context.Context, u domain.User) error {
+	err := svc.policy.Check(u.Password, u.Email)
 	if err != nil {
 		return err
 	}
-	u.Password = string(hash)
+	hash, err := svc.hasher.Hash(u.Password)
+	if err != nil {
+		return err
+	}
+	u.Password = hash
 	return svc.repo.Create(ctx, u)
 }
 
-func (svc *UserService) Login(ctx context.Context, email string, password string) (domain.User, error) {
+func (svc *LocalUserService) Login(ctx context.Context, email string, password string, ip string) (domain.User, error) {
+	// 先看看是不是已经被锁了，被锁了连密码都不用校验
+	err := svc.guard.Check(ctx, email, ip)
+	if err != nil {
+		return domain.User{}, err
+	}
 	u, err := svc.repo.FindByEmail(ctx, email)
 	if err == repository.ErrUserNotFound {
+		// 用户不存在也要计数，不然可以用来探测哪些邮箱注册过
+		svc.fail(ctx, email, ip)
 		return domain.User{}, ErrInvalidUserOrPassword
 	}
 	if err != nil {
 		return domain.User{}, err
 	}
 	// 检查密码对不对
-	err = bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(password))
-	if err != nil {
+	err = svc.hasher.Verify(u.Password, password)
+	if err == hasher.ErrMismatch {
+		svc.fail(ctx, email, ip)
 		return domain.User{}, ErrInvalidUserOrPassword
 	}
+	if err != nil {
+		return domain.User{}, err
+	}
+	// 密码对了才告诉他账号被禁用了，不然可以用来探测
+	err = CheckUserStatus(u)
+	if err != nil {
+		return domain.User{}, err
+	}
+	err = svc.guard.Succeed(ctx, email)
+	if err != nil {
+		// 不影响这一次登录
+		log.Println(err)
+	}
+	svc.rehash(ctx, u, password)
 	return u, nil
 }
+
+// rehash 老算法或者老参数生成的哈希，趁着这次登录拿到了明文密码，换成新的。
+// 失败了也无所谓，下次登录再换
+func (svc *LocalUserService) rehash(ctx context.Context, u domain.User, password string) {
+	if !svc.hasher.NeedsRehash(u.Password) {
+		return
+	}
+	hash, err := svc.hasher.Hash(password)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	err = svc.repo.UpdatePassword(ctx, u.Id, hash)
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+// CheckUserStatus 不是正常状态的用户不能登录，
+// 两步登录的第二步也要再检查一次，中间可能被禁用了
+func CheckUserStatus(u domain.User) error {
+	switch u.Status {
+	case domain.UserStatusDisabled:
+		return ErrUserDisabled
+	case domain.UserStatusBanned:
+		return ErrUserBanned
+	case domain.UserStatusDeleted:
+		return ErrUserDeleted
+	default:
+		return nil
+	}
+}
+
+func (svc *LocalUserService) Profile(ctx context.Context, id int64) (domain.User, error) {
+	u, err := svc.repo.FindById(ctx, id)
+	u.Password = ""
+	return u, err
+}
+
+func (svc *LocalUserService) FindById(ctx context.Context, id int64) (domain.User, error) {
+	return svc.repo.FindById(ctx, id)
+}
+
+// FindOrCreateByPhone 被禁用的用户返回 ErrUserDisabled 或者 ErrUserBanned
+func (svc *LocalUserService) FindOrCreateByPhone(ctx context.Context, phone string) (domain.User, error) {
+	u, err := svc.repo.FindByPhone(ctx, phone)
+	if err == repository.ErrUserNotFound {
+		err = svc.repo.Create(ctx, domain.User{Phone: phone})
+		// 冲突了说明别的请求刚好注册了，再查一次就可以
+		if err != nil && err != repository.ErrDuplicateUser {
+			return domain.User{}, err
+		}
+		u, err = svc.repo.FindByPhone(ctx, phone)
+	}
+	if err != nil {
+		return domain.User{}, err
+	}
+	return u, CheckUserStatus(u)
+}
+
+func (svc *LocalUserService) UpdateAvatar(ctx context.Context, id int64, avatar string) error {
+	return svc.repo.UpdateAvatar(ctx, id, avatar)
+}
+
+// UpdateStatus 禁用、封禁或者恢复正常，已经登录的地方要调用方自己让它下线
+func (svc *LocalUserService) UpdateStatus(ctx context.Context, id int64, status domain.UserStatus, reason string) error {
+	return svc.repo.UpdateStatus(ctx, id, status, reason)
+}
+
+// CheckPassword 已经登录了，做敏感操作之前再确认一次密码。
+// 手机号注册的没有设置过密码，也返回 ErrInvalidUserOrPassword
+func (svc *LocalUserService) CheckPassword(ctx context.Context, id int64, password string) error {
+	u, err := svc.repo.FindById(ctx, id)
+	if err != nil {
+		return err
+	}
+	if u.Password == "" {
+		return ErrInvalidUserOrPassword
+	}
+	err = svc.hasher.Verify(u.Password, password)
+	if err == hasher.ErrMismatch {
+		return ErrInvalidUserOrPassword
+	}
+	return err
+}
+
+// Anonymize 注销的时候抹掉个人信息，已经登录的地方要调用方自己让它下线
+func (svc *LocalUserService) Anonymize(ctx context.Context, id int64) error {
+	return svc.repo.Anonymize(ctx, id, "用户注销")
+}
+
+// Search 按邮箱或者手机号的前缀找用户，返回这一页的用户和总数
+func (svc *LocalUserService) Search(ctx context.Context, email string, phone string,
+	offset int, limit int) ([]domain.User, int64, error) {
+	return svc.repo.Search(ctx, email, phone, offset, limit)
+}
+
+// ResetPassword 把密码重置成一个随机的临时密码，返回明文，只有这一次能拿到
+func (svc *LocalUserService) ResetPassword(ctx context.Context, id int64) (string, error) {
+	password, err := randomPassword(16)
+	if err != nil {
+		return "", err
+	}
+	hash, err := svc.hasher.Hash(password)
+	if err != nil {
+		return "", err
+	}
+	return password, svc.repo.UpdatePassword(ctx, id, hash)
+}
+
+// randomPassword 大小写字母、数字和符号都至少有一个，满足密码策略
+func randomPassword(n int) (string, error) {
+	classes := []string{
+		"abcdefghijkmnpqrstuvwxyz",
+		"ABCDEFGHJKLMNPQRSTUVWXYZ",
+		"23456789",
+		"!@#$%^&*-_=+",
+	}
+	all := strings.Join(classes, "")
+	res := make([]byte, n)
+	for i := range res {
+		pool := all
+		if i < len(classes) {
+			pool = classes[i]
+		}
+		k, err := rand.Int(rand.Reader, big.NewInt(int64(len(pool))))
+		if err != nil {
+			return "", err
+		}
+		res[i] = pool[k.Int64()]
+	}
+	// 打乱一下，不然前几位的类型是固定的
+	for i := len(res) - 1; i > 0; i-- {
+		k, err := rand.Int(rand.Reader, big.NewInt(int64(i+1)))
+		if err != nil {
+			return "", err
+		}
+		j := k.Int64()
+		res[i], res[j] = res[j], res[i]
+	}
+	return string(res), nil
+}
+
+// UnlockAccount 管理员手动解锁被锁定的账号
+func (svc *LocalUserService) UnlockAccount(ctx context.Context, email string) error {
+	return svc.guard.Unlock(ctx, email)
+}
+
+func (svc *LocalUserService) fail(ctx context.Context, email string, ip string) {
+	err := svc.guard.Fail(ctx, email, ip)
+	if err != nil {
+		// 记录失败次数失败了，也还是返回密码错误
+		log.Println(err)
+	}
+}
@@ -0,0 +1,335 @@
+package main
+
+import (
+	"basic_go/webook/internal/domain"
+	"basic_go/webook/internal/repository/dao"
+	"basic_go/webook/internal/web"
+	"basic_go/webook/pkg/totp"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testPassword = "hello#world123"
+
+func TestUserSignup(t *testing.T) {
+	app := newTestApp(t)
+	testCases := []struct {
+		name     string
+		email    string
+		password string
+		confirm  string
+		// body 不是 nil 的时候直接发它
+		body     any
+		wantCode int
+		wantBody string
+		// 参数校验不通过的时候返回的是 Result
+		wantErrs []web.FieldError
+	}{
+		{name: "邮箱格式不对", email: "abc", password: testPassword, confirm: testPassword,
+			wantCode: http.StatusOK, wantErrs: []web.FieldError{
+				{Field: "email", Tag: "email", Msg: "email不是合法的邮箱"},
+			}},
+		{name: "两次密码不一样", email: "a@qq.com", password: testPassword, confirm: testPassword + "1",
+			wantCode: http.StatusOK, wantErrs: []web.FieldError{
+				{Field: "confirmPassword", Tag: "eqfield", Msg: "confirmPassword和password不一致"},
+			}},
+		{name: "密码太简单", email: "a@qq.com", password: "hello123", confirm: "hello123",
+			wantCode: http.StatusOK, wantErrs: []web.FieldError{
+				{Field: "password", Tag: "password",
+					Msg: "password不符合要求：至少 8 位，必须包含字母、数字、特殊字符，不能是常见密码，不能包含邮箱"},
+			}},
+		{name: "注册成功", email: "a@qq.com", password: testPassword, confirm: testPassword,
+			wantCode: http.StatusOK, wantBody: "hello 欢迎注册"},
+		{name: "邮箱冲突", email: "a@qq.com", password: testPassword, confirm: testPassword,
+			wantCode: http.StatusOK, wantBody: "邮箱冲突，请换一个"},
+		{name: "请求格式不对", body: "不是 JSON 对象", wantCode: http.StatusBadRequest},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			body := tc.body
+			if body == nil {
+				body = map[string]string{
+					"email":           tc.email,
+					"password":        tc.password,
+					"confirmPassword": tc.confirm,
+				}
+			}
+			resp := app.client().do(http.MethodPost, "/users/signup", body)
+			assert.Equal(t, tc.wantCode, resp.Code)
+			if tc.wantErrs == nil {
+				assert.Equal(t, tc.wantBody, resp.Body.String())
+				return
+			}
+			res := decodeFieldErrors(t, resp.Body.Bytes())
+			assert.Equal(t, 4, res.Code)
+			assert.Equal(t, tc.wantErrs, res.Data)
+		})
+	}
+}
+
+// TestSignupValidationLocale 错误信息按照 Accept-Language 翻译，每个字段一条
+func TestSignupValidationLocale(t *testing.T) {
+	app := newTestApp(t)
+	body := map[string]string{
+		"email":           "abc",
+		"password":        "hello123",
+		"confirmPassword": "hello",
+	}
+	testCases := []struct {
+		name     string
+		lang     string
+		wantMsgs []string
+	}{
+		{name: "默认中文", wantMsgs: []string{
+			"email不是合法的邮箱",
+			"password不符合要求：至少 8 位，必须包含字母、数字、特殊字符，不能是常见密码，不能包含邮箱",
+			"confirmPassword和password不一致",
+		}},
+		{name: "英文", lang: "en-US,en;q=0.9", wantMsgs: []string{
+			"email must be a valid email address",
+			"password must be at least 8 characters, contain letters, digits, symbols, " +
+				"not be a common password, not contain the email",
+			"confirmPassword does not match password",
+		}},
+		{name: "中文优先", lang: "zh-CN,zh;q=0.9,en;q=0.8", wantMsgs: []string{
+			"email不是合法的邮箱",
+			"password不符合要求：至少 8 位，必须包含字母、数字、特殊字符，不能是常见密码，不能包含邮箱",
+			"confirmPassword和password不一致",
+		}},
+		{name: "不支持的语言用中文", lang: "fr-FR", wantMsgs: []string{
+			"email不是合法的邮箱",
+			"password不符合要求：至少 8 位，必须包含字母、数字、特殊字符，不能是常见密码，不能包含邮箱",
+			"confirmPassword和password不一致",
+		}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := app.client()
+			c.lang = tc.lang
+			res := decodeFieldErrors(t, c.do(http.MethodPost, "/users/signup", body).Body.Bytes())
+			assert.Equal(t, 4, res.Code)
+			var msgs []string
+			for _, e := range res.Data {
+				msgs = append(msgs, e.Msg)
+			}
+			assert.Equal(t, tc.wantMsgs, msgs)
+			assert.Equal(t, []string{"email", "password", "confirmPassword"},
+				[]string{res.Data[0].Field, res.Data[1].Field, res.Data[2].Field})
+		})
+	}
+}
+
+type fieldErrorsResult struct {
+	Code int              `json:"code"`
+	Msg  string           `json:"msg"`
+	Data []web.FieldError `json:"data"`
+}
+
+func decodeFieldErrors(t *testing.T, body []byte) fieldErrorsResult {
+	var res fieldErrorsResult
+	require.NoError(t, json.Unmarshal(body, &res), string(body))
+	return res
+}
+
+func TestUserLogin(t *testing.T) {
+	app := newTestApp(t)
+	require.Equal(t, "hello 欢迎注册", app.client().signup("a@qq.com", testPassword).Body.String())
+	testCases := []struct {
+		name      string
+		email     string
+		password  string
+		wantBody  string
+		wantToken bool
+	}{
+		{name: "登录成功", email: "a@qq.com", password: testPassword, wantBody: "登录成功", wantToken: true},
+		{name: "密码不对", email: "a@qq.com", password: testPassword + "1", wantBody: "用户名或者密码错误"},
+		{name: "用户不存在", email: "b@qq.com", password: testPassword, wantBody: "用户名或者密码错误"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := app.client()
+			resp := c.login(tc.email, tc.password)
+			assert.Equal(t, http.StatusOK, resp.Code)
+			assert.Equal(t, tc.wantBody, resp.Body.String())
+			assert.Equal(t, tc.wantToken, c.token != "")
+		})
+	}
+}
+
+func TestUserProfile(t *testing.T) {
+	app := newTestApp(t)
+	// 没登录
+	resp := app.client().do(http.MethodGet, "/users/profile", nil)
+	assert.Equal(t, http.StatusUnauthorized, resp.Code)
+	// token 是乱写的
+	c := app.client()
+	c.token = "abc"
+	resp = c.do(http.MethodGet, "/users/profile", nil)
+	assert.Equal(t, http.StatusUnauthorized, resp.Code)
+
+	c = app.mustLogin("a@qq.com", testPassword)
+	resp = c.do(http.MethodGet, "/users/profile", nil)
+	require.Equal(t, http.StatusOK, resp.Code)
+	var res struct {
+		Code int
+		Data web.ProfileVO
+	}
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &res))
+	assert.Equal(t, 0, res.Code)
+	assert.Equal(t, "a@qq.com", res.Data.Email)
+}
+
+// TestLoginAfterRevoke 被强制下线之后马上重新登录，新的 token 可以用
+func TestLoginAfterRevoke(t *testing.T) {
+	app := newTestApp(t)
+	admin := app.mustLogin("admin@qq.com", testPassword)
+	require.NoError(t, app.db.Model(&dao.User{}).Where("email = ?", "admin@qq.com").
+		Update("role", uint8(domain.UserRoleAdmin)).Error)
+	c := app.mustLogin("a@qq.com", testPassword)
+	var u dao.User
+	require.NoError(t, app.db.Where("email = ?", "a@qq.com").First(&u).Error)
+
+	resp := admin.do(http.MethodPost, "/admin/users/reset_password", map[string]any{"id": u.Id, "reason": "测试"})
+	require.Equal(t, http.StatusOK, resp.Code)
+	var res struct {
+		Code int
+		Data struct {
+			Password string `json:"password"`
+		}
+	}
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &res))
+	require.Equal(t, 0, res.Code)
+	// 原来的登录态失效了
+	resp = c.do(http.MethodGet, "/users/profile", nil)
+	assert.Equal(t, http.StatusUnauthorized, resp.Code)
+
+	c = app.client()
+	resp = c.login("a@qq.com", res.Data.Password)
+	require.Equal(t, "登录成功", resp.Body.String())
+	resp = c.do(http.MethodGet, "/users/profile", nil)
+	assert.Equal(t, http.StatusOK, resp.Code)
+}
+
+// TestLoginBackoff 连续输错密码之后要等一等，密码对了也不行
+func TestLoginBackoff(t *testing.T) {
+	app := newTestApp(t)
+	c := app.mustLogin("a@qq.com", testPassword)
+	for i := 0; i < 3; i++ {
+		resp := c.login("a@qq.com", "wrong#password123")
+		require.Equal(t, "用户名或者密码错误", resp.Body.String())
+	}
+	resp := c.login("a@qq.com", testPassword)
+	assert.Equal(t, "登录过于频繁，请稍后再试", resp.Body.String())
+}
+
+// TestLoginTwoFactorBanned 密码校验过了，输验证码之前被封禁了，第二步不能登录
+func TestLoginTwoFactorBanned(t *testing.T) {
+	app := newTestApp(t)
+	admin := app.mustLogin("admin@qq.com", testPassword)
+	require.NoError(t, app.db.Model(&dao.User{}).Where("email = ?", "admin@qq.com").
+		Update("role", uint8(domain.UserRoleAdmin)).Error)
+	c := app.mustLogin("a@qq.com", testPassword)
+	resp := c.do(http.MethodPost, "/users/2fa/enroll", nil)
+	var enroll struct {
+		Code int
+		Data struct {
+			Secret        string   `json:"secret"`
+			RecoveryCodes []string `json:"recoveryCodes"`
+		}
+	}
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &enroll))
+	require.Equal(t, 0, enroll.Code)
+	code, err := totp.New(time.Now).Code(enroll.Data.Secret, time.Now())
+	require.NoError(t, err)
+	resp = c.do(http.MethodPost, "/users/2fa/activate", map[string]string{"code": code})
+	require.Contains(t, resp.Body.String(), "二次验证已开启")
+
+	c = app.client()
+	resp = c.login("a@qq.com", testPassword)
+	require.Equal(t, "请输入二次验证码", resp.Body.String())
+	token := resp.Header().Get("x-2fa-token")
+	require.NotEmpty(t, token)
+
+	var u dao.User
+	require.NoError(t, app.db.Where("email = ?", "a@qq.com").First(&u).Error)
+	resp = admin.do(http.MethodPost, "/admin/users/ban", map[string]any{"id": u.Id, "reason": "测试"})
+	require.Contains(t, resp.Body.String(), `"code":0`)
+
+	resp = c.do(http.MethodPost, "/users/login/2fa", map[string]string{
+		"token": token,
+		"code":  enroll.Data.RecoveryCodes[0],
+	})
+	assert.Equal(t, "账号已被封禁", resp.Body.String())
+	assert.Empty(t, c.token)
+	resp = c.do(http.MethodGet, "/users/profile", nil)
+	assert.Equal(t, http.StatusUnauthorized, resp.Code)
+}
+
+func TestTokenRefresh(t *testing.T) {
+	app := newTestApp(t)
+	c := app.mustLogin("a@qq.com", testPassword)
+	uc := parseToken(t, c.token)
+	issuedAt := uc.IssuedAt.Time
+
+	// 刚登录的 token 不用刷新
+	resp := c.do(http.MethodGet, "/users/profile", nil)
+	require.Equal(t, http.StatusOK, resp.Code)
+	assert.Empty(t, resp.Header().Get("x-jwt-token"))
+
+	// 快过期了的 token 会刷新，登录时间不变
+	uc.ExpiresAt = jwt.NewNumericDate(time.Now().Add(time.Second * 30))
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS512, uc).SignedString(web.JWTKey)
+	require.NoError(t, err)
+	c.token = token
+	resp = c.do(http.MethodGet, "/users/profile", nil)
+	require.Equal(t, http.StatusOK, resp.Code)
+	require.NotEmpty(t, resp.Header().Get("x-jwt-token"))
+	refreshed := parseToken(t, c.token)
+	assert.True(t, refreshed.ExpiresAt.After(uc.ExpiresAt.Time))
+	assert.True(t, refreshed.IssuedAt.Equal(issuedAt))
+	assert.Equal(t, uc.Uid, refreshed.Uid)
+
+	// 过期了的不能用
+	uc.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Second))
+	token, err = jwt.NewWithClaims(jwt.SigningMethodHS512, uc).SignedString(web.JWTKey)
+	require.NoError(t, err)
+	c.token = token
+	resp = c.do(http.MethodGet, "/users/profile", nil)
+	assert.Equal(t, http.StatusUnauthorized, resp.Code)
+}
+
+func parseToken(t *testing.T, token string) web.UserClaims {
+	var uc web.UserClaims
+	_, err := jwt.ParseWithClaims(token, &uc, func(token *jwt.Token) (interface{}, error) {
+		return web.JWTKey, nil
+	})
+	require.NoError(t, err)
+	return uc
+}
+
+func TestSessionAuth(t *testing.T) {
+	for _, store := range []string{"cookie", "memory", "redis", "gorm"} {
+		t.Run(store, func(t *testing.T) {
+			app := newTestAppWithAuth(t, authConfig{Mode: web.AuthModeSession, SessionStore: store})
+			resp := app.client().do(http.MethodGet, "/users/profile", nil)
+			assert.Equal(t, http.StatusUnauthorized, resp.Code)
+
+			c := app.mustLogin("a@qq.com", testPassword)
+			// session 模式不返回 token
+			assert.Empty(t, c.token)
+			resp = c.do(http.MethodGet, "/users/profile", nil)
+			require.Equal(t, http.StatusOK, resp.Code)
+			var res struct {
+				Data web.ProfileVO
+			}
+			require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &res))
+			assert.Equal(t, "a@qq.com", res.Data.Email)
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package web
+
+import (
+	"basic_go/webook/internal/domain"
+	"basic_go/webook/internal/service"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AccountHandler struct {
+	svc *service.AccountService
+}
+
+func NewAccountHandler(svc *service.AccountService) *AccountHandler {
+	return &AccountHandler{
+		svc: svc,
+	}
+}
+
+func (h *AccountHandler) RegisterRoutes(server *gin.Engine) {
+	g := server.Group("/account")
+	g.GET("/balance", h.Balance)
+	g.POST("/statement", h.Statement)
+}
+
+var accountOps = []Operation{
+	{Tag: "账户", Method: http.MethodGet, Path: "/account/balance", Summary: "余额，单位是分",
+		Resp: struct {
+			Balance int64 `json:"balance"`
+		}{}},
+	{Tag: "账户", Method: http.MethodPost, Path: "/account/statement", Summary: "流水", Req: StatementReq{}, Resp: []AccountEntryVO{}},
+}
+
+type AccountEntryVO struct {
+	Id    int64  `json:"id"`
+	Biz   string `json:"biz"`
+	BizId int64  `json:"bizId"`
+	// debit 或者 credit
+	Direction string `json:"direction"`
+	// 单位是分
+	Amount  int64  `json:"amount"`
+	Balance int64  `json:"balance"`
+	Memo    string `json:"memo"`
+	Ctime   string `json:"ctime"`
+}
+
+func (h *AccountHandler) Balance(ctx *gin.Context) {
+	uid := CurrentUid(ctx)
+	balance, err := h.svc.Balance(ctx.Request.Context(), uid)
+	if err != nil {
+		log.Println("查询余额失败", err)
+		ctx.JSON(http.StatusOK, Result{Code: 5, Msg: "系统错误"})
+		return
+	}
+	ctx.JSON(http.StatusOK, Result{Data: gin.H{
+		"balance": balance,
+	}})
+}
+
+type StatementReq struct {
+	StartDate string `json:"startDate"`
+	EndDate   string `json:"endDate"`
+	MaxId     int64  `json:"maxId"`
+	Limit     int    `json:"limit"`
+}
+
+// Statement 查自己的流水。日期是 2006-01-02 的格式，两头都包含，不传就不限制
+func (h *AccountHandler) Statement(ctx *gin.Context) {
+	var req StatementReq
+	if !bind(ctx, &req) {
+		return
+	}
+	if req.Limit <= 0 || req.Limit > 100 {
+		req.Limit = 20
+	}
+	q := domain.StatementQuery{MaxId: req.MaxId, Limit: req.Limit}
+	var err error
+	if req.StartDate != "" {
+		q.Start, err = time.ParseInLocation(time.DateOnly, req.StartDate, time.Local)
+		if err != nil {
+			ctx.JSON(http.StatusOK, Result{Code: 4, Msg: "日期格式不对"})
+			return
+		}
+	}
+	if req.EndDate != "" {
+		q.End, err = time.ParseInLocation(time.DateOnly, req.EndDate, time.Local)
+		if err != nil {
+			ctx.JSON(http.StatusOK, Result{Code: 4, Msg: "日期格式不对"})
+			return
+		}
+		// 包含结束的那一天
+		q.End = q.End.AddDate(0, 0, 1)
+	}
+	uid := CurrentUid(ctx)
+	es, err := h.svc.Statement(ctx.Request.Context(), uid, q)
+	if err != nil {
+		log.Println("查询流水失败", err)
+		ctx.JSON(http.StatusOK, Result{Code: 5, Msg: "系统错误"})
+		return
+	}
+	vos := make([]AccountEntryVO, 0, len(es))
+	for _, e := range es {
+		vos = append(vos, AccountEntryVO{
+			Id:        e.Id,
+			Biz:       e.Biz,
+			BizId:     e.BizId,
+			Direction: e.Direction.String(),
+			Amount:    e.Amount,
+			Balance:   e.Balance,
+			Memo:      e.Memo,
+			Ctime:     e.Ctime.Format(time.DateTime),
+		})
+	}
+	ctx.JSON(http.StatusOK, Result{Data: vos})
+}
@@ -0,0 +1,246 @@
+package web
+
+import (
+	"basic_go/webook/internal/domain"
+	"basic_go/webook/internal/service"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AdminHandler struct {
+	svc *service.AdminService
+}
+
+func NewAdminHandler(svc *service.AdminService) *AdminHandler {
+	return &AdminHandler{
+		svc: svc,
+	}
+}
+
+func (h *AdminHandler) RegisterRoutes(server *gin.Engine) {
+	g := server.Group("/admin", h.CheckAdmin)
+	g.POST("/users/search", h.SearchUsers)
+	g.POST("/users/disable", h.updateStatus(domain.UserStatusDisabled))
+	g.POST("/users/ban", h.updateStatus(domain.UserStatusBanned))
+	g.POST("/users/enable", h.updateStatus(domain.UserStatusActive))
+	g.POST("/users/reset_password", h.ResetPassword)
+	g.POST("/users/unlock", h.Unlock)
+	g.POST("/audit_logs", h.AuditLogs)
+}
+
+var adminOps = []Operation{
+	{Tag: "管理后台", Method: http.MethodPost, Path: "/admin/users/search", Summary: "搜索用户", Req: AdminSearchUsersReq{},
+		Resp: struct {
+			Total int64         `json:"total"`
+			Users []AdminUserVO `json:"users"`
+		}{}},
+	{Tag: "管理后台", Method: http.MethodPost, Path: "/admin/users/disable", Summary: "禁用账号", Req: adminUserReq{}},
+	{Tag: "管理后台", Method: http.MethodPost, Path: "/admin/users/ban", Summary: "封禁账号", Req: adminUserReq{}},
+	{Tag: "管理后台", Method: http.MethodPost, Path: "/admin/users/enable", Summary: "恢复账号", Req: adminUserReq{}},
+	{Tag: "管理后台", Method: http.MethodPost, Path: "/admin/users/reset_password", Summary: "重置密码，返回新的随机密码", Req: adminUserReq{},
+		Resp: struct {
+			Password string `json:"password"`
+		}{}},
+	{Tag: "管理后台", Method: http.MethodPost, Path: "/admin/users/unlock", Summary: "解除登录失败导致的锁定", Req: adminUserReq{}},
+	{Tag: "管理后台", Method: http.MethodPost, Path: "/admin/audit_logs", Summary: "操作日志", Req: AuditLogReq{}, Resp: []AuditLogVO{}},
+}
+
+// CheckAdmin 登录校验之后再判断是不是管理员
+func (h *AdminHandler) CheckAdmin(ctx *gin.Context) {
+	uid := CurrentUid(ctx)
+	ok, err := h.svc.IsAdmin(ctx.Request.Context(), uid)
+	if err != nil {
+		log.Println("查询管理员失败", err)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		ctx.AbortWithStatus(http.StatusForbidden)
+		return
+	}
+}
+
+type AdminUserVO struct {
+	Id           int64  `json:"id"`
+	Email        string `json:"email"`
+	Phone        string `json:"phone"`
+	Role         uint8  `json:"role"`
+	Status       uint8  `json:"status"`
+	StatusReason string `json:"statusReason"`
+	Ctime        string `json:"ctime"`
+}
+
+type AdminSearchUsersReq struct {
+	Email  string `json:"email"`
+	Phone  string `json:"phone" binding:"omitempty,phone"`
+	Offset int    `json:"offset"`
+	Limit  int    `json:"limit"`
+}
+
+// SearchUsers 按邮箱或者手机号的前缀找，都不传就是所有用户
+func (h *AdminHandler) SearchUsers(ctx *gin.Context) {
+	var req AdminSearchUsersReq
+	if !bind(ctx, &req) {
+		return
+	}
+	if req.Limit <= 0 || req.Limit > 100 {
+		req.Limit = 20
+	}
+	us, total, err := h.svc.SearchUsers(ctx.Request.Context(), req.Email, req.Phone, req.Offset, req.Limit)
+	if err != nil {
+		log.Println("查找用户失败", err)
+		ctx.JSON(http.StatusOK, Result{Code: 5, Msg: "系统错误"})
+		return
+	}
+	vos := make([]AdminUserVO, 0, len(us))
+	for _, u := range us {
+		vos = append(vos, AdminUserVO{
+			Id:           u.Id,
+			Email:        u.Email,
+			Phone:        u.Phone,
+			Role:         uint8(u.Role),
+			Status:       uint8(u.Status),
+			StatusReason: u.StatusReason,
+			Ctime:        u.Ctime.Format(time.DateTime),
+		})
+	}
+	ctx.JSON(http.StatusOK, Result{Data: gin.H{
+		"total": total,
+		"users": vos,
+	}})
+}
+
+type adminUserReq struct {
+	Id     int64  `json:"id"`
+	Reason string `json:"reason"`
+}
+
+func (h *AdminHandler) updateStatus(status domain.UserStatus) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req adminUserReq
+		if !bind(ctx, &req) {
+			return
+		}
+		if req.Reason == "" && status != domain.UserStatusActive {
+			ctx.JSON(http.StatusOK, Result{Code: 4, Msg: "要填写原因"})
+			return
+		}
+		err := h.svc.UpdateUserStatus(ctx.Request.Context(), h.operator(ctx), req.Id, status, req.Reason)
+		if err != nil {
+			h.handleErr(ctx, err, "修改用户状态失败")
+			return
+		}
+		ctx.JSON(http.StatusOK, Result{Msg: "OK"})
+	}
+}
+
+// ResetPassword 返回临时密码，管理员自己告诉用户
+func (h *AdminHandler) ResetPassword(ctx *gin.Context) {
+	var req adminUserReq
+	if !bind(ctx, &req) {
+		return
+	}
+	password, err := h.svc.ResetPassword(ctx.Request.Context(), h.operator(ctx), req.Id, req.Reason)
+	if err != nil {
+		h.handleErr(ctx, err, "重置密码失败")
+		return
+	}
+	ctx.JSON(http.StatusOK, Result{Data: gin.H{
+		"password": password,
+	}})
+}
+
+func (h *AdminHandler) Unlock(ctx *gin.Context) {
+	var req adminUserReq
+	if !bind(ctx, &req) {
+		return
+	}
+	err := h.svc.UnlockUser(ctx.Request.Context(), h.operator(ctx), req.Id, req.Reason)
+	if err != nil {
+		h.handleErr(ctx, err, "解锁账号失败")
+		return
+	}
+	ctx.JSON(http.StatusOK, Result{Msg: "OK"})
+}
+
+type AuditLogVO struct {
+	Id         int64          `json:"id"`
+	OperatorId int64          `json:"operatorId"`
+	TargetType string         `json:"targetType"`
+	TargetId   int64          `json:"targetId"`
+	Action     string         `json:"action"`
+	Reason     string         `json:"reason"`
+	Detail     map[string]any `json:"detail"`
+	Ip         string         `json:"ip"`
+	Ctime      string         `json:"ctime"`
+}
+
+type AuditLogReq struct {
+	OperatorId int64 `json:"operatorId"`
+	TargetId   int64 `json:"targetId"`
+	MaxId      int64 `json:"maxId"`
+	Limit      int   `json:"limit"`
+}
+
+// AuditLogs 按操作人或者操作对象查，都不传就是所有的
+func (h *AdminHandler) AuditLogs(ctx *gin.Context) {
+	var req AuditLogReq
+	if !bind(ctx, &req) {
+		return
+	}
+	if req.Limit <= 0 || req.Limit > 100 {
+		req.Limit = 20
+	}
+	ls, err := h.svc.AuditLogs(ctx.Request.Context(), domain.AuditLogQuery{
+		OperatorId: req.OperatorId,
+		TargetType: domain.AuditTargetUser,
+		TargetId:   req.TargetId,
+		MaxId:      req.MaxId,
+		Limit:      req.Limit,
+	})
+	if err != nil {
+		log.Println("查询审计日志失败", err)
+		ctx.JSON(http.StatusOK, Result{Code: 5, Msg: "系统错误"})
+		return
+	}
+	vos := make([]AuditLogVO, 0, len(ls))
+	for _, l := range ls {
+		vos = append(vos, AuditLogVO{
+			Id:         l.Id,
+			OperatorId: l.OperatorId,
+			TargetType: l.TargetType,
+			TargetId:   l.TargetId,
+			Action:     l.Action,
+			Reason:     l.Reason,
+			Detail:     l.Detail,
+			Ip:         l.Ip,
+			Ctime:      l.Ctime.Format(time.DateTime),
+		})
+	}
+	ctx.JSON(http.StatusOK, Result{Data: vos})
+}
+
+func (h *AdminHandler) operator(ctx *gin.Context) service.AdminOperator {
+	uid := CurrentUid(ctx)
+	return service.AdminOperator{
+		Uid: uid,
+		Ip:  ctx.ClientIP(),
+	}
+}
+
+func (h *AdminHandler) handleErr(ctx *gin.Context, err error, msg string) {
+	switch err {
+	case service.ErrUserNotFound:
+		ctx.JSON(http.StatusOK, Result{Code: 4, Msg: "用户不存在"})
+	case service.ErrOperateSelf:
+		ctx.JSON(http.StatusOK, Result{Code: 4, Msg: "不能对自己操作"})
+	case service.ErrUserDeleted:
+		ctx.JSON(http.StatusOK, Result{Code: 4, Msg: "用户已注销"})
+	default:
+		log.Println(msg, err)
+		ctx.JSON(http.StatusOK, Result{Code: 5, Msg: "系统错误"})
+	}
+}
@@ -0,0 +1,295 @@
+package web
+
+import (
+	"basic_go/webook/internal/domain"
+	"basic_go/webook/internal/service"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ArticleHandler struct {
+	svc      *service.ArticleService
+	interSvc *service.InteractiveService
+	// 文章里面引用的图片要标记成用上了，不然会被当成没用的文件清理掉
+	uploadSvc *service.UploadService
+	biz       string
+}
+
+func NewArticleHandler(svc *service.ArticleService, interSvc *service.InteractiveService,
+	uploadSvc *service.UploadService) *ArticleHandler {
+	return &ArticleHandler{
+		svc:       svc,
+		interSvc:  interSvc,
+		uploadSvc: uploadSvc,
+		biz:       "article",
+	}
+}
+
+func (h *ArticleHandler) RegisterRoutes(server *gin.Engine) {
+	g := server.Group("/articles")
+	// 作者用的
+	g.POST("/edit", h.Edit)
+	g.POST("/publish", h.Publish)
+	g.POST("/withdraw", h.Withdraw)
+	g.POST("/list", h.List)
+	g.GET("/detail/:id", h.Detail)
+
+	// 读者用的
+	pub := g.Group("/pub")
+	pub.GET("/:id", h.PubDetail)
+	pub.POST("/like", h.Like)
+}
+
+var articleOps = []Operation{
+	{Tag: "文章", Method: http.MethodPost, Path: "/articles/edit", Summary: "保存草稿，返回文章 ID", Req: ArticleReq{}, Resp: int64(0)},
+	{Tag: "文章", Method: http.MethodPost, Path: "/articles/publish", Summary: "发表，返回文章 ID", Req: ArticleReq{}, Resp: int64(0)},
+	{Tag: "文章", Method: http.MethodPost, Path: "/articles/withdraw", Summary: "撤回", Req: ArticleWithdrawReq{}},
+	{Tag: "文章", Method: http.MethodPost, Path: "/articles/list", Summary: "作者自己的文章列表", Req: ArticleListReq{}, Resp: []ArticleVO{}},
+	{Tag: "文章", Method: http.MethodGet, Path: "/articles/detail/:id", Summary: "作者看自己的文章", Resp: ArticleVO{}},
+	{Tag: "文章", Method: http.MethodGet, Path: "/articles/pub/:id", Summary: "读者看已发表的文章", Resp: ArticleVO{}},
+	{Tag: "文章", Method: http.MethodPost, Path: "/articles/pub/like", Summary: "点赞或者取消点赞", Req: LikeReq{}},
+}
+
+type ArticleReq struct {
+	Id      int64  `json:"id"`
+	Title   string `json:"title"`
+	Content string `json:"content"`
+}
+
+func (req ArticleReq) toDomain(uid int64) domain.Article {
+	return domain.Article{
+		Id:      req.Id,
+		Title:   req.Title,
+		Content: req.Content,
+		Author: domain.Author{
+			Id: uid,
+		},
+	}
+}
+
+// ArticleVO 返回给前端的文章
+type ArticleVO struct {
+	Id       int64  `json:"id"`
+	Title    string `json:"title"`
+	Abstract string `json:"abstract"`
+	Content  string `json:"content"`
+	AuthorId int64  `json:"authorId"`
+	Status   uint8  `json:"status"`
+	Ctime    string `json:"ctime"`
+	Utime    string `json:"utime"`
+
+	ReadCnt    int64 `json:"readCnt"`
+	LikeCnt    int64 `json:"likeCnt"`
+	CollectCnt int64 `json:"collectCnt"`
+	CommentCnt int64 `json:"commentCnt"`
+	// 当前用户有没有点赞
+	Liked bool `json:"liked"`
+}
+
+func newArticleVO(art domain.Article) ArticleVO {
+	return ArticleVO{
+		Id:       art.Id,
+		Title:    art.Title,
+		Abstract: art.Abstract(),
+		Content:  art.Content,
+		AuthorId: art.Author.Id,
+		Status:   art.Status.ToUint8(),
+		Ctime:    art.Ctime.Format(time.DateTime),
+		Utime:    art.Utime.Format(time.DateTime),
+	}
+}
+
+func (h *ArticleHandler) Edit(ctx *gin.Context) {
+	var req ArticleReq
+	if !bind(ctx, &req) {
+		return
+	}
+	uid := CurrentUid(ctx)
+	id, err := h.svc.Save(ctx.Request.Context(), req.toDomain(uid))
+	switch err {
+	case nil:
+		h.attachImages(ctx, uid, id, req.Content)
+		ctx.JSON(http.StatusOK, Result{Data: id})
+	case service.ErrArticleNotFound:
+		ctx.JSON(http.StatusOK, Result{Code: 4, Msg: "文章不存在"})
+	default:
+		log.Println("保存文章失败", err)
+		ctx.JSON(http.StatusOK, Result{Code: 5, Msg: "系统错误"})
+	}
+}
+
+func (h *ArticleHandler) Publish(ctx *gin.Context) {
+	var req ArticleReq
+	if !bind(ctx, &req) {
+		return
+	}
+	uid := CurrentUid(ctx)
+	id, err := h.svc.Publish(ctx.Request.Context(), req.toDomain(uid))
+	switch err {
+	case nil:
+		h.attachImages(ctx, uid, id, req.Content)
+		ctx.JSON(http.StatusOK, Result{Data: id})
+	case service.ErrArticleNotFound:
+		ctx.JSON(http.StatusOK, Result{Code: 4, Msg: "文章不存在"})
+	default:
+		log.Println("发表文章失败", err)
+		ctx.JSON(http.StatusOK, Result{Code: 5, Msg: "系统错误"})
+	}
+}
+
+// attachImages 失败了不影响保存文章，只是图片有可能被清理掉
+func (h *ArticleHandler) attachImages(ctx *gin.Context, uid int64, aid int64, content string) {
+	err := h.uploadSvc.AttachContent(ctx.Request.Context(), uid, domain.UploadBizArticle, aid, content)
+	if err != nil {
+		log.Println("标记文章图片失败", aid, err)
+	}
+}
+
+type ArticleWithdrawReq struct {
+	Id int64 `json:"id"`
+}
+
+func (h *ArticleHandler) Withdraw(ctx *gin.Context) {
+	var req ArticleWithdrawReq
+	if !bind(ctx, &req) {
+		return
+	}
+	uid := CurrentUid(ctx)
+	err := h.svc.Withdraw(ctx.Request.Context(), uid, req.Id)
+	switch err {
+	case nil:
+		ctx.JSON(http.StatusOK, Result{Msg: "OK"})
+	case service.ErrArticleNotFound:
+		ctx.JSON(http.StatusOK, Result{Code: 4, Msg: "文章不存在"})
+	default:
+		log.Println("撤回文章失败", err)
+		ctx.JSON(http.StatusOK, Result{Code: 5, Msg: "系统错误"})
+	}
+}
+
+type ArticleListReq struct {
+	Offset int `json:"offset"`
+	Limit  int `json:"limit"`
+}
+
+func (h *ArticleHandler) List(ctx *gin.Context) {
+	var req ArticleListReq
+	if !bind(ctx, &req) {
+		return
+	}
+	if req.Limit <= 0 || req.Limit > 100 {
+		req.Limit = 100
+	}
+	uid := CurrentUid(ctx)
+	arts, err := h.svc.GetByAuthor(ctx.Request.Context(), uid, req.Offset, req.Limit)
+	if err != nil {
+		log.Println("查找文章列表失败", err)
+		ctx.JSON(http.StatusOK, Result{Code: 5, Msg: "系统错误"})
+		return
+	}
+	vos := make([]ArticleVO, 0, len(arts))
+	for _, art := range arts {
+		vo := newArticleVO(art)
+		// 列表页不需要内容
+		vo.Content = ""
+		vos = append(vos, vo)
+	}
+	ctx.JSON(http.StatusOK, Result{Data: vos})
+}
+
+// Detail 作者查看自己的文章
+func (h *ArticleHandler) Detail(ctx *gin.Context) {
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusOK, Result{Code: 4, Msg: "参数错误"})
+		return
+	}
+	art, err := h.svc.GetById(ctx.Request.Context(), id)
+	uid := CurrentUid(ctx)
+	// 不是自己的文章，也当作不存在
+	if err == service.ErrArticleNotFound || (err == nil && art.Author.Id != uid) {
+		ctx.JSON(http.StatusOK, Result{Code: 4, Msg: "文章不存在"})
+		return
+	}
+	if err != nil {
+		log.Println("查找文章失败", err)
+		ctx.JSON(http.StatusOK, Result{Code: 5, Msg: "系统错误"})
+		return
+	}
+	ctx.JSON(http.StatusOK, Result{Data: newArticleVO(art)})
+}
+
+// PubDetail 读者看文章
+func (h *ArticleHandler) PubDetail(ctx *gin.Context) {
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusOK, Result{Code: 4, Msg: "参数错误"})
+		return
+	}
+	uid := CurrentUid(ctx)
+	art, err := h.svc.GetPubById(ctx.Request.Context(), id, uid)
+	switch err {
+	case nil:
+	case service.ErrArticleNotFound:
+		ctx.JSON(http.StatusOK, Result{Code: 4, Msg: "文章不存在"})
+		return
+	default:
+		log.Println("查找文章失败", err)
+		ctx.JSON(http.StatusOK, Result{Code: 5, Msg: "系统错误"})
+		return
+	}
+	vo := newArticleVO(art)
+	intr, err := h.interSvc.Get(ctx.Request.Context(), h.biz, id)
+	if err != nil {
+		// 互动数据拿不到，文章还是可以看的
+		log.Println("查找互动数据失败", err)
+	}
+	vo.ReadCnt = intr.ReadCnt
+	vo.LikeCnt = intr.LikeCnt
+	vo.CollectCnt = intr.CollectCnt
+	vo.CommentCnt = intr.CommentCnt
+	vo.Liked, err = h.interSvc.Liked(ctx.Request.Context(), h.biz, id, uid)
+	if err != nil {
+		log.Println("查找点赞状态失败", err)
+	}
+	ctx.JSON(http.StatusOK, Result{Data: vo})
+}
+
+type LikeReq struct {
+	Id int64 `json:"id"`
+	// true 是点赞，false 是取消点赞
+	Like bool `json:"like"`
+}
+
+// Like 点赞或者取消点赞
+func (h *ArticleHandler) Like(ctx *gin.Context) {
+	var req LikeReq
+	if !bind(ctx, &req) {
+		return
+	}
+	uid := CurrentUid(ctx)
+	var err error
+	if req.Like {
+		// 只能给已发表的文章点赞，顺便拿到作者
+		var art domain.Article
+		art, err = h.svc.GetPublished(ctx.Request.Context(), req.Id)
+		if err == nil {
+			err = h.interSvc.Like(ctx.Request.Context(), h.biz, req.Id, uid, art.Author.Id)
+		}
+	} else {
+		err = h.interSvc.CancelLike(ctx.Request.Context(), h.biz, req.Id, uid)
+	}
+	switch err {
+	case nil:
+		ctx.JSON(http.StatusOK, Result{Msg: "OK"})
+	case service.ErrArticleNotFound:
+		ctx.JSON(http.StatusOK, Result{Code: 4, Msg: "文章不存在"})
+	default:
+		log.Println("点赞失败", err)
+		ctx.JSON(http.StatusOK, Result{Code: 5, Msg: "系统错误"})
+	}
+}
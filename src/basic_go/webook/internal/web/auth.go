@@ -0,0 +1,36 @@
+package web
+
+import "github.com/gin-gonic/gin"
+
+// AuthMode 登录态怎么保持
+type AuthMode string
+
+const (
+	// AuthModeJWT 登录之后在 x-jwt-token 头部返回 token，之后的请求放在 Authorization 里面
+	AuthModeJWT AuthMode = "jwt"
+	// AuthModeSession 登录之后用 cookie 里面的 session
+	AuthModeSession AuthMode = "session"
+)
+
+// uidKey 登录校验通过之后，当前用户的 ID 放在 gin.Context 里面的 key
+const uidKey = "uid"
+
+// SetCurrentUid 登录校验的 middleware 调用，不管是哪种模式都放在同一个地方
+func SetCurrentUid(ctx *gin.Context, uid int64) {
+	ctx.Set(uidKey, uid)
+}
+
+// CurrentUid 当前登录的用户，handler 不用管是 JWT 还是 session。
+// 只能在需要登录的接口里面用，没登录的话会 panic
+func CurrentUid(ctx *gin.Context) int64 {
+	return ctx.MustGet(uidKey).(int64)
+}
+
+// TryCurrentUid 不需要登录的接口也会经过的 middleware 用，没登录返回 false
+func TryCurrentUid(ctx *gin.Context) (int64, bool) {
+	uid, ok := ctx.Get(uidKey)
+	if !ok {
+		return 0, false
+	}
+	return uid.(int64), true
+}
@@ -0,0 +1,190 @@
+package web
+
+import (
+	"basic_go/webook/internal/domain"
+	"basic_go/webook/internal/service"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 评论最长多少个字
+const maxCommentLength = 1000
+
+type CommentHandler struct {
+	svc *service.CommentService
+}
+
+func NewCommentHandler(svc *service.CommentService) *CommentHandler {
+	return &CommentHandler{
+		svc: svc,
+	}
+}
+
+func (h *CommentHandler) RegisterRoutes(server *gin.Engine) {
+	g := server.Group("/comments")
+	g.POST("/create", h.Create)
+	g.POST("/delete", h.Delete)
+	g.POST("/list", h.List)
+	g.POST("/replies", h.Replies)
+}
+
+var commentOps = []Operation{
+	{Tag: "评论", Method: http.MethodPost, Path: "/comments/create", Summary: "发评论，返回评论 ID", Req: CommentCreateReq{}, Resp: int64(0)},
+	{Tag: "评论", Method: http.MethodPost, Path: "/comments/delete", Summary: "删除自己的评论", Req: CommentDeleteReq{}},
+	{Tag: "评论", Method: http.MethodPost, Path: "/comments/list", Summary: "根评论列表", Req: CommentListReq{}, Resp: []CommentVO{}},
+	{Tag: "评论", Method: http.MethodPost, Path: "/comments/replies", Summary: "加载更多回复", Req: CommentRepliesReq{}, Resp: []CommentVO{}},
+}
+
+type CommentVO struct {
+	Id       int64       `json:"id"`
+	Uid      int64       `json:"uid"`
+	RootId   int64       `json:"rootId"`
+	ParentId int64       `json:"parentId"`
+	Content  string      `json:"content"`
+	Ctime    string      `json:"ctime"`
+	Replies  []CommentVO `json:"replies,omitempty"`
+	ReplyCnt int64       `json:"replyCnt"`
+}
+
+func newCommentVO(c domain.Comment) CommentVO {
+	vo := CommentVO{
+		Id:       c.Id,
+		Uid:      c.Uid,
+		RootId:   c.RootId,
+		ParentId: c.ParentId,
+		Content:  c.Content,
+		Ctime:    c.Ctime.Format(time.DateTime),
+		ReplyCnt: c.ReplyCnt,
+	}
+	for _, r := range c.Replies {
+		vo.Replies = append(vo.Replies, newCommentVO(r))
+	}
+	return vo
+}
+
+type CommentCreateReq struct {
+	Biz   string `json:"biz"`
+	BizId int64  `json:"bizId"`
+	// 回复的是哪一条，发根评论就不传
+	ParentId int64  `json:"parentId"`
+	Content  string `json:"content"`
+}
+
+func (h *CommentHandler) Create(ctx *gin.Context) {
+	var req CommentCreateReq
+	if !bind(ctx, &req) {
+		return
+	}
+	req.Content = strings.TrimSpace(req.Content)
+	if req.Content == "" || utf8.RuneCountInString(req.Content) > maxCommentLength {
+		ctx.JSON(http.StatusOK, Result{Code: 4, Msg: "评论不能为空，也不能太长"})
+		return
+	}
+	uid := CurrentUid(ctx)
+	id, err := h.svc.Create(ctx.Request.Context(), domain.Comment{
+		Uid:      uid,
+		Biz:      req.Biz,
+		BizId:    req.BizId,
+		ParentId: req.ParentId,
+		Content:  req.Content,
+	})
+	switch err {
+	case nil:
+		ctx.JSON(http.StatusOK, Result{Data: id})
+	case service.ErrArticleNotFound:
+		ctx.JSON(http.StatusOK, Result{Code: 4, Msg: "文章不存在"})
+	case service.ErrCommentNotFound:
+		ctx.JSON(http.StatusOK, Result{Code: 4, Msg: "回复的评论不存在"})
+	case service.ErrUnsupportedBiz, service.ErrInvalidCommentArg:
+		ctx.JSON(http.StatusOK, Result{Code: 4, Msg: "参数错误"})
+	default:
+		log.Println("发表评论失败", err)
+		ctx.JSON(http.StatusOK, Result{Code: 5, Msg: "系统错误"})
+	}
+}
+
+type CommentDeleteReq struct {
+	Id int64 `json:"id"`
+}
+
+func (h *CommentHandler) Delete(ctx *gin.Context) {
+	var req CommentDeleteReq
+	if !bind(ctx, &req) {
+		return
+	}
+	uid := CurrentUid(ctx)
+	err := h.svc.Delete(ctx.Request.Context(), uid, req.Id)
+	switch err {
+	case nil:
+		ctx.JSON(http.StatusOK, Result{Msg: "OK"})
+	case service.ErrCommentNotFound:
+		ctx.JSON(http.StatusOK, Result{Code: 4, Msg: "评论不存在"})
+	case service.ErrCommentNoAuth:
+		ctx.JSON(http.StatusOK, Result{Code: 4, Msg: "只能删除自己的评论，或者自己文章下面的评论"})
+	default:
+		log.Println("删除评论失败", err)
+		ctx.JSON(http.StatusOK, Result{Code: 5, Msg: "系统错误"})
+	}
+}
+
+type CommentListReq struct {
+	Biz   string `json:"biz"`
+	BizId int64  `json:"bizId"`
+	MaxId int64  `json:"maxId"`
+	Limit int    `json:"limit"`
+}
+
+// List 根评论列表，新的在前面。翻页的时候 maxId 传上一页最后一条的 ID
+func (h *CommentHandler) List(ctx *gin.Context) {
+	var req CommentListReq
+	if !bind(ctx, &req) {
+		return
+	}
+	if req.Limit <= 0 || req.Limit > 50 {
+		req.Limit = 20
+	}
+	cs, err := h.svc.List(ctx.Request.Context(), req.Biz, req.BizId, req.MaxId, req.Limit)
+	if err != nil {
+		log.Println("查找评论失败", err)
+		ctx.JSON(http.StatusOK, Result{Code: 5, Msg: "系统错误"})
+		return
+	}
+	vos := make([]CommentVO, 0, len(cs))
+	for _, c := range cs {
+		vos = append(vos, newCommentVO(c))
+	}
+	ctx.JSON(http.StatusOK, Result{Data: vos})
+}
+
+type CommentRepliesReq struct {
+	RootId int64 `json:"rootId"`
+	MinId  int64 `json:"minId"`
+	Limit  int   `json:"limit"`
+}
+
+// Replies 加载更多回复，按时间顺序。minId 传已经加载的最后一条回复的 ID
+func (h *CommentHandler) Replies(ctx *gin.Context) {
+	var req CommentRepliesReq
+	if !bind(ctx, &req) {
+		return
+	}
+	if req.Limit <= 0 || req.Limit > 50 {
+		req.Limit = 20
+	}
+	cs, err := h.svc.Replies(ctx.Request.Context(), req.RootId, req.MinId, req.Limit)
+	if err != nil {
+		log.Println("查找回复失败", err)
+		ctx.JSON(http.StatusOK, Result{Code: 5, Msg: "系统错误"})
+		return
+	}
+	vos := make([]CommentVO, 0, len(cs))
+	for _, c := range cs {
+		vos = append(vos, newCommentVO(c))
+	}
+	ctx.JSON(http.StatusOK, Result{Data: vos})
+}
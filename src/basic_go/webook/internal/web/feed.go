@@ -0,0 +1,80 @@
+package web
+
+import (
+	"basic_go/webook/internal/domain"
+	"basic_go/webook/internal/service"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type FeedHandler struct {
+	svc *service.FeedService
+}
+
+func NewFeedHandler(svc *service.FeedService) *FeedHandler {
+	return &FeedHandler{
+		svc: svc,
+	}
+}
+
+func (h *FeedHandler) RegisterRoutes(server *gin.Engine) {
+	g := server.Group("/feed")
+	g.POST("/list", h.List)
+}
+
+var feedOps = []Operation{
+	{Tag: "Feed", Method: http.MethodPost, Path: "/feed/list", Summary: "关注的人的动态", Req: FeedListReq{}, Resp: []FeedEventVO{}},
+}
+
+type FeedEventVO struct {
+	// 翻页的时候把最后一条的 id 当作 maxId 传回来
+	Id   int64             `json:"id"`
+	Type string            `json:"type"`
+	Ext  map[string]string `json:"ext"`
+	// 毫秒数，翻页的时候把最后一条的 ctime 当作 maxTime 传回来
+	Ctime int64 `json:"ctime"`
+	// 翻页的时候把最后一条的 pulled 当作 maxPulled 传回来
+	Pulled bool `json:"pulled"`
+}
+
+type FeedListReq struct {
+	MaxTime   int64 `json:"maxTime"`
+	MaxPulled bool  `json:"maxPulled"`
+	MaxId     int64 `json:"maxId"`
+	Limit     int   `json:"limit"`
+}
+
+func (h *FeedHandler) List(ctx *gin.Context) {
+	var req FeedListReq
+	if !bind(ctx, &req) {
+		return
+	}
+	if req.Limit <= 0 || req.Limit > 100 {
+		req.Limit = 20
+	}
+	cursor := domain.FeedCursor{Pulled: req.MaxPulled, Id: req.MaxId}
+	if req.MaxTime > 0 {
+		cursor.Time = time.UnixMilli(req.MaxTime)
+	}
+	uid := CurrentUid(ctx)
+	evts, err := h.svc.GetFeed(ctx.Request.Context(), uid, cursor, req.Limit)
+	if err != nil {
+		log.Println("查找动态失败", err)
+		ctx.JSON(http.StatusOK, Result{Code: 5, Msg: "系统错误"})
+		return
+	}
+	vos := make([]FeedEventVO, 0, len(evts))
+	for _, evt := range evts {
+		vos = append(vos, FeedEventVO{
+			Id:     evt.Id,
+			Type:   evt.Type,
+			Ext:    evt.Ext,
+			Ctime:  evt.Ctime.UnixMilli(),
+			Pulled: evt.Pulled,
+		})
+	}
+	ctx.JSON(http.StatusOK, Result{Data: vos})
+}
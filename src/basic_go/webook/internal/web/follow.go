@@ -0,0 +1,152 @@
+package web
+
+import (
+	"basic_go/webook/internal/domain"
+	"basic_go/webook/internal/service"
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type FollowHandler struct {
+	svc *service.FollowService
+}
+
+func NewFollowHandler(svc *service.FollowService) *FollowHandler {
+	return &FollowHandler{
+		svc: svc,
+	}
+}
+
+func (h *FollowHandler) RegisterRoutes(server *gin.Engine) {
+	g := server.Group("/follow")
+	g.POST("/follow", h.Follow)
+	g.POST("/unfollow", h.Unfollow)
+	g.POST("/followers", h.Followers)
+	g.POST("/followees", h.Followees)
+	g.GET("/is_following", h.IsFollowing)
+}
+
+var followOps = []Operation{
+	{Tag: "关注", Method: http.MethodPost, Path: "/follow/follow", Summary: "关注", Req: FollowReq{}},
+	{Tag: "关注", Method: http.MethodPost, Path: "/follow/unfollow", Summary: "取消关注", Req: FollowReq{}},
+	{Tag: "关注", Method: http.MethodPost, Path: "/follow/followers", Summary: "粉丝列表", Req: FollowListReq{}, Resp: []FollowRelationVO{}},
+	{Tag: "关注", Method: http.MethodPost, Path: "/follow/followees", Summary: "关注列表", Req: FollowListReq{}, Resp: []FollowRelationVO{}},
+	{Tag: "关注", Method: http.MethodGet, Path: "/follow/is_following", Summary: "是不是关注了", Req: IsFollowingReq{}, Resp: false},
+}
+
+type FollowRelationVO struct {
+	// 翻页的时候传这个
+	Id       int64  `json:"id"`
+	Follower int64  `json:"follower"`
+	Followee int64  `json:"followee"`
+	Ctime    string `json:"ctime"`
+}
+
+type FollowReq struct {
+	Followee int64 `json:"followee"`
+}
+
+// FollowListReq Uid 不传就是查自己的
+type FollowListReq struct {
+	Uid   int64 `json:"uid"`
+	MaxId int64 `json:"maxId"`
+	Limit int   `json:"limit"`
+}
+
+func (h *FollowHandler) Follow(ctx *gin.Context) {
+	var req FollowReq
+	if !bind(ctx, &req) {
+		return
+	}
+	uid := CurrentUid(ctx)
+	err := h.svc.Follow(ctx.Request.Context(), uid, req.Followee)
+	switch err {
+	case nil:
+		ctx.JSON(http.StatusOK, Result{Msg: "OK"})
+	case service.ErrFollowSelf:
+		ctx.JSON(http.StatusOK, Result{Code: 4, Msg: "不能关注自己"})
+	case service.ErrUserNotFound:
+		ctx.JSON(http.StatusOK, Result{Code: 4, Msg: "用户不存在"})
+	default:
+		log.Println("关注失败", err)
+		ctx.JSON(http.StatusOK, Result{Code: 5, Msg: "系统错误"})
+	}
+}
+
+func (h *FollowHandler) Unfollow(ctx *gin.Context) {
+	var req FollowReq
+	if !bind(ctx, &req) {
+		return
+	}
+	uid := CurrentUid(ctx)
+	err := h.svc.Unfollow(ctx.Request.Context(), uid, req.Followee)
+	if err != nil {
+		log.Println("取消关注失败", err)
+		ctx.JSON(http.StatusOK, Result{Code: 5, Msg: "系统错误"})
+		return
+	}
+	ctx.JSON(http.StatusOK, Result{Msg: "OK"})
+}
+
+func (h *FollowHandler) Followers(ctx *gin.Context) {
+	h.list(ctx, h.svc.Followers)
+}
+
+func (h *FollowHandler) Followees(ctx *gin.Context) {
+	h.list(ctx, h.svc.Followees)
+}
+
+// list 粉丝列表和关注列表的处理是一样的，只是查的方法不同
+func (h *FollowHandler) list(ctx *gin.Context,
+	find func(ctx context.Context, uid int64, maxId int64, limit int) ([]domain.FollowRelation, error)) {
+	var req FollowListReq
+	if !bind(ctx, &req) {
+		return
+	}
+	if req.Uid <= 0 {
+		req.Uid = CurrentUid(ctx)
+	}
+	if req.Limit <= 0 || req.Limit > 100 {
+		req.Limit = 20
+	}
+	rs, err := find(ctx.Request.Context(), req.Uid, req.MaxId, req.Limit)
+	if err != nil {
+		log.Println("查找关注关系失败", err)
+		ctx.JSON(http.StatusOK, Result{Code: 5, Msg: "系统错误"})
+		return
+	}
+	vos := make([]FollowRelationVO, 0, len(rs))
+	for _, r := range rs {
+		vos = append(vos, FollowRelationVO{
+			Id:       r.Id,
+			Follower: r.Follower,
+			Followee: r.Followee,
+			Ctime:    r.Ctime.Format(time.DateTime),
+		})
+	}
+	ctx.JSON(http.StatusOK, Result{Data: vos})
+}
+
+type IsFollowingReq struct {
+	Followee int64 `form:"followee"`
+}
+
+// IsFollowing GET /follow/is_following?followee=123
+func (h *FollowHandler) IsFollowing(ctx *gin.Context) {
+	var req IsFollowingReq
+	if !bind(ctx, &req) {
+		return
+	}
+	uid := CurrentUid(ctx)
+	ok, err := h.svc.IsFollowing(ctx.Request.Context(), uid, req.Followee)
+	if err != nil {
+		log.Println("查找关注关系失败", err)
+		ctx.JSON(http.StatusOK, Result{Code: 5, Msg: "系统错误"})
+		return
+	}
+	ctx.JSON(http.StatusOK, Result{Data: ok})
+}
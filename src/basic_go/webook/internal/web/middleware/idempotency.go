@@ -0,0 +1,165 @@
+package middleware
+
+import (
+	"basic_go/webook/internal/web"
+	"basic_go/webook/pkg/idempotency"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	HeaderIdempotencyKey = "Idempotency-Key"
+	// HeaderIdempotentReplayed 重放的响应会带上这个头部
+	HeaderIdempotentReplayed = "Idempotent-Replayed"
+)
+
+// IdempotencyMiddlewareBuilder 写接口带了 Idempotency-Key 的话，同一个用户在同一个接口上面
+// 用同一个 key 重试，直接返回第一次的响应，不会重复执行。
+// 要放在登录校验后面，key 是按照用户区分的；不用登录的接口比如注册，就只按照 key 区分
+type IdempotencyMiddlewareBuilder struct {
+	Store idempotency.Store
+	// 只有这些路由（gin 的 FullPath）才生效，空的话所有写接口都生效。
+	// 重放只有状态码和响应体，登录这种要写响应头的接口不能放进来，不然重试拿不到 token
+	Paths []string
+	// 第一次的响应保存多久，客户端在这之内重试才会重放
+	Expiration time.Duration
+	// 处理中的锁多久过期，要比接口最慢的时候还长，不然会有并发的重复请求进来
+	LockExpiration time.Duration
+}
+
+func (m *IdempotencyMiddlewareBuilder) Build() gin.HandlerFunc {
+	paths := make(map[string]struct{}, len(m.Paths))
+	for _, p := range m.Paths {
+		paths[p] = struct{}{}
+	}
+	return func(ctx *gin.Context) {
+		key := ctx.GetHeader(HeaderIdempotencyKey)
+		if key == "" || ctx.Request.Method == http.MethodGet || ctx.FullPath() == "" ||
+			// 上传文件请求体太大了，不能整个读到内存里面来算哈希，重复上传也只是多一个文件
+			strings.HasPrefix(ctx.ContentType(), "multipart/") {
+			return
+		}
+		if _, ok := paths[ctx.FullPath()]; len(paths) > 0 && !ok {
+			return
+		}
+		if len(key) > 255 {
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, web.Result{Code: 4, Msg: "Idempotency-Key 太长了"})
+			return
+		}
+		body, err := io.ReadAll(ctx.Request.Body)
+		if err != nil {
+			ctx.AbortWithStatus(http.StatusBadRequest)
+			return
+		}
+		ctx.Request.Body = io.NopCloser(bytes.NewReader(body))
+		sum := sha256.Sum256(body)
+		hash := hex.EncodeToString(sum[:])
+		// 没登录的就是 0
+		uid, _ := web.TryCurrentUid(ctx)
+		storeKey := fmt.Sprintf("idempotency:%d:%s:%s:%s", uid, ctx.Request.Method, ctx.FullPath(), key)
+
+		if m.replay(ctx, storeKey, hash) {
+			return
+		}
+		unlock, err := m.Store.Lock(ctx.Request.Context(), storeKey, m.LockExpiration)
+		switch {
+		case errors.Is(err, idempotency.ErrLocked):
+			ctx.AbortWithStatusJSON(http.StatusConflict, web.Result{Code: 4, Msg: "请求正在处理，请稍后重试"})
+			return
+		case err != nil:
+			// 存储出问题了不影响业务，只是这一次不保证幂等
+			log.Println("幂等加锁失败", storeKey, err)
+			return
+		}
+		// 客户端断开了也要释放锁，保存响应
+		bgCtx := context.WithoutCancel(ctx.Request.Context())
+		defer func() {
+			if err := unlock(bgCtx); err != nil {
+				log.Println("幂等解锁失败", storeKey, err)
+			}
+		}()
+		// 抢到锁之前，别的请求可能刚好处理完
+		if m.replay(ctx, storeKey, hash) {
+			return
+		}
+
+		w := &responseRecorder{ResponseWriter: ctx.Writer}
+		ctx.Writer = w
+		ctx.Next()
+		if !cacheable(w.Status(), w.Header().Get("Content-Type"), w.body.Bytes()) {
+			return
+		}
+		err = m.Store.Save(bgCtx, storeKey, idempotency.Response{
+			BodyHash:    hash,
+			Status:      w.Status(),
+			ContentType: w.Header().Get("Content-Type"),
+			Body:        w.body.Bytes(),
+		}, m.Expiration)
+		if err != nil {
+			log.Println("保存幂等响应失败", storeKey, err)
+		}
+	}
+}
+
+// replay 有保存的响应就处理掉，返回 true
+func (m *IdempotencyMiddlewareBuilder) replay(ctx *gin.Context, storeKey string, hash string) bool {
+	resp, err := m.Store.Get(ctx.Request.Context(), storeKey)
+	if errors.Is(err, idempotency.ErrNotFound) {
+		return false
+	}
+	if err != nil {
+		log.Println("查询幂等响应失败", storeKey, err)
+		return false
+	}
+	if resp.BodyHash != hash {
+		ctx.AbortWithStatusJSON(http.StatusUnprocessableEntity,
+			web.Result{Code: 4, Msg: "Idempotency-Key 已经用过了，但是请求内容不一样"})
+		return true
+	}
+	ctx.Header(HeaderIdempotentReplayed, "true")
+	ctx.Data(resp.Status, resp.ContentType, resp.Body)
+	ctx.Abort()
+	return true
+}
+
+// cacheable 系统错误不保存，客户端重试的时候还会再执行一次
+func cacheable(status int, contentType string, body []byte) bool {
+	if status >= http.StatusInternalServerError {
+		return false
+	}
+	if strings.HasPrefix(contentType, "application/json") {
+		var res web.Result
+		if json.Unmarshal(body, &res) == nil && res.Code == 5 {
+			return false
+		}
+	}
+	return true
+}
+
+// responseRecorder 写给客户端的同时记下来
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
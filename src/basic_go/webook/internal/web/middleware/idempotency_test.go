@@ -0,0 +1,89 @@
+package middleware
+
+import (
+	"basic_go/webook/internal/web"
+	"basic_go/webook/pkg/idempotency"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func newIdempotencyServer(hdl gin.HandlerFunc) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	server := gin.New()
+	server.Use(func(ctx *gin.Context) {
+		web.SetCurrentUid(ctx, 1)
+	})
+	m := &IdempotencyMiddlewareBuilder{
+		Store:          idempotency.NewMemoryStore(),
+		Expiration:     time.Minute,
+		LockExpiration: time.Minute,
+	}
+	server.Use(m.Build())
+	server.POST("/test", hdl)
+	return server
+}
+
+func doIdempotent(server *gin.Engine, key string, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/test", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderIdempotencyKey, key)
+	resp := httptest.NewRecorder()
+	server.ServeHTTP(resp, req)
+	return resp
+}
+
+// TestIdempotency_Concurrent 第一个请求还没处理完，同一个 key 的请求进来直接拒绝
+func TestIdempotency_Concurrent(t *testing.T) {
+	var cnt atomic.Int32
+	started := make(chan struct{})
+	release := make(chan struct{})
+	server := newIdempotencyServer(func(ctx *gin.Context) {
+		cnt.Add(1)
+		close(started)
+		<-release
+		ctx.JSON(http.StatusOK, web.Result{Msg: "OK"})
+	})
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() {
+		done <- doIdempotent(server, "k", `{"a":1}`)
+	}()
+	<-started
+	resp := doIdempotent(server, "k", `{"a":1}`)
+	assert.Equal(t, http.StatusConflict, resp.Code)
+
+	close(release)
+	first := <-done
+	assert.Equal(t, http.StatusOK, first.Code)
+	resp = doIdempotent(server, "k", `{"a":1}`)
+	assert.Equal(t, first.Body.String(), resp.Body.String())
+	assert.Equal(t, "true", resp.Header().Get(HeaderIdempotentReplayed))
+	assert.Equal(t, int32(1), cnt.Load())
+}
+
+// TestIdempotency_SystemError 系统错误不保存，重试会再执行
+func TestIdempotency_SystemError(t *testing.T) {
+	var cnt atomic.Int32
+	server := newIdempotencyServer(func(ctx *gin.Context) {
+		if cnt.Add(1) == 1 {
+			ctx.JSON(http.StatusOK, web.Result{Code: 5, Msg: "系统错误"})
+			return
+		}
+		ctx.JSON(http.StatusOK, web.Result{Msg: "OK"})
+	})
+	resp := doIdempotent(server, "k", `{}`)
+	assert.JSONEq(t, `{"code":5,"msg":"系统错误","data":null}`, resp.Body.String())
+	resp = doIdempotent(server, "k", `{}`)
+	assert.JSONEq(t, `{"code":0,"msg":"OK","data":null}`, resp.Body.String())
+	assert.Empty(t, resp.Header().Get(HeaderIdempotentReplayed))
+	resp = doIdempotent(server, "k", `{}`)
+	assert.Equal(t, "true", resp.Header().Get(HeaderIdempotentReplayed))
+	assert.Equal(t, int32(2), cnt.Load())
+}
@@ -1,6 +1,8 @@
 package middleware
 
 import (
+	"basic_go/webook/internal/service"
+	"basic_go/webook/internal/web"
 	"encoding/gob"
 	"fmt"
 	"net/http"
@@ -11,25 +13,37 @@ import (
 )
 
 type LoginMiddlewareBuilder struct {
+	// 不设置的话不检查是不是被强制下线了
+	Sessions *service.SessionService
 }
 
 func (m *LoginMiddlewareBuilder) CheckLogin() gin.HandlerFunc {
 	// 注册一下这个类型
 	gob.Register(time.Now())
 	return func(ctx *gin.Context) {
-		path := ctx.Request.URL.Path
-		if path == "/users/signup" || path == "/users/login" {
+		if isPublicPath(ctx.Request.URL.Path) {
 			// 不需要登录校验
 			return
 		}
 		sess := sessions.Default(ctx)
-		userId := sess.Get("userId")
+		userId := sess.Get(web.SessionUidKey)
 		if userId == nil {
 			// 中断，不要往后执行，也就是不要执行后面的业务逻辑
 			ctx.AbortWithStatus(http.StatusUnauthorized)
 			return
 		}
 
+		uid, _ := userId.(int64)
+		loginTime, _ := sess.Get(web.LoginTimeKey).(int64)
+		if revoked(ctx, m.Sessions, uid, time.UnixMilli(loginTime)) {
+			sess.Clear()
+			if err := sess.Save(); err != nil {
+				fmt.Println(err)
+			}
+			ctx.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+
 		now := time.Now()
 
 		// 怎么知道要刷新了呢
@@ -41,13 +55,15 @@ func (m *LoginMiddlewareBuilder) CheckLogin() gin.HandlerFunc {
 		if val == nil || !ok || now.Sub(lastUpdateTime) > time.Second*10 {
 			// 代表第一次进来
 			sess.Set(updateTimeKey, now)
-			sess.Set("userId", userId)
+			sess.Set(web.SessionUidKey, userId)
 			err := sess.Save()
 			if err != nil {
 				// 打日志
 				fmt.Println(err)
 			}
 		}
+		// handler 通过 web.CurrentUid 拿
+		web.SetCurrentUid(ctx, uid)
 		//lastUpdateTime, ok := val.(time.Time)
 		//if !ok {
 		//	// 代表第一次进来
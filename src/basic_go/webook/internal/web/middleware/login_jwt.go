@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"basic_go/webook/internal/service"
 	"basic_go/webook/internal/web"
 	"log"
 	"net/http"
@@ -12,12 +13,13 @@ import (
 )
 
 type LoginJWTMiddlewareBuilder struct {
+	// 不设置的话不检查是不是被强制下线了
+	Sessions *service.SessionService
 }
 
 func (m *LoginJWTMiddlewareBuilder) CheckLogin() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
-		path := ctx.Request.URL.Path
-		if path == "/users/signup" || path == "/users/login" {
+		if isPublicPath(ctx.Request.URL.Path) {
 			// 不需要登录校验
 			return
 		}
@@ -51,6 +53,20 @@ func (m *LoginJWTMiddlewareBuilder) CheckLogin() gin.HandlerFunc {
 			return
 		}
 
+		var loginTime time.Time
+		switch {
+		case uc.LoginTime > 0:
+			loginTime = time.UnixMilli(uc.LoginTime)
+		case uc.IssuedAt != nil:
+			// 老的 token 没有 LoginTime
+			loginTime = uc.IssuedAt.Time
+		}
+		if revoked(ctx, m.Sessions, uc.Uid, loginTime) {
+			// 被强制下线了，比如账号被禁用了
+			ctx.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+
 		expireTime := uc.ExpiresAt
 		// 不判定都可以，因为过期了的会进入Valid判定
 		//if expireTime.Before(time.Now()) {
@@ -67,7 +83,7 @@ func (m *LoginJWTMiddlewareBuilder) CheckLogin() gin.HandlerFunc {
 			}
 			ctx.Header("x-jwt-token", tokenStr)
 		}
-		ctx.Set("user", uc)
-		// 缓存在这里，已经解析好了，可以直接拿出来用
+		// handler 通过 web.CurrentUid 拿
+		web.SetCurrentUid(ctx, uc.Uid)
 	}
 }
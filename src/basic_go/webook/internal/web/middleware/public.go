@@ -0,0 +1,35 @@
+package middleware
+
+import "strings"
+
+// publicPaths 不需要登录就能访问的接口
+var publicPaths = map[string]struct{}{
+	"/users/signup":    {},
+	"/users/login":     {},
+	"/users/login/2fa": {},
+	// 支付平台的回调，靠签名校验
+	"/reward/notify": {},
+	"/articles/hot":  {},
+	"/docs":          {},
+}
+
+// publicPrefixes 这些前缀下面的都不需要登录。前缀要带上结尾的 /，
+// 不然以后加个 /docsxxx 的接口也会被放行
+var publicPrefixes = []string{
+	// 私有文件靠签名校验
+	"/objects/",
+	"/docs/",
+}
+
+// isPublicPath 两种登录校验共用这一份白名单
+func isPublicPath(path string) bool {
+	if _, ok := publicPaths[path]; ok {
+		return true
+	}
+	for _, prefix := range publicPrefixes {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
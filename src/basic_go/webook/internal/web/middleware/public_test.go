@@ -0,0 +1,29 @@
+package middleware
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsPublicPath(t *testing.T) {
+	testCases := []struct {
+		path string
+		want bool
+	}{
+		{path: "/users/login", want: true},
+		{path: "/users/login/2fa", want: true},
+		{path: "/users/profile", want: false},
+		{path: "/objects/public/a.png", want: true},
+		{path: "/objects", want: false},
+		{path: "/docs", want: true},
+		{path: "/docs/openapi.json", want: true},
+		// 只是前缀一样，不能放行
+		{path: "/docsxxx", want: false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.path, func(t *testing.T) {
+			assert.Equal(t, tc.want, isPublicPath(tc.path))
+		})
+	}
+}
@@ -0,0 +1,23 @@
+package middleware
+
+import (
+	"basic_go/webook/internal/service"
+	"log"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// revoked 登录态是不是已经被强制失效了，比如账号被禁用了。
+// 查不到的时候放行，不能因为 Redis 出问题所有人都登录不了
+func revoked(ctx *gin.Context, sessions *service.SessionService, uid int64, loginTime time.Time) bool {
+	if sessions == nil {
+		return false
+	}
+	ok, err := sessions.Valid(ctx.Request.Context(), uid, loginTime)
+	if err != nil {
+		log.Println("校验登录态失败", uid, err)
+		return false
+	}
+	return !ok
+}
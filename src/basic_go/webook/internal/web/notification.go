@@ -0,0 +1,127 @@
+package web
+
+import (
+	"basic_go/webook/internal/service"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type NotificationHandler struct {
+	svc *service.NotificationService
+}
+
+func NewNotificationHandler(svc *service.NotificationService) *NotificationHandler {
+	return &NotificationHandler{
+		svc: svc,
+	}
+}
+
+func (h *NotificationHandler) RegisterRoutes(server *gin.Engine) {
+	g := server.Group("/notifications")
+	g.POST("/list", h.List)
+	g.GET("/unread_count", h.UnreadCount)
+	g.POST("/read", h.MarkRead)
+	g.POST("/read_all", h.MarkAllRead)
+}
+
+var notificationOps = []Operation{
+	{Tag: "通知", Method: http.MethodPost, Path: "/notifications/list", Summary: "通知列表", Req: NotificationListReq{}, Resp: []NotificationVO{}},
+	{Tag: "通知", Method: http.MethodGet, Path: "/notifications/unread_count", Summary: "未读数", Resp: int64(0)},
+	{Tag: "通知", Method: http.MethodPost, Path: "/notifications/read", Summary: "标记已读", Req: NotificationReadReq{}},
+	{Tag: "通知", Method: http.MethodPost, Path: "/notifications/read_all", Summary: "全部标记已读"},
+}
+
+type NotificationVO struct {
+	Id       int64   `json:"id"`
+	Type     string  `json:"type"`
+	Biz      string  `json:"biz"`
+	BizId    int64   `json:"bizId"`
+	Summary  string  `json:"summary"`
+	Actors   []int64 `json:"actors"`
+	ActorCnt int64   `json:"actorCnt"`
+	Content  string  `json:"content"`
+	Read     bool    `json:"read"`
+	Utime    string  `json:"utime"`
+}
+
+type NotificationListReq struct {
+	Offset int `json:"offset"`
+	Limit  int `json:"limit"`
+}
+
+func (h *NotificationHandler) List(ctx *gin.Context) {
+	var req NotificationListReq
+	if !bind(ctx, &req) {
+		return
+	}
+	if req.Limit <= 0 || req.Limit > 100 {
+		req.Limit = 20
+	}
+	uid := CurrentUid(ctx)
+	ns, err := h.svc.List(ctx.Request.Context(), uid, req.Offset, req.Limit)
+	if err != nil {
+		log.Println("查找通知失败", err)
+		ctx.JSON(http.StatusOK, Result{Code: 5, Msg: "系统错误"})
+		return
+	}
+	vos := make([]NotificationVO, 0, len(ns))
+	for _, n := range ns {
+		vos = append(vos, NotificationVO{
+			Id:       n.Id,
+			Type:     n.Type,
+			Biz:      n.Biz,
+			BizId:    n.BizId,
+			Summary:  n.Summary(),
+			Actors:   n.Actors,
+			ActorCnt: n.ActorCnt,
+			Content:  n.Content,
+			Read:     n.Read,
+			Utime:    n.Utime.Format(time.DateTime),
+		})
+	}
+	ctx.JSON(http.StatusOK, Result{Data: vos})
+}
+
+func (h *NotificationHandler) UnreadCount(ctx *gin.Context) {
+	uid := CurrentUid(ctx)
+	cnt, err := h.svc.UnreadCount(ctx.Request.Context(), uid)
+	if err != nil {
+		log.Println("查找未读通知数失败", err)
+		ctx.JSON(http.StatusOK, Result{Code: 5, Msg: "系统错误"})
+		return
+	}
+	ctx.JSON(http.StatusOK, Result{Data: cnt})
+}
+
+type NotificationReadReq struct {
+	Id int64 `json:"id"`
+}
+
+func (h *NotificationHandler) MarkRead(ctx *gin.Context) {
+	var req NotificationReadReq
+	if !bind(ctx, &req) {
+		return
+	}
+	uid := CurrentUid(ctx)
+	err := h.svc.MarkRead(ctx.Request.Context(), uid, req.Id)
+	if err != nil {
+		log.Println("标记通知已读失败", err)
+		ctx.JSON(http.StatusOK, Result{Code: 5, Msg: "系统错误"})
+		return
+	}
+	ctx.JSON(http.StatusOK, Result{Msg: "OK"})
+}
+
+func (h *NotificationHandler) MarkAllRead(ctx *gin.Context) {
+	uid := CurrentUid(ctx)
+	err := h.svc.MarkAllRead(ctx.Request.Context(), uid)
+	if err != nil {
+		log.Println("标记通知已读失败", err)
+		ctx.JSON(http.StatusOK, Result{Code: 5, Msg: "系统错误"})
+		return
+	}
+	ctx.JSON(http.StatusOK, Result{Msg: "OK"})
+}
@@ -0,0 +1,232 @@
+package web
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3gen"
+	"github.com/gin-gonic/gin"
+)
+
+// Operation 一个接口的文档，和 RegisterRoutes 写在一起，改路由的时候顺手改掉。
+// 每个注册了的路由都要有，测试会检查
+type Operation struct {
+	Method string
+	// gin 风格的路径，比如 /articles/detail/:id
+	Path    string
+	Tag     string
+	Summary string
+	// 请求的零值，用来反射出 schema。GET 的话按照 form tag 生成 query 参数，
+	// Multipart 的话是 multipart 表单，其它的都是 JSON
+	Req       any
+	Multipart bool
+	// Result.Data 的零值，nil 的话就只有 code 和 msg
+	Resp any
+	// 返回的是纯文本，不是 Result
+	Text bool
+	// 不用登录
+	Public bool
+}
+
+// pkg 里面的组件自己注册的路由，它们不知道 OpenAPI，在这里补上
+var infraOps = []Operation{
+	{Method: http.MethodGet, Path: "/objects/*key", Tag: "文件", Public: true,
+		Summary: "下载文件。私有的文件要带上 expires 和 sign，通过 /upload/sign 拿到"},
+}
+
+// Operations 所有接口的文档
+func Operations() []Operation {
+	var ops []Operation
+	for _, group := range [][]Operation{
+		userOps, followOps, adminOps, articleOps, rankingOps, commentOps, notificationOps,
+		searchOps, feedOps, accountOps, rewardOps, uploadOps, privacyOps, infraOps,
+	} {
+		ops = append(ops, group...)
+	}
+	return ops
+}
+
+// OpenAPIPath 把 gin 的 :id 和 *key 换成 OpenAPI 的 {id} 和 {key}
+func OpenAPIPath(path string) (string, []string) {
+	segs := strings.Split(path, "/")
+	var params []string
+	for i, seg := range segs {
+		if strings.HasPrefix(seg, ":") || strings.HasPrefix(seg, "*") {
+			params = append(params, seg[1:])
+			segs[i] = "{" + seg[1:] + "}"
+		}
+	}
+	return strings.Join(segs, "/"), params
+}
+
+// NewOpenAPISpec 根据 Operations 生成 OpenAPI 3 文档
+func NewOpenAPISpec() (*openapi3.T, error) {
+	spec := &openapi3.T{
+		OpenAPI: "3.0.3",
+		Info: &openapi3.Info{
+			Title:   "webook",
+			Version: "1.0.0",
+			Description: "JSON 接口返回的都是 HTTP 200，code 0 代表成功，4 是参数或者业务上的错误，5 是系统错误。" +
+				"JWT 模式登录之后从响应头 x-jwt-token 拿到 token，放到 Authorization: Bearer 里面；session 模式用 cookie ssid。" +
+				"注册、发表文章、打赏可以带上 Idempotency-Key 头部，重试的时候返回第一次的响应，响应头带上 Idempotent-Replayed: true",
+		},
+		Paths: openapi3.NewPaths(),
+		Components: &openapi3.Components{
+			SecuritySchemes: openapi3.SecuritySchemes{
+				"jwt": &openapi3.SecuritySchemeRef{
+					Value: openapi3.NewJWTSecurityScheme(),
+				},
+				"session": &openapi3.SecuritySchemeRef{
+					Value: openapi3.NewSecurityScheme().WithType("apiKey").WithIn("cookie").WithName("ssid"),
+				},
+			},
+		},
+		Security: openapi3.SecurityRequirements{
+			openapi3.NewSecurityRequirement().Authenticate("jwt"),
+			openapi3.NewSecurityRequirement().Authenticate("session"),
+		},
+	}
+	for _, op := range Operations() {
+		o, err := newOperation(op)
+		if err != nil {
+			return nil, err
+		}
+		path, _ := OpenAPIPath(op.Path)
+		item := spec.Paths.Value(path)
+		if item == nil {
+			item = &openapi3.PathItem{}
+			spec.Paths.Set(path, item)
+		}
+		item.SetOperation(op.Method, o)
+	}
+	return spec, nil
+}
+
+func newOperation(op Operation) (*openapi3.Operation, error) {
+	o := openapi3.NewOperation()
+	o.Tags = []string{op.Tag}
+	o.Summary = op.Summary
+	o.OperationID = op.Method + " " + op.Path
+	if op.Public {
+		o.Security = openapi3.NewSecurityRequirements()
+	}
+	_, params := OpenAPIPath(op.Path)
+	for _, p := range params {
+		o.AddParameter(openapi3.NewPathParameter(p).WithSchema(openapi3.NewStringSchema()))
+	}
+
+	if op.Req != nil {
+		switch {
+		case op.Method == http.MethodGet:
+			qs, err := queryParameters(op.Req)
+			if err != nil {
+				return nil, err
+			}
+			for _, q := range qs {
+				o.AddParameter(q)
+			}
+		case op.Multipart:
+			schema, err := newSchema(op.Req)
+			if err != nil {
+				return nil, err
+			}
+			o.RequestBody = &openapi3.RequestBodyRef{Value: openapi3.NewRequestBody().WithRequired(true).
+				WithSchemaRef(schema, []string{"multipart/form-data"})}
+		default:
+			schema, err := newSchema(op.Req)
+			if err != nil {
+				return nil, err
+			}
+			o.RequestBody = &openapi3.RequestBodyRef{Value: openapi3.NewRequestBody().WithRequired(true).
+				WithJSONSchemaRef(schema)}
+		}
+	}
+
+	resp := openapi3.NewResponse().WithDescription("OK")
+	if op.Text {
+		resp.WithContent(openapi3.NewContentWithSchema(openapi3.NewStringSchema(), []string{"text/plain"}))
+	} else {
+		result := openapi3.NewObjectSchema().
+			WithProperty("code", openapi3.NewIntegerSchema()).
+			WithProperty("msg", openapi3.NewStringSchema())
+		if op.Resp != nil {
+			data, err := newSchema(op.Resp)
+			if err != nil {
+				return nil, err
+			}
+			result.WithPropertyRef("data", data)
+		}
+		resp.WithJSONSchema(result)
+	}
+	o.AddResponse(http.StatusOK, resp)
+	return o, nil
+}
+
+func newSchema(val any) (*openapi3.SchemaRef, error) {
+	return openapi3gen.NewSchemaRefForValue(val, nil, openapi3gen.SchemaCustomizer(
+		func(name string, t reflect.Type, tag reflect.StructTag, schema *openapi3.Schema) error {
+			// 上传的文件用 format:"binary" 标出来
+			if f := tag.Get("format"); f != "" {
+				schema.Format = f
+			}
+			return nil
+		}))
+}
+
+// queryParameters GET 请求是 bind 从 query 里面按照 form tag 取的
+func queryParameters(val any) ([]*openapi3.Parameter, error) {
+	t := reflect.TypeOf(val)
+	var res []*openapi3.Parameter
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := f.Tag.Get("form")
+		if name == "" || name == "-" {
+			continue
+		}
+		schema, err := newSchema(reflect.Zero(f.Type).Interface())
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, openapi3.NewQueryParameter(name).WithSchema(schema.Value))
+	}
+	return res, nil
+}
+
+// RegisterDocs 注册 /docs 的 Swagger UI 和 /docs/openapi.json。只在非生产环境打开
+func RegisterDocs(server *gin.Engine) error {
+	spec, err := NewOpenAPISpec()
+	if err != nil {
+		return err
+	}
+	data, err := spec.MarshalJSON()
+	if err != nil {
+		return err
+	}
+	g := server.Group("/docs")
+	g.GET("", func(ctx *gin.Context) {
+		ctx.Data(http.StatusOK, "text/html; charset=utf-8", []byte(swaggerUIPage))
+	})
+	g.GET("/openapi.json", func(ctx *gin.Context) {
+		ctx.Data(http.StatusOK, "application/json", data)
+	})
+	return nil
+}
+
+const swaggerUIPage = `<!DOCTYPE html>
+<html lang="zh-CN">
+<head>
+  <meta charset="utf-8"/>
+  <title>webook API</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css"/>
+</head>
+<body>
+<div id="swagger-ui"></div>
+<script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js"></script>
+<script>
+  window.ui = SwaggerUIBundle({url: "/docs/openapi.json", dom_id: "#swagger-ui"});
+</script>
+</body>
+</html>
+`
@@ -0,0 +1,188 @@
+package web
+
+import (
+	"basic_go/webook/internal/domain"
+	"basic_go/webook/internal/service"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PrivacyHandler 用户自己注销账号、导出自己的数据
+type PrivacyHandler struct {
+	deletionSvc *service.AccountDeletionService
+	exportSvc   *service.DataExportService
+}
+
+func NewPrivacyHandler(deletionSvc *service.AccountDeletionService, exportSvc *service.DataExportService) *PrivacyHandler {
+	return &PrivacyHandler{
+		deletionSvc: deletionSvc,
+		exportSvc:   exportSvc,
+	}
+}
+
+func (h *PrivacyHandler) RegisterRoutes(server *gin.Engine) {
+	g := server.Group("/users")
+	g.POST("/delete", h.Delete)
+	g.POST("/delete/cancel", h.CancelDelete)
+	g.GET("/delete/status", h.DeleteStatus)
+	g.POST("/export", h.Export)
+	g.POST("/export/detail", h.ExportDetail)
+}
+
+var privacyOps = []Operation{
+	{Tag: "隐私", Method: http.MethodPost, Path: "/users/delete", Req: DeleteAccountReq{}, Resp: AccountDeletionVO{},
+		Summary: "申请注销账号，要再输入一次密码。冷静期过了之后才真的注销，这期间可以取消"},
+	{Tag: "隐私", Method: http.MethodPost, Path: "/users/delete/cancel", Summary: "取消注销"},
+	{Tag: "隐私", Method: http.MethodGet, Path: "/users/delete/status", Summary: "最近一次注销申请，没有申请过 data 是 null",
+		Resp: AccountDeletionVO{}},
+	{Tag: "隐私", Method: http.MethodPost, Path: "/users/export", Resp: DataExportVO{},
+		Summary: "申请导出个人信息、文章、评论和点赞关注，异步打包成 ZIP，用 /users/export/detail 轮询"},
+	{Tag: "隐私", Method: http.MethodPost, Path: "/users/export/detail", Req: ExportDetailReq{}, Resp: DataExportVO{},
+		Summary: "导出的进度，打包好了带上下载地址，地址一会儿就过期了，过期了再调一次拿新的"},
+}
+
+// 文章的处理方式，和前端约定的值
+var articlePolicies = map[string]domain.ArticlePolicy{
+	"unpublish":   domain.ArticlePolicyUnpublish,
+	"reattribute": domain.ArticlePolicyReattribute,
+}
+
+type DeleteAccountReq struct {
+	Password string `json:"password" binding:"required"`
+	// unpublish 是全部撤回，reattribute 是文章保留，作者换成匿名账号
+	ArticlePolicy string `json:"articlePolicy" binding:"required,oneof=unpublish reattribute"`
+}
+
+type AccountDeletionVO struct {
+	// 1 是冷静期，2 是已取消，3 是处理中，4 是已注销
+	Status        uint8  `json:"status"`
+	ArticlePolicy string `json:"articlePolicy"`
+	// 冷静期结束，真的注销的时间
+	ScheduledAt string `json:"scheduledAt"`
+	Ctime       string `json:"ctime"`
+}
+
+func newAccountDeletionVO(d domain.AccountDeletion) AccountDeletionVO {
+	vo := AccountDeletionVO{
+		Status:      uint8(d.Status),
+		ScheduledAt: d.ScheduledAt.Format(time.DateTime),
+		Ctime:       d.Ctime.Format(time.DateTime),
+	}
+	for name, p := range articlePolicies {
+		if p == d.ArticlePolicy {
+			vo.ArticlePolicy = name
+		}
+	}
+	return vo
+}
+
+// Delete 申请注销
+func (h *PrivacyHandler) Delete(ctx *gin.Context) {
+	var req DeleteAccountReq
+	if !bind(ctx, &req) {
+		return
+	}
+	uid := CurrentUid(ctx)
+	d, err := h.deletionSvc.Request(ctx.Request.Context(), uid, req.Password, articlePolicies[req.ArticlePolicy])
+	switch err {
+	case nil:
+		ctx.JSON(http.StatusOK, Result{Data: newAccountDeletionVO(d)})
+	case service.ErrInvalidUserOrPassword:
+		ctx.JSON(http.StatusOK, Result{Code: 4, Msg: "密码不对"})
+	case service.ErrDeletionAlreadyRequested:
+		ctx.JSON(http.StatusOK, Result{Code: 4, Msg: "已经申请注销了"})
+	case service.ErrInvalidArticlePolicy:
+		ctx.JSON(http.StatusOK, Result{Code: 4, Msg: "文章的处理方式不对"})
+	default:
+		log.Println("申请注销失败", err)
+		ctx.JSON(http.StatusOK, Result{Code: 5, Msg: "系统错误"})
+	}
+}
+
+// CancelDelete 冷静期里面取消注销
+func (h *PrivacyHandler) CancelDelete(ctx *gin.Context) {
+	uid := CurrentUid(ctx)
+	err := h.deletionSvc.Cancel(ctx.Request.Context(), uid)
+	switch err {
+	case nil:
+		ctx.JSON(http.StatusOK, Result{Msg: "已取消注销"})
+	case service.ErrNoPendingDeletion:
+		ctx.JSON(http.StatusOK, Result{Code: 4, Msg: "没有可以取消的注销申请"})
+	default:
+		log.Println("取消注销失败", err)
+		ctx.JSON(http.StatusOK, Result{Code: 5, Msg: "系统错误"})
+	}
+}
+
+// DeleteStatus 最近一次注销申请
+func (h *PrivacyHandler) DeleteStatus(ctx *gin.Context) {
+	uid := CurrentUid(ctx)
+	d, err := h.deletionSvc.Status(ctx.Request.Context(), uid)
+	switch err {
+	case nil:
+		ctx.JSON(http.StatusOK, Result{Data: newAccountDeletionVO(d)})
+	case service.ErrAccountDeletionNotFound:
+		ctx.JSON(http.StatusOK, Result{})
+	default:
+		log.Println("查询注销申请失败", err)
+		ctx.JSON(http.StatusOK, Result{Code: 5, Msg: "系统错误"})
+	}
+}
+
+type DataExportVO struct {
+	Id int64 `json:"id"`
+	// 1 是排队中，2 是打包好了，3 是失败了，4 是过期了
+	Status uint8 `json:"status"`
+	// 打包好了才有，带签名的下载地址
+	URL   string `json:"url,omitempty"`
+	Size  int64  `json:"size"`
+	Ctime string `json:"ctime"`
+}
+
+func newDataExportVO(e domain.DataExport) DataExportVO {
+	return DataExportVO{
+		Id:     e.Id,
+		Status: uint8(e.Status),
+		URL:    e.URL,
+		Size:   e.Size,
+		Ctime:  e.Ctime.Format(time.DateTime),
+	}
+}
+
+// Export 申请导出，已经在排队的话返回排队的那一个
+func (h *PrivacyHandler) Export(ctx *gin.Context) {
+	uid := CurrentUid(ctx)
+	e, err := h.exportSvc.Request(ctx.Request.Context(), uid)
+	if err != nil {
+		log.Println("申请导出失败", err)
+		ctx.JSON(http.StatusOK, Result{Code: 5, Msg: "系统错误"})
+		return
+	}
+	ctx.JSON(http.StatusOK, Result{Data: newDataExportVO(e)})
+}
+
+type ExportDetailReq struct {
+	Id int64 `json:"id"`
+}
+
+// ExportDetail 前端轮询导出的进度
+func (h *PrivacyHandler) ExportDetail(ctx *gin.Context) {
+	var req ExportDetailReq
+	if !bind(ctx, &req) {
+		return
+	}
+	uid := CurrentUid(ctx)
+	e, err := h.exportSvc.Detail(ctx.Request.Context(), uid, req.Id)
+	switch err {
+	case nil:
+		ctx.JSON(http.StatusOK, Result{Data: newDataExportVO(e)})
+	case service.ErrDataExportNotFound:
+		ctx.JSON(http.StatusOK, Result{Code: 4, Msg: "导出记录不存在"})
+	default:
+		log.Println("查询导出进度失败", err)
+		ctx.JSON(http.StatusOK, Result{Code: 5, Msg: "系统错误"})
+	}
+}
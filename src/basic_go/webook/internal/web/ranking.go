@@ -0,0 +1,48 @@
+package web
+
+import (
+	"basic_go/webook/internal/service"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type RankingHandler struct {
+	svc *service.RankingService
+}
+
+func NewRankingHandler(svc *service.RankingService) *RankingHandler {
+	return &RankingHandler{
+		svc: svc,
+	}
+}
+
+// RegisterRoutes 热榜不用登录也能看
+func (h *RankingHandler) RegisterRoutes(server *gin.Engine) {
+	server.GET("/articles/hot", h.Hot)
+}
+
+var rankingOps = []Operation{
+	{Tag: "文章", Method: http.MethodGet, Path: "/articles/hot", Summary: "热榜，只有摘要", Resp: []ArticleVO{}, Public: true},
+}
+
+// Hot 热榜，定时任务算好的，不是实时的
+func (h *RankingHandler) Hot(ctx *gin.Context) {
+	arts, err := h.svc.TopN(ctx.Request.Context())
+	if err != nil {
+		log.Println("查询热榜失败", err)
+		ctx.JSON(http.StatusOK, Result{Code: 5, Msg: "系统错误"})
+		return
+	}
+	vos := make([]ArticleVO, 0, len(arts))
+	for _, a := range arts {
+		vo := newArticleVO(a.Article)
+		// 热榜里面只有摘要
+		vo.Content = ""
+		vo.ReadCnt = a.ReadCnt
+		vo.LikeCnt = a.LikeCnt
+		vos = append(vos, vo)
+	}
+	ctx.JSON(http.StatusOK, Result{Data: vos})
+}
@@ -0,0 +1,9 @@
+package web
+
+// Result 统一的 JSON 响应格式
+type Result struct {
+	// 0 代表成功，其它的代表出错了
+	Code int    `json:"code"`
+	Msg  string `json:"msg"`
+	Data any    `json:"data"`
+}
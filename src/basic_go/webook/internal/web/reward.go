@@ -0,0 +1,144 @@
+package web
+
+import (
+	"basic_go/webook/internal/domain"
+	"basic_go/webook/internal/service"
+	"basic_go/webook/pkg/payment"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type RewardHandler struct {
+	svc     *service.RewardService
+	artSvc  *service.ArticleService
+	gateway payment.Gateway
+}
+
+func NewRewardHandler(svc *service.RewardService, artSvc *service.ArticleService, gateway payment.Gateway) *RewardHandler {
+	return &RewardHandler{
+		svc:     svc,
+		artSvc:  artSvc,
+		gateway: gateway,
+	}
+}
+
+func (h *RewardHandler) RegisterRoutes(server *gin.Engine) {
+	g := server.Group("/reward")
+	g.POST("/article", h.RewardArticle)
+	g.POST("/detail", h.Detail)
+	// 第三方支付的回调，不需要登录
+	g.POST("/notify", h.Notify)
+}
+
+var rewardOps = []Operation{
+	{Tag: "打赏", Method: http.MethodPost, Path: "/reward/article", Summary: "打赏文章，返回扫码付款的链接", Req: RewardReq{},
+		Resp: struct {
+			Rid     int64  `json:"rid"`
+			CodeURL string `json:"codeURL"`
+		}{}},
+	{Tag: "打赏", Method: http.MethodPost, Path: "/reward/detail", Summary: "查询打赏订单的状态", Req: RewardDetailReq{},
+		Resp: struct {
+			Rid    int64 `json:"rid"`
+			Amount int64 `json:"amount"`
+			Status uint8 `json:"status"`
+		}{}},
+	{Tag: "打赏", Method: http.MethodPost, Path: "/reward/notify", Summary: "第三方支付的回调，返回 SUCCESS 或者 FAIL", Text: true, Public: true},
+}
+
+type RewardReq struct {
+	Id int64 `json:"id"`
+	// 单位是分
+	Amount int64 `json:"amount"`
+}
+
+// RewardArticle 打赏文章，返回付钱用的二维码链接
+func (h *RewardHandler) RewardArticle(ctx *gin.Context) {
+	var req RewardReq
+	if !bind(ctx, &req) {
+		return
+	}
+	art, err := h.artSvc.GetPublished(ctx.Request.Context(), req.Id)
+	if err == service.ErrArticleNotFound {
+		ctx.JSON(http.StatusOK, Result{Code: 4, Msg: "文章不存在"})
+		return
+	}
+	if err != nil {
+		log.Println("查找文章失败", err)
+		ctx.JSON(http.StatusOK, Result{Code: 5, Msg: "系统错误"})
+		return
+	}
+	uid := CurrentUid(ctx)
+	codeURL, err := h.svc.PreReward(ctx.Request.Context(), domain.Reward{
+		Uid: uid,
+		Target: domain.RewardTarget{
+			Biz:     "article",
+			BizId:   art.Id,
+			BizName: art.Title,
+			Uid:     art.Author.Id,
+		},
+		Amount: req.Amount,
+	})
+	switch err {
+	case nil:
+		ctx.JSON(http.StatusOK, Result{Data: gin.H{
+			"rid":     codeURL.Rid,
+			"codeURL": codeURL.URL,
+		}})
+	case service.ErrInvalidRewardAmount:
+		ctx.JSON(http.StatusOK, Result{Code: 4, Msg: "打赏金额不对"})
+	default:
+		log.Println("打赏下单失败", err)
+		ctx.JSON(http.StatusOK, Result{Code: 5, Msg: "系统错误"})
+	}
+}
+
+type RewardDetailReq struct {
+	Rid int64 `json:"rid"`
+}
+
+// Detail 前端轮询订单状态
+func (h *RewardHandler) Detail(ctx *gin.Context) {
+	var req RewardDetailReq
+	if !bind(ctx, &req) {
+		return
+	}
+	uid := CurrentUid(ctx)
+	r, err := h.svc.GetReward(ctx.Request.Context(), req.Rid, uid)
+	switch err {
+	case nil:
+		ctx.JSON(http.StatusOK, Result{Data: gin.H{
+			"rid":    r.Id,
+			"amount": r.Amount,
+			"status": uint8(r.Status),
+		}})
+	case service.ErrRewardNotFound:
+		ctx.JSON(http.StatusOK, Result{Code: 4, Msg: "订单不存在"})
+	default:
+		log.Println("查找打赏订单失败", err)
+		ctx.JSON(http.StatusOK, Result{Code: 5, Msg: "系统错误"})
+	}
+}
+
+// Notify 第三方支付的回调。返回非 200 第三方会重试
+func (h *RewardHandler) Notify(ctx *gin.Context) {
+	txn, err := h.gateway.ParseNotification(ctx.Request)
+	if err == payment.ErrInvalidSignature {
+		log.Println("支付回调签名错误", ctx.ClientIP())
+		ctx.String(http.StatusBadRequest, "FAIL")
+		return
+	}
+	if err != nil {
+		log.Println("解析支付回调失败", err)
+		ctx.String(http.StatusBadRequest, "FAIL")
+		return
+	}
+	err = h.svc.HandleTransaction(ctx.Request.Context(), txn)
+	if err != nil {
+		log.Println("处理支付回调失败", txn.OutTradeNo, err)
+		ctx.String(http.StatusInternalServerError, "FAIL")
+		return
+	}
+	ctx.String(http.StatusOK, "SUCCESS")
+}
@@ -0,0 +1,93 @@
+package web
+
+import (
+	"basic_go/webook/internal/service"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SearchHandler struct {
+	svc *service.ArticleSearchService
+}
+
+func NewSearchHandler(svc *service.ArticleSearchService) *SearchHandler {
+	return &SearchHandler{
+		svc: svc,
+	}
+}
+
+func (h *SearchHandler) RegisterRoutes(server *gin.Engine) {
+	g := server.Group("/search")
+	g.GET("/articles", h.SearchArticles)
+}
+
+var searchOps = []Operation{
+	{Tag: "搜索", Method: http.MethodGet, Path: "/search/articles", Summary: "搜索文章", Req: SearchArticlesReq{},
+		Resp: struct {
+			Total int               `json:"total"`
+			List  []ArticleSearchVO `json:"list"`
+		}{}},
+}
+
+// ArticleSearchVO 搜索结果。Title 和 Abstract 是高亮过的 HTML，前端直接渲染
+type ArticleSearchVO struct {
+	Id       int64  `json:"id"`
+	Title    string `json:"title"`
+	Abstract string `json:"abstract"`
+	AuthorId int64  `json:"authorId"`
+	Utime    string `json:"utime"`
+
+	ReadCnt int64 `json:"readCnt"`
+	LikeCnt int64 `json:"likeCnt"`
+}
+
+type SearchArticlesReq struct {
+	Q      string `form:"q"`
+	Offset int    `form:"offset"`
+	Limit  int    `form:"limit"`
+}
+
+// SearchArticles GET /search/articles?q=关键词&offset=0&limit=10
+func (h *SearchHandler) SearchArticles(ctx *gin.Context) {
+	var req SearchArticlesReq
+	if !bind(ctx, &req) {
+		return
+	}
+	req.Q = strings.TrimSpace(req.Q)
+	if req.Q == "" {
+		ctx.JSON(http.StatusOK, Result{Code: 4, Msg: "请输入关键词"})
+		return
+	}
+	if req.Offset < 0 {
+		req.Offset = 0
+	}
+	if req.Limit <= 0 || req.Limit > 50 {
+		req.Limit = 10
+	}
+	res, err := h.svc.Search(ctx.Request.Context(), req.Q, req.Offset, req.Limit)
+	if err != nil {
+		log.Println("搜索文章失败", err)
+		ctx.JSON(http.StatusOK, Result{Code: 5, Msg: "系统错误"})
+		return
+	}
+	vos := make([]ArticleSearchVO, 0, len(res.Hits))
+	for _, hit := range res.Hits {
+		vos = append(vos, ArticleSearchVO{
+			Id:       hit.Article.Id,
+			Title:    hit.TitleHighlight,
+			Abstract: hit.ContentHighlight,
+			AuthorId: hit.Article.Author.Id,
+			Utime:    hit.Article.Utime.Format(time.DateTime),
+			ReadCnt:  hit.Interactive.ReadCnt,
+			LikeCnt:  hit.Interactive.LikeCnt,
+		})
+	}
+	ctx.JSON(http.StatusOK, Result{Data: gin.H{
+		"total": res.Total,
+		"list":  vos,
+	}})
+}
@@ -0,0 +1,193 @@
+package web
+
+import (
+	"basic_go/webook/internal/service"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	jwt "github.com/golang-jwt/jwt/v5"
+)
+
+// TwoFactorJWTKey 中间 token 用单独的 key 签名，
+// 这样它没办法被当成正常的登录 token 用
+var TwoFactorJWTKey = []byte("mQ9#tL2vX!pR7kZ@wE4yH8uJ0nB3cF6dG1sA5qW*eT^iO&lK%zV$xC(rY)bN+m-")
+
+// TwoFactorClaims 密码校验通过，但是还没有通过二次验证。
+// RegisteredClaims.ID 是 jti，服务端靠它保证一个 token 只能成功用一次
+type TwoFactorClaims struct {
+	jwt.RegisteredClaims
+	Uid int64
+}
+
+// requireTwoFactor 开启了二次验证的话，返回中间 token，登录到这里就结束了。
+// 返回 true 代表已经写了响应
+func (h *UserHandler) requireTwoFactor(ctx *gin.Context, uid int64) bool {
+	enabled, err := h.twoFactorSvc.Enabled(ctx.Request.Context(), uid)
+	if err != nil {
+		ctx.String(http.StatusOK, "系统错误")
+		return true
+	}
+	if !enabled {
+		return false
+	}
+	var jti [16]byte
+	if _, err = rand.Read(jti[:]); err != nil {
+		ctx.String(http.StatusOK, "系统错误")
+		return true
+	}
+	tc := TwoFactorClaims{
+		Uid: uid,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID: hex.EncodeToString(jti[:]),
+			// 给用户五分钟打开 App 输入验证码
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute * 5)),
+		},
+	}
+	tokenStr, err := jwt.NewWithClaims(jwt.SigningMethodHS512, tc).SignedString(TwoFactorJWTKey)
+	if err != nil {
+		ctx.String(http.StatusOK, "系统错误")
+		return true
+	}
+	ctx.Header("x-2fa-token", tokenStr)
+	ctx.String(http.StatusOK, "请输入二次验证码")
+	return true
+}
+
+type LoginTwoFactorReq struct {
+	Token string `json:"token"`
+	// TOTP 验证码或者恢复码
+	Code string `json:"code"`
+}
+
+// LoginTwoFactor 登录的第二步，拿中间 token 和验证码换正式的 JWT token
+func (h *UserHandler) LoginTwoFactor(ctx *gin.Context) {
+	var req LoginTwoFactorReq
+	if !bind(ctx, &req) {
+		return
+	}
+	var tc TwoFactorClaims
+	token, err := jwt.ParseWithClaims(req.Token, &tc, func(token *jwt.Token) (interface{}, error) {
+		return TwoFactorJWTKey, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS512.Alg()}))
+	if err != nil || token == nil || !token.Valid || tc.ID == "" || tc.ExpiresAt == nil {
+		ctx.String(http.StatusOK, "登录已过期，请重新登录")
+		return
+	}
+	err = h.twoFactorSvc.VerifyLogin(ctx.Request.Context(), tc.Uid, tc.ID, tc.ExpiresAt.Time, req.Code)
+	switch err {
+	case nil:
+		err = h.checkStatus(ctx.Request.Context(), tc.Uid)
+		if err == nil {
+			err = h.setLoginState(ctx, tc.Uid)
+		}
+		switch err {
+		case nil:
+			ctx.String(http.StatusOK, "登录成功")
+		case service.ErrUserDisabled:
+			ctx.String(http.StatusOK, "账号已被禁用")
+		case service.ErrUserBanned:
+			ctx.String(http.StatusOK, "账号已被封禁")
+		default:
+			ctx.String(http.StatusOK, "系统错误")
+		}
+	case service.ErrInvalidTwoFactorCode:
+		ctx.String(http.StatusOK, "验证码错误")
+	case service.ErrTwoFactorTokenInvalid:
+		ctx.String(http.StatusOK, "验证失败次数过多或者已经登录过了，请重新登录")
+	default:
+		ctx.String(http.StatusOK, "系统错误")
+	}
+}
+
+// checkStatus 输验证码的这几分钟里面账号可能被禁用或者封禁了，
+// 新 token 的登录时间比下线的时间晚，不检查的话就绕过去了
+func (h *UserHandler) checkStatus(ctx context.Context, uid int64) error {
+	u, err := h.svc.FindById(ctx, uid)
+	if err != nil {
+		return err
+	}
+	return service.CheckUserStatus(u)
+}
+
+// EnrollTwoFactor 绑定 TOTP，返回 otpauth 链接和恢复码。恢复码只展示这一次
+func (h *UserHandler) EnrollTwoFactor(ctx *gin.Context) {
+	uid := CurrentUid(ctx)
+	u, err := h.svc.FindById(ctx.Request.Context(), uid)
+	if err != nil {
+		ctx.JSON(http.StatusOK, Result{Code: 5, Msg: "系统错误"})
+		return
+	}
+	e, err := h.twoFactorSvc.Enroll(ctx.Request.Context(), u)
+	switch err {
+	case nil:
+		ctx.JSON(http.StatusOK, Result{
+			Data: gin.H{
+				"secret":        e.Secret,
+				"uri":           e.URI,
+				"recoveryCodes": e.RecoveryCodes,
+			},
+		})
+	case service.ErrTwoFactorAlreadyEnabled:
+		ctx.JSON(http.StatusOK, Result{Code: 4, Msg: "已经开启了二次验证"})
+	default:
+		log.Println(err)
+		ctx.JSON(http.StatusOK, Result{Code: 5, Msg: "系统错误"})
+	}
+}
+
+type TwoFactorActivateReq struct {
+	Code string `json:"code"`
+}
+
+func (h *UserHandler) ActivateTwoFactor(ctx *gin.Context) {
+	var req TwoFactorActivateReq
+	if !bind(ctx, &req) {
+		return
+	}
+	uid := CurrentUid(ctx)
+	err := h.twoFactorSvc.Activate(ctx.Request.Context(), uid, req.Code)
+	switch err {
+	case nil:
+		ctx.JSON(http.StatusOK, Result{Msg: "二次验证已开启"})
+	case service.ErrInvalidTwoFactorCode:
+		ctx.JSON(http.StatusOK, Result{Code: 4, Msg: "验证码错误"})
+	case service.ErrTwoFactorLocked:
+		ctx.JSON(http.StatusOK, Result{Code: 4, Msg: "验证码错误次数过多，请稍后再试"})
+	case service.ErrTwoFactorNotEnrolled:
+		ctx.JSON(http.StatusOK, Result{Code: 4, Msg: "请先绑定二次验证"})
+	case service.ErrTwoFactorAlreadyEnabled:
+		ctx.JSON(http.StatusOK, Result{Code: 4, Msg: "已经开启了二次验证"})
+	default:
+		ctx.JSON(http.StatusOK, Result{Code: 5, Msg: "系统错误"})
+	}
+}
+
+type TwoFactorDisableReq struct {
+	Code string `json:"code"`
+}
+
+func (h *UserHandler) DisableTwoFactor(ctx *gin.Context) {
+	var req TwoFactorDisableReq
+	if !bind(ctx, &req) {
+		return
+	}
+	uid := CurrentUid(ctx)
+	err := h.twoFactorSvc.Disable(ctx.Request.Context(), uid, req.Code)
+	switch err {
+	case nil:
+		ctx.JSON(http.StatusOK, Result{Msg: "二次验证已关闭"})
+	case service.ErrInvalidTwoFactorCode:
+		ctx.JSON(http.StatusOK, Result{Code: 4, Msg: "验证码错误"})
+	case service.ErrTwoFactorLocked:
+		ctx.JSON(http.StatusOK, Result{Code: 4, Msg: "验证码错误次数过多，请稍后再试"})
+	case service.ErrTwoFactorNotEnrolled:
+		ctx.JSON(http.StatusOK, Result{Code: 4, Msg: "还没有开启二次验证"})
+	default:
+		ctx.JSON(http.StatusOK, Result{Code: 5, Msg: "系统错误"})
+	}
+}
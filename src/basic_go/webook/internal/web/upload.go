@@ -0,0 +1,169 @@
+package web
+
+import (
+	"basic_go/webook/internal/domain"
+	"basic_go/webook/internal/service"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type UploadHandler struct {
+	svc     *service.UploadService
+	userSvc *service.LocalUserService
+	// 请求体最大多少字节，要比图片的大小限制大一点，multipart 还有别的内容
+	maxBody int64
+}
+
+func NewUploadHandler(svc *service.UploadService, userSvc *service.LocalUserService, maxBody int64) *UploadHandler {
+	return &UploadHandler{
+		svc:     svc,
+		userSvc: userSvc,
+		maxBody: maxBody,
+	}
+}
+
+func (h *UploadHandler) RegisterRoutes(server *gin.Engine) {
+	g := server.Group("/upload")
+	g.POST("/image", h.UploadImage)
+	g.POST("/sign", h.Sign)
+	g.POST("/avatar", h.SetAvatar)
+}
+
+var uploadOps = []Operation{
+	{Tag: "文件", Method: http.MethodPost, Path: "/upload/image", Summary: "上传图片", Req: UploadImageForm{}, Multipart: true, Resp: UploadVO{}},
+	{Tag: "文件", Method: http.MethodPost, Path: "/upload/sign", Summary: "拿私有文件的访问地址", Req: UploadSignReq{},
+		Resp: struct {
+			URL string `json:"url"`
+		}{}},
+	{Tag: "文件", Method: http.MethodPost, Path: "/upload/avatar", Summary: "把上传好的图片设置成头像", Req: AvatarReq{},
+		Resp: struct {
+			Avatar string `json:"avatar"`
+		}{}},
+}
+
+type UploadVO struct {
+	Key    string `json:"key"`
+	URL    string `json:"url"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+	Size   int64  `json:"size"`
+}
+
+// UploadImageForm 只是给文档用的，UploadImage 自己从 multipart 表单里面取
+type UploadImageForm struct {
+	File    string `json:"file" format:"binary"`
+	Biz     string `json:"biz"`
+	Private bool   `json:"private"`
+}
+
+// UploadImage multipart 表单：file 是文件，biz 是 avatar 或者 article，private 是 true 的话只有自己能看
+func (h *UploadHandler) UploadImage(ctx *gin.Context) {
+	ctx.Request.Body = http.MaxBytesReader(ctx.Writer, ctx.Request.Body, h.maxBody)
+	biz := ctx.PostForm("biz")
+	if biz != domain.UploadBizAvatar && biz != domain.UploadBizArticle {
+		ctx.JSON(http.StatusOK, Result{Code: 4, Msg: "不支持的业务"})
+		return
+	}
+	private := ctx.PostForm("private") == "true"
+	if private && biz == domain.UploadBizAvatar {
+		// 头像大家都要看，签名地址会过期
+		ctx.JSON(http.StatusOK, Result{Code: 4, Msg: "头像不能是私有的"})
+		return
+	}
+	fh, err := ctx.FormFile("file")
+	if err != nil {
+		ctx.JSON(http.StatusOK, Result{Code: 4, Msg: "没有文件，或者文件太大了"})
+		return
+	}
+	f, err := fh.Open()
+	if err != nil {
+		log.Println("打开上传的文件失败", err)
+		ctx.JSON(http.StatusOK, Result{Code: 5, Msg: "系统错误"})
+		return
+	}
+	defer f.Close()
+	uid := CurrentUid(ctx)
+	u, err := h.svc.UploadImage(ctx.Request.Context(), domain.Upload{
+		Uid:     uid,
+		Biz:     biz,
+		Private: private,
+	}, f)
+	switch err {
+	case nil:
+		ctx.JSON(http.StatusOK, Result{Data: UploadVO{
+			Key:    u.Key,
+			URL:    u.URL,
+			Width:  u.Width,
+			Height: u.Height,
+			Size:   u.Size,
+		}})
+	case service.ErrFileTooLarge:
+		ctx.JSON(http.StatusOK, Result{Code: 4, Msg: "文件太大了"})
+	case service.ErrUnsupportedFileType:
+		ctx.JSON(http.StatusOK, Result{Code: 4, Msg: "只支持 JPEG、PNG 和 GIF 图片"})
+	case service.ErrInvalidImage:
+		ctx.JSON(http.StatusOK, Result{Code: 4, Msg: "图片格式不对，或者尺寸太大了"})
+	default:
+		log.Println("上传文件失败", err)
+		ctx.JSON(http.StatusOK, Result{Code: 5, Msg: "系统错误"})
+	}
+}
+
+type UploadSignReq struct {
+	Key string `json:"key"`
+}
+
+// Sign 拿私有文件的访问地址，地址一会儿就过期了
+func (h *UploadHandler) Sign(ctx *gin.Context) {
+	var req UploadSignReq
+	if !bind(ctx, &req) {
+		return
+	}
+	uid := CurrentUid(ctx)
+	url, err := h.svc.SignURL(ctx.Request.Context(), uid, req.Key)
+	switch err {
+	case nil:
+		ctx.JSON(http.StatusOK, Result{Data: gin.H{
+			"url": url,
+		}})
+	case service.ErrUploadNotFound:
+		ctx.JSON(http.StatusOK, Result{Code: 4, Msg: "文件不存在"})
+	default:
+		log.Println("签名失败", err)
+		ctx.JSON(http.StatusOK, Result{Code: 5, Msg: "系统错误"})
+	}
+}
+
+type AvatarReq struct {
+	Key string `json:"key"`
+}
+
+// SetAvatar 把上传好的图片设置成头像
+func (h *UploadHandler) SetAvatar(ctx *gin.Context) {
+	var req AvatarReq
+	if !bind(ctx, &req) {
+		return
+	}
+	uid := CurrentUid(ctx)
+	u, err := h.svc.AttachOne(ctx.Request.Context(), uid, domain.UploadBizAvatar, uid, req.Key)
+	if err == service.ErrUploadNotFound {
+		ctx.JSON(http.StatusOK, Result{Code: 4, Msg: "文件不存在"})
+		return
+	}
+	if err != nil {
+		log.Println("设置头像失败", err)
+		ctx.JSON(http.StatusOK, Result{Code: 5, Msg: "系统错误"})
+		return
+	}
+	err = h.userSvc.UpdateAvatar(ctx.Request.Context(), uid, u.URL)
+	if err != nil {
+		log.Println("设置头像失败", err)
+		ctx.JSON(http.StatusOK, Result{Code: 5, Msg: "系统错误"})
+		return
+	}
+	ctx.JSON(http.StatusOK, Result{Data: gin.H{
+		"avatar": u.URL,
+	}})
+}
@@ -3,32 +3,31 @@ package web
 import (
 	"basic_go/webook/internal/domain"
 	"basic_go/webook/internal/service"
+	"errors"
+	"log"
 	"net/http"
 	"time"
 
-	regexp "github.com/dlclark/regexp2"
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
 	jwt "github.com/golang-jwt/jwt/v5"
 )
 
-const (
-	emailRegexPattern = "^\\w+([-+.]\\w+)*@\\w+([-.]\\w+)*\\.\\w+([-.]\\w+)*$"
-	// 和上面比起来，用 ` 看起来就比较清爽
-	passwordRegexPattern = `^(?=.*[A-Za-z])(?=.*\d)(?=.*[$@$!%*#?&])[A-Za-z\d$@$!%*#?&]{8,}$`
-)
-
 type UserHandler struct {
-	emailRegex *regexp.Regexp
-	password   *regexp.Regexp
-	svc        *service.UserService
+	svc          service.UserService
+	twoFactorSvc *service.TwoFactorService
+	followSvc    *service.FollowService
+	// 登录成功之后返回 JWT 还是设置 session，要和登录校验的 middleware 一致
+	authMode AuthMode
 }
 
-func NewUserHandler(svc *service.UserService) *UserHandler {
+func NewUserHandler(svc service.UserService, twoFactorSvc *service.TwoFactorService,
+	followSvc *service.FollowService, authMode AuthMode) *UserHandler {
 	return &UserHandler{
-		emailRegex: regexp.MustCompile(emailRegexPattern, regexp.None),
-		password:   regexp.MustCompile(passwordRegexPattern, regexp.None),
-		svc:        svc,
+		svc:          svc,
+		twoFactorSvc: twoFactorSvc,
+		followSvc:    followSvc,
+		authMode:     authMode,
 	}
 }
 func (h *UserHandler) RegisterRoutes(server *gin.Engine) {
@@ -42,58 +41,62 @@ func (h *UserHandler) RegisterRoutes(server *gin.Engine) {
 	//POST /users/signup
 	ug.POST("/signup", h.SignUp)
 	//POST /users/login
-	//ug.POST("/login", h.Login)
-	ug.POST("/login", h.LoginJWT)
+	ug.POST("/login", h.Login)
 
 	//POST /users/edit
 	ug.POST("/edit", h.Edit)
 	//GET /users/profile
 	ug.GET("/profile", h.Profile)
-}
-
-func (h *UserHandler) SignUp(ctx *gin.Context) {
-	type SignUpReq struct {
-		Email           string `json:"email" `
-		Password        string `json:"password" `
-		ConfirmPassword string `json:"confirmPassword" `
-	}
-
-	var req SignUpReq
-	if err := ctx.Bind(&req); err != nil {
-		return
-	}
 
-	isEmail, err := h.emailRegex.MatchString(req.Email)
+	// 二次验证
+	ug.POST("/login/2fa", h.LoginTwoFactor)
+	ug.POST("/2fa/enroll", h.EnrollTwoFactor)
+	ug.POST("/2fa/activate", h.ActivateTwoFactor)
+	ug.POST("/2fa/disable", h.DisableTwoFactor)
+}
 
-	if err != nil {
-		ctx.String(http.StatusOK, "系统错误")
-		return
-	}
-	if !isEmail {
-		ctx.String(http.StatusOK, "非法邮箱格式")
-		return
-	}
+var userOps = []Operation{
+	{Tag: "用户", Method: http.MethodPost, Path: "/users/signup", Summary: "注册", Req: SignUpReq{}, Text: true, Public: true},
+	{Tag: "用户", Method: http.MethodPost, Path: "/users/login", Req: LoginReq{}, Text: true, Public: true,
+		Summary: "登录。开了二次验证的话返回 请输入二次验证码，响应头 x-2fa-token 带上临时 token"},
+	{Tag: "用户", Method: http.MethodPost, Path: "/users/login/2fa", Summary: "二次验证登录，Authorization 带上 x-2fa-token",
+		Req: LoginTwoFactorReq{}, Text: true, Public: true},
+	{Tag: "用户", Method: http.MethodPost, Path: "/users/edit", Summary: "编辑个人信息，还没实现"},
+	{Tag: "用户", Method: http.MethodGet, Path: "/users/profile", Summary: "个人信息", Resp: ProfileVO{}},
+	{Tag: "用户", Method: http.MethodPost, Path: "/users/2fa/enroll", Summary: "绑定二次验证，拿到密钥和恢复码",
+		Resp: struct {
+			Secret        string   `json:"secret"`
+			URI           string   `json:"uri"`
+			RecoveryCodes []string `json:"recoveryCodes"`
+		}{}},
+	{Tag: "用户", Method: http.MethodPost, Path: "/users/2fa/activate", Summary: "输入验证码开启二次验证", Req: TwoFactorActivateReq{}},
+	{Tag: "用户", Method: http.MethodPost, Path: "/users/2fa/disable", Summary: "关闭二次验证", Req: TwoFactorDisableReq{}},
+}
 
-	if req.Password != req.ConfirmPassword {
-		ctx.String(http.StatusOK, "密码两次输入错误")
-		return
-	}
+type SignUpReq struct {
+	Email           string `json:"email" binding:"required,email"`
+	Password        string `json:"password" binding:"required,password"`
+	ConfirmPassword string `json:"confirmPassword" binding:"required,eqfield=Password"`
+}
 
-	isPassword, err := h.password.MatchString(req.Password)
-	if err != nil {
-		ctx.String(http.StatusOK, "系统错误")
-		return
-	}
-	if !isPassword {
-		ctx.String(http.StatusOK, "密码格式错误")
+func (h *UserHandler) SignUp(ctx *gin.Context) {
+	var req SignUpReq
+	// 邮箱、密码强度和两次密码一不一样在 bind 的时候就校验了
+	if !bind(ctx, &req) {
 		return
 	}
 
-	err = h.svc.Signup(ctx, domain.User{
+	err := h.svc.Signup(ctx.Request.Context(), domain.User{
 		Email:    req.Email,
 		Password: req.Password,
 	})
 
+	var policyErr domain.PasswordPolicyError
+	if errors.As(err, &policyErr) {
+		ctx.String(http.StatusOK, "密码格式错误："+policyErr.Error())
+		return
+	}
+
 	switch err {
 	case nil:
 		ctx.String(http.StatusOK, "hello 欢迎注册")
@@ -108,91 +111,130 @@ func (h *UserHandler) SignUp(ctx *gin.Context) {
 
 }
 
-func (h *UserHandler) LoginJWT(ctx *gin.Context) {
-	type LoginReq struct {
-		Email    string `json:"email" `
-		Password string `json:"password" `
-	}
+type LoginReq struct {
+	Email    string `json:"email" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+// Login 按照 authMode 返回 JWT 或者设置 session
+func (h *UserHandler) Login(ctx *gin.Context) {
 	var req LoginReq
-	if err := ctx.Bind(&req); err != nil {
+	if !bind(ctx, &req) {
 		return
 	}
 
-	u, err := h.svc.Login(ctx, req.Email, req.Password)
+	u, err := h.svc.Login(ctx.Request.Context(), req.Email, req.Password, ctx.ClientIP())
 	switch err {
 	case nil:
-		uc := UserClaims{
-			Uid: u.Id,
-			RegisteredClaims: jwt.RegisteredClaims{
-				// 1分钟到期
-				ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
-			},
+		if h.requireTwoFactor(ctx, u.Id) {
+			return
 		}
-
-		token := jwt.NewWithClaims(jwt.SigningMethodHS512, uc)
-		tokenStr, err := token.SignedString(JWTKey)
+		err = h.setLoginState(ctx, u.Id)
 		if err != nil {
 			ctx.String(http.StatusOK, "系统错误")
+			return
 		}
-		ctx.Header("x-jwt-token", tokenStr)
-
 		ctx.String(http.StatusOK, "登录成功")
 	case service.ErrInvalidUserOrPassword:
 		ctx.String(http.StatusOK, "用户名或者密码错误")
+	case service.ErrLoginLocked:
+		ctx.String(http.StatusOK, "登录失败次数过多，账号已被临时锁定，请稍后再试")
+	case service.ErrLoginTooFrequent:
+		ctx.String(http.StatusOK, "登录过于频繁，请稍后再试")
+	case service.ErrUserDisabled:
+		ctx.String(http.StatusOK, "账号已被禁用")
+	case service.ErrUserBanned:
+		ctx.String(http.StatusOK, "账号已被封禁")
 	default:
 		ctx.String(http.StatusOK, "系统错误")
 
 	}
 }
 
-func (h *UserHandler) Login(ctx *gin.Context) {
-	type LoginReq struct {
-		Email    string `json:"email" `
-		Password string `json:"password" `
-	}
-	var req LoginReq
-	if err := ctx.Bind(&req); err != nil {
-		return
-	}
-
-	u, err := h.svc.Login(ctx, req.Email, req.Password)
-	switch err {
-	case nil:
+func (h *UserHandler) Edit(ctx *gin.Context) {
 
-		sess := sessions.Default(ctx)
-		sess.Set("userId", u.Id)
-		sess.Options(sessions.Options{
-			// 设置15分钟
-			MaxAge: 30,
-		})
-		err = sess.Save()
-		if err != nil {
-			ctx.String(http.StatusOK, "系统错误")
-			return
-		}
+}
 
-		ctx.String(http.StatusOK, "登录成功")
-	case service.ErrInvalidUserOrPassword:
-		ctx.String(http.StatusOK, "用户名或者密码错误")
-	default:
-		ctx.String(http.StatusOK, "系统错误")
+// ProfileVO 个人信息，带上粉丝数和关注数
+type ProfileVO struct {
+	Id          int64  `json:"id"`
+	Email       string `json:"email"`
+	Avatar      string `json:"avatar"`
+	Ctime       string `json:"ctime"`
+	FollowerCnt int64  `json:"followerCnt"`
+	FolloweeCnt int64  `json:"followeeCnt"`
+}
 
+func (h *UserHandler) Profile(ctx *gin.Context) {
+	uid := CurrentUid(ctx)
+	u, err := h.svc.Profile(ctx.Request.Context(), uid)
+	if err != nil {
+		ctx.JSON(http.StatusOK, Result{Code: 5, Msg: "系统错误"})
+		return
 	}
-
+	stats, err := h.followSvc.Stats(ctx.Request.Context(), uid)
+	if err != nil {
+		// 计数拿不到，个人信息还是可以看的
+		log.Println("查找关注数失败", err)
+	}
+	ctx.JSON(http.StatusOK, Result{Data: ProfileVO{
+		Id:          u.Id,
+		Email:       u.Email,
+		Avatar:      u.Avatar,
+		Ctime:       u.Ctime.Format(time.DateTime),
+		FollowerCnt: stats.Followers,
+		FolloweeCnt: stats.Followees,
+	}})
 }
 
-func (h *UserHandler) Edit(ctx *gin.Context) {
+// setLoginState 登录成功了，保持登录态
+func (h *UserHandler) setLoginState(ctx *gin.Context, uid int64) error {
+	if h.authMode == AuthModeSession {
+		return h.setSession(ctx, uid)
+	}
+	return h.setJWTToken(ctx, uid)
+}
 
+func (h *UserHandler) setSession(ctx *gin.Context, uid int64) error {
+	sess := sessions.Default(ctx)
+	sess.Set(SessionUidKey, uid)
+	// 被强制下线的时候要比较登录时间
+	sess.Set(LoginTimeKey, time.Now().UnixMilli())
+	return sess.Save()
 }
 
-func (h *UserHandler) Profile(ctx *gin.Context) {
-	//us := ctx.MustGet("user").(UserClaims)
-	ctx.String(http.StatusOK, "这是 profile")
+func (h *UserHandler) setJWTToken(ctx *gin.Context, uid int64) error {
+	now := time.Now()
+	uc := UserClaims{
+		Uid:       uid,
+		LoginTime: now.UnixMilli(),
+		RegisteredClaims: jwt.RegisteredClaims{
+			// 1分钟到期
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Minute)),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS512, uc)
+	tokenStr, err := token.SignedString(JWTKey)
+	if err != nil {
+		return err
+	}
+	ctx.Header("x-jwt-token", tokenStr)
+	return nil
 }
 
+// SessionUidKey session 里面存用户 ID 的 key
+const SessionUidKey = "userId"
+
+// LoginTimeKey session 里面存登录时间的 key，毫秒数
+const LoginTimeKey = "login_time"
+
 var JWTKey = []byte("aNaL?A*dqgo#oE3aPjmU,AE:D2bxNtPtK4P%,kXp.*Auqpd>}c!>iun=M?AhA5XW")
 
 type UserClaims struct {
 	jwt.RegisteredClaims
 	Uid int64
+	// LoginTime 登录时间的毫秒数，刷新的时候不会改，被强制下线的时候要比较。
+	// 不用 IssuedAt 是因为它只精确到秒，失效之后同一秒里面重新登录拿到的 token 也会被拒绝
+	LoginTime int64
 }
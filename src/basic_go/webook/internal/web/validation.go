@@ -0,0 +1,231 @@
+package web
+
+import (
+	"basic_go/webook/internal/domain"
+	"errors"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	regexp "github.com/dlclark/regexp2"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/locales/en"
+	"github.com/go-playground/locales/zh"
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+	entrans "github.com/go-playground/validator/v10/translations/en"
+	zhtrans "github.com/go-playground/validator/v10/translations/zh"
+	"golang.org/x/text/language"
+)
+
+const (
+	emailRegexPattern = "^\\w+([-+.]\\w+)*@\\w+([-.]\\w+)*\\.\\w+([-.]\\w+)*$"
+	// 大陆的手机号
+	phoneRegexPattern = "^1[3-9]\\d{9}$"
+)
+
+// FieldError 校验不通过的字段，Msg 已经按照 Accept-Language 翻译好了
+type FieldError struct {
+	// 和请求里面的 JSON 字段名一样
+	Field string `json:"field"`
+	// 没通过的规则，比如 required、email、password，前端想自己翻译的话用它
+	Tag string `json:"tag"`
+	Msg string `json:"msg"`
+}
+
+var (
+	// 第一个是默认的
+	langMatcher = language.NewMatcher([]language.Tag{language.SimplifiedChinese, language.AmericanEnglish})
+	// InitValidator 之前是 nil，这时候直接用校验器自己的英文错误信息
+	uni *ut.UniversalTranslator
+)
+
+// InitValidator 给 gin 的校验器加上 email、password 和 phone 三个规则，注册中英文的错误信息。
+// 请求结构体上写 binding:"required,email" 这样的 tag 就可以了，password 规则用的是 policy，
+// 同一个结构体里面有 Email 字段的话会一起检查密码里面有没有邮箱
+func InitValidator(policy domain.PasswordPolicy) error {
+	v, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return errors.New("gin 的校验器不是 validator/v10")
+	}
+	// 错误信息里面用 JSON 的字段名，和前端传的一样
+	v.RegisterTagNameFunc(func(f reflect.StructField) string {
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			return f.Name
+		}
+		return name
+	})
+
+	emailRegex := regexp.MustCompile(emailRegexPattern, regexp.None)
+	phoneRegex := regexp.MustCompile(phoneRegexPattern, regexp.None)
+	validations := map[string]validator.Func{
+		// 替换掉自带的 email，和以前注册的时候手写的校验保持一致
+		"email": func(fl validator.FieldLevel) bool {
+			ok, err := emailRegex.MatchString(fl.Field().String())
+			return err == nil && ok
+		},
+		"phone": func(fl validator.FieldLevel) bool {
+			ok, err := phoneRegex.MatchString(fl.Field().String())
+			return err == nil && ok
+		},
+		"password": func(fl validator.FieldLevel) bool {
+			var email string
+			if parent := fl.Parent(); parent.Kind() == reflect.Struct {
+				if f := parent.FieldByName("Email"); f.IsValid() && f.Kind() == reflect.String {
+					email = f.String()
+				}
+			}
+			return len(policy.Validate(fl.Field().String(), email)) == 0
+		},
+	}
+	for tag, fn := range validations {
+		if err := v.RegisterValidation(tag, fn); err != nil {
+			return err
+		}
+	}
+
+	zhT, enT := zh.New(), en.New()
+	u := ut.New(zhT, zhT, enT)
+	zhTrans, _ := u.GetTranslator(zhT.Locale())
+	enTrans, _ := u.GetTranslator(enT.Locale())
+	if err := zhtrans.RegisterDefaultTranslations(v, zhTrans); err != nil {
+		return err
+	}
+	if err := entrans.RegisterDefaultTranslations(v, enTrans); err != nil {
+		return err
+	}
+	msgs := map[ut.Translator]map[string]string{
+		zhTrans: {
+			"email":    "{0}不是合法的邮箱",
+			"phone":    "{0}不是合法的手机号",
+			"password": "{0}" + passwordRuleZH(policy),
+			"eqfield":  "{0}和{1}不一致",
+		},
+		enTrans: {
+			"email":    "{0} must be a valid email address",
+			"phone":    "{0} must be a valid mobile phone number",
+			"password": "{0}" + passwordRuleEN(policy),
+			"eqfield":  "{0} does not match {1}",
+		},
+	}
+	for trans, m := range msgs {
+		for tag, msg := range m {
+			err := v.RegisterTranslation(tag, trans, func(ut ut.Translator) error {
+				return ut.Add(tag, msg, true)
+			}, translateFieldError)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	uni = u
+	return nil
+}
+
+func translateFieldError(trans ut.Translator, fe validator.FieldError) string {
+	// eqfield 的参数是 Go 的字段名，换成 JSON 的
+	msg, err := trans.T(fe.Tag(), fe.Field(), lowerFirst(fe.Param()))
+	if err != nil {
+		return fe.Error()
+	}
+	return msg
+}
+
+func lowerFirst(s string) string {
+	r, size := utf8.DecodeRuneInString(s)
+	if size == 0 {
+		return s
+	}
+	return string(unicode.ToLower(r)) + s[size:]
+}
+
+func passwordRuleZH(p domain.PasswordPolicy) string {
+	rules := []string{"至少 " + strconv.Itoa(p.MinLength) + " 位"}
+	if len(p.RequiredClasses) > 0 {
+		classes := make([]string, 0, len(p.RequiredClasses))
+		for _, c := range p.RequiredClasses {
+			classes = append(classes, c.String())
+		}
+		rules = append(rules, "必须包含"+strings.Join(classes, "、"))
+	}
+	if len(p.Denylist) > 0 {
+		rules = append(rules, "不能是常见密码")
+	}
+	if p.ForbidEmail {
+		rules = append(rules, "不能包含邮箱")
+	}
+	return "不符合要求：" + strings.Join(rules, "，")
+}
+
+var charClassEN = map[domain.CharClass]string{
+	domain.CharClassLetter: "letters",
+	domain.CharClassLower:  "lowercase letters",
+	domain.CharClassUpper:  "uppercase letters",
+	domain.CharClassDigit:  "digits",
+	domain.CharClassSymbol: "symbols",
+}
+
+func passwordRuleEN(p domain.PasswordPolicy) string {
+	rules := []string{"be at least " + strconv.Itoa(p.MinLength) + " characters"}
+	if len(p.RequiredClasses) > 0 {
+		classes := make([]string, 0, len(p.RequiredClasses))
+		for _, c := range p.RequiredClasses {
+			classes = append(classes, charClassEN[c])
+		}
+		rules = append(rules, "contain "+strings.Join(classes, ", "))
+	}
+	if len(p.Denylist) > 0 {
+		rules = append(rules, "not be a common password")
+	}
+	if p.ForbidEmail {
+		rules = append(rules, "not contain the email")
+	}
+	return " must " + strings.Join(rules, ", ")
+}
+
+// translator 按照 Accept-Language 选，都不支持的话用中文
+func translator(ctx *gin.Context) ut.Translator {
+	tag, _ := language.MatchStrings(langMatcher, ctx.GetHeader("Accept-Language"))
+	base, _ := tag.Base()
+	trans, _ := uni.GetTranslator(base.String())
+	return trans
+}
+
+// bind 代替 ctx.Bind，请求格式不对的话一样返回 400；
+// 校验不通过的话返回 Result，Data 是每个字段的 FieldError
+func bind(ctx *gin.Context, req any) bool {
+	err := ctx.ShouldBind(req)
+	if err == nil {
+		return true
+	}
+	var errs validator.ValidationErrors
+	if !errors.As(err, &errs) {
+		_ = ctx.AbortWithError(http.StatusBadRequest, err).SetType(gin.ErrorTypeBind)
+		return false
+	}
+	var trans ut.Translator
+	sep := "; "
+	if uni != nil {
+		trans = translator(ctx)
+		if trans.Locale() == "zh" {
+			sep = "；"
+		}
+	}
+	fes := make([]FieldError, 0, len(errs))
+	msgs := make([]string, 0, len(errs))
+	for _, e := range errs {
+		msg := e.Error()
+		if trans != nil {
+			msg = e.Translate(trans)
+		}
+		fes = append(fes, FieldError{Field: e.Field(), Tag: e.Tag(), Msg: msg})
+		msgs = append(msgs, msg)
+	}
+	ctx.JSON(http.StatusOK, Result{Code: 4, Msg: strings.Join(msgs, sep), Data: fes})
+	return false
+}
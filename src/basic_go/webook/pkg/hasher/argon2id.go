@@ -0,0 +1,114 @@
+package hasher
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+type Argon2idParams struct {
+	// 内存，单位 KiB
+	Memory  uint32
+	Time    uint32
+	Threads uint8
+	SaltLen uint32
+	KeyLen  uint32
+}
+
+// DefaultArgon2idParams 参考 RFC 9106 第二推荐配置
+func DefaultArgon2idParams() Argon2idParams {
+	return Argon2idParams{
+		Memory:  64 * 1024,
+		Time:    3,
+		Threads: 2,
+		SaltLen: 16,
+		KeyLen:  32,
+	}
+}
+
+// Argon2id 生成的哈希是 PHC 格式的：
+// $argon2id$v=19$m=65536,t=3,p=2$<salt>$<key>
+type Argon2id struct {
+	params Argon2idParams
+}
+
+func NewArgon2id(params Argon2idParams) *Argon2id {
+	return &Argon2id{
+		params: params,
+	}
+}
+
+const argon2idPrefix = "$argon2id$"
+
+func (a *Argon2id) Hash(password string) (string, error) {
+	salt := make([]byte, a.params.SaltLen)
+	_, err := rand.Read(salt)
+	if err != nil {
+		return "", err
+	}
+	p := a.params
+	key := argon2.IDKey([]byte(password), salt, p.Time, p.Memory, p.Threads, p.KeyLen)
+	return fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s", argon2idPrefix, argon2.Version,
+		p.Memory, p.Time, p.Threads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+func (a *Argon2id) Verify(hash string, password string) error {
+	p, salt, key, err := a.decode(hash)
+	if err != nil {
+		return err
+	}
+	other := argon2.IDKey([]byte(password), salt, p.Time, p.Memory, p.Threads, uint32(len(key)))
+	if subtle.ConstantTimeCompare(key, other) != 1 {
+		return ErrMismatch
+	}
+	return nil
+}
+
+func (a *Argon2id) Match(hash string) bool {
+	return strings.HasPrefix(hash, argon2idPrefix)
+}
+
+func (a *Argon2id) NeedsRehash(hash string) bool {
+	p, salt, key, err := a.decode(hash)
+	if err != nil {
+		return true
+	}
+	return p.Memory != a.params.Memory || p.Time != a.params.Time ||
+		p.Threads != a.params.Threads ||
+		uint32(len(salt)) != a.params.SaltLen || uint32(len(key)) != a.params.KeyLen
+}
+
+func (a *Argon2id) decode(hash string) (Argon2idParams, []byte, []byte, error) {
+	// 切出来是 "", "argon2id", "v=19", "m=65536,t=3,p=2", salt, key
+	segs := strings.Split(hash, "$")
+	if len(segs) != 6 || segs[1] != "argon2id" {
+		return Argon2idParams{}, nil, nil, ErrUnknownHash
+	}
+	var version int
+	_, err := fmt.Sscanf(segs[2], "v=%d", &version)
+	if err != nil || version != argon2.Version {
+		return Argon2idParams{}, nil, nil, ErrUnknownHash
+	}
+	var p Argon2idParams
+	_, err = fmt.Sscanf(segs[3], "m=%d,t=%d,p=%d", &p.Memory, &p.Time, &p.Threads)
+	if err != nil {
+		return Argon2idParams{}, nil, nil, ErrUnknownHash
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(segs[4])
+	if err != nil {
+		return Argon2idParams{}, nil, nil, ErrUnknownHash
+	}
+	key, err := base64.RawStdEncoding.DecodeString(segs[5])
+	if err != nil {
+		return Argon2idParams{}, nil, nil, ErrUnknownHash
+	}
+	p.SaltLen = uint32(len(salt))
+	p.KeyLen = uint32(len(key))
+	return p, salt, key, nil
+}
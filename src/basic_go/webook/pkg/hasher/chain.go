@@ -0,0 +1,52 @@
+package hasher
+
+// Chain 用 primary 生成新的哈希，同时还能校验 legacy 生成的老哈希。
+// 迁移算法的时候，把新算法放 primary，老算法放 legacy，
+// 用户登录成功的时候根据 NeedsRehash 重新哈希
+type Chain struct {
+	primary Hasher
+	legacy  []Hasher
+}
+
+func NewChain(primary Hasher, legacy ...Hasher) *Chain {
+	return &Chain{
+		primary: primary,
+		legacy:  legacy,
+	}
+}
+
+func (c *Chain) Hash(password string) (string, error) {
+	return c.primary.Hash(password)
+}
+
+func (c *Chain) Verify(hash string, password string) error {
+	h, ok := c.find(hash)
+	if !ok {
+		return ErrUnknownHash
+	}
+	return h.Verify(hash, password)
+}
+
+func (c *Chain) Match(hash string) bool {
+	_, ok := c.find(hash)
+	return ok
+}
+
+func (c *Chain) NeedsRehash(hash string) bool {
+	if !c.primary.Match(hash) {
+		return true
+	}
+	return c.primary.NeedsRehash(hash)
+}
+
+func (c *Chain) find(hash string) (Hasher, bool) {
+	if c.primary.Match(hash) {
+		return c.primary, true
+	}
+	for _, h := range c.legacy {
+		if h.Match(hash) {
+			return h, true
+		}
+	}
+	return nil, false
+}
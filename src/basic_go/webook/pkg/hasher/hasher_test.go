@@ -0,0 +1,47 @@
+package hasher
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestArgon2id(t *testing.T) {
+	// 测试没必要用那么大的内存
+	params := Argon2idParams{Memory: 1024, Time: 1, Threads: 1, SaltLen: 16, KeyLen: 32}
+	h := NewArgon2id(params)
+	hash, err := h.Hash("hello#world123")
+	require.NoError(t, err)
+	assert.True(t, h.Match(hash))
+	assert.NoError(t, h.Verify(hash, "hello#world123"))
+	assert.Equal(t, ErrMismatch, h.Verify(hash, "hello#world124"))
+	assert.False(t, h.NeedsRehash(hash))
+
+	params.Time = 2
+	assert.True(t, NewArgon2id(params).NeedsRehash(hash))
+	assert.Equal(t, ErrUnknownHash, h.Verify("$argon2id$abc", "hello#world123"))
+}
+
+func TestChain(t *testing.T) {
+	argon := NewArgon2id(Argon2idParams{Memory: 1024, Time: 1, Threads: 1, SaltLen: 16, KeyLen: 32})
+	bc := NewBcrypt(bcrypt.MinCost)
+	c := NewChain(argon, bc)
+
+	// 老的 bcrypt 哈希还能校验，但是要重新哈希
+	old, err := bc.Hash("hello#world123")
+	require.NoError(t, err)
+	assert.NoError(t, c.Verify(old, "hello#world123"))
+	assert.Equal(t, ErrMismatch, c.Verify(old, "hello#world124"))
+	assert.True(t, c.NeedsRehash(old))
+
+	// 新生成的都是 argon2id
+	hash, err := c.Hash("hello#world123")
+	require.NoError(t, err)
+	assert.True(t, argon.Match(hash))
+	assert.NoError(t, c.Verify(hash, "hello#world123"))
+	assert.False(t, c.NeedsRehash(hash))
+
+	assert.Equal(t, ErrUnknownHash, c.Verify("plain", "plain"))
+}
@@ -0,0 +1,20 @@
+// Package hasher 密码哈希算法的抽象，方便在不同算法之间迁移
+package hasher
+
+import "errors"
+
+var (
+	ErrMismatch    = errors.New("hasher: 密码不匹配")
+	ErrUnknownHash = errors.New("hasher: 无法识别的哈希格式")
+)
+
+type Hasher interface {
+	// Hash 生成密码的哈希，结果里面要带上算法和参数，后面才能校验
+	Hash(password string) (string, error)
+	// Verify 密码不对的时候返回 ErrMismatch
+	Verify(hash string, password string) error
+	// Match 判断 hash 是不是这个算法生成的
+	Match(hash string) bool
+	// NeedsRehash hash 用的参数和当前配置的不一样，需要重新哈希
+	NeedsRehash(hash string) bool
+}
@@ -0,0 +1,80 @@
+package idempotency
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryStore 存在内存里面，只能单实例或者测试的时候用
+type MemoryStore struct {
+	mu        sync.Mutex
+	responses map[string]memoryEntry[Response]
+	// 值是加锁的时候的序号，解锁的时候对一下，免得把别人的锁释放了
+	locks map[string]memoryEntry[uint64]
+	seq   uint64
+}
+
+type memoryEntry[T any] struct {
+	val      T
+	expireAt time.Time
+}
+
+func (e memoryEntry[T]) expired(now time.Time) bool {
+	return !now.Before(e.expireAt)
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		responses: make(map[string]memoryEntry[Response]),
+		locks:     make(map[string]memoryEntry[uint64]),
+	}
+}
+
+func (s *MemoryStore) Get(ctx context.Context, key string) (Response, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.responses[key]
+	if !ok {
+		return Response{}, ErrNotFound
+	}
+	if e.expired(time.Now()) {
+		delete(s.responses, key)
+		return Response{}, ErrNotFound
+	}
+	return e.val, nil
+}
+
+func (s *MemoryStore) Save(ctx context.Context, key string, resp Response, expiration time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	// 顺便把过期的清掉，不然没人再来 Get 的就一直占着内存
+	for k, e := range s.responses {
+		if e.expired(now) {
+			delete(s.responses, k)
+		}
+	}
+	s.responses[key] = memoryEntry[Response]{val: resp, expireAt: now.Add(expiration)}
+	return nil
+}
+
+func (s *MemoryStore) Lock(ctx context.Context, key string, expiration time.Duration) (func(ctx context.Context) error, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	if e, ok := s.locks[key]; ok && !e.expired(now) {
+		return nil, ErrLocked
+	}
+	s.seq++
+	seq := s.seq
+	s.locks[key] = memoryEntry[uint64]{val: seq, expireAt: now.Add(expiration)}
+	return func(ctx context.Context) error {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if e, ok := s.locks[key]; ok && e.val == seq {
+			delete(s.locks, key)
+		}
+		return nil
+	}, nil
+}
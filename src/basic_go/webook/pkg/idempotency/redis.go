@@ -0,0 +1,63 @@
+package idempotency
+
+import (
+	"basic_go/webook/pkg/redislock"
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisStore 响应存在 key 里面，锁是 key:lock，多个实例共享
+type RedisStore struct {
+	client redis.Cmdable
+	locks  *redislock.Client
+}
+
+func NewRedisStore(client redis.Cmdable) *RedisStore {
+	return &RedisStore{
+		client: client,
+		locks:  redislock.NewClient(client),
+	}
+}
+
+func (s *RedisStore) Get(ctx context.Context, key string) (Response, error) {
+	data, err := s.client.Get(ctx, key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return Response{}, ErrNotFound
+	}
+	if err != nil {
+		return Response{}, err
+	}
+	var resp Response
+	err = json.Unmarshal(data, &resp)
+	return resp, err
+}
+
+func (s *RedisStore) Save(ctx context.Context, key string, resp Response, expiration time.Duration) error {
+	data, err := json.Marshal(resp)
+	if err != nil {
+		return err
+	}
+	return s.client.Set(ctx, key, data, expiration).Err()
+}
+
+func (s *RedisStore) Lock(ctx context.Context, key string, expiration time.Duration) (func(ctx context.Context) error, error) {
+	l, err := s.locks.TryLock(ctx, key+":lock", expiration)
+	if errors.Is(err, redislock.ErrFailedToPreemptLock) {
+		return nil, ErrLocked
+	}
+	if err != nil {
+		return nil, err
+	}
+	return func(ctx context.Context) error {
+		err := l.Unlock(ctx)
+		if errors.Is(err, redislock.ErrLockNotHold) {
+			// 已经过期了，没什么要释放的
+			return nil
+		}
+		return err
+	}, nil
+}
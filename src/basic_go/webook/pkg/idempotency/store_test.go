@@ -0,0 +1,79 @@
+package idempotency
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testStore struct {
+	name  string
+	store Store
+	// 让时间过去 d
+	elapse func(d time.Duration)
+}
+
+func newTestStores(t *testing.T) []testStore {
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() {
+		_ = rdb.Close()
+	})
+	return []testStore{
+		{name: "redis", store: NewRedisStore(rdb), elapse: mr.FastForward},
+		{name: "memory", store: NewMemoryStore(), elapse: time.Sleep},
+	}
+}
+
+func TestStore_SaveAndGet(t *testing.T) {
+	for _, ts := range newTestStores(t) {
+		t.Run(ts.name, func(t *testing.T) {
+			ctx := context.Background()
+			_, err := ts.store.Get(ctx, "k")
+			assert.Equal(t, ErrNotFound, err)
+
+			resp := Response{BodyHash: "abc", Status: 200, ContentType: "application/json", Body: []byte(`{"code":0}`)}
+			require.NoError(t, ts.store.Save(ctx, "k", resp, time.Millisecond*50))
+			got, err := ts.store.Get(ctx, "k")
+			require.NoError(t, err)
+			assert.Equal(t, resp, got)
+
+			ts.elapse(time.Millisecond * 60)
+			_, err = ts.store.Get(ctx, "k")
+			assert.Equal(t, ErrNotFound, err)
+		})
+	}
+}
+
+func TestStore_Lock(t *testing.T) {
+	for _, ts := range newTestStores(t) {
+		t.Run(ts.name, func(t *testing.T) {
+			ctx := context.Background()
+			unlock, err := ts.store.Lock(ctx, "k", time.Millisecond*50)
+			require.NoError(t, err)
+			_, err = ts.store.Lock(ctx, "k", time.Millisecond*50)
+			assert.Equal(t, ErrLocked, err)
+			// 不同的 key 互不影响
+			unlockOther, err := ts.store.Lock(ctx, "k2", time.Millisecond*50)
+			require.NoError(t, err)
+			require.NoError(t, unlockOther(ctx))
+
+			require.NoError(t, unlock(ctx))
+			unlock, err = ts.store.Lock(ctx, "k", time.Millisecond*50)
+			require.NoError(t, err)
+
+			// 过期了别人能抢到，原来的 unlock 不能把别人的锁释放了
+			ts.elapse(time.Millisecond * 60)
+			_, err = ts.store.Lock(ctx, "k", time.Millisecond*50)
+			require.NoError(t, err)
+			require.NoError(t, unlock(ctx))
+			_, err = ts.store.Lock(ctx, "k", time.Millisecond*50)
+			assert.Equal(t, ErrLocked, err)
+		})
+	}
+}
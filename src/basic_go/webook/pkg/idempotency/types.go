@@ -0,0 +1,32 @@
+// Package idempotency 保存写接口第一次的响应，客户端带着同一个 Idempotency-Key 重试的时候直接重放
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var (
+	ErrNotFound = errors.New("idempotency: 没有保存的响应")
+	// ErrLocked 同一个 key 的请求正在处理
+	ErrLocked = errors.New("idempotency: 请求正在处理")
+)
+
+// Response 第一次请求的响应
+type Response struct {
+	// 请求体的哈希，重试的时候请求体不一样要拒绝
+	BodyHash    string `json:"bodyHash"`
+	Status      int    `json:"status"`
+	ContentType string `json:"contentType"`
+	Body        []byte `json:"body"`
+}
+
+type Store interface {
+	// Get 没有的话返回 ErrNotFound
+	Get(ctx context.Context, key string) (Response, error)
+	Save(ctx context.Context, key string, resp Response, expiration time.Duration) error
+	// Lock 同一个 key 同时只能有一个请求在处理，被别人持有的时候返回 ErrLocked。
+	// 处理完了调用返回的 unlock，锁过期了被别人抢走的话 unlock 不会释放别人的锁
+	Lock(ctx context.Context, key string, expiration time.Duration) (unlock func(ctx context.Context) error, err error)
+}
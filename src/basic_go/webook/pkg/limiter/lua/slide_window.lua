@@ -0,0 +1,17 @@
+-- 滑动窗口限流，ZSET 里面放的是窗口里面每个请求的时间
+local key = KEYS[1]
+-- 窗口大小，单位毫秒
+local window = tonumber(ARGV[1])
+-- 窗口里面最多多少个请求
+local threshold = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+-- 同一毫秒可能有多个请求，member 要不一样
+local member = ARGV[4]
+redis.call("ZREMRANGEBYSCORE", key, "-inf", now - window)
+local cnt = redis.call("ZCOUNT", key, "-inf", "+inf")
+if cnt >= threshold then
+    return "true"
+end
+redis.call("ZADD", key, now, member)
+redis.call("PEXPIRE", key, window)
+return "false"
@@ -0,0 +1,40 @@
+package limiter
+
+import (
+	"context"
+	"crypto/rand"
+	_ "embed"
+	"encoding/hex"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+//go:embed lua/slide_window.lua
+var luaSlideWindow string
+
+// RedisSlidingWindowLimiter 基于 Redis 的滑动窗口限流，多个实例共享一个额度
+type RedisSlidingWindowLimiter struct {
+	client redis.Cmdable
+	// 窗口大小
+	interval time.Duration
+	// 窗口里面最多允许多少个请求
+	rate int
+}
+
+func NewRedisSlidingWindowLimiter(client redis.Cmdable, interval time.Duration, rate int) *RedisSlidingWindowLimiter {
+	return &RedisSlidingWindowLimiter{
+		client:   client,
+		interval: interval,
+		rate:     rate,
+	}
+}
+
+func (l *RedisSlidingWindowLimiter) Limit(ctx context.Context, key string) (bool, error) {
+	var buf [8]byte
+	if _, err := rand.Read(buf[:]); err != nil {
+		return false, err
+	}
+	return l.client.Eval(ctx, luaSlideWindow, []string{key},
+		l.interval.Milliseconds(), l.rate, time.Now().UnixMilli(), hex.EncodeToString(buf[:])).Bool()
+}
@@ -0,0 +1,38 @@
+package limiter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedisSlidingWindowLimiter(t *testing.T) {
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer rdb.Close()
+	l := NewRedisSlidingWindowLimiter(rdb, time.Millisecond*100, 3)
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		limited, err := l.Limit(ctx, "limit:a")
+		require.NoError(t, err)
+		assert.False(t, limited)
+	}
+	limited, err := l.Limit(ctx, "limit:a")
+	require.NoError(t, err)
+	assert.True(t, limited)
+	// 别的 key 不受影响
+	limited, err = l.Limit(ctx, "limit:b")
+	require.NoError(t, err)
+	assert.False(t, limited)
+
+	// 窗口滑过去之后又可以了
+	time.Sleep(time.Millisecond * 110)
+	limited, err = l.Limit(ctx, "limit:a")
+	require.NoError(t, err)
+	assert.False(t, limited)
+}
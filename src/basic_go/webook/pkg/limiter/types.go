@@ -0,0 +1,9 @@
+// Package limiter 限流
+package limiter
+
+import "context"
+
+type Limiter interface {
+	// Limit 返回 true 代表触发了限流，这一次请求不应该处理
+	Limit(ctx context.Context, key string) (bool, error)
+}
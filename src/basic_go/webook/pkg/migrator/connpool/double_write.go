@@ -0,0 +1,300 @@
+// Package connpool 双写用的 gorm.ConnPool
+package connpool
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"sync/atomic"
+
+	"gorm.io/gorm"
+)
+
+// 迁移的四个阶段，按顺序切换
+const (
+	PatternSrcOnly  = "src_only"
+	PatternSrcFirst = "src_first"
+	PatternDstFirst = "dst_first"
+	PatternDstOnly  = "dst_only"
+)
+
+var (
+	ErrUnknownPattern = errors.New("connpool: 未知的双写模式")
+	errPrepare        = errors.New("connpool: 双写模式下不支持 Prepare")
+)
+
+// DoubleWritePool 根据 pattern 决定读写源表还是目标表。
+// 以源表为准的阶段，写目标表失败只记录日志，等校验修复；反过来也一样
+type DoubleWritePool struct {
+	src     gorm.ConnPool
+	dst     gorm.ConnPool
+	pattern atomic.Value
+}
+
+func NewDoubleWritePool(src *gorm.DB, dst *gorm.DB, pattern string) (*DoubleWritePool, error) {
+	if !validPattern(pattern) {
+		return nil, ErrUnknownPattern
+	}
+	p := &DoubleWritePool{
+		src: src.ConnPool,
+		dst: dst.ConnPool,
+	}
+	p.pattern.Store(pattern)
+	return p, nil
+}
+
+// UpdatePattern 运行时切换模式
+func (d *DoubleWritePool) UpdatePattern(pattern string) error {
+	if !validPattern(pattern) {
+		return ErrUnknownPattern
+	}
+	d.pattern.Store(pattern)
+	return nil
+}
+
+func (d *DoubleWritePool) Pattern() string {
+	return d.pattern.Load().(string)
+}
+
+func (d *DoubleWritePool) BeginTx(ctx context.Context, opts *sql.TxOptions) (gorm.ConnPool, error) {
+	pattern := d.Pattern()
+	switch pattern {
+	case PatternSrcOnly:
+		tx, err := d.src.(gorm.TxBeginner).BeginTx(ctx, opts)
+		if err != nil {
+			return nil, err
+		}
+		return &DoubleWriteTx{src: tx, pattern: pattern}, nil
+	case PatternSrcFirst:
+		src, err := d.src.(gorm.TxBeginner).BeginTx(ctx, opts)
+		if err != nil {
+			return nil, err
+		}
+		dst, err := d.dst.(gorm.TxBeginner).BeginTx(ctx, opts)
+		if err != nil {
+			// 目标表的事务开不了，只用源表的事务
+			log.Println("双写目标表开启事务失败", err)
+		}
+		return &DoubleWriteTx{src: src, dst: dst, pattern: pattern}, nil
+	case PatternDstFirst:
+		dst, err := d.dst.(gorm.TxBeginner).BeginTx(ctx, opts)
+		if err != nil {
+			return nil, err
+		}
+		src, err := d.src.(gorm.TxBeginner).BeginTx(ctx, opts)
+		if err != nil {
+			log.Println("双写源表开启事务失败", err)
+		}
+		return &DoubleWriteTx{src: src, dst: dst, pattern: pattern}, nil
+	case PatternDstOnly:
+		tx, err := d.dst.(gorm.TxBeginner).BeginTx(ctx, opts)
+		if err != nil {
+			return nil, err
+		}
+		return &DoubleWriteTx{dst: tx, pattern: pattern}, nil
+	default:
+		return nil, ErrUnknownPattern
+	}
+}
+
+func (d *DoubleWritePool) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	// sql.Stmt 绑定在某一个连接上，没办法同时给两边用
+	return nil, errPrepare
+}
+
+func (d *DoubleWritePool) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	switch d.Pattern() {
+	case PatternSrcOnly:
+		return d.src.ExecContext(ctx, query, args...)
+	case PatternSrcFirst:
+		res, err := d.src.ExecContext(ctx, query, args...)
+		if err != nil {
+			return res, err
+		}
+		_, err = d.dst.ExecContext(ctx, query, args...)
+		if err != nil {
+			log.Println("双写写入目标表失败", err)
+		}
+		return res, nil
+	case PatternDstFirst:
+		res, err := d.dst.ExecContext(ctx, query, args...)
+		if err != nil {
+			return res, err
+		}
+		_, err = d.src.ExecContext(ctx, query, args...)
+		if err != nil {
+			log.Println("双写写入源表失败", err)
+		}
+		return res, nil
+	case PatternDstOnly:
+		return d.dst.ExecContext(ctx, query, args...)
+	default:
+		return nil, ErrUnknownPattern
+	}
+}
+
+func (d *DoubleWritePool) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	switch d.Pattern() {
+	case PatternSrcOnly, PatternSrcFirst:
+		return d.src.QueryContext(ctx, query, args...)
+	case PatternDstFirst, PatternDstOnly:
+		return d.dst.QueryContext(ctx, query, args...)
+	default:
+		return nil, ErrUnknownPattern
+	}
+}
+
+func (d *DoubleWritePool) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	switch d.Pattern() {
+	case PatternSrcOnly, PatternSrcFirst:
+		return d.src.QueryRowContext(ctx, query, args...)
+	case PatternDstFirst, PatternDstOnly:
+		return d.dst.QueryRowContext(ctx, query, args...)
+	default:
+		// sql.Row 没办法构造一个带 error 的，只能 panic
+		panic(ErrUnknownPattern)
+	}
+}
+
+// DoubleWriteTx 双写模式下的事务。模式在开启事务的时候就定下来了，
+// 事务进行中切换模式不会影响它
+type DoubleWriteTx struct {
+	src     *sql.Tx
+	dst     *sql.Tx
+	pattern string
+}
+
+func (t *DoubleWriteTx) Commit() error {
+	switch t.pattern {
+	case PatternSrcOnly:
+		return t.src.Commit()
+	case PatternSrcFirst:
+		err := t.src.Commit()
+		if err != nil {
+			if t.dst != nil {
+				_ = t.dst.Rollback()
+			}
+			return err
+		}
+		if t.dst != nil {
+			err = t.dst.Commit()
+			if err != nil {
+				log.Println("双写目标表提交事务失败", err)
+			}
+		}
+		return nil
+	case PatternDstFirst:
+		err := t.dst.Commit()
+		if err != nil {
+			if t.src != nil {
+				_ = t.src.Rollback()
+			}
+			return err
+		}
+		if t.src != nil {
+			err = t.src.Commit()
+			if err != nil {
+				log.Println("双写源表提交事务失败", err)
+			}
+		}
+		return nil
+	case PatternDstOnly:
+		return t.dst.Commit()
+	default:
+		return ErrUnknownPattern
+	}
+}
+
+func (t *DoubleWriteTx) Rollback() error {
+	switch t.pattern {
+	case PatternSrcOnly:
+		return t.src.Rollback()
+	case PatternSrcFirst:
+		err := t.src.Rollback()
+		if t.dst != nil {
+			if dstErr := t.dst.Rollback(); dstErr != nil {
+				log.Println("双写目标表回滚事务失败", dstErr)
+			}
+		}
+		return err
+	case PatternDstFirst:
+		err := t.dst.Rollback()
+		if t.src != nil {
+			if srcErr := t.src.Rollback(); srcErr != nil {
+				log.Println("双写源表回滚事务失败", srcErr)
+			}
+		}
+		return err
+	case PatternDstOnly:
+		return t.dst.Rollback()
+	default:
+		return ErrUnknownPattern
+	}
+}
+
+func (t *DoubleWriteTx) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	return nil, errPrepare
+}
+
+func (t *DoubleWriteTx) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	switch t.pattern {
+	case PatternSrcOnly:
+		return t.src.ExecContext(ctx, query, args...)
+	case PatternSrcFirst:
+		res, err := t.src.ExecContext(ctx, query, args...)
+		if err != nil || t.dst == nil {
+			return res, err
+		}
+		_, err = t.dst.ExecContext(ctx, query, args...)
+		if err != nil {
+			log.Println("双写写入目标表失败", err)
+		}
+		return res, nil
+	case PatternDstFirst:
+		res, err := t.dst.ExecContext(ctx, query, args...)
+		if err != nil || t.src == nil {
+			return res, err
+		}
+		_, err = t.src.ExecContext(ctx, query, args...)
+		if err != nil {
+			log.Println("双写写入源表失败", err)
+		}
+		return res, nil
+	case PatternDstOnly:
+		return t.dst.ExecContext(ctx, query, args...)
+	default:
+		return nil, ErrUnknownPattern
+	}
+}
+
+func (t *DoubleWriteTx) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	switch t.pattern {
+	case PatternSrcOnly, PatternSrcFirst:
+		return t.src.QueryContext(ctx, query, args...)
+	case PatternDstFirst, PatternDstOnly:
+		return t.dst.QueryContext(ctx, query, args...)
+	default:
+		return nil, ErrUnknownPattern
+	}
+}
+
+func (t *DoubleWriteTx) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	switch t.pattern {
+	case PatternSrcOnly, PatternSrcFirst:
+		return t.src.QueryRowContext(ctx, query, args...)
+	case PatternDstFirst, PatternDstOnly:
+		return t.dst.QueryRowContext(ctx, query, args...)
+	default:
+		panic(ErrUnknownPattern)
+	}
+}
+
+func validPattern(pattern string) bool {
+	switch pattern {
+	case PatternSrcOnly, PatternSrcFirst, PatternDstFirst, PatternDstOnly:
+		return true
+	default:
+		return false
+	}
+}
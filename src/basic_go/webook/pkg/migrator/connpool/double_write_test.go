@@ -0,0 +1,123 @@
+package connpool
+
+import (
+	"basic_go/webook/pkg/migrator/internal/migratortest"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// newTestPool 返回走双写的 db，以及直接访问两边的 src 和 dst
+func newTestPool(t *testing.T, pattern string) (*gorm.DB, *DoubleWritePool, *gorm.DB, *gorm.DB) {
+	src := migratortest.OpenDB(t)
+	dst := migratortest.OpenDB(t)
+	pool, err := NewDoubleWritePool(src, dst, pattern)
+	require.NoError(t, err)
+	db, err := gorm.Open(sqlite.Dialector{Conn: pool}, &gorm.Config{})
+	require.NoError(t, err)
+	return db, pool, src, dst
+}
+
+// insert 用 Exec 写，SQLite 的 Create 会带上 RETURNING 走 Query
+func insert(db *gorm.DB, id int64, name string) error {
+	return db.Exec("INSERT INTO items (id, name, utime) VALUES (?, ?, 0)", id, name).Error
+}
+
+func ids(t *testing.T, db *gorm.DB) []int64 {
+	res := []int64{}
+	require.NoError(t, db.Model(&migratortest.Item{}).Order("id").Pluck("id", &res).Error)
+	return res
+}
+
+func TestDoubleWritePool(t *testing.T) {
+	testCases := []struct {
+		pattern string
+		wantSrc []int64
+		wantDst []int64
+		// 读的是哪一边
+		wantRead string
+	}{
+		{pattern: PatternSrcOnly, wantSrc: []int64{1, 2}, wantDst: []int64{}, wantRead: "src"},
+		{pattern: PatternSrcFirst, wantSrc: []int64{1, 2}, wantDst: []int64{1, 2}, wantRead: "src"},
+		{pattern: PatternDstFirst, wantSrc: []int64{1, 2}, wantDst: []int64{1, 2}, wantRead: "dst"},
+		{pattern: PatternDstOnly, wantSrc: []int64{}, wantDst: []int64{1, 2}, wantRead: "dst"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.pattern, func(t *testing.T) {
+			db, _, src, dst := newTestPool(t, tc.pattern)
+			require.NoError(t, insert(db, 1, "a"))
+			// 事务提交了两边都有
+			require.NoError(t, db.Transaction(func(tx *gorm.DB) error {
+				return insert(tx, 2, "b")
+			}))
+			// 回滚了两边都没有
+			err := db.Transaction(func(tx *gorm.DB) error {
+				require.NoError(t, insert(tx, 3, "c"))
+				return errors.New("mock error")
+			})
+			require.Error(t, err)
+			assert.Equal(t, tc.wantSrc, ids(t, src))
+			assert.Equal(t, tc.wantDst, ids(t, dst))
+
+			require.NoError(t, insert(src, 10, "src"))
+			require.NoError(t, insert(dst, 10, "dst"))
+			var item migratortest.Item
+			require.NoError(t, db.Where("id = ?", 10).First(&item).Error)
+			assert.Equal(t, tc.wantRead, item.Name)
+			require.NoError(t, db.Transaction(func(tx *gorm.DB) error {
+				return tx.Where("id = ?", 10).First(&item).Error
+			}))
+			assert.Equal(t, tc.wantRead, item.Name)
+		})
+	}
+}
+
+func TestDoubleWritePool_SecondaryFailure(t *testing.T) {
+	// 以源表为准，目标表写失败了也算成功
+	db, _, src, dst := newTestPool(t, PatternSrcFirst)
+	require.NoError(t, dst.Migrator().DropTable(&migratortest.Item{}))
+	require.NoError(t, insert(db, 1, "a"))
+	require.NoError(t, db.Transaction(func(tx *gorm.DB) error {
+		return insert(tx, 2, "b")
+	}))
+	assert.Equal(t, []int64{1, 2}, ids(t, src))
+
+	// 以目标表为准，反过来也一样
+	db, _, src, dst = newTestPool(t, PatternDstFirst)
+	require.NoError(t, src.Migrator().DropTable(&migratortest.Item{}))
+	require.NoError(t, insert(db, 1, "a"))
+	require.NoError(t, db.Transaction(func(tx *gorm.DB) error {
+		return insert(tx, 2, "b")
+	}))
+	assert.Equal(t, []int64{1, 2}, ids(t, dst))
+
+	// 为准的那一边失败了就是失败
+	db, _, _, dst = newTestPool(t, PatternDstFirst)
+	require.NoError(t, dst.Migrator().DropTable(&migratortest.Item{}))
+	assert.Error(t, insert(db, 1, "a"))
+}
+
+func TestDoubleWritePool_UpdatePattern(t *testing.T) {
+	db, pool, src, dst := newTestPool(t, PatternSrcOnly)
+	assert.Equal(t, ErrUnknownPattern, pool.UpdatePattern("unknown"))
+	assert.Equal(t, PatternSrcOnly, pool.Pattern())
+
+	// 事务进行中切换模式，事务还是按开始时的模式
+	require.NoError(t, db.Transaction(func(tx *gorm.DB) error {
+		require.NoError(t, pool.UpdatePattern(PatternDstOnly))
+		return insert(tx, 1, "a")
+	}))
+	assert.Equal(t, []int64{1}, ids(t, src))
+	assert.Empty(t, ids(t, dst))
+
+	// 之后的请求按新的模式
+	require.NoError(t, insert(db, 2, "b"))
+	assert.Equal(t, []int64{2}, ids(t, dst))
+
+	_, err := NewDoubleWritePool(src, dst, "unknown")
+	assert.Equal(t, ErrUnknownPattern, err)
+}
@@ -0,0 +1,73 @@
+package fixer
+
+import (
+	"basic_go/webook/pkg/migrator"
+	"basic_go/webook/pkg/migrator/events"
+	"basic_go/webook/pkg/migrator/fixer"
+	"basic_go/webook/pkg/mq"
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+
+	"gorm.io/gorm"
+)
+
+var errUnknownDirection = errors.New("未知的校验方向")
+
+// Consumer 消费不一致事件，修复数据
+type Consumer[T migrator.Entity] struct {
+	client mq.MQ
+	topic  string
+	// 以源表为准，修目标表
+	srcFirst *fixer.OverrideFixer[T]
+	// 以目标表为准，修源表
+	dstFirst *fixer.OverrideFixer[T]
+}
+
+func NewConsumer[T migrator.Entity](client mq.MQ, topic string, src *gorm.DB, dst *gorm.DB) *Consumer[T] {
+	return &Consumer[T]{
+		client:   client,
+		topic:    topic,
+		srcFirst: fixer.NewOverrideFixer[T](src, dst),
+		dstFirst: fixer.NewOverrideFixer[T](dst, src),
+	}
+}
+
+// Start 在后台消费，不会阻塞
+func (c *Consumer[T]) Start() error {
+	cg, err := c.client.ConsumerGroup("migrator-fixer-"+c.topic, mq.DefaultBatchConfig())
+	if err != nil {
+		return err
+	}
+	hdl := mq.WithRetry(c.Consume, c.client.Producer(), mq.DefaultRetryConfig())
+	go func() {
+		err := cg.Consume(context.Background(), []string{c.topic}, hdl)
+		if err != nil {
+			log.Println("退出了消费循环", err)
+		}
+	}()
+	return nil
+}
+
+func (c *Consumer[T]) Consume(ctx context.Context, msgs []*mq.Message) error {
+	for _, msg := range msgs {
+		var evt events.InconsistentEvent
+		err := json.Unmarshal(msg.Value, &evt)
+		if err != nil {
+			return err
+		}
+		switch evt.Direction {
+		case events.DirectionSrc:
+			err = c.srcFirst.Fix(ctx, evt.ID)
+		case events.DirectionDst:
+			err = c.dstFirst.Fix(ctx, evt.ID)
+		default:
+			err = errUnknownDirection
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+// Package events 校验发现的不一致事件
+package events
+
+import (
+	"basic_go/webook/pkg/mq"
+	"context"
+	"encoding/json"
+	"strconv"
+)
+
+const (
+	// DirectionSrc 以源表为准
+	DirectionSrc = "SRC"
+	// DirectionDst 以目标表为准
+	DirectionDst = "DST"
+)
+
+const (
+	// InconsistentEventTypeTargetMissing 目标表里面没有这条数据
+	InconsistentEventTypeTargetMissing = "target_missing"
+	// InconsistentEventTypeNEQ 两边都有，但是不相等
+	InconsistentEventTypeNEQ = "neq"
+	// InconsistentEventTypeBaseMissing 基准表里面没有，目标表里面多出来了
+	InconsistentEventTypeBaseMissing = "base_missing"
+)
+
+type InconsistentEvent struct {
+	ID        int64  `json:"id"`
+	Direction string `json:"direction"`
+	Type      string `json:"type"`
+}
+
+type Producer struct {
+	producer mq.Producer
+	topic    string
+}
+
+// NewProducer 每张表用自己的 topic
+func NewProducer(producer mq.Producer, topic string) *Producer {
+	return &Producer{
+		producer: producer,
+		topic:    topic,
+	}
+}
+
+func (p *Producer) ProduceInconsistentEvent(ctx context.Context, evt InconsistentEvent) error {
+	val, err := json.Marshal(evt)
+	if err != nil {
+		return err
+	}
+	return p.producer.Produce(ctx, &mq.Message{
+		Topic: p.topic,
+		Key:   []byte(strconv.FormatInt(evt.ID, 10)),
+		Value: val,
+	})
+}
@@ -0,0 +1,40 @@
+// Package fixer 修复校验发现的不一致数据
+package fixer
+
+import (
+	"basic_go/webook/pkg/migrator"
+	"context"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// OverrideFixer 不管事件说的是什么不一致，都重新查一遍基准表，
+// 用基准表的数据覆盖目标表。这样事件发出来之后数据又变了也不要紧
+type OverrideFixer[T migrator.Entity] struct {
+	base   *gorm.DB
+	target *gorm.DB
+}
+
+func NewOverrideFixer[T migrator.Entity](base *gorm.DB, target *gorm.DB) *OverrideFixer[T] {
+	return &OverrideFixer[T]{
+		base:   base,
+		target: target,
+	}
+}
+
+func (f *OverrideFixer[T]) Fix(ctx context.Context, id int64) error {
+	var t T
+	err := f.base.WithContext(ctx).Where("id = ?", id).First(&t).Error
+	switch err {
+	case gorm.ErrRecordNotFound:
+		// 基准表里面已经没有了，目标表也删掉
+		return f.target.WithContext(ctx).Where("id = ?", id).Delete(&t).Error
+	case nil:
+		return f.target.WithContext(ctx).Clauses(clause.OnConflict{
+			UpdateAll: true,
+		}).Create(&t).Error
+	default:
+		return err
+	}
+}
@@ -0,0 +1,40 @@
+package fixer
+
+import (
+	"basic_go/webook/pkg/migrator/internal/migratortest"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOverrideFixer(t *testing.T) {
+	base := migratortest.OpenDB(t)
+	target := migratortest.OpenDB(t)
+	require.NoError(t, base.Create([]migratortest.Item{
+		{Id: 1, Name: "a", Utime: 1},
+		{Id: 2, Name: "b", Utime: 2},
+	}).Error)
+	require.NoError(t, target.Create([]migratortest.Item{
+		{Id: 2, Name: "bb", Utime: 1},
+		{Id: 3, Name: "c", Utime: 3},
+	}).Error)
+	f := NewOverrideFixer[migratortest.Item](base, target)
+	ctx := context.Background()
+
+	// target 缺了的补上，不一样的覆盖，base 没有的删掉
+	for _, id := range []int64{1, 2, 3} {
+		require.NoError(t, f.Fix(ctx, id))
+	}
+	// 本来就一致的，或者两边都没有的，修复了也没影响
+	require.NoError(t, f.Fix(ctx, 1))
+	require.NoError(t, f.Fix(ctx, 4))
+
+	var items []migratortest.Item
+	require.NoError(t, target.Order("id").Find(&items).Error)
+	assert.Equal(t, []migratortest.Item{
+		{Id: 1, Name: "a", Utime: 1},
+		{Id: 2, Name: "b", Utime: 2},
+	}, items)
+}
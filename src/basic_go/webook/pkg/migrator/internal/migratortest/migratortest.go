@@ -0,0 +1,43 @@
+// Package migratortest migrator 各个包的测试共用的表和数据库
+package migratortest
+
+import (
+	"basic_go/webook/pkg/migrator"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// Item 测试用的表
+type Item struct {
+	Id    int64 `gorm:"primaryKey"`
+	Name  string
+	Utime int64
+}
+
+func (i Item) ID() int64 {
+	return i.Id
+}
+
+func (i Item) GetUtime() int64 {
+	return i.Utime
+}
+
+func (i Item) CompareTo(dst migrator.Entity) bool {
+	val, ok := dst.(Item)
+	return ok && i == val
+}
+
+// OpenDB 建好 Item 表的 SQLite 内存数据库
+func OpenDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	// 内存数据库每个连接都是独立的，只能用一个连接
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	sqlDB.SetMaxOpenConns(1)
+	require.NoError(t, db.AutoMigrate(&Item{}))
+	return db
+}
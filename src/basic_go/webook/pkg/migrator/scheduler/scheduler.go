@@ -0,0 +1,143 @@
+// Package scheduler 通过 HTTP 接口控制迁移的进度：切换双写模式，启动和停止校验
+package scheduler
+
+import (
+	"basic_go/webook/pkg/migrator"
+	"basic_go/webook/pkg/migrator/connpool"
+	"basic_go/webook/pkg/migrator/events"
+	"basic_go/webook/pkg/migrator/validator"
+	"context"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type Scheduler[T migrator.Entity] struct {
+	lock     sync.Mutex
+	src      *gorm.DB
+	dst      *gorm.DB
+	pool     *connpool.DoubleWritePool
+	producer *events.Producer
+
+	// 停止正在运行的校验，什么都没有运行的时候是空函数
+	cancelFull func()
+	cancelIncr func()
+}
+
+func NewScheduler[T migrator.Entity](src *gorm.DB, dst *gorm.DB,
+	pool *connpool.DoubleWritePool, producer *events.Producer) *Scheduler[T] {
+	return &Scheduler[T]{
+		src:        src,
+		dst:        dst,
+		pool:       pool,
+		producer:   producer,
+		cancelFull: func() {},
+		cancelIncr: func() {},
+	}
+}
+
+// RegisterRoutes 这些接口只能在内网用，不要暴露出去
+func (s *Scheduler[T]) RegisterRoutes(g *gin.RouterGroup) {
+	g.POST("/src_only", s.switchPattern(connpool.PatternSrcOnly))
+	g.POST("/src_first", s.switchPattern(connpool.PatternSrcFirst))
+	g.POST("/dst_first", s.switchPattern(connpool.PatternDstFirst))
+	g.POST("/dst_only", s.switchPattern(connpool.PatternDstOnly))
+	g.GET("/pattern", s.Pattern)
+	g.POST("/full/start", s.StartFullValidation)
+	g.POST("/full/stop", s.StopFullValidation)
+	g.POST("/incr/start", s.StartIncrValidation)
+	g.POST("/incr/stop", s.StopIncrValidation)
+}
+
+func (s *Scheduler[T]) switchPattern(pattern string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		s.lock.Lock()
+		defer s.lock.Unlock()
+		err := s.pool.UpdatePattern(pattern)
+		if err != nil {
+			ctx.JSON(http.StatusOK, gin.H{"code": 5, "msg": "系统错误"})
+			return
+		}
+		ctx.JSON(http.StatusOK, gin.H{"msg": "已切换到 " + pattern})
+	}
+}
+
+func (s *Scheduler[T]) Pattern(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, gin.H{"data": s.pool.Pattern()})
+}
+
+// StartFullValidation 全量校验，校验完就结束
+func (s *Scheduler[T]) StartFullValidation(ctx *gin.Context) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.cancelFull()
+	v := s.newValidator()
+	var vctx context.Context
+	vctx, s.cancelFull = context.WithCancel(context.Background())
+	go func() {
+		err := v.Validate(vctx)
+		if err != nil {
+			log.Println("全量校验退出", err)
+		}
+	}()
+	ctx.JSON(http.StatusOK, gin.H{"msg": "OK"})
+}
+
+func (s *Scheduler[T]) StopFullValidation(ctx *gin.Context) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.cancelFull()
+	ctx.JSON(http.StatusOK, gin.H{"msg": "OK"})
+}
+
+// StartIncrValidation 增量校验 utime 之后更新过的数据，一直运行到被停止
+func (s *Scheduler[T]) StartIncrValidation(ctx *gin.Context) {
+	type StartIncrReq struct {
+		// 和表里面的 Utime 同一个单位
+		Utime int64 `json:"utime"`
+		// 没有新数据的时候隔多久再看，单位毫秒
+		Interval int64 `json:"interval"`
+	}
+	var req StartIncrReq
+	if err := ctx.Bind(&req); err != nil {
+		return
+	}
+	if req.Interval <= 0 {
+		req.Interval = 1000
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.cancelIncr()
+	v := s.newValidator().Utime(req.Utime).
+		SleepInterval(time.Duration(req.Interval) * time.Millisecond)
+	var vctx context.Context
+	vctx, s.cancelIncr = context.WithCancel(context.Background())
+	go func() {
+		err := v.Validate(vctx)
+		if err != nil {
+			log.Println("增量校验退出", err)
+		}
+	}()
+	ctx.JSON(http.StatusOK, gin.H{"msg": "OK"})
+}
+
+func (s *Scheduler[T]) StopIncrValidation(ctx *gin.Context) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.cancelIncr()
+	ctx.JSON(http.StatusOK, gin.H{"msg": "OK"})
+}
+
+// newValidator 以当前读的那一边为准
+func (s *Scheduler[T]) newValidator() *validator.Validator[T] {
+	switch s.pool.Pattern() {
+	case connpool.PatternDstFirst, connpool.PatternDstOnly:
+		return validator.NewValidator[T](s.dst, s.src, events.DirectionDst, s.producer)
+	default:
+		return validator.NewValidator[T](s.src, s.dst, events.DirectionSrc, s.producer)
+	}
+}
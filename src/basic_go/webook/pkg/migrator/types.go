@@ -0,0 +1,12 @@
+// Package migrator 不停机迁移表的工具：双写、校验、修复
+package migrator
+
+// Entity 要迁移的表
+type Entity interface {
+	// ID 主键
+	ID() int64
+	// GetUtime 更新时间，增量校验靠它
+	GetUtime() int64
+	// CompareTo 和另外一边的数据是否一致，dst 一定是同一个类型
+	CompareTo(dst Entity) bool
+}
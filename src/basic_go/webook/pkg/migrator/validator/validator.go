@@ -0,0 +1,181 @@
+// Package validator 对比源表和目标表，发现不一致就发事件
+package validator
+
+import (
+	"basic_go/webook/pkg/migrator"
+	"basic_go/webook/pkg/migrator/events"
+	"context"
+	"log"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+	"gorm.io/gorm"
+)
+
+// Validator 以 base 为准校验 target。
+// 两个方向都要校验：base 有 target 没有或者不相等，以及 target 有 base 没有。
+type Validator[T migrator.Entity] struct {
+	base   *gorm.DB
+	target *gorm.DB
+	// 以哪边为准，events.DirectionSrc 或者 events.DirectionDst
+	direction string
+	producer  *events.Producer
+	batchSize int
+	// 只校验 Utime >= utime 的数据，0 就是全量校验。
+	// 删除没办法通过 Utime 发现，所以 target 到 base 的方向永远是全量的
+	utime int64
+	// <= 0 代表校验到最后一条就退出，
+	// > 0 代表没有新数据的时候睡一会儿再继续，直到 ctx 被取消
+	sleepInterval time.Duration
+}
+
+func NewValidator[T migrator.Entity](base *gorm.DB, target *gorm.DB,
+	direction string, producer *events.Producer) *Validator[T] {
+	return &Validator[T]{
+		base:      base,
+		target:    target,
+		direction: direction,
+		producer:  producer,
+		batchSize: 100,
+	}
+}
+
+func (v *Validator[T]) Utime(utime int64) *Validator[T] {
+	v.utime = utime
+	return v
+}
+
+func (v *Validator[T]) SleepInterval(interval time.Duration) *Validator[T] {
+	v.sleepInterval = interval
+	return v
+}
+
+func (v *Validator[T]) BatchSize(size int) *Validator[T] {
+	v.batchSize = size
+	return v
+}
+
+func (v *Validator[T]) Validate(ctx context.Context) error {
+	var eg errgroup.Group
+	eg.Go(func() error {
+		return v.baseToTarget(ctx)
+	})
+	eg.Go(func() error {
+		return v.targetToBase(ctx)
+	})
+	return eg.Wait()
+}
+
+// baseToTarget 按照 (utime, id) 的顺序遍历 base，找 target 里面缺的和不相等的
+func (v *Validator[T]) baseToTarget(ctx context.Context) error {
+	utime, id := v.utime, int64(0)
+	for {
+		var bases []T
+		err := v.base.WithContext(ctx).
+			Where("utime > ? OR (utime = ? AND id > ?)", utime, utime, id).
+			Order("utime, id").Limit(v.batchSize).
+			Find(&bases).Error
+		if err != nil {
+			return err
+		}
+		if len(bases) == 0 {
+			if !v.sleep(ctx) {
+				return nil
+			}
+			continue
+		}
+		ids := make([]int64, 0, len(bases))
+		for _, b := range bases {
+			ids = append(ids, b.ID())
+		}
+		var targets []T
+		err = v.target.WithContext(ctx).Where("id IN ?", ids).Find(&targets).Error
+		if err != nil {
+			return err
+		}
+		targetMap := make(map[int64]T, len(targets))
+		for _, t := range targets {
+			targetMap[t.ID()] = t
+		}
+		for _, b := range bases {
+			t, ok := targetMap[b.ID()]
+			switch {
+			case !ok:
+				v.notify(ctx, b.ID(), events.InconsistentEventTypeTargetMissing)
+			case !b.CompareTo(t):
+				v.notify(ctx, b.ID(), events.InconsistentEventTypeNEQ)
+			}
+		}
+		last := bases[len(bases)-1]
+		utime, id = last.GetUtime(), last.ID()
+	}
+}
+
+// targetToBase 按照 id 遍历 target，找 base 里面已经没有了的
+func (v *Validator[T]) targetToBase(ctx context.Context) error {
+	id := int64(0)
+	for {
+		var targets []T
+		err := v.target.WithContext(ctx).
+			Where("id > ?", id).
+			Order("id").Limit(v.batchSize).
+			Find(&targets).Error
+		if err != nil {
+			return err
+		}
+		if len(targets) == 0 {
+			if !v.sleep(ctx) {
+				return nil
+			}
+			// 从头再扫一遍，中间可能又有数据被删了
+			id = 0
+			continue
+		}
+		ids := make([]int64, 0, len(targets))
+		for _, t := range targets {
+			ids = append(ids, t.ID())
+		}
+		var existing []int64
+		var t T
+		err = v.base.WithContext(ctx).Model(&t).
+			Where("id IN ?", ids).Pluck("id", &existing).Error
+		if err != nil {
+			return err
+		}
+		exists := make(map[int64]struct{}, len(existing))
+		for _, e := range existing {
+			exists[e] = struct{}{}
+		}
+		for _, tid := range ids {
+			if _, ok := exists[tid]; !ok {
+				v.notify(ctx, tid, events.InconsistentEventTypeBaseMissing)
+			}
+		}
+		id = ids[len(ids)-1]
+	}
+}
+
+// sleep 返回 false 代表要退出了
+func (v *Validator[T]) sleep(ctx context.Context) bool {
+	if v.sleepInterval <= 0 {
+		return false
+	}
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(v.sleepInterval):
+		return true
+	}
+}
+
+func (v *Validator[T]) notify(ctx context.Context, id int64, typ string) {
+	err := v.producer.ProduceInconsistentEvent(ctx, events.InconsistentEvent{
+		ID:        id,
+		Direction: v.direction,
+		Type:      typ,
+	})
+	if err != nil {
+		// 发不出去也不要紧，下一轮校验还能发现
+		log.Println("发送不一致事件失败", err)
+	}
+}
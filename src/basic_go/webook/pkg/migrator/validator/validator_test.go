@@ -0,0 +1,83 @@
+package validator
+
+import (
+	"basic_go/webook/pkg/migrator/events"
+	"basic_go/webook/pkg/migrator/fixer"
+	"basic_go/webook/pkg/migrator/internal/migratortest"
+	"basic_go/webook/pkg/mq"
+	"context"
+	"encoding/json"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordProducer 把事件记下来
+type recordProducer struct {
+	evts []events.InconsistentEvent
+}
+
+func (p *recordProducer) Produce(ctx context.Context, msg *mq.Message) error {
+	var evt events.InconsistentEvent
+	err := json.Unmarshal(msg.Value, &evt)
+	if err != nil {
+		return err
+	}
+	p.evts = append(p.evts, evt)
+	return nil
+}
+
+func (p *recordProducer) Close() error {
+	return nil
+}
+
+func TestValidator(t *testing.T) {
+	src := migratortest.OpenDB(t)
+	dst := migratortest.OpenDB(t)
+	require.NoError(t, src.Create([]migratortest.Item{
+		{Id: 1, Name: "a", Utime: 1},
+		{Id: 2, Name: "b", Utime: 2},
+		{Id: 3, Name: "c", Utime: 3},
+		{Id: 4, Name: "d", Utime: 4},
+	}).Error)
+	require.NoError(t, dst.Create([]migratortest.Item{
+		{Id: 1, Name: "a", Utime: 1},
+		// 不相等
+		{Id: 2, Name: "bb", Utime: 2},
+		// 3 和 4 缺了，5 多了
+		{Id: 5, Name: "e", Utime: 5},
+	}).Error)
+
+	p := &recordProducer{}
+	ctx := context.Background()
+	v := NewValidator[migratortest.Item](src, dst, events.DirectionSrc, events.NewProducer(p, "items")).BatchSize(2)
+	require.NoError(t, v.Validate(ctx))
+	sort.Slice(p.evts, func(i, j int) bool {
+		return p.evts[i].ID < p.evts[j].ID
+	})
+	assert.Equal(t, []events.InconsistentEvent{
+		{ID: 2, Direction: events.DirectionSrc, Type: events.InconsistentEventTypeNEQ},
+		{ID: 3, Direction: events.DirectionSrc, Type: events.InconsistentEventTypeTargetMissing},
+		{ID: 4, Direction: events.DirectionSrc, Type: events.InconsistentEventTypeTargetMissing},
+		{ID: 5, Direction: events.DirectionSrc, Type: events.InconsistentEventTypeBaseMissing},
+	}, p.evts)
+
+	// 增量校验只看 utime >= 4 的，target 到 base 的方向还是全量的
+	p.evts = nil
+	require.NoError(t, v.Utime(4).Validate(ctx))
+	assert.ElementsMatch(t, []events.InconsistentEvent{
+		{ID: 4, Direction: events.DirectionSrc, Type: events.InconsistentEventTypeTargetMissing},
+		{ID: 5, Direction: events.DirectionSrc, Type: events.InconsistentEventTypeBaseMissing},
+	}, p.evts)
+
+	// 修复之后再校验，就一致了
+	f := fixer.NewOverrideFixer[migratortest.Item](src, dst)
+	for _, id := range []int64{2, 3, 4, 5} {
+		require.NoError(t, f.Fix(ctx, id))
+	}
+	p.evts = nil
+	require.NoError(t, v.Utime(0).Validate(ctx))
+	assert.Empty(t, p.evts)
+}
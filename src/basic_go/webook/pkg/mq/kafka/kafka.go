@@ -0,0 +1,208 @@
+// Package kafka 基于 sarama 的 mq 实现
+package kafka
+
+import (
+	"basic_go/webook/pkg/mq"
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/IBM/sarama"
+)
+
+type Client struct {
+	addrs    []string
+	cfg      *sarama.Config
+	producer *producer
+}
+
+func NewClient(addrs []string) (*Client, error) {
+	cfg := sarama.NewConfig()
+	// SyncProducer 要求这个必须是 true
+	cfg.Producer.Return.Successes = true
+	cfg.Producer.RequiredAcks = sarama.WaitForAll
+	cfg.Consumer.Offsets.Initial = sarama.OffsetOldest
+	p, err := sarama.NewSyncProducer(addrs, cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{
+		addrs:    addrs,
+		cfg:      cfg,
+		producer: &producer{producer: p},
+	}, nil
+}
+
+// Producer 所有人共用一个生产者
+func (c *Client) Producer() mq.Producer {
+	return c.producer
+}
+
+func (c *Client) ConsumerGroup(group string, cfg mq.BatchConfig) (mq.ConsumerGroup, error) {
+	cg, err := sarama.NewConsumerGroup(c.addrs, group, c.cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &consumerGroup{
+		cg:  cg,
+		cfg: cfg,
+	}, nil
+}
+
+type producer struct {
+	producer sarama.SyncProducer
+}
+
+func (p *producer) Produce(ctx context.Context, msg *mq.Message) error {
+	msg, err := mq.WithMessageId(msg)
+	if err != nil {
+		return err
+	}
+	pm := &sarama.ProducerMessage{
+		Topic: msg.Topic,
+		Value: sarama.ByteEncoder(msg.Value),
+	}
+	if len(msg.Key) > 0 {
+		pm.Key = sarama.ByteEncoder(msg.Key)
+	}
+	for k, v := range msg.Headers {
+		pm.Headers = append(pm.Headers, sarama.RecordHeader{
+			Key:   []byte(k),
+			Value: []byte(v),
+		})
+	}
+	_, _, err = p.producer.SendMessage(pm)
+	return err
+}
+
+func (p *producer) Close() error {
+	return p.producer.Close()
+}
+
+type consumerGroup struct {
+	cg  sarama.ConsumerGroup
+	cfg mq.BatchConfig
+}
+
+func (c *consumerGroup) Consume(ctx context.Context, topics []string, hdl mq.BatchHandler) error {
+	h := &batchHandler{hdl: hdl, cfg: c.cfg, backoff: time.Millisecond * 100, maxBackoff: time.Minute}
+	for {
+		// 每次 rebalance 之后 Consume 都会返回，要重新调用
+		err := c.cg.Consume(ctx, topics, h)
+		if errors.Is(err, sarama.ErrClosedConsumerGroup) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if ctx.Err() != nil {
+			return nil
+		}
+	}
+}
+
+func (c *consumerGroup) Close() error {
+	return c.cg.Close()
+}
+
+// batchHandler 实现 sarama.ConsumerGroupHandler，攒一批再处理
+type batchHandler struct {
+	hdl mq.BatchHandler
+	cfg mq.BatchConfig
+	// 处理失败之后重试同一批的等待时间，每次翻倍，最多 maxBackoff
+	backoff    time.Duration
+	maxBackoff time.Duration
+}
+
+func (h *batchHandler) Setup(session sarama.ConsumerGroupSession) error {
+	return nil
+}
+
+func (h *batchHandler) Cleanup(session sarama.ConsumerGroupSession) error {
+	return nil
+}
+
+func (h *batchHandler) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	msgs := claim.Messages()
+	for {
+		batch := make([]*sarama.ConsumerMessage, 0, h.cfg.Size)
+		ctx, cancel := context.WithTimeout(session.Context(), h.cfg.Interval)
+		done := false
+		for i := 0; i < h.cfg.Size && !done; i++ {
+			select {
+			case <-ctx.Done():
+				done = true
+			case msg, ok := <-msgs:
+				if !ok {
+					cancel()
+					// 分区被收回了
+					return nil
+				}
+				batch = append(batch, msg)
+			}
+		}
+		cancel()
+		if len(batch) == 0 {
+			if session.Context().Err() != nil {
+				return nil
+			}
+			continue
+		}
+		if !h.handle(session, claim, batch) {
+			// session 结束了，这一批没有提交，下一个拿到分区的消费者会从这一批开始消费
+			return nil
+		}
+		for _, msg := range batch {
+			session.MarkMessage(msg, "")
+		}
+	}
+}
+
+// handle 处理一批消息，失败了就一直重试这一批。
+// 不能跳过去处理下一批：后面的批次一提交 offset，这一批就永远丢了。
+// 真正处理不了的消息应该由 hdl 自己丢进死信队列，参考 mq.WithRetry。
+// 返回 false 代表 session 结束了也没有处理成功
+func (h *batchHandler) handle(session sarama.ConsumerGroupSession,
+	claim sarama.ConsumerGroupClaim, batch []*sarama.ConsumerMessage) bool {
+	msgs := toMessages(batch)
+	backoff := h.backoff
+	for {
+		err := h.hdl(session.Context(), msgs)
+		if err == nil {
+			return true
+		}
+		log.Printf("处理消息失败 topic: %s, partition: %d, offset: %d, err: %v",
+			claim.Topic(), claim.Partition(), batch[0].Offset, err)
+		select {
+		case <-session.Context().Done():
+			return false
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, h.maxBackoff)
+	}
+}
+
+func toMessages(batch []*sarama.ConsumerMessage) []*mq.Message {
+	res := make([]*mq.Message, 0, len(batch))
+	for _, msg := range batch {
+		m := &mq.Message{
+			Topic:     msg.Topic,
+			Key:       msg.Key,
+			Value:     msg.Value,
+			Partition: msg.Partition,
+			Offset:    msg.Offset,
+		}
+		if len(msg.Headers) > 0 {
+			m.Headers = make(map[string]string, len(msg.Headers))
+			for _, hd := range msg.Headers {
+				m.Headers[string(hd.Key)] = string(hd.Value)
+			}
+		}
+		res = append(res, m)
+	}
+	return res
+}
+
+var _ mq.MQ = &Client{}
+var _ sarama.ConsumerGroupHandler = &batchHandler{}
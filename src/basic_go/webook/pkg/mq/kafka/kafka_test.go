@@ -0,0 +1,113 @@
+package kafka
+
+import (
+	"basic_go/webook/pkg/mq"
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeSession struct {
+	sarama.ConsumerGroupSession
+	ctx    context.Context
+	mu     sync.Mutex
+	marked []int64
+}
+
+func (s *fakeSession) Context() context.Context {
+	return s.ctx
+}
+
+func (s *fakeSession) MarkMessage(msg *sarama.ConsumerMessage, metadata string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.marked = append(s.marked, msg.Offset)
+}
+
+func (s *fakeSession) Marked() []int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]int64(nil), s.marked...)
+}
+
+type fakeClaim struct {
+	sarama.ConsumerGroupClaim
+	msgs chan *sarama.ConsumerMessage
+}
+
+func (c *fakeClaim) Topic() string                            { return "test_topic" }
+func (c *fakeClaim) Partition() int32                         { return 0 }
+func (c *fakeClaim) Messages() <-chan *sarama.ConsumerMessage { return c.msgs }
+
+func newFakeClaim(cnt int) *fakeClaim {
+	msgs := make(chan *sarama.ConsumerMessage, cnt)
+	for i := 0; i < cnt; i++ {
+		msgs <- &sarama.ConsumerMessage{Topic: "test_topic", Offset: int64(i)}
+	}
+	return &fakeClaim{msgs: msgs}
+}
+
+func TestBatchHandler_RetryFailedBatch(t *testing.T) {
+	var calls [][]int64
+	fails := 2
+	h := &batchHandler{
+		hdl: func(ctx context.Context, msgs []*mq.Message) error {
+			offsets := make([]int64, 0, len(msgs))
+			for _, m := range msgs {
+				offsets = append(offsets, m.Offset)
+			}
+			calls = append(calls, offsets)
+			if offsets[0] == 0 && fails > 0 {
+				fails--
+				return errors.New("mock error")
+			}
+			return nil
+		},
+		cfg:        mq.BatchConfig{Size: 2, Interval: time.Millisecond * 10},
+		backoff:    time.Millisecond,
+		maxBackoff: time.Millisecond,
+	}
+	claim := newFakeClaim(4)
+	close(claim.msgs)
+	session := &fakeSession{ctx: context.Background()}
+	require.NoError(t, h.ConsumeClaim(session, claim))
+
+	// 第一批失败了两次，一直重试到成功才处理第二批
+	assert.Equal(t, [][]int64{{0, 1}, {0, 1}, {0, 1}, {2, 3}}, calls)
+	assert.Equal(t, []int64{0, 1, 2, 3}, session.Marked())
+}
+
+func TestBatchHandler_SessionEndWithoutMark(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	h := &batchHandler{
+		hdl: func(ctx context.Context, msgs []*mq.Message) error {
+			return errors.New("mock error")
+		},
+		cfg:        mq.BatchConfig{Size: 2, Interval: time.Millisecond * 10},
+		backoff:    time.Millisecond,
+		maxBackoff: time.Millisecond * 5,
+	}
+	claim := newFakeClaim(4)
+	session := &fakeSession{ctx: ctx}
+	done := make(chan error, 1)
+	go func() {
+		done <- h.ConsumeClaim(session, claim)
+	}()
+	time.Sleep(time.Millisecond * 20)
+	// rebalance 了，失败的批次和后面的都不能提交
+	cancel()
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("ConsumeClaim 没有退出")
+	}
+	assert.Empty(t, session.Marked())
+}
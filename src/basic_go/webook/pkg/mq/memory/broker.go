@@ -0,0 +1,152 @@
+// Package memory 基于 channel 的进程内消息队列，本地跑和测试的时候用。
+// 消息不持久化，也不支持重新投递，进程重启消息就没了
+package memory
+
+import (
+	"basic_go/webook/pkg/mq"
+	"context"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type Broker struct {
+	mu sync.RWMutex
+	// topic => 订阅了这个 topic 的消费者组
+	subs map[string]map[string]struct{}
+	// 消费者组 => 消息。同一个组里面的消费者抢同一个 channel
+	groups  map[string]chan *mq.Message
+	offset  atomic.Int64
+	bufSize int
+}
+
+// NewBroker bufSize 是每个消费者组的缓冲区大小，满了之后生产者会阻塞
+func NewBroker(bufSize int) *Broker {
+	return &Broker{
+		subs:    make(map[string]map[string]struct{}),
+		groups:  make(map[string]chan *mq.Message),
+		bufSize: bufSize,
+	}
+}
+
+func (b *Broker) Producer() mq.Producer {
+	return &producer{broker: b}
+}
+
+func (b *Broker) ConsumerGroup(group string, cfg mq.BatchConfig) (mq.ConsumerGroup, error) {
+	return &consumerGroup{
+		broker: b,
+		group:  group,
+		cfg:    cfg,
+		closed: make(chan struct{}),
+	}, nil
+}
+
+func (b *Broker) subscribe(group string, topics []string) chan *mq.Message {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	ch, ok := b.groups[group]
+	if !ok {
+		ch = make(chan *mq.Message, b.bufSize)
+		b.groups[group] = ch
+	}
+	for _, topic := range topics {
+		gs, ok := b.subs[topic]
+		if !ok {
+			gs = make(map[string]struct{})
+			b.subs[topic] = gs
+		}
+		gs[group] = struct{}{}
+	}
+	return ch
+}
+
+// targets 订阅了 topic 的所有 channel
+func (b *Broker) targets(topic string) []chan *mq.Message {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	res := make([]chan *mq.Message, 0, len(b.subs[topic]))
+	for group := range b.subs[topic] {
+		res = append(res, b.groups[group])
+	}
+	return res
+}
+
+type producer struct {
+	broker *Broker
+}
+
+// Produce 没有消费者组订阅的 topic，消息直接丢掉
+func (p *producer) Produce(ctx context.Context, msg *mq.Message) error {
+	msg, err := mq.WithMessageId(msg)
+	if err != nil {
+		return err
+	}
+	offset := p.broker.offset.Add(1)
+	for _, ch := range p.broker.targets(msg.Topic) {
+		// 每个组一份，避免消费者之间互相影响
+		m := *msg
+		m.Offset = offset
+		select {
+		case ch <- &m:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+func (p *producer) Close() error {
+	return nil
+}
+
+type consumerGroup struct {
+	broker    *Broker
+	group     string
+	cfg       mq.BatchConfig
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+func (c *consumerGroup) Consume(ctx context.Context, topics []string, hdl mq.BatchHandler) error {
+	ch := c.broker.subscribe(c.group, topics)
+	batch := make([]*mq.Message, 0, c.cfg.Size)
+	ticker := time.NewTicker(c.cfg.Interval)
+	defer ticker.Stop()
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		err := hdl(ctx, batch)
+		if err != nil {
+			// 内存实现没办法重新投递，只能记录一下
+			log.Printf("处理消息失败 group: %s, err: %v", c.group, err)
+		}
+		batch = make([]*mq.Message, 0, c.cfg.Size)
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-c.closed:
+			return nil
+		case msg := <-ch:
+			batch = append(batch, msg)
+			if len(batch) >= c.cfg.Size {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+func (c *consumerGroup) Close() error {
+	c.closeOnce.Do(func() {
+		close(c.closed)
+	})
+	return nil
+}
+
+var _ mq.MQ = &Broker{}
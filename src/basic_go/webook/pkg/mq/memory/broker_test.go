@@ -0,0 +1,60 @@
+package memory
+
+import (
+	"basic_go/webook/pkg/mq"
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBroker_Batch(t *testing.T) {
+	b := NewBroker(16)
+	cg, err := b.ConsumerGroup("test", mq.BatchConfig{Size: 3, Interval: time.Millisecond * 50})
+	require.NoError(t, err)
+
+	var mu sync.Mutex
+	var batches [][]string
+	ids := map[string]struct{}{}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_ = cg.Consume(ctx, []string{"a"}, func(ctx context.Context, msgs []*mq.Message) error {
+			mu.Lock()
+			defer mu.Unlock()
+			var vals []string
+			for _, m := range msgs {
+				vals = append(vals, string(m.Value))
+				ids[m.Headers[mq.HeaderMessageId]] = struct{}{}
+			}
+			batches = append(batches, vals)
+			return nil
+		})
+	}()
+	// 等消费者订阅上
+	time.Sleep(time.Millisecond * 10)
+
+	p := b.Producer()
+	for _, v := range []string{"1", "2", "3", "4"} {
+		require.NoError(t, p.Produce(ctx, &mq.Message{Topic: "a", Value: []byte(v)}))
+	}
+	// 别的 topic 不会收到
+	require.NoError(t, p.Produce(ctx, &mq.Message{Topic: "b", Value: []byte("x")}))
+
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(batches) == 2
+	}, time.Second, time.Millisecond*10)
+	// 攒够 3 条处理一次，剩下的 1 条等时间到了处理
+	assert.Equal(t, [][]string{{"1", "2", "3"}, {"4"}}, batches)
+	// 每条消息都生成了不一样的 ID
+	assert.Len(t, ids, 4)
+	require.NoError(t, cg.Close())
+	<-done
+}
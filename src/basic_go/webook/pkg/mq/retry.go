@@ -0,0 +1,87 @@
+package mq
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// DeadLetterTopic 死信队列的 topic
+func DeadLetterTopic(topic string) string {
+	return topic + ".dlq"
+}
+
+type RetryConfig struct {
+	// 不算第一次，最多重试几次
+	MaxRetries int
+	// 第一次重试前等待的时间，之后每次翻倍
+	Backoff time.Duration
+}
+
+func DefaultRetryConfig() RetryConfig {
+	return RetryConfig{
+		MaxRetries: 3,
+		Backoff:    time.Millisecond * 100,
+	}
+}
+
+// WithRetry 给 hdl 加上重试和死信队列。
+// 整批重试都失败之后，再一条一条地处理，把真正有问题的消息找出来丢到死信队列，
+// 免得一条坏消息拖累整批
+func WithRetry(hdl BatchHandler, dlq Producer, cfg RetryConfig) BatchHandler {
+	return func(ctx context.Context, msgs []*Message) error {
+		err := retry(ctx, cfg, func() error {
+			return hdl(ctx, msgs)
+		})
+		if err == nil {
+			return nil
+		}
+		if len(msgs) == 1 {
+			return sendToDLQ(ctx, dlq, msgs[0], err)
+		}
+		for _, msg := range msgs {
+			err = retry(ctx, cfg, func() error {
+				return hdl(ctx, []*Message{msg})
+			})
+			if err == nil {
+				continue
+			}
+			err = sendToDLQ(ctx, dlq, msg, err)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+func retry(ctx context.Context, cfg RetryConfig, fn func() error) error {
+	err := fn()
+	backoff := cfg.Backoff
+	for i := 0; err != nil && i < cfg.MaxRetries; i++ {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff = backoff * 2
+		err = fn()
+	}
+	return err
+}
+
+func sendToDLQ(ctx context.Context, dlq Producer, msg *Message, cause error) error {
+	log.Printf("消息处理失败，进入死信队列 topic: %s, offset: %d, err: %v", msg.Topic, msg.Offset, cause)
+	headers := make(map[string]string, len(msg.Headers)+2)
+	for k, v := range msg.Headers {
+		headers[k] = v
+	}
+	headers["x-origin-topic"] = msg.Topic
+	headers["x-error"] = cause.Error()
+	return dlq.Produce(ctx, &Message{
+		Topic:   DeadLetterTopic(msg.Topic),
+		Key:     msg.Key,
+		Value:   msg.Value,
+		Headers: headers,
+	})
+}
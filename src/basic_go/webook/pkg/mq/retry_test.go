@@ -0,0 +1,60 @@
+package mq_test
+
+import (
+	"basic_go/webook/pkg/mq"
+	"basic_go/webook/pkg/mq/memory"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithRetry_DeadLetter(t *testing.T) {
+	b := memory.NewBroker(16)
+	dlq, err := b.ConsumerGroup("dlq", mq.BatchConfig{Size: 10, Interval: time.Millisecond * 10})
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	dead := make(chan *mq.Message, 10)
+	go func() {
+		_ = dlq.Consume(ctx, []string{mq.DeadLetterTopic("a")}, func(ctx context.Context, msgs []*mq.Message) error {
+			for _, m := range msgs {
+				dead <- m
+			}
+			return nil
+		})
+	}()
+	time.Sleep(time.Millisecond * 10)
+
+	handled := map[string]int{}
+	hdl := mq.WithRetry(func(ctx context.Context, msgs []*mq.Message) error {
+		for _, m := range msgs {
+			if string(m.Value) == "bad" {
+				return errors.New("坏消息")
+			}
+		}
+		for _, m := range msgs {
+			handled[string(m.Value)]++
+		}
+		return nil
+	}, b.Producer(), mq.RetryConfig{MaxRetries: 2, Backoff: time.Millisecond})
+
+	err = hdl(ctx, []*mq.Message{
+		{Topic: "a", Value: []byte("ok1")},
+		{Topic: "a", Value: []byte("bad")},
+		{Topic: "a", Value: []byte("ok2")},
+	})
+	require.NoError(t, err)
+	// 好消息一条一条处理成功了，坏消息进了死信队列
+	assert.Equal(t, map[string]int{"ok1": 1, "ok2": 1}, handled)
+	select {
+	case m := <-dead:
+		assert.Equal(t, "bad", string(m.Value))
+		assert.Equal(t, "a", m.Headers["x-origin-topic"])
+	case <-time.After(time.Second):
+		t.Fatal("没有收到死信")
+	}
+}
@@ -0,0 +1,87 @@
+// Package mq 消息队列的抽象。业务代码只依赖这里的接口，
+// 本地跑用 memory，线上用 kafka
+package mq
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+)
+
+// HeaderMessageId 生产的时候给每条消息生成的唯一 ID，消费者靠它去重
+const HeaderMessageId = "x-msg-id"
+
+type Message struct {
+	Topic   string
+	Key     []byte
+	Value   []byte
+	Headers map[string]string
+
+	// 下面两个字段只有消费的时候才有，memory 实现里面 Partition 永远是 0
+	Partition int32
+	Offset    int64
+}
+
+type Producer interface {
+	Produce(ctx context.Context, msg *Message) error
+	Close() error
+}
+
+// MessageId 消息的唯一 ID。重试、重新消费、从死信队列重放的时候都不会变。
+// 老的消息没有这个头部，用 topic、分区和 offset 拼一个
+func (m *Message) MessageId() string {
+	if id := m.Headers[HeaderMessageId]; id != "" {
+		return id
+	}
+	return fmt.Sprintf("%s:%d:%d", m.Topic, m.Partition, m.Offset)
+}
+
+// WithMessageId 生产者实现要调用，没有 ID 的话生成一个。返回的是拷贝，不会修改 msg
+func WithMessageId(msg *Message) (*Message, error) {
+	res := *msg
+	res.Headers = make(map[string]string, len(msg.Headers)+1)
+	for k, v := range msg.Headers {
+		res.Headers[k] = v
+	}
+	if res.Headers[HeaderMessageId] != "" {
+		return &res, nil
+	}
+	var buf [16]byte
+	if _, err := rand.Read(buf[:]); err != nil {
+		return nil, err
+	}
+	res.Headers[HeaderMessageId] = hex.EncodeToString(buf[:])
+	return &res, nil
+}
+
+// BatchHandler 批量处理消息，返回 error 代表这一批都没有处理成功
+type BatchHandler func(ctx context.Context, msgs []*Message) error
+
+type ConsumerGroup interface {
+	// Consume 一直消费，直到 ctx 被取消或者 Close 了。
+	// 攒够 BatchConfig.Size 条，或者等了 BatchConfig.Interval 就调用一次 hdl
+	Consume(ctx context.Context, topics []string, hdl BatchHandler) error
+	Close() error
+}
+
+// MQ 把生产者和消费者组的创建收拢到一起，方便切换实现
+type MQ interface {
+	Producer() Producer
+	ConsumerGroup(group string, cfg BatchConfig) (ConsumerGroup, error)
+}
+
+type BatchConfig struct {
+	// 一批最多多少条
+	Size int
+	// 最多等多久，没攒够也要处理
+	Interval time.Duration
+}
+
+func DefaultBatchConfig() BatchConfig {
+	return BatchConfig{
+		Size:     100,
+		Interval: time.Second,
+	}
+}
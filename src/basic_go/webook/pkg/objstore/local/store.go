@@ -0,0 +1,148 @@
+// Package local 把对象存在本地磁盘上，开发和单机部署用。
+// 访问对象要通过 RegisterRoutes 注册的接口，私有的对象要带签名
+package local
+
+import (
+	"basic_go/webook/pkg/objstore"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type Store struct {
+	root string
+	// 访问对象的地址前缀，比如 http://localhost:8080/objects
+	baseURL string
+	secret  []byte
+	// 这个前缀下面的对象是公开的，不用签名就能访问
+	publicPrefix string
+	now          func() time.Time
+}
+
+func NewStore(root string, baseURL string, secret []byte, publicPrefix string) *Store {
+	return &Store{
+		root:         root,
+		baseURL:      strings.TrimSuffix(baseURL, "/"),
+		secret:       secret,
+		publicPrefix: publicPrefix,
+		now:          time.Now,
+	}
+}
+
+func (s *Store) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	// 先写临时文件再改名，读的人不会看到写了一半的文件
+	f, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	_, err = io.Copy(f, r)
+	if err1 := f.Close(); err == nil {
+		err = err1
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+func (s *Store) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, objstore.ErrObjectNotFound
+	}
+	return f, err
+}
+
+func (s *Store) Delete(ctx context.Context, key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+func (s *Store) URL(key string) string {
+	return s.baseURL + "/" + key
+}
+
+func (s *Store) SignURL(ctx context.Context, key string, expire time.Duration) (string, error) {
+	if err := objstore.ValidateKey(key); err != nil {
+		return "", err
+	}
+	expires := strconv.FormatInt(s.now().Add(expire).Unix(), 10)
+	q := url.Values{}
+	q.Set("expires", expires)
+	q.Set("sign", s.sign(key, expires))
+	return s.URL(key) + "?" + q.Encode(), nil
+}
+
+// Verify 校验签名和有没有过期
+func (s *Store) Verify(key string, expires string, sign string) bool {
+	exp, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || s.now().Unix() > exp {
+		return false
+	}
+	return hmac.Equal([]byte(sign), []byte(s.sign(key, expires)))
+}
+
+// RegisterRoutes 注册 GET /*key，下载对象
+func (s *Store) RegisterRoutes(rg *gin.RouterGroup) {
+	rg.GET("/*key", func(ctx *gin.Context) {
+		key := strings.TrimPrefix(ctx.Param("key"), "/")
+		path, err := s.path(key)
+		if err != nil {
+			ctx.Status(http.StatusNotFound)
+			return
+		}
+		if !strings.HasPrefix(key, s.publicPrefix) &&
+			!s.Verify(key, ctx.Query("expires"), ctx.Query("sign")) {
+			ctx.Status(http.StatusForbidden)
+			return
+		}
+		if _, err = os.Stat(path); err != nil {
+			ctx.Status(http.StatusNotFound)
+			return
+		}
+		ctx.File(path)
+	})
+}
+
+func (s *Store) sign(key string, expires string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(key + "\n" + expires))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (s *Store) path(key string) (string, error) {
+	if err := objstore.ValidateKey(key); err != nil {
+		return "", err
+	}
+	return filepath.Join(s.root, filepath.FromSlash(key)), nil
+}
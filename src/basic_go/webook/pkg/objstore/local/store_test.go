@@ -0,0 +1,80 @@
+package local
+
+import (
+	"basic_go/webook/pkg/objstore"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore(t *testing.T) {
+	s := NewStore(t.TempDir(), "http://localhost/objects/", []byte("secret"), "public/")
+	ctx := context.Background()
+
+	require.NoError(t, s.Put(ctx, "private/a/1.txt", strings.NewReader("hello"), 5, "text/plain"))
+	rc, err := s.Get(ctx, "private/a/1.txt")
+	require.NoError(t, err)
+	data, err := io.ReadAll(rc)
+	require.NoError(t, err)
+	require.NoError(t, rc.Close())
+	assert.Equal(t, "hello", string(data))
+
+	_, err = s.Get(ctx, "private/a/2.txt")
+	assert.Equal(t, objstore.ErrObjectNotFound, err)
+	// 不能跳出根目录
+	assert.Equal(t, objstore.ErrInvalidKey, s.Put(ctx, "../x", strings.NewReader(""), 0, ""))
+
+	require.NoError(t, s.Delete(ctx, "private/a/1.txt"))
+	require.NoError(t, s.Delete(ctx, "private/a/1.txt"))
+	_, err = s.Get(ctx, "private/a/1.txt")
+	assert.Equal(t, objstore.ErrObjectNotFound, err)
+}
+
+func TestStore_RegisterRoutes(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	s := NewStore(t.TempDir(), "http://localhost/objects", []byte("secret"), "public/")
+	ctx := context.Background()
+	require.NoError(t, s.Put(ctx, "public/1.txt", strings.NewReader("pub"), 3, "text/plain"))
+	require.NoError(t, s.Put(ctx, "private/1.txt", strings.NewReader("pri"), 3, "text/plain"))
+	server := gin.New()
+	s.RegisterRoutes(server.Group("/objects"))
+
+	get := func(rawURL string) *httptest.ResponseRecorder {
+		u, err := url.Parse(rawURL)
+		require.NoError(t, err)
+		req := httptest.NewRequest(http.MethodGet, u.RequestURI(), nil)
+		resp := httptest.NewRecorder()
+		server.ServeHTTP(resp, req)
+		return resp
+	}
+
+	resp := get(s.URL("public/1.txt"))
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, "pub", resp.Body.String())
+
+	// 私有的要签名
+	assert.Equal(t, http.StatusForbidden, get(s.URL("private/1.txt")).Code)
+	signed, err := s.SignURL(ctx, "private/1.txt", time.Minute)
+	require.NoError(t, err)
+	resp = get(signed)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, "pri", resp.Body.String())
+
+	// 签名不能用在别的对象上
+	assert.Equal(t, http.StatusForbidden, get(strings.Replace(signed, "1.txt", "2.txt", 1)).Code)
+
+	// 过期了
+	s.now = func() time.Time {
+		return time.Now().Add(time.Hour)
+	}
+	assert.Equal(t, http.StatusForbidden, get(signed).Code)
+}
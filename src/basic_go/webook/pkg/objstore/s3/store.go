@@ -0,0 +1,103 @@
+// Package s3 对接 S3 兼容的对象存储，比如 MinIO、AWS S3、各家云厂商的 OSS
+package s3
+
+import (
+	"basic_go/webook/pkg/objstore"
+	"context"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+type Config struct {
+	Endpoint  string
+	AccessKey string
+	SecretKey string
+	Bucket    string
+	UseSSL    bool
+	// 公开访问的地址前缀，一般是 CDN 的域名。不配就用 Endpoint/Bucket
+	PublicBaseURL string
+}
+
+// Store 公开的对象要在 bucket 的策略里面配置成可以匿名读
+type Store struct {
+	client  *minio.Client
+	bucket  string
+	baseURL string
+}
+
+func NewStore(cfg Config) (*Store, error) {
+	client, err := minio.New(cfg.Endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(cfg.AccessKey, cfg.SecretKey, ""),
+		Secure: cfg.UseSSL,
+	})
+	if err != nil {
+		return nil, err
+	}
+	baseURL := cfg.PublicBaseURL
+	if baseURL == "" {
+		scheme := "http://"
+		if cfg.UseSSL {
+			scheme = "https://"
+		}
+		baseURL = scheme + cfg.Endpoint + "/" + cfg.Bucket
+	}
+	return &Store{
+		client:  client,
+		bucket:  cfg.Bucket,
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+	}, nil
+}
+
+func (s *Store) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	if err := objstore.ValidateKey(key); err != nil {
+		return err
+	}
+	_, err := s.client.PutObject(ctx, s.bucket, key, r, size, minio.PutObjectOptions{
+		ContentType: contentType,
+	})
+	return err
+}
+
+func (s *Store) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	// GetObject 不会真的发请求，先 Stat 一下才知道存不存在
+	_, err := s.client.StatObject(ctx, s.bucket, key, minio.StatObjectOptions{})
+	if err != nil {
+		return nil, s.convertErr(err)
+	}
+	obj, err := s.client.GetObject(ctx, s.bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, s.convertErr(err)
+	}
+	return obj, nil
+}
+
+func (s *Store) Delete(ctx context.Context, key string) error {
+	// 不存在的对象 RemoveObject 也是成功
+	return s.client.RemoveObject(ctx, s.bucket, key, minio.RemoveObjectOptions{})
+}
+
+func (s *Store) URL(key string) string {
+	return s.baseURL + "/" + key
+}
+
+func (s *Store) SignURL(ctx context.Context, key string, expire time.Duration) (string, error) {
+	if err := objstore.ValidateKey(key); err != nil {
+		return "", err
+	}
+	u, err := s.client.PresignedGetObject(ctx, s.bucket, key, expire, nil)
+	if err != nil {
+		return "", err
+	}
+	return u.String(), nil
+}
+
+func (s *Store) convertErr(err error) error {
+	if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+		return objstore.ErrObjectNotFound
+	}
+	return err
+}
@@ -0,0 +1,42 @@
+// Package objstore 对象存储的抽象，存头像、文章图片这种文件。
+// 具体实现在子包里面：local 存本地磁盘，s3 对接 S3 兼容的存储，比如 MinIO
+package objstore
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"time"
+)
+
+var (
+	ErrObjectNotFound = errors.New("objstore: 对象不存在")
+	ErrInvalidKey     = errors.New("objstore: key 不合法")
+)
+
+type Store interface {
+	// Put 上传，size 是 -1 的时候代表不知道大小
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Get 对象不存在返回 ErrObjectNotFound，用完要 Close
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete 对象不存在也不会返回错误
+	Delete(ctx context.Context, key string) error
+	// URL 公开访问的地址，只有公开的对象才能直接访问
+	URL(key string) string
+	// SignURL 带签名的地址，过了 expire 就不能用了，私有的对象要用这个访问
+	SignURL(ctx context.Context, key string, expire time.Duration) (string, error)
+}
+
+// ValidateKey key 用 / 分隔，不能是绝对路径，也不能有 . 和 ..
+func ValidateKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") {
+		return ErrInvalidKey
+	}
+	for _, seg := range strings.Split(key, "/") {
+		if seg == "" || seg == "." || seg == ".." {
+			return ErrInvalidKey
+		}
+	}
+	return nil
+}
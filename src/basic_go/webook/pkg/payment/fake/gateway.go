@@ -0,0 +1,169 @@
+// Package fake 本地和测试用的支付网关，不会真的扣钱。
+// 回调用 HMAC-SHA256 签名，和真实的网关一样要验签
+package fake
+
+import (
+	"basic_go/webook/pkg/payment"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+const signatureHeader = "X-Fake-Signature"
+
+type Gateway struct {
+	secret []byte
+	// 回调地址
+	notifyURL string
+	client    *http.Client
+
+	lock   sync.Mutex
+	orders map[string]*payment.Transaction
+	seq    int64
+}
+
+func NewGateway(secret []byte, notifyURL string) *Gateway {
+	return &Gateway{
+		secret:    secret,
+		notifyURL: notifyURL,
+		client:    http.DefaultClient,
+		orders:    make(map[string]*payment.Transaction),
+	}
+}
+
+func (g *Gateway) Prepay(ctx context.Context, req payment.PrepayRequest) (payment.PrepayResponse, error) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	if _, ok := g.orders[req.OutTradeNo]; !ok {
+		g.orders[req.OutTradeNo] = &payment.Transaction{
+			OutTradeNo: req.OutTradeNo,
+			Status:     payment.StatusNotPay,
+			Amount:     req.Amount,
+		}
+	}
+	return payment.PrepayResponse{
+		CodeURL: "fakepay://pay?out_trade_no=" + url.QueryEscape(req.OutTradeNo),
+	}, nil
+}
+
+// Pay 模拟用户付钱，然后把签过名的回调发到 notifyURL
+func (g *Gateway) Pay(ctx context.Context, outTradeNo string) error {
+	req, err := g.NewNotification(ctx, outTradeNo)
+	if err != nil {
+		return err
+	}
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("回调失败，状态码 %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// NewNotification 把订单改成已支付，返回签过名的回调请求，测试的时候可以直接交给 handler
+func (g *Gateway) NewNotification(ctx context.Context, outTradeNo string) (*http.Request, error) {
+	g.lock.Lock()
+	txn, ok := g.orders[outTradeNo]
+	if !ok {
+		g.lock.Unlock()
+		return nil, payment.ErrOrderNotFound
+	}
+	if txn.Status == payment.StatusNotPay {
+		g.seq++
+		txn.Status = payment.StatusSuccess
+		txn.TransactionId = "fake_" + strconv.FormatInt(g.seq, 10)
+	}
+	body, err := json.Marshal(txn)
+	g.lock.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, g.notifyURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(signatureHeader, g.sign(body))
+	return req, nil
+}
+
+func (g *Gateway) ParseNotification(req *http.Request) (payment.Transaction, error) {
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return payment.Transaction{}, err
+	}
+	sig, err := hex.DecodeString(req.Header.Get(signatureHeader))
+	if err != nil {
+		return payment.Transaction{}, payment.ErrInvalidSignature
+	}
+	expected, _ := hex.DecodeString(g.sign(body))
+	if !hmac.Equal(sig, expected) {
+		return payment.Transaction{}, payment.ErrInvalidSignature
+	}
+	var txn payment.Transaction
+	err = json.Unmarshal(body, &txn)
+	return txn, err
+}
+
+func (g *Gateway) Query(ctx context.Context, outTradeNo string) (payment.Transaction, error) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	txn, ok := g.orders[outTradeNo]
+	if !ok {
+		return payment.Transaction{}, payment.ErrOrderNotFound
+	}
+	return *txn, nil
+}
+
+func (g *Gateway) Close(ctx context.Context, outTradeNo string) error {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	txn, ok := g.orders[outTradeNo]
+	if !ok {
+		return payment.ErrOrderNotFound
+	}
+	if txn.Status == payment.StatusNotPay {
+		txn.Status = payment.StatusClosed
+	}
+	return nil
+}
+
+func (g *Gateway) sign(body []byte) string {
+	mac := hmac.New(sha256.New, g.secret)
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// RegisterRoutes 本地没有真的支付，调 /pay 假装用户付了钱，
+// 网关会把签过名的回调发到 notifyURL。只能在本地用
+func (g *Gateway) RegisterRoutes(rg *gin.RouterGroup) {
+	rg.POST("/pay", func(ctx *gin.Context) {
+		type PayReq struct {
+			OutTradeNo string `json:"outTradeNo"`
+		}
+		var req PayReq
+		if err := ctx.Bind(&req); err != nil {
+			return
+		}
+		err := g.Pay(ctx, req.OutTradeNo)
+		if err != nil {
+			ctx.JSON(http.StatusOK, gin.H{"code": 5, "msg": err.Error()})
+			return
+		}
+		ctx.JSON(http.StatusOK, gin.H{"msg": "OK"})
+	})
+}
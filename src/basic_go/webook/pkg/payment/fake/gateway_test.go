@@ -0,0 +1,54 @@
+package fake
+
+import (
+	"basic_go/webook/pkg/payment"
+	"bytes"
+	"context"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGateway_Notification(t *testing.T) {
+	g := NewGateway([]byte("secret"), "http://localhost/notify")
+	ctx := context.Background()
+	_, err := g.Prepay(ctx, payment.PrepayRequest{OutTradeNo: "o1", Amount: 100})
+	require.NoError(t, err)
+
+	req, err := g.NewNotification(ctx, "o1")
+	require.NoError(t, err)
+	body, err := io.ReadAll(req.Body)
+	require.NoError(t, err)
+
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	txn, err := g.ParseNotification(req)
+	require.NoError(t, err)
+	assert.Equal(t, payment.StatusSuccess, txn.Status)
+	assert.Equal(t, int64(100), txn.Amount)
+
+	// 改了金额，签名就对不上了
+	req.Body = io.NopCloser(bytes.NewReader(bytes.Replace(body, []byte("100"), []byte("999"), 1)))
+	_, err = g.ParseNotification(req)
+	assert.Equal(t, payment.ErrInvalidSignature, err)
+
+	// 别的密钥签的也不行
+	other := NewGateway([]byte("other"), "http://localhost/notify")
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	_, err = other.ParseNotification(req)
+	assert.Equal(t, payment.ErrInvalidSignature, err)
+}
+
+func TestGateway_Close(t *testing.T) {
+	g := NewGateway([]byte("secret"), "http://localhost/notify")
+	ctx := context.Background()
+	_, err := g.Prepay(ctx, payment.PrepayRequest{OutTradeNo: "o1", Amount: 100})
+	require.NoError(t, err)
+	require.NoError(t, g.Close(ctx, "o1"))
+	txn, err := g.Query(ctx, "o1")
+	require.NoError(t, err)
+	assert.Equal(t, payment.StatusClosed, txn.Status)
+	_, err = g.Query(ctx, "o2")
+	assert.Equal(t, payment.ErrOrderNotFound, err)
+}
@@ -0,0 +1,54 @@
+// Package payment 第三方支付的抽象。业务代码只依赖 Gateway，
+// 本地和测试用 fake，接微信、支付宝的时候各自实现一个
+package payment
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+var (
+	// ErrInvalidSignature 回调的签名不对，可能是伪造的
+	ErrInvalidSignature = errors.New("支付回调签名错误")
+	ErrOrderNotFound    = errors.New("支付订单不存在")
+)
+
+// 第三方那边订单的状态
+const (
+	StatusNotPay  = "NOTPAY"
+	StatusSuccess = "SUCCESS"
+	StatusClosed  = "CLOSED"
+)
+
+type PrepayRequest struct {
+	// 我们自己的订单号，第三方回调和查询都用它
+	OutTradeNo  string
+	Description string
+	// 单位是分
+	Amount int64
+}
+
+type PrepayResponse struct {
+	// 用户扫码或者打开这个链接去付钱
+	CodeURL string
+}
+
+// Transaction 第三方告诉我们的订单状态
+type Transaction struct {
+	OutTradeNo string
+	// 第三方自己的流水号
+	TransactionId string
+	Status        string
+	Amount        int64
+}
+
+type Gateway interface {
+	Prepay(ctx context.Context, req PrepayRequest) (PrepayResponse, error)
+	// ParseNotification 校验回调的签名并解析。签名不对返回 ErrInvalidSignature
+	ParseNotification(req *http.Request) (Transaction, error)
+	// Query 主动查询订单状态，对账的时候用
+	Query(ctx context.Context, outTradeNo string) (Transaction, error)
+	// Close 关闭订单，关了之后用户就付不了钱了
+	Close(ctx context.Context, outTradeNo string) error
+}
@@ -0,0 +1,189 @@
+// Package redislock 基于 Redis 的分布式锁。
+// 锁的值是每次加锁随机生成的，解锁和续约都要先确认锁还是自己的
+package redislock
+
+import (
+	"context"
+	"crypto/rand"
+	_ "embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	ErrFailedToPreemptLock = errors.New("redislock: 抢锁失败")
+	// ErrLockNotHold 锁已经过期了，或者被别人抢走了
+	ErrLockNotHold = errors.New("redislock: 没有持有锁")
+)
+
+var (
+	//go:embed lua/lock.lua
+	luaLock string
+	//go:embed lua/unlock.lua
+	luaUnlock string
+	//go:embed lua/refresh.lua
+	luaRefresh string
+)
+
+type Client struct {
+	client redis.Cmdable
+}
+
+func NewClient(client redis.Cmdable) *Client {
+	return &Client{
+		client: client,
+	}
+}
+
+// TryLock 只试一次，被别人持有的时候返回 ErrFailedToPreemptLock
+func (c *Client) TryLock(ctx context.Context, key string, expiration time.Duration) (*Lock, error) {
+	val, err := newValue()
+	if err != nil {
+		return nil, err
+	}
+	ok, err := c.client.SetNX(ctx, key, val, expiration).Result()
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrFailedToPreemptLock
+	}
+	return newLock(c.client, key, val, expiration), nil
+}
+
+// Lock 抢锁，失败了按照 retry 重试，直到抢到、retry 不让重试或者 ctx 结束。
+// timeout 是每一次尝试的超时时间，超时了也会重试
+func (c *Client) Lock(ctx context.Context, key string, expiration time.Duration,
+	timeout time.Duration, retry RetryStrategy) (*Lock, error) {
+	val, err := newValue()
+	if err != nil {
+		return nil, err
+	}
+	for attempt := 1; ; attempt++ {
+		lctx, cancel := context.WithTimeout(ctx, timeout)
+		// 重试用的是同一个值，上一次其实加锁成功了的话这一次也能拿到
+		res, err := c.client.Eval(lctx, luaLock, []string{key}, val, expiration.Milliseconds()).Result()
+		cancel()
+		if err != nil && !errors.Is(err, context.DeadlineExceeded) {
+			return nil, err
+		}
+		if res == "OK" {
+			return newLock(c.client, key, val, expiration), nil
+		}
+		interval, ok := retry.Next(attempt)
+		if !ok {
+			if err != nil {
+				return nil, fmt.Errorf("%w: %v", ErrFailedToPreemptLock, err)
+			}
+			return nil, ErrFailedToPreemptLock
+		}
+		timer := time.NewTimer(interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// Lock 抢到的锁，不要复制
+type Lock struct {
+	client     redis.Cmdable
+	key        string
+	value      string
+	expiration time.Duration
+
+	unlockOnce sync.Once
+	unlocked   chan struct{}
+}
+
+func newLock(client redis.Cmdable, key string, value string, expiration time.Duration) *Lock {
+	return &Lock{
+		client:     client,
+		key:        key,
+		value:      value,
+		expiration: expiration,
+		unlocked:   make(chan struct{}),
+	}
+}
+
+func (l *Lock) Key() string {
+	return l.key
+}
+
+// Unlock 锁已经不是自己的了返回 ErrLockNotHold。
+// 不管成功失败，AutoRefresh 都会停下来
+func (l *Lock) Unlock(ctx context.Context) error {
+	l.unlockOnce.Do(func() {
+		close(l.unlocked)
+	})
+	res, err := l.client.Eval(ctx, luaUnlock, []string{l.key}, l.value).Int64()
+	if err != nil {
+		return err
+	}
+	if res != 1 {
+		return ErrLockNotHold
+	}
+	return nil
+}
+
+// Refresh 把过期时间重新设置成加锁时候的 expiration，锁已经不是自己的了返回 ErrLockNotHold
+func (l *Lock) Refresh(ctx context.Context) error {
+	res, err := l.client.Eval(ctx, luaRefresh, []string{l.key}, l.value, l.expiration.Milliseconds()).Int64()
+	if err != nil {
+		return err
+	}
+	if res != 1 {
+		return ErrLockNotHold
+	}
+	return nil
+}
+
+// AutoRefresh 在后台每隔 interval 续约一次，每次续约的超时时间是 timeout，Unlock 之后停止。
+// 锁丢了的时候，返回的 channel 会收到一个 ErrLockNotHold 然后关闭，业务应该马上停下来。
+// 偶尔续约失败会在下一次接着试，一直失败到锁过期了也算锁丢了，
+// 所以 interval 要比 expiration 小很多，至少能试两三次
+func (l *Lock) AutoRefresh(interval time.Duration, timeout time.Duration) <-chan error {
+	ch := make(chan error, 1)
+	go func() {
+		defer close(ch)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		lastRefresh := time.Now()
+		for {
+			select {
+			case <-l.unlocked:
+				return
+			case <-ticker.C:
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			err := l.Refresh(ctx)
+			cancel()
+			switch {
+			case err == nil:
+				lastRefresh = time.Now()
+			case err == ErrLockNotHold:
+				ch <- err
+				return
+			case time.Since(lastRefresh) >= l.expiration:
+				ch <- fmt.Errorf("%w: 续约一直失败，锁已经过期了: %v", ErrLockNotHold, err)
+				return
+			}
+		}
+	}()
+	return ch
+}
+
+func newValue() (string, error) {
+	var buf [16]byte
+	if _, err := rand.Read(buf[:]); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf[:]), nil
+}
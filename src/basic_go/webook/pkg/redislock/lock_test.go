@@ -0,0 +1,128 @@
+package redislock
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestClient(t *testing.T) (*Client, *miniredis.Miniredis) {
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() {
+		_ = rdb.Close()
+	})
+	return NewClient(rdb), mr
+}
+
+func TestClient_TryLock(t *testing.T) {
+	c, mr := newTestClient(t)
+	ctx := context.Background()
+	l, err := c.TryLock(ctx, "lock:a", time.Minute)
+	require.NoError(t, err)
+	_, err = c.TryLock(ctx, "lock:a", time.Minute)
+	assert.Equal(t, ErrFailedToPreemptLock, err)
+	assert.Equal(t, time.Minute, mr.TTL("lock:a"))
+
+	// 过期了别人就能抢到，原来的锁不能再解锁和续约
+	mr.FastForward(time.Minute)
+	l2, err := c.TryLock(ctx, "lock:a", time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, ErrLockNotHold, l.Refresh(ctx))
+	assert.Equal(t, ErrLockNotHold, l.Unlock(ctx))
+	assert.True(t, mr.Exists("lock:a"))
+
+	mr.FastForward(time.Second * 30)
+	require.NoError(t, l2.Refresh(ctx))
+	assert.Equal(t, time.Minute, mr.TTL("lock:a"))
+	require.NoError(t, l2.Unlock(ctx))
+	assert.False(t, mr.Exists("lock:a"))
+}
+
+func TestClient_Lock(t *testing.T) {
+	c, _ := newTestClient(t)
+	ctx := context.Background()
+	l, err := c.TryLock(ctx, "lock:a", time.Minute)
+	require.NoError(t, err)
+
+	// 重试几次都抢不到
+	_, err = c.Lock(ctx, "lock:a", time.Minute, time.Second,
+		FixedIntervalRetry{Interval: time.Millisecond, MaxRetries: 3})
+	assert.Equal(t, ErrFailedToPreemptLock, err)
+
+	// 重试的过程中别人释放了
+	go func() {
+		time.Sleep(time.Millisecond * 20)
+		_ = l.Unlock(ctx)
+	}()
+	l2, err := c.Lock(ctx, "lock:a", time.Minute, time.Second,
+		FixedIntervalRetry{Interval: time.Millisecond * 5, MaxRetries: 100})
+	require.NoError(t, err)
+	require.NoError(t, l2.Unlock(ctx))
+
+	// ctx 结束了就不等了
+	_, err = c.TryLock(ctx, "lock:a", time.Minute)
+	require.NoError(t, err)
+	tctx, cancel := context.WithTimeout(ctx, time.Millisecond*20)
+	defer cancel()
+	_, err = c.Lock(tctx, "lock:a", time.Minute, time.Second,
+		FixedIntervalRetry{Interval: time.Millisecond * 5, MaxRetries: 100})
+	assert.Equal(t, context.DeadlineExceeded, err)
+}
+
+func TestLock_AutoRefresh(t *testing.T) {
+	c, mr := newTestClient(t)
+	ctx := context.Background()
+	l, err := c.TryLock(ctx, "lock:a", time.Minute)
+	require.NoError(t, err)
+	ch := l.AutoRefresh(time.Millisecond*5, time.Second)
+	mr.FastForward(time.Second * 30)
+	assert.Eventually(t, func() bool {
+		return mr.TTL("lock:a") == time.Minute
+	}, time.Second, time.Millisecond*5)
+
+	// 锁被别人抢走了
+	mr.Set("lock:a", "other")
+	select {
+	case err, ok := <-ch:
+		assert.True(t, ok)
+		assert.True(t, errors.Is(err, ErrLockNotHold))
+	case <-time.After(time.Second):
+		t.Fatal("没有通知锁丢了")
+	}
+	_, ok := <-ch
+	assert.False(t, ok)
+
+	// 解锁之后停止续约，channel 直接关掉
+	l, err = c.TryLock(ctx, "lock:b", time.Minute)
+	require.NoError(t, err)
+	ch = l.AutoRefresh(time.Millisecond*5, time.Second)
+	require.NoError(t, l.Unlock(ctx))
+	select {
+	case err, ok := <-ch:
+		assert.False(t, ok)
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("解锁之后没有停止续约")
+	}
+}
+
+func TestExponentialBackoffRetry(t *testing.T) {
+	r := ExponentialBackoffRetry{Initial: time.Millisecond * 10, MaxInterval: time.Millisecond * 50, MaxRetries: 4}
+	var got []time.Duration
+	for attempt := 1; ; attempt++ {
+		interval, ok := r.Next(attempt)
+		if !ok {
+			break
+		}
+		got = append(got, interval)
+	}
+	assert.Equal(t, []time.Duration{time.Millisecond * 10, time.Millisecond * 20,
+		time.Millisecond * 40, time.Millisecond * 50}, got)
+}
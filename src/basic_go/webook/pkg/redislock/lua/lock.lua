@@ -0,0 +1,9 @@
+-- 没有人持有就加锁；已经是自己的了，说明上一次加锁其实成功了，只是超时没拿到响应，续一下过期时间
+local val = redis.call("GET", KEYS[1])
+if val == false then
+    return redis.call("SET", KEYS[1], ARGV[1], "PX", ARGV[2])
+elseif val == ARGV[1] then
+    redis.call("PEXPIRE", KEYS[1], ARGV[2])
+    return "OK"
+end
+return ""
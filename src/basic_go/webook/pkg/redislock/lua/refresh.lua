@@ -0,0 +1,5 @@
+-- 是自己的锁才续约
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+    return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0
@@ -0,0 +1,5 @@
+-- 是自己的锁才删，不然会把别人的锁删掉
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+    return redis.call("DEL", KEYS[1])
+end
+return 0
@@ -0,0 +1,47 @@
+package redislock
+
+import "time"
+
+// RetryStrategy 抢锁失败之后要不要重试，等多久再试。
+// attempt 是已经失败了几次，从 1 开始
+type RetryStrategy interface {
+	Next(attempt int) (time.Duration, bool)
+}
+
+// NoRetry 只试一次
+type NoRetry struct{}
+
+func (NoRetry) Next(attempt int) (time.Duration, bool) {
+	return 0, false
+}
+
+// FixedIntervalRetry 每次都等一样久，最多重试 MaxRetries 次
+type FixedIntervalRetry struct {
+	Interval   time.Duration
+	MaxRetries int
+}
+
+func (r FixedIntervalRetry) Next(attempt int) (time.Duration, bool) {
+	return r.Interval, attempt <= r.MaxRetries
+}
+
+// ExponentialBackoffRetry 每次等待的时间翻倍，不超过 MaxInterval，最多重试 MaxRetries 次
+type ExponentialBackoffRetry struct {
+	Initial     time.Duration
+	MaxInterval time.Duration
+	MaxRetries  int
+}
+
+func (r ExponentialBackoffRetry) Next(attempt int) (time.Duration, bool) {
+	if attempt > r.MaxRetries {
+		return 0, false
+	}
+	interval := r.Initial
+	for i := 1; i < attempt && interval < r.MaxInterval; i++ {
+		interval *= 2
+	}
+	if r.MaxInterval > 0 && interval > r.MaxInterval {
+		interval = r.MaxInterval
+	}
+	return interval, true
+}
@@ -0,0 +1,68 @@
+package search
+
+import (
+	"html"
+	"strings"
+)
+
+const (
+	highlightPre  = "<em>"
+	highlightPost = "</em>"
+	ellipsis      = "..."
+)
+
+// Highlight 把 text 里面命中 terms 的部分用 <em> 包起来，其余部分做 HTML 转义。
+// snippet > 0 的时候只截取第一个命中位置附近最多 snippet 个字
+func Highlight(text string, terms map[string]struct{}, snippet int) string {
+	rs := []rune(text)
+	marked := make([]bool, len(rs))
+	first := -1
+	for _, t := range Tokenize(text) {
+		if _, ok := terms[t.Term]; !ok {
+			continue
+		}
+		for k := t.Start; k < t.End; k++ {
+			marked[k] = true
+		}
+		if first < 0 || t.Start < first {
+			first = t.Start
+		}
+	}
+
+	start, end := 0, len(rs)
+	if snippet > 0 && len(rs) > snippet {
+		// 命中的词前面留一点上下文
+		if first > snippet/4 {
+			start = first - snippet/4
+		}
+		end = start + snippet
+		if end > len(rs) {
+			end = len(rs)
+			start = end - snippet
+		}
+	}
+
+	var sb strings.Builder
+	if start > 0 {
+		sb.WriteString(ellipsis)
+	}
+	for i := start; i < end; {
+		j := i
+		for j < end && marked[j] == marked[i] {
+			j++
+		}
+		seg := html.EscapeString(string(rs[i:j]))
+		if marked[i] {
+			sb.WriteString(highlightPre)
+			sb.WriteString(seg)
+			sb.WriteString(highlightPost)
+		} else {
+			sb.WriteString(seg)
+		}
+		i = j
+	}
+	if end < len(rs) {
+		sb.WriteString(ellipsis)
+	}
+	return sb.String()
+}
@@ -0,0 +1,187 @@
+package search
+
+import (
+	"context"
+	"math"
+	"sort"
+	"sync"
+)
+
+// BM25 的两个参数，用的是常见的默认值
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// MemoryIndex 进程内的倒排索引。
+// 重启之后就没了，所以启动的时候要从数据库全量加载一遍；
+// 多个实例之间也不共享，每个实例都要自己消费变更事件
+type MemoryIndex struct {
+	lock   sync.RWMutex
+	fields map[string]FieldConfig
+	// term => 文档 ID => 字段 => 出现次数
+	postings map[string]map[int64]map[string]int
+	docs     map[int64]*memoryDoc
+	// 每个字段所有文档的总长度，用来算平均长度
+	totalLen map[string]int
+}
+
+type memoryDoc struct {
+	fields map[string]string
+	// 每个字段有多少个词
+	lens  map[string]int
+	terms map[string]struct{}
+}
+
+// NewMemoryIndex fields 之外的字段不会被索引
+func NewMemoryIndex(fields map[string]FieldConfig) *MemoryIndex {
+	return &MemoryIndex{
+		fields:   fields,
+		postings: make(map[string]map[int64]map[string]int),
+		docs:     make(map[int64]*memoryDoc),
+		totalLen: make(map[string]int),
+	}
+}
+
+func (idx *MemoryIndex) Upsert(ctx context.Context, doc Document) error {
+	idx.lock.Lock()
+	defer idx.lock.Unlock()
+	idx.delete(doc.Id)
+	md := &memoryDoc{
+		fields: make(map[string]string, len(idx.fields)),
+		lens:   make(map[string]int, len(idx.fields)),
+		terms:  make(map[string]struct{}),
+	}
+	for field := range idx.fields {
+		text := doc.Fields[field]
+		md.fields[field] = text
+		tokens := Tokenize(text)
+		md.lens[field] = len(tokens)
+		idx.totalLen[field] += len(tokens)
+		for _, t := range tokens {
+			md.terms[t.Term] = struct{}{}
+			ps, ok := idx.postings[t.Term]
+			if !ok {
+				ps = make(map[int64]map[string]int)
+				idx.postings[t.Term] = ps
+			}
+			tf, ok := ps[doc.Id]
+			if !ok {
+				tf = make(map[string]int, 1)
+				ps[doc.Id] = tf
+			}
+			tf[field]++
+		}
+	}
+	idx.docs[doc.Id] = md
+	return nil
+}
+
+func (idx *MemoryIndex) Delete(ctx context.Context, id int64) error {
+	idx.lock.Lock()
+	defer idx.lock.Unlock()
+	idx.delete(id)
+	return nil
+}
+
+func (idx *MemoryIndex) delete(id int64) {
+	md, ok := idx.docs[id]
+	if !ok {
+		return
+	}
+	for term := range md.terms {
+		ps := idx.postings[term]
+		delete(ps, id)
+		if len(ps) == 0 {
+			delete(idx.postings, term)
+		}
+	}
+	for field, l := range md.lens {
+		idx.totalLen[field] -= l
+	}
+	delete(idx.docs, id)
+}
+
+func (idx *MemoryIndex) Search(ctx context.Context, keyword string, limit int) (Result, error) {
+	terms := make(map[string]struct{})
+	for _, t := range TokenizeQuery(keyword) {
+		terms[t.Term] = struct{}{}
+	}
+	if len(terms) == 0 {
+		return Result{}, nil
+	}
+
+	idx.lock.RLock()
+	defer idx.lock.RUnlock()
+
+	// 从文档最少的词开始求交集，候选集合会小很多
+	lists := make([]map[int64]map[string]int, 0, len(terms))
+	for term := range terms {
+		ps, ok := idx.postings[term]
+		if !ok {
+			return Result{}, nil
+		}
+		lists = append(lists, ps)
+	}
+	sort.Slice(lists, func(i, j int) bool {
+		return len(lists[i]) < len(lists[j])
+	})
+
+	n := float64(len(idx.docs))
+	hits := make([]Hit, 0, len(lists[0]))
+	for id := range lists[0] {
+		score := 0.0
+		matched := true
+		for _, ps := range lists {
+			tf, ok := ps[id]
+			if !ok {
+				matched = false
+				break
+			}
+			df := float64(len(ps))
+			idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+			score += idf * idx.fieldScore(id, tf)
+		}
+		if matched {
+			hits = append(hits, Hit{Id: id, Score: score})
+		}
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		// 分数一样的时候新的在前面
+		return hits[i].Id > hits[j].Id
+	})
+
+	res := Result{Total: len(hits)}
+	if limit > 0 && len(hits) > limit {
+		hits = hits[:limit]
+	}
+	for i := range hits {
+		md := idx.docs[hits[i].Id]
+		hits[i].Highlights = make(map[string]string, len(idx.fields))
+		for field, cfg := range idx.fields {
+			hits[i].Highlights[field] = Highlight(md.fields[field], terms, cfg.Snippet)
+		}
+	}
+	res.Hits = hits
+	return res, nil
+}
+
+// fieldScore 一个词在一个文档里面各个字段的 BM25 分数按权重加起来
+func (idx *MemoryIndex) fieldScore(id int64, tf map[string]int) float64 {
+	md := idx.docs[id]
+	n := float64(len(idx.docs))
+	score := 0.0
+	for field, cnt := range tf {
+		avg := float64(idx.totalLen[field]) / n
+		if avg == 0 {
+			continue
+		}
+		f := float64(cnt)
+		norm := bm25K1 * (1 - bm25B + bm25B*float64(md.lens[field])/avg)
+		score += idx.fields[field].Weight * f * (bm25K1 + 1) / (f + norm)
+	}
+	return score
+}
@@ -0,0 +1,116 @@
+package search
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenize(t *testing.T) {
+	testCases := []struct {
+		name  string
+		text  string
+		query bool
+		want  []string
+	}{
+		{
+			name: "英文转小写",
+			text: "Hello, Go-Lang 123",
+			want: []string{"hello", "go", "lang", "123"},
+		},
+		{
+			name: "中文单字加二元",
+			text: "全文检索",
+			want: []string{"全", "文", "检", "索", "全文", "文检", "检索"},
+		},
+		{
+			name:  "搜索词只用二元",
+			text:  "全文检索",
+			query: true,
+			want:  []string{"全文", "文检", "检索"},
+		},
+		{
+			name:  "搜索词只有一个汉字",
+			text:  "猫",
+			query: true,
+			want:  []string{"猫"},
+		},
+		{
+			name:  "中英混合",
+			text:  "学习Go语言",
+			query: true,
+			want:  []string{"学习", "go", "语言"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var tokens []Token
+			if tc.query {
+				tokens = TokenizeQuery(tc.text)
+			} else {
+				tokens = Tokenize(tc.text)
+			}
+			terms := make([]string, 0, len(tokens))
+			for _, tk := range tokens {
+				terms = append(terms, tk.Term)
+			}
+			assert.Equal(t, tc.want, terms)
+		})
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	terms := map[string]struct{}{"检索": {}, "go": {}}
+	assert.Equal(t, "用 <em>Go</em> 写全文<em>检索</em>", Highlight("用 Go 写全文检索", terms, 0))
+	assert.Equal(t, "&lt;b&gt;<em>go</em>", Highlight("<b>go", terms, 0))
+	assert.Equal(t, "...三<em>检索</em>四五六七...",
+		Highlight("零一二三检索四五六七八九", terms, 7))
+}
+
+func TestMemoryIndex_Search(t *testing.T) {
+	idx := NewMemoryIndex(map[string]FieldConfig{
+		"title":   {Weight: 2},
+		"content": {Weight: 1, Snippet: 20},
+	})
+	ctx := context.Background()
+	docs := []Document{
+		{Id: 1, Fields: map[string]string{"title": "Go 语言入门", "content": "介绍一下 Go 语言的基本语法"}},
+		{Id: 2, Fields: map[string]string{"title": "数据库索引", "content": "顺便也讲一下 Go 语言怎么用索引"}},
+		{Id: 3, Fields: map[string]string{"title": "Java 入门", "content": "和语言无关的内容"}},
+	}
+	for _, doc := range docs {
+		require.NoError(t, idx.Upsert(ctx, doc))
+	}
+
+	res, err := idx.Search(ctx, "go 语言", 10)
+	require.NoError(t, err)
+	assert.Equal(t, 2, res.Total)
+	require.Len(t, res.Hits, 2)
+	// 标题命中的排在前面
+	assert.Equal(t, int64(1), res.Hits[0].Id)
+	assert.Equal(t, "<em>Go</em> <em>语言</em>入门", res.Hits[0].Highlights["title"])
+
+	// 分页只影响返回多少条，不影响总数
+	res, err = idx.Search(ctx, "go 语言", 1)
+	require.NoError(t, err)
+	assert.Equal(t, 2, res.Total)
+	assert.Len(t, res.Hits, 1)
+
+	// 修改之后旧的内容搜不到了
+	require.NoError(t, idx.Upsert(ctx, Document{Id: 1, Fields: map[string]string{"title": "Rust 入门"}}))
+	res, err = idx.Search(ctx, "go 语言", 10)
+	require.NoError(t, err)
+	assert.Equal(t, 1, res.Total)
+	assert.Equal(t, int64(2), res.Hits[0].Id)
+
+	require.NoError(t, idx.Delete(ctx, 2))
+	res, err = idx.Search(ctx, "go 语言", 10)
+	require.NoError(t, err)
+	assert.Equal(t, 0, res.Total)
+
+	res, err = idx.Search(ctx, "入门", 10)
+	require.NoError(t, err)
+	assert.Equal(t, 2, res.Total)
+}
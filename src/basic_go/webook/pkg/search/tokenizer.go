@@ -0,0 +1,75 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Token 一个词，Start 和 End 是在原文里面的下标，按 rune 算，左闭右开
+type Token struct {
+	Term  string
+	Start int
+	End   int
+}
+
+// Tokenize 建索引用的分词。
+// 英文和数字按照连续的字母数字切，统一转成小写；
+// 中文没有空格，用二元切分，"全文检索" 切成 "全文" "文检" "检索"，
+// 另外每个汉字也单独作为一个词，这样只搜一个字也能搜到。
+func Tokenize(text string) []Token {
+	return tokenize(text, true)
+}
+
+// TokenizeQuery 搜索词的分词。
+// 连续两个以上的汉字只用二元切分，单独一个汉字才用单字，这样结果更准
+func TokenizeQuery(text string) []Token {
+	return tokenize(text, false)
+}
+
+func tokenize(text string, withUnigram bool) []Token {
+	rs := []rune(text)
+	var tokens []Token
+	for i := 0; i < len(rs); {
+		r := rs[i]
+		switch {
+		case isHan(r):
+			j := i
+			for j < len(rs) && isHan(rs[j]) {
+				j++
+			}
+			tokens = appendHan(tokens, rs, i, j, withUnigram)
+			i = j
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			j := i
+			for j < len(rs) && !isHan(rs[j]) && (unicode.IsLetter(rs[j]) || unicode.IsDigit(rs[j])) {
+				j++
+			}
+			tokens = append(tokens, Token{
+				Term:  strings.ToLower(string(rs[i:j])),
+				Start: i,
+				End:   j,
+			})
+			i = j
+		default:
+			i++
+		}
+	}
+	return tokens
+}
+
+// appendHan 处理 rs[start:end] 这一段连续的汉字
+func appendHan(tokens []Token, rs []rune, start, end int, withUnigram bool) []Token {
+	if end-start == 1 || withUnigram {
+		for k := start; k < end; k++ {
+			tokens = append(tokens, Token{Term: string(rs[k]), Start: k, End: k + 1})
+		}
+	}
+	for k := start; k+1 < end; k++ {
+		tokens = append(tokens, Token{Term: string(rs[k : k+2]), Start: k, End: k + 2})
+	}
+	return tokens
+}
+
+func isHan(r rune) bool {
+	return unicode.Is(unicode.Han, r)
+}
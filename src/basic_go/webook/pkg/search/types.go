@@ -0,0 +1,44 @@
+// Package search 全文检索的抽象。业务代码只依赖 Index 接口，
+// 现在只有进程内的倒排索引实现，以后数据量大了可以换成 ES
+package search
+
+import "context"
+
+// Document 一个可以被搜索的文档，Fields 的 key 是字段名
+type Document struct {
+	Id     int64
+	Fields map[string]string
+}
+
+// Hit 一条搜索结果
+type Hit struct {
+	Id int64
+	// 相关度，只在同一次搜索里面可以比较
+	Score float64
+	// 高亮之后的字段，已经做了 HTML 转义，命中的词用 <em> 包起来
+	Highlights map[string]string
+}
+
+type Result struct {
+	// 一共命中了多少个文档
+	Total int
+	// 按照相关度从高到低排好序的，最多 limit 条
+	Hits []Hit
+}
+
+type Index interface {
+	// Upsert 已经有了就整个替换掉
+	Upsert(ctx context.Context, doc Document) error
+	// Delete 不存在也不会报错
+	Delete(ctx context.Context, id int64) error
+	// Search 多个关键词之间是"与"的关系
+	Search(ctx context.Context, keyword string, limit int) (Result, error)
+}
+
+// FieldConfig 字段怎么参与打分和高亮
+type FieldConfig struct {
+	// 打分的时候这个字段的权重，标题一般比内容高
+	Weight float64
+	// 高亮片段最多多少个字，0 代表返回整个字段
+	Snippet int
+}
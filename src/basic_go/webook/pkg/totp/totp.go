@@ -0,0 +1,125 @@
+// Package totp RFC 6238 的实现，兼容 Google Authenticator 之类的 App
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var ErrInvalidSecret = errors.New("totp: 非法的密钥")
+
+var b32 = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerateSecret 生成一个 160 位的随机密钥，base32 编码
+func GenerateSecret() (string, error) {
+	buf := make([]byte, 20)
+	_, err := rand.Read(buf)
+	if err != nil {
+		return "", err
+	}
+	return b32.EncodeToString(buf), nil
+}
+
+type TOTP struct {
+	// 多久换一个验证码
+	Period time.Duration
+	// 验证码的位数
+	Digits int
+	// 允许前后偏差多少个周期，用来容忍客户端和服务端的时钟误差
+	Skew int64
+	now  func() time.Time
+}
+
+// New now 传 nil 就是用 time.Now，测试的时候可以传入自己控制的时钟
+func New(now func() time.Time) *TOTP {
+	if now == nil {
+		now = time.Now
+	}
+	return &TOTP{
+		Period: time.Second * 30,
+		Digits: 6,
+		Skew:   1,
+		now:    now,
+	}
+}
+
+// URI 生成 otpauth:// 链接，前端把它做成二维码给 App 扫
+func (t *TOTP) URI(issuer string, account string, secret string) string {
+	label := url.PathEscape(issuer + ":" + account)
+	q := url.Values{}
+	q.Set("secret", secret)
+	q.Set("issuer", issuer)
+	q.Set("algorithm", "SHA1")
+	q.Set("digits", fmt.Sprint(t.Digits))
+	q.Set("period", fmt.Sprint(int64(t.Period/time.Second)))
+	return "otpauth://totp/" + label + "?" + q.Encode()
+}
+
+// Counter 时间 at 对应的计数器
+func (t *TOTP) Counter(at time.Time) int64 {
+	return at.Unix() / int64(t.Period/time.Second)
+}
+
+// Code 时间 at 对应的验证码
+func (t *TOTP) Code(secret string, at time.Time) (string, error) {
+	key, err := decodeSecret(secret)
+	if err != nil {
+		return "", err
+	}
+	return t.hotp(key, t.Counter(at)), nil
+}
+
+// Validate 校验验证码，成功的时候返回验证码对应的计数器。
+// 调用者要记住用过的计数器，拒绝小于等于它的，这样同一个验证码不能用两次
+func (t *TOTP) Validate(secret string, code string) (int64, bool, error) {
+	key, err := decodeSecret(secret)
+	if err != nil {
+		return 0, false, err
+	}
+	if len(code) != t.Digits {
+		return 0, false, nil
+	}
+	cur := t.Counter(t.now())
+	for i := -t.Skew; i <= t.Skew; i++ {
+		expected := t.hotp(key, cur+i)
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return cur + i, true, nil
+		}
+	}
+	return 0, false, nil
+}
+
+// hotp RFC 4226
+func (t *TOTP) hotp(key []byte, counter int64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(counter))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	val := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	mod := uint32(1)
+	for i := 0; i < t.Digits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", t.Digits, val%mod)
+}
+
+func decodeSecret(secret string) ([]byte, error) {
+	secret = strings.ToUpper(strings.ReplaceAll(secret, " ", ""))
+	secret = strings.TrimRight(secret, "=")
+	key, err := b32.DecodeString(secret)
+	if err != nil || len(key) == 0 {
+		return nil, ErrInvalidSecret
+	}
+	return key, nil
+}
@@ -0,0 +1,74 @@
+package totp
+
+import (
+	"encoding/base32"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// RFC 6238 附录 B 的测试向量，SHA1，8 位
+func TestTOTP_Code_RFC6238(t *testing.T) {
+	secret := base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+	tp := New(nil)
+	tp.Digits = 8
+	testCases := []struct {
+		unix int64
+		want string
+	}{
+		{unix: 59, want: "94287082"},
+		{unix: 1111111109, want: "07081804"},
+		{unix: 1111111111, want: "14050471"},
+		{unix: 1234567890, want: "89005924"},
+		{unix: 2000000000, want: "69279037"},
+		{unix: 20000000000, want: "65353130"},
+	}
+	for _, tc := range testCases {
+		code, err := tp.Code(secret, time.Unix(tc.unix, 0))
+		require.NoError(t, err)
+		assert.Equal(t, tc.want, code)
+	}
+}
+
+func TestTOTP_Validate(t *testing.T) {
+	secret, err := GenerateSecret()
+	require.NoError(t, err)
+	now := time.Unix(1700000000, 0)
+	tp := New(func() time.Time { return now })
+
+	code, err := tp.Code(secret, now)
+	require.NoError(t, err)
+	counter, ok, err := tp.Validate(secret, code)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, tp.Counter(now), counter)
+
+	// 客户端慢了一个周期，还能过
+	old, err := tp.Code(secret, now.Add(-time.Second*30))
+	require.NoError(t, err)
+	_, ok, err = tp.Validate(secret, old)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	// 慢了两个周期就不行了
+	older, err := tp.Code(secret, now.Add(-time.Minute))
+	require.NoError(t, err)
+	_, ok, err = tp.Validate(secret, older)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	_, ok, err = tp.Validate(secret, "12345")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	_, _, err = tp.Validate("!!!", code)
+	assert.Equal(t, ErrInvalidSecret, err)
+}
+
+func TestTOTP_URI(t *testing.T) {
+	tp := New(nil)
+	uri := tp.URI("webook", "tom@qq.com", "JBSWY3DPEHPK3PXP")
+	assert.Equal(t, "otpauth://totp/webook:tom@qq.com?algorithm=SHA1&digits=6&issuer=webook&period=30&secret=JBSWY3DPEHPK3PXP", uri)
+}
@@ -2,3 +2,8 @@
 CREATE DATABASE IF NOT EXISTS `webook`
     DEFAULT CHARACTER SET utf8mb4
     COLLATE utf8mb4_general_ci;
+
+# 迁移 users 表用的目标库
+CREATE DATABASE IF NOT EXISTS `webook_user`
+    DEFAULT CHARACTER SET utf8mb4
+    COLLATE utf8mb4_general_ci;